@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -11,7 +11,6 @@ import (
 	"github.com/plasmadev/codex-api-router/internal/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 // configCmd represents the config command
@@ -49,7 +48,10 @@ Examples:
   codex-router config init ./my-config.yaml
 
   # Overwrite existing config
-  codex-router config init --force`,
+  codex-router config init --force
+
+  # Add settings introduced by a newer version, keeping existing values
+  codex-router config init --merge`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Determine config path
@@ -66,8 +68,12 @@ Examples:
 
 		// Check if file exists
 		force, _ := cmd.Flags().GetBool("force")
+		merge, _ := cmd.Flags().GetBool("merge")
 		if _, err := os.Stat(configPath); err == nil && !force {
-			return fmt.Errorf("config file already exists at %s (use --force to overwrite)", configPath)
+			if merge {
+				return mergeConfigDefaults(configPath)
+			}
+			return fmt.Errorf("config file already exists at %s (use --force to overwrite or --merge to add missing settings)", configPath)
 		}
 
 		// Create directory if needed
@@ -121,31 +127,17 @@ The output shows the final resolved configuration that will be used.`,
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		// Get output format
+		// --format is a deprecated alias for the global --output flag
 		format := globalOpts.Output
-		if f, _ := cmd.Flags().GetString("format"); f != "" {
-			format = f
-		}
-
-		// Output configuration
-		var output []byte
-		var err2 error
-
-		switch format {
-		case "json":
-			output, err2 = json.MarshalIndent(cfg, "", "  ")
-		case "yaml":
-			output, err2 = yaml.Marshal(cfg)
-		default:
-			output, err2 = yaml.Marshal(cfg)
-		}
-
-		if err2 != nil {
-			return fmt.Errorf("failed to format config: %w", err2)
+		if cmd.Flags().Changed("format") {
+			format, _ = cmd.Flags().GetString("format")
+			if err := validateOutputFormat(format); err != nil {
+				return err
+			}
 		}
 
-		fmt.Println(string(output))
-		return nil
+		// The configuration has no text form, so text output is YAML
+		return printOutput(cfg, format)
 	},
 }
 
@@ -172,7 +164,7 @@ Returns exit code 0 if valid, 1 if invalid.`,
 
 		cfg, err := loadConfigFromPath(configPath)
 		if err != nil {
-			fmt.Printf("✗ Configuration invalid: %v\n", err)
+			printValidationErrors(err)
 			return fmt.Errorf("validation failed")
 		}
 
@@ -265,6 +257,7 @@ Keys use dot notation for nested values.
 Examples:
   codex-router config set server.port 9090
   codex-router config set zai.api_key sk-xxx
+  codex-router config set zai.plan standard
   codex-router config set logging.level debug`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -343,10 +336,13 @@ func init() {
 
 	// Init flags
 	configInitCmd.Flags().Bool("force", false, "overwrite existing config file")
+	configInitCmd.Flags().Bool("merge", false, "add missing settings to an existing config file, keeping existing values")
 	configInitCmd.Flags().BoolP("interactive", "i", false, "interactive configuration")
+	configInitCmd.MarkFlagsMutuallyExclusive("force", "merge")
 
 	// Show flags
-	configShowCmd.Flags().StringP("format", "f", "yaml", "output format (yaml, json)")
+	configShowCmd.Flags().StringP("format", "f", "", "output format (yaml, json)")
+	configShowCmd.Flags().MarkDeprecated("format", "use the global --output flag instead")
 	
 	// Validate flags
 	configValidateCmd.Flags().Bool("strict", false, "enable strict security validation")
@@ -354,6 +350,34 @@ func init() {
 
 // Helper functions
 
+// mergeConfigDefaults fills in settings missing from the config file at path
+// with defaults and prints what was added
+func mergeConfigDefaults(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	merged, added, err := config.MergeDefaults(data)
+	if err != nil {
+		return err
+	}
+	if len(added) == 0 {
+		fmt.Printf("✓ Configuration file at %s is up to date\n", path)
+		return nil
+	}
+
+	if err := os.WriteFile(path, merged, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Printf("✓ Added %d missing settings to %s:\n", len(added), path)
+	for _, line := range added {
+		fmt.Printf("  + %s\n", line)
+	}
+	return nil
+}
+
 func loadConfigFromPath(path string) (*config.Config, error) {
 	if path != "" {
 		viper.SetConfigFile(path)
@@ -400,6 +424,25 @@ func interactiveConfig(cfg *config.Config) error {
 	return nil
 }
 
+// printValidationErrors lists each configuration problem with the setting it
+// concerns, or the error itself when loading failed before validation
+func printValidationErrors(err error) {
+	var verrs config.ValidationErrors
+	if !errors.As(err, &verrs) {
+		fmt.Printf("✗ Configuration invalid: %v\n", err)
+		return
+	}
+
+	if len(verrs) == 1 {
+		fmt.Println("✗ Configuration invalid (1 problem):")
+	} else {
+		fmt.Printf("✗ Configuration invalid (%d problems):\n", len(verrs))
+	}
+	for _, fe := range verrs {
+		fmt.Printf("  - %s: %v\n", fe.Path, fe.Err)
+	}
+}
+
 func validateSecurity(cfg *config.Config) error {
 	// Security checks
 	if cfg.Server.Host == "0.0.0.0" {
@@ -428,6 +471,13 @@ func setConfigValue(cfg *config.Config, key, value string) error {
 		cfg.Zai.APIKey = value
 	case "zai.base_url":
 		cfg.Zai.BaseURL = value
+	case "zai.plan":
+		baseURL, err := config.ZaiBaseURL(value)
+		if err != nil {
+			return err
+		}
+		cfg.Zai.Plan = value
+		cfg.Zai.BaseURL = baseURL
 	case "logging.level":
 		cfg.Logging.Level = value
 	default:
@@ -450,6 +500,8 @@ func getConfigValue(cfg *config.Config, key string) (string, error) {
 		return "", nil
 	case "zai.base_url":
 		return cfg.Zai.BaseURL, nil
+	case "zai.plan":
+		return cfg.Zai.Plan, nil
 	case "logging.level":
 		return cfg.Logging.Level, nil
 	default:
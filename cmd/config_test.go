@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestPrintValidationErrors(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Port = 0
+	cfg.Server.ErrorFormat = "xml"
+
+	out := captureStdout(t, func() error {
+		printValidationErrors(cfg.Validate())
+		return nil
+	})
+
+	for _, want := range []string{"problems):", "  - server.port: invalid server port: 0", "  - providers: ", "  - server.error_format: "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	out = captureStdout(t, func() error {
+		printValidationErrors(errors.New("failed to read config file"))
+		return nil
+	})
+	if !strings.Contains(out, "✗ Configuration invalid: failed to read config file") {
+		t.Errorf("load error output = %q", out)
+	}
+}
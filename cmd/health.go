@@ -134,20 +134,20 @@ func checkHealth(url string) error {
 		return nil
 	}
 
-	// Format output
-	if globalOpts.Output == "json" {
-		fmt.Println(string(body))
-	} else {
-		fmt.Println("✓ Router is healthy")
-		if status, ok := health["status"]; ok {
-			fmt.Printf("  Status: %v\n", status)
-		}
-		if version, ok := health["version"]; ok {
-			fmt.Printf("  Version: %v\n", version)
-		}
-	}
+	return printOutput(healthInfo(health), globalOpts.Output)
+}
 
-	return nil
+// healthInfo is the /health response body
+type healthInfo map[string]interface{}
+
+func (health healthInfo) printText() {
+	fmt.Println("✓ Router is healthy")
+	if status, ok := health["status"]; ok {
+		fmt.Printf("  Status: %v\n", status)
+	}
+	if version, ok := health["version"]; ok {
+		fmt.Printf("  Version: %v\n", version)
+	}
 }
 
 func checkStatus(url string) error {
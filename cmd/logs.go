@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// logsCmd streams server logs from the admin endpoint
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show recent logs of a running router",
+	Long: `Show recent log lines from a running codex-router instance.
+
+Reads GET /admin/logs, which must be enabled with admin.enabled on the
+server. The admin token is taken from --token or CODEX_ROUTER_ADMIN_TOKEN.
+
+Examples:
+  # Show recent logs of the local router
+  codex-router logs
+
+  # Keep streaming new lines from a remote router
+  codex-router logs --url http://router.example.com:8080 --follow`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url, _ := cmd.Flags().GetString("url")
+		if url == "" {
+			url = "http://localhost:8080"
+		}
+
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("CODEX_ROUTER_ADMIN_TOKEN")
+		}
+
+		follow, _ := cmd.Flags().GetBool("follow")
+		return streamLogs(url, token, follow)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().String("url", "", "router URL (default: http://localhost:8080)")
+	logsCmd.Flags().String("token", "", "admin token (default: $CODEX_ROUTER_ADMIN_TOKEN)")
+	logsCmd.Flags().BoolP("follow", "f", false, "keep streaming new log lines")
+}
+
+func streamLogs(url, token string, follow bool) error {
+	endpoint := strings.TrimSuffix(url, "/") + "/admin/logs"
+	if follow {
+		endpoint += "?follow=true"
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	// No client timeout: a followed stream stays open until interrupted
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("router not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("unauthorized: check the admin token")
+	case http.StatusNotFound:
+		return fmt.Errorf("log endpoint not found: is admin.enabled set on the router?")
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			fmt.Println(data)
+		}
+	}
+
+	return scanner.Err()
+}
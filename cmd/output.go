@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// textPrinter is implemented by command results with a human-readable form
+type textPrinter interface {
+	printText()
+}
+
+// validateOutputFormat checks the global --output flag
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format: %s (must be '%s', '%s', or '%s')", format, outputText, outputJSON, outputYAML)
+	}
+}
+
+// printOutput prints a command result in the given format. In text format
+// results implementing textPrinter print themselves; anything else is shown
+// as YAML.
+func printOutput(v interface{}, format string) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputText:
+		if p, ok := v.(textPrinter); ok {
+			p.printText()
+			return nil
+		}
+		fallthrough
+	case outputYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return enc.Encode(v)
+	default:
+		return validateOutputFormat(format)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// captureStdout returns what fn writes to os.Stdout
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	if err := fn(); err != nil {
+		t.Errorf("print error = %v", err)
+	}
+	w.Close()
+	return <-done
+}
+
+func TestPrintOutput(t *testing.T) {
+	version := VersionInfo{Version: "1.2.3", Commit: "abc123", Platform: "linux/amd64"}
+	providers := providerList{
+		{Name: "openai", Type: "openai", Status: "disabled", Priority: 2},
+		{Name: "zai", Type: "zai", Status: "enabled", Priority: 1, APIKey: "***wxyz"},
+	}
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		format   string
+		contains []string
+		decode   func(string) error
+	}{
+		{"version text", version, outputText, []string{"codex-router 1.2.3", "Commit:     abc123"}, nil},
+		{"version json", version, outputJSON, []string{`"version": "1.2.3"`}, func(s string) error {
+			var v VersionInfo
+			return json.Unmarshal([]byte(s), &v)
+		}},
+		{"version yaml", version, outputYAML, []string{"version: 1.2.3", "commit: abc123"}, func(s string) error {
+			var v VersionInfo
+			return yaml.Unmarshal([]byte(s), &v)
+		}},
+		{"providers text", providers, outputText, []string{"zai (zai)", "openai (openai)"}, nil},
+		{"providers json", providers, outputJSON, []string{`"name": "zai"`, `"api_key": "***wxyz"`}, func(s string) error {
+			var v []providerInfo
+			return json.Unmarshal([]byte(s), &v)
+		}},
+		{"providers yaml", providers, outputYAML, []string{"- name: openai", "status: enabled"}, func(s string) error {
+			var v []providerInfo
+			return yaml.Unmarshal([]byte(s), &v)
+		}},
+		{"no text form falls back to yaml", map[string]int{"port": 8080}, outputText, []string{"port: 8080"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureStdout(t, func() error { return printOutput(tt.value, tt.format) })
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+			if tt.decode != nil {
+				if err := tt.decode(out); err != nil {
+					t.Errorf("output does not parse as %s: %v\n%s", tt.format, err, out)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid format", func(t *testing.T) {
+		if err := printOutput(version, "xml"); err == nil {
+			t.Error("printOutput() with invalid format succeeded")
+		}
+	})
+}
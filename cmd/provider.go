@@ -2,7 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	
+	"sort"
+
 	"github.com/spf13/cobra"
 )
 
@@ -44,33 +45,65 @@ Shows:
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		fmt.Println("Configured Providers:")
-		fmt.Println("=====================")
-		
+		var list providerList
 		for name, provider := range cfg.Providers.GetProviders() {
 			status := "disabled"
 			if provider.Enabled {
 				status = "enabled"
 			}
-			
-			fmt.Printf("\n%s (%s):\n", name, provider.Type)
-			fmt.Printf("  Status: %s\n", status)
-			fmt.Printf("  Priority: %d\n", provider.Priority)
-			fmt.Printf("  Base URL: %s\n", provider.BaseURL)
-			
-			if len(provider.Models) > 0 {
-				fmt.Printf("  Models: %v\n", provider.Models)
+
+			info := providerInfo{
+				Name:     name,
+				Type:     provider.Type,
+				Status:   status,
+				Priority: provider.Priority,
+				BaseURL:  provider.BaseURL,
+				Models:   provider.Models,
 			}
-			
-			if provider.Enabled && provider.APIKey != "" {
-				fmt.Printf("  API Key: ***%s\n", provider.APIKey[len(provider.APIKey)-4:])
+			if provider.Enabled && len(provider.APIKey) >= 4 {
+				info.APIKey = "***" + provider.APIKey[len(provider.APIKey)-4:]
 			}
+			list = append(list, info)
 		}
-		
-		return nil
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+		return printOutput(list, globalOpts.Output)
 	},
 }
 
+// providerInfo describes a configured provider in provider list output
+type providerInfo struct {
+	Name     string   `json:"name" yaml:"name"`
+	Type     string   `json:"type" yaml:"type"`
+	Status   string   `json:"status" yaml:"status"`
+	Priority int      `json:"priority" yaml:"priority"`
+	BaseURL  string   `json:"base_url" yaml:"base_url"`
+	Models   []string `json:"models,omitempty" yaml:"models,omitempty"`
+	APIKey   string   `json:"api_key,omitempty" yaml:"api_key,omitempty"` // Masked
+}
+
+type providerList []providerInfo
+
+func (list providerList) printText() {
+	fmt.Println("Configured Providers:")
+	fmt.Println("=====================")
+
+	for _, p := range list {
+		fmt.Printf("\n%s (%s):\n", p.Name, p.Type)
+		fmt.Printf("  Status: %s\n", p.Status)
+		fmt.Printf("  Priority: %d\n", p.Priority)
+		fmt.Printf("  Base URL: %s\n", p.BaseURL)
+
+		if len(p.Models) > 0 {
+			fmt.Printf("  Models: %v\n", p.Models)
+		}
+
+		if p.APIKey != "" {
+			fmt.Printf("  API Key: %s\n", p.APIKey)
+		}
+	}
+}
+
 // providerHealthCmd checks provider health
 var providerHealthCmd = &cobra.Command{
 	Use:   "health [provider-name]",
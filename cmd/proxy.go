@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -155,9 +157,13 @@ var proxyCallCmd = &cobra.Command{
 This command sends an actual request to the router and displays the response.
 Useful for end-to-end testing.
 
+Streaming requests ("stream": true, or --stream) print the assembled text and
+usage along with the time to first token and total stream duration.
+
 Examples:
   codex-router proxy call request.json
-  echo '{"model":"gpt-4","input":"hello"}' | codex-router proxy call`,
+  echo '{"model":"gpt-4","input":"hello"}' | codex-router proxy call
+  echo '{"model":"gpt-4","input":"hello"}' | codex-router proxy call --stream`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get router URL
@@ -182,13 +188,45 @@ Examples:
 			return fmt.Errorf("failed to read input: %w", err)
 		}
 
+		// --stream turns streaming on in the request. Invalid JSON is sent
+		// as is, to see how the router rejects it.
+		var req map[string]interface{}
+		if forceStream, _ := cmd.Flags().GetBool("stream"); forceStream {
+			if err := json.Unmarshal(data, &req); err != nil {
+				return fmt.Errorf("invalid JSON: %w", err)
+			}
+			req["stream"] = true
+			data, _ = json.Marshal(req)
+		} else {
+			json.Unmarshal(data, &req)
+		}
+		streaming, _ := req["stream"].(bool)
+
 		// Make request
-		resp, err := http.Post(url+"/v1/responses", "application/json", strings.NewReader(string(data)))
+		httpReq, err := http.NewRequest(http.MethodPost, url+"/v1/responses", bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		if streaming {
+			httpReq.Header.Set("Accept", "text/event-stream")
+		}
+
+		start := time.Now()
+		resp, err := http.DefaultClient.Do(httpReq)
 		if err != nil {
 			return fmt.Errorf("request failed: %w", err)
 		}
 		defer resp.Body.Close()
 
+		if streaming && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+			report, err := readCallStream(resp.Body, resp.StatusCode, start)
+			if err != nil {
+				return err
+			}
+			return printOutput(report, globalOpts.Output)
+		}
+
 		// Read response
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
@@ -212,6 +250,7 @@ func init() {
 
 	// Call command flags
 	proxyCallCmd.Flags().String("url", "", "router URL (default: http://localhost:8080)")
+	proxyCallCmd.Flags().Bool("stream", false, "stream the response and report time to first token")
 }
 
 // Helper functions
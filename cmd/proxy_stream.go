@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// streamCallReport summarizes a streamed proxy call: the assembled text,
+// time to first token and total stream duration
+type streamCallReport struct {
+	Status         int                    `json:"status" yaml:"status"`
+	ResponseStatus string                 `json:"response_status,omitempty" yaml:"response_status,omitempty"`
+	TTFTMs         int64                  `json:"ttft_ms" yaml:"ttft_ms"` // -1 when no output arrived
+	DurationMs     int64                  `json:"duration_ms" yaml:"duration_ms"`
+	Events         int                    `json:"events" yaml:"events"`
+	Text           string                 `json:"text" yaml:"text"`
+	Usage          map[string]interface{} `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Error          string                 `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// readCallStream reads a Responses API event stream, timing from start. The
+// first output text or tool-call arguments delta counts as the first token.
+func readCallStream(body io.Reader, status int, start time.Time) (*streamCallReport, error) {
+	report := &streamCallReport{Status: status, TTFTMs: -1}
+	var text strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event struct {
+			Type     string `json:"type"`
+			Delta    string `json:"delta"`
+			Response struct {
+				Status string                 `json:"status"`
+				Usage  map[string]interface{} `json:"usage"`
+				Error  *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"response"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			continue
+		}
+		report.Events++
+
+		switch event.Type {
+		case "response.output_text.delta", "response.function_call_arguments.delta":
+			if report.TTFTMs < 0 {
+				report.TTFTMs = time.Since(start).Milliseconds()
+			}
+			if event.Type == "response.output_text.delta" {
+				text.WriteString(event.Delta)
+			}
+		case "response.completed", "response.incomplete", "response.failed":
+			report.ResponseStatus = event.Response.Status
+			report.Usage = event.Response.Usage
+			if event.Response.Error != nil {
+				report.Error = event.Response.Error.Message
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	report.DurationMs = time.Since(start).Milliseconds()
+	report.Text = text.String()
+	return report, nil
+}
+
+func (r *streamCallReport) printText() {
+	fmt.Printf("Status: %d\n", r.Status)
+	if r.ResponseStatus != "" {
+		fmt.Printf("Response status: %s\n", r.ResponseStatus)
+	}
+	if r.Error != "" {
+		fmt.Printf("Error: %s\n", r.Error)
+	}
+	fmt.Println("Output:")
+	fmt.Println(r.Text)
+	fmt.Println()
+
+	if r.TTFTMs >= 0 {
+		fmt.Printf("Time to first token: %dms\n", r.TTFTMs)
+	} else {
+		fmt.Println("Time to first token: no output received")
+	}
+	fmt.Printf("Total duration:      %dms (%d events)\n", r.DurationMs, r.Events)
+	if r.Usage != nil {
+		fmt.Printf("Usage: input %v, output %v, total %v tokens\n", r.Usage["input_tokens"], r.Usage["output_tokens"], r.Usage["total_tokens"])
+	}
+}
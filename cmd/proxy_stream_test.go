@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCallStream(t *testing.T) {
+	const firstTokenDelay = 30 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+
+		io.WriteString(w, "event: response.created\ndata: {\"type\":\"response.created\"}\n\n")
+		flusher.Flush()
+		time.Sleep(firstTokenDelay)
+
+		for _, delta := range []string{"Hello", ", ", "world"} {
+			io.WriteString(w, "event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\""+delta+"\"}\n\n")
+			flusher.Flush()
+		}
+		io.WriteString(w, "event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"status\":\"completed\",\"usage\":{\"input_tokens\":3,\"output_tokens\":3,\"total_tokens\":6}}}\n\n")
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	report, err := readCallStream(resp.Body, resp.StatusCode, start)
+	if err != nil {
+		t.Fatalf("readCallStream() error = %v", err)
+	}
+
+	if report.Text != "Hello, world" {
+		t.Errorf("text = %q, want %q", report.Text, "Hello, world")
+	}
+	if report.TTFTMs < firstTokenDelay.Milliseconds() {
+		t.Errorf("time to first token = %dms, want at least %dms", report.TTFTMs, firstTokenDelay.Milliseconds())
+	}
+	if report.DurationMs < report.TTFTMs {
+		t.Errorf("duration %dms is shorter than time to first token %dms", report.DurationMs, report.TTFTMs)
+	}
+	if report.Events != 5 || report.ResponseStatus != "completed" || report.Status != http.StatusOK {
+		t.Errorf("report = %+v, want 5 events ending completed with status 200", report)
+	}
+	if report.Usage["total_tokens"] != float64(6) {
+		t.Errorf("usage = %v", report.Usage)
+	}
+}
+
+func TestReadCallStreamWithoutOutput(t *testing.T) {
+	body := "data: {\"type\":\"response.created\"}\n\ndata: {\"type\":\"response.failed\",\"response\":{\"status\":\"failed\",\"error\":{\"message\":\"backend down\"}}}\n\n"
+
+	report, err := readCallStream(strings.NewReader(body), http.StatusOK, time.Now())
+	if err != nil {
+		t.Fatalf("readCallStream() error = %v", err)
+	}
+	if report.TTFTMs != -1 {
+		t.Errorf("time to first token = %d, want -1 without output", report.TTFTMs)
+	}
+	if report.ResponseStatus != "failed" || report.Error != "backend down" {
+		t.Errorf("report = %+v, want failed with the backend error", report)
+	}
+}
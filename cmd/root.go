@@ -59,6 +59,10 @@ Quick Start:
 For more information, see: https://github.com/plasmadev/codex-api-router`,
 	Version: Version,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(globalOpts.Output); err != nil {
+			return err
+		}
+
 		// Initialize configuration
 		return initConfig()
 	},
@@ -145,6 +149,14 @@ func GetConfig() (*config.Config, error) {
 		cfg.Providers.Zai.Enabled = true
 	}
 
+	// Derive z.ai base URLs from the configured plan
+	if err := cfg.ApplyZaiPlan(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Replace zero durations that would mean "no timeout"
+	cfg.NormalizeDurations()
+
 	// Validate
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/plasmadev/codex-api-router/internal/server/handlers"
+	"github.com/spf13/cobra"
+)
+
+// routeCmd represents the route command
+var routeCmd = &cobra.Command{
+	Use:   "route <model>",
+	Short: "Show how a model would be routed",
+	Long: `Show which provider the server would use for a model, and why.
+
+The configuration's model mapping and provider selection are applied exactly
+as the server does, without starting it or sending a request. The output
+names the backend model, whether providers.model_mapping changed it, and
+the models pattern that matched or that the provider is used as a fallback.
+
+Examples:
+  codex-router route gpt-4.1
+  codex-router route glm-5 --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := GetConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+
+		return printOutput(routeReport(handlers.RouteModel(cfg, args[0])), globalOpts.Output)
+	},
+}
+
+// routeReport is a routing decision printed by the route command
+type routeReport handlers.RouteDecision
+
+func (r routeReport) printText() {
+	fmt.Printf("Model:          %s\n", r.Model)
+	if r.Mapped {
+		fmt.Printf("Backend model:  %s (providers.model_mapping)\n", r.BackendModel)
+	} else {
+		fmt.Printf("Backend model:  %s (not mapped)\n", r.BackendModel)
+	}
+	fmt.Printf("Provider:       %s\n", r.Provider)
+	fmt.Printf("Reason:         %s\n", r.Reason)
+}
+
+func init() {
+	rootCmd.AddCommand(routeCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/plasmadev/codex-api-router/internal/server/handlers"
+	"github.com/spf13/cobra"
+)
+
+// selftestCmd represents the selftest command
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Run the transform pipeline self-test",
+	Long: `Run canned requests through the request and response transforms
+using the mock provider and check the output is valid Responses API.
+
+Fixtures cover string input, tool calls, image input and streaming. No
+request leaves the router. The same self-test runs at startup according to
+server.self_test (off, warn or strict).
+
+Examples:
+  codex-router selftest
+  codex-router selftest --output json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := GetConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+
+		report := selfTestReport(handlers.SelfTest(cfg, slog.New(slog.NewTextHandler(io.Discard, nil))))
+		if err := printOutput(report, globalOpts.Output); err != nil {
+			return err
+		}
+
+		if failed := report.failed(); failed > 0 {
+			return fmt.Errorf("self-test failed: %d fixture(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// selfTestReport lists the outcome of each self-test fixture
+type selfTestReport []handlers.SelfTestResult
+
+func (report selfTestReport) failed() int {
+	failed := 0
+	for _, result := range report {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (report selfTestReport) printText() {
+	for _, result := range report {
+		if result.Error == "" {
+			fmt.Printf("✓ %s\n", result.Name)
+		} else {
+			fmt.Printf("✗ %s: %s\n", result.Name, result.Error)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+}
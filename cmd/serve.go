@@ -76,6 +76,15 @@ The server supports:
 			cfg.Logging.Level = "debug"
 		}
 
+		// Validate the effective config (including flag overrides) and stop
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			if err := cfg.Validate(); err != nil {
+				return fmt.Errorf("invalid configuration: %w", err)
+			}
+			fmt.Println("✓ Configuration is valid")
+			return nil
+		}
+
 		// Bind flags to viper for persistence
 		viper.BindPFlag("server.port", cmd.Flags().Lookup("port"))
 		viper.BindPFlag("server.host", cmd.Flags().Lookup("host"))
@@ -86,6 +95,7 @@ The server supports:
 		printBanner(cfg)
 
 		// Create server
+		server.Version = Version
 		srv := server.New(cfg)
 
 		// Setup signal handling
@@ -174,10 +184,22 @@ func printBanner(cfg *config.Config) {
 	fmt.Println()
 	fmt.Println("  Endpoints:")
 	fmt.Printf("    Proxy:    POST http://%s:%d/v1/responses\n", cfg.Server.Host, cfg.Server.Port)
-	fmt.Printf("    Health:   GET  http://%s:%d/health\n", cfg.Server.Host, cfg.Server.Port)
+	adminAddr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+	if cfg.Server.AdminAddr != "" {
+		adminAddr = cfg.Server.AdminAddr
+	}
+	fmt.Printf("    Health:   GET  http://%s/health\n", adminAddr)
+
+	if cfg.Batch.Enabled {
+		fmt.Printf("    Batch:    POST http://%s:%d/v1/responses/batch\n", cfg.Server.Host, cfg.Server.Port)
+	}
 	
 	if cfg.Metrics.Enabled {
-		fmt.Printf("    Metrics:  GET  http://%s:%d%s\n", cfg.Server.Host, cfg.Server.Port, cfg.Metrics.Path)
+		metricsAddr := adminAddr
+		if cfg.Metrics.ListenAddr != "" {
+			metricsAddr = cfg.Metrics.ListenAddr
+		}
+		fmt.Printf("    Metrics:  GET  http://%s%s\n", metricsAddr, cfg.Metrics.Path)
 	}
 	
 	fmt.Println()
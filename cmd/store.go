@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// storeCmd groups commands acting on a running router's response store
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Manage stored responses and conversations of a running router",
+}
+
+// storeFlushCmd clears stored responses and conversations
+var storeFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Clear stored responses and conversations",
+	Long: `Clear the responses and conversations a running codex-router instance
+has stored, for example to honour a request to forget a user's data.
+
+Calls POST /admin/store/flush, which must be enabled with admin.enabled on
+the server. The admin token is taken from --token or CODEX_ROUTER_ADMIN_TOKEN.
+
+Examples:
+  # Clear everything stored by the local router
+  codex-router store flush
+
+  # Remove a single conversation, by the ID of the response that ended it
+  codex-router store flush --conversation resp_abc123`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url, _ := cmd.Flags().GetString("url")
+		if url == "" {
+			url = "http://localhost:8080"
+		}
+
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("CODEX_ROUTER_ADMIN_TOKEN")
+		}
+
+		conversation, _ := cmd.Flags().GetString("conversation")
+		return flushStore(url, token, conversation)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(storeCmd)
+	storeCmd.AddCommand(storeFlushCmd)
+
+	storeFlushCmd.Flags().String("url", "", "router URL (default: http://localhost:8080)")
+	storeFlushCmd.Flags().String("token", "", "admin token (default: $CODEX_ROUTER_ADMIN_TOKEN)")
+	storeFlushCmd.Flags().String("conversation", "", "only remove the conversation stored under this response ID")
+}
+
+func flushStore(routerURL, token, conversation string) error {
+	endpoint := strings.TrimSuffix(routerURL, "/") + "/admin/store/flush"
+	if conversation != "" {
+		endpoint += "?conversation_id=" + url.QueryEscape(conversation)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("router not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("unauthorized: check the admin token")
+	case http.StatusNotFound:
+		return fmt.Errorf("store endpoint not found: is admin.enabled set on the router?")
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Flushed struct {
+			Conversations int `json:"conversations"`
+			Responses     int `json:"responses"`
+		} `json:"flushed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	fmt.Printf("Removed %d conversation(s) and %d response(s)\n", result.Flushed.Conversations, result.Flushed.Responses)
+	return nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlushStore(t *testing.T) {
+	var gotPath, gotQuery, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotAuth = r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization")
+		switch r.Header.Get("Authorization") {
+		case "Bearer admin-token":
+			w.Write([]byte(`{"flushed":{"conversations":1,"responses":2}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	out := captureStdout(t, func() error { return flushStore(srv.URL+"/", "admin-token", "resp_1") })
+	if gotPath != "/admin/store/flush" || gotQuery != "conversation_id=resp_1" || gotAuth != "Bearer admin-token" {
+		t.Errorf("request = %s?%s with %q", gotPath, gotQuery, gotAuth)
+	}
+	if !strings.Contains(out, "Removed 1 conversation(s) and 2 response(s)") {
+		t.Errorf("output = %q", out)
+	}
+
+	err := flushStore(srv.URL, "wrong", "")
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("flushStore() with a wrong token error = %v, want unauthorized", err)
+	}
+}
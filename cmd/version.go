@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"runtime"
 
@@ -24,7 +23,7 @@ Shows:
 Examples:
   codex-router version
   codex-router version --output json`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		info := VersionInfo{
 			Version:   Version,
 			Commit:    Commit,
@@ -33,26 +32,25 @@ Examples:
 			Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
 		}
 
-		if globalOpts.Output == "json" {
-			data, _ := json.MarshalIndent(info, "", "  ")
-			fmt.Println(string(data))
-		} else {
-			fmt.Printf("codex-router %s\n", info.Version)
-			fmt.Printf("  Commit:     %s\n", info.Commit)
-			fmt.Printf("  Built:      %s\n", info.BuildDate)
-			fmt.Printf("  Go version: %s\n", info.GoVersion)
-			fmt.Printf("  Platform:   %s\n", info.Platform)
-		}
+		return printOutput(info, globalOpts.Output)
 	},
 }
 
 // VersionInfo holds version metadata
 type VersionInfo struct {
-	Version   string `json:"version"`
-	Commit    string `json:"commit"`
-	BuildDate string `json:"build_date"`
-	GoVersion string `json:"go_version"`
-	Platform  string `json:"platform"`
+	Version   string `json:"version" yaml:"version"`
+	Commit    string `json:"commit" yaml:"commit"`
+	BuildDate string `json:"build_date" yaml:"build_date"`
+	GoVersion string `json:"go_version" yaml:"go_version"`
+	Platform  string `json:"platform" yaml:"platform"`
+}
+
+func (info VersionInfo) printText() {
+	fmt.Printf("codex-router %s\n", info.Version)
+	fmt.Printf("  Commit:     %s\n", info.Commit)
+	fmt.Printf("  Built:      %s\n", info.BuildDate)
+	fmt.Printf("  Go version: %s\n", info.GoVersion)
+	fmt.Printf("  Platform:   %s\n", info.Platform)
 }
 
 func init() {
@@ -1,9 +1,14 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -39,6 +44,11 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
+	// Derive z.ai base URLs from the plan before they are copied anywhere
+	if err := cfg.ApplyZaiPlan(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Preserve default model_mapping if not set in config file
 	if cfg.Providers.ModelMapping == nil || len(cfg.Providers.ModelMapping) == 0 {
 		cfg.Providers.ModelMapping = defaultModelMapping
@@ -50,6 +60,7 @@ func Load(configPath string) (*Config, error) {
 		cfg.Providers = defaults
 		zaiProvider := defaults.Zai
 		zaiProvider.APIKey = cfg.Zai.APIKey
+		zaiProvider.Plan = cfg.Zai.Plan
 		zaiProvider.BaseURL = cfg.Zai.BaseURL
 		zaiProvider.Timeout = cfg.Zai.Timeout
 		zaiProvider.MaxRetries = cfg.Zai.MaxRetries
@@ -88,6 +99,9 @@ func Load(configPath string) (*Config, error) {
 		cfg.Providers.SetProvider("openai", openaiProvider)
 	}
 
+	// Replace zero durations that would mean "no timeout"
+	cfg.NormalizeDurations()
+
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -96,15 +110,19 @@ func Load(configPath string) (*Config, error) {
 	return cfg, nil
 }
 
-// Validate validates the configuration
+// Validate validates the configuration. It checks every setting and returns
+// all problems found as ValidationErrors.
 func (c *Config) Validate() error {
+	var errs ValidationErrors
+
 	if c.Server.Port <= 0 || c.Server.Port > 65535 {
-		return fmt.Errorf("invalid server port: %d", c.Server.Port)
+		errs.add("server.port", "invalid server port: %d", c.Server.Port)
 	}
 
 	// Check if at least one provider is configured
+	providers := c.Providers.GetProviders()
 	hasProvider := false
-	for _, provider := range c.Providers.GetProviders() {
+	for _, provider := range providers {
 		if provider.Enabled && provider.APIKey != "" {
 			hasProvider = true
 			break
@@ -116,15 +134,273 @@ func (c *Config) Validate() error {
 		hasProvider = true
 	}
 
+	// The mock provider needs no API key, and with passthrough auth clients
+	// bring their own
+	if c.Providers.Mock.Enabled || c.Providers.PassthroughAuth {
+		hasProvider = true
+	}
+
 	if !hasProvider {
-		return fmt.Errorf("at least one provider must be configured with an API key")
+		errs.add("providers", "at least one provider must be configured with an API key")
+	}
+
+	// Report per-provider problems in a stable order
+	providerNames := make([]string, 0, len(providers))
+	for name := range providers {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
+	if c.Server.ErrorFormat != "" && c.Server.ErrorFormat != ErrorFormatOpenAI && c.Server.ErrorFormat != ErrorFormatAnthropic {
+		errs.add("server.error_format", "invalid server error_format: %s (must be '%s' or '%s')", c.Server.ErrorFormat, ErrorFormatOpenAI, ErrorFormatAnthropic)
+	}
+
+	switch c.Server.SelfTest {
+	case "", SelfTestOff, SelfTestWarn, SelfTestStrict:
+	default:
+		errs.add("server.self_test", "invalid server self_test: %s (must be '%s', '%s' or '%s')", c.Server.SelfTest, SelfTestOff, SelfTestWarn, SelfTestStrict)
+	}
+
+	switch c.Server.ResponsesAPIVersion {
+	case "", ResponsesAPIV1, ResponsesAPIV2:
+	default:
+		errs.add("server.responses_api_version", "invalid server responses_api_version: %s (must be '%s' or '%s')", c.Server.ResponsesAPIVersion, ResponsesAPIV1, ResponsesAPIV2)
+	}
+
+	if c.Codex.ToolCallStatus != "" && c.Codex.ToolCallStatus != ToolCallStatusRequiresAction && c.Codex.ToolCallStatus != ToolCallStatusCompleted {
+		errs.add("codex.tool_call_status", "invalid codex tool_call_status: %s (must be '%s' or '%s')", c.Codex.ToolCallStatus, ToolCallStatusRequiresAction, ToolCallStatusCompleted)
+	}
+
+	if _, err := ParseInstructionsTemplate(c.Codex.InstructionsTemplate); err != nil {
+		errs.addErr("codex.instructions_template", err)
+	}
+	for _, name := range providerNames {
+		if _, err := ParseInstructionsTemplate(providers[name].InstructionsTemplate); err != nil {
+			errs.addErr("providers."+name+".instructions_template", fmt.Errorf("provider %s: %w", name, err))
+		}
+	}
+
+	if c.Session.Enabled {
+		switch c.Session.Store {
+		case "", "memory":
+		case "file":
+			if c.Session.Path == "" {
+				errs.add("session.path", "invalid session config: path is required when store is 'file'")
+			}
+		default:
+			errs.add("session.store", "invalid session store: %s (must be 'memory' or 'file')", c.Session.Store)
+		}
+	}
+
+	if c.Session.MaxResponseBytes < 0 {
+		errs.add("session.max_response_bytes", "invalid session config: max_response_bytes must not be negative")
+	}
+	switch c.Session.OversizedResponses {
+	case "", "skip", "truncate":
+	default:
+		errs.add("session.oversized_responses", "invalid session oversized_responses: %s (must be 'skip' or 'truncate')", c.Session.OversizedResponses)
+	}
+
+	for i, rule := range c.Providers.RoutingRules {
+		path := fmt.Sprintf("providers.routing_rules[%d]", i)
+		if len(rule.Metadata) == 0 {
+			errs.add(path+".metadata", "invalid routing rule %d: metadata is required", i+1)
+		}
+		switch rule.Provider {
+		case "zai", "openai", "anthropic", "azure":
+		default:
+			errs.add(path+".provider", "invalid routing rule %d: unknown provider %q (must be 'zai', 'openai', 'anthropic' or 'azure')", i+1, rule.Provider)
+		}
+	}
+
+	models := make([]string, 0, len(c.Providers.Pricing))
+	for model := range c.Providers.Pricing {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		if pricing := c.Providers.Pricing[model]; pricing.Input < 0 || pricing.Output < 0 {
+			errs.add("providers.pricing."+model, "invalid pricing for model %s: prices must not be negative", model)
+		}
+	}
+
+	if c.Providers.Fallback.Parallel < 0 {
+		errs.add("providers.fallback.parallel", "invalid fallback config: parallel must not be negative")
+	}
+	if c.Providers.HealthCheckConcurrency < 0 {
+		errs.add("providers.health_check_concurrency", "invalid providers config: health_check_concurrency must not be negative")
+	}
+
+	// Client auth and passthrough can't both claim the Authorization header
+	if c.Providers.PassthroughAuth && len(c.Codex.APIKeys) > 0 && (c.Codex.APIKeyHeader == "" || strings.EqualFold(c.Codex.APIKeyHeader, "Authorization")) {
+		errs.add("providers.passthrough_auth", "invalid passthrough_auth: the Authorization header is forwarded to the backend, so codex.api_key_header must name another header when codex.api_keys is set")
+	}
+
+	switch c.Providers.EnforceToolChoice {
+	case "", EnforceToolChoiceOff, EnforceToolChoiceRetry, EnforceToolChoiceError:
+	default:
+		errs.add("providers.enforce_tool_choice", "invalid enforce_tool_choice: %s (must be '%s', '%s' or '%s')", c.Providers.EnforceToolChoice, EnforceToolChoiceOff, EnforceToolChoiceRetry, EnforceToolChoiceError)
+	}
+
+	if fallback := c.Providers.FallbackResponse; fallback.Enabled {
+		if fallback.Message == "" {
+			errs.add("providers.fallback_response.message", "invalid fallback_response: message is required when enabled")
+		}
+		if fallback.Status < 200 || fallback.Status > 599 {
+			errs.add("providers.fallback_response.status", "invalid fallback_response: status %d is not an HTTP status", fallback.Status)
+		}
+	}
+
+	if c.Server.MaxConcurrentRequests < 0 {
+		errs.add("server.max_concurrent_requests", "invalid server config: max_concurrent_requests must not be negative")
+	}
+	if c.Server.MaxQueueDepth < 0 {
+		errs.add("server.max_queue_depth", "invalid server config: max_queue_depth must not be negative")
+	}
+
+	if c.Server.MaxConnectionsPerIP < 0 {
+		errs.add("server.max_connections_per_ip", "invalid server config: max_connections_per_ip must not be negative")
+	}
+
+	if c.Server.MaxStreamOutputBytes < 0 {
+		errs.add("server.max_stream_output_bytes", "invalid server config: max_stream_output_bytes must not be negative")
+	}
+
+	if (c.Metrics.Auth.Username == "") != (c.Metrics.Auth.Password == "") {
+		errs.add("metrics.auth", "invalid metrics auth: username and password must be set together")
+	}
+	if c.Server.AdminAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Server.AdminAddr); err != nil {
+			errs.add("server.admin_addr", "invalid server admin_addr: %v", err)
+		}
+	}
+	if c.Metrics.ListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.ListenAddr); err != nil {
+			errs.add("metrics.listen_addr", "invalid metrics listen_addr: %v", err)
+		}
+	}
+	if c.Metrics.Tenants.MaxTenants < 0 {
+		errs.add("metrics.tenants.max_tenants", "invalid metrics tenants: max_tenants must not be negative")
+	}
+
+	if c.Admin.Enabled {
+		if c.Admin.Token == "" {
+			errs.add("admin.token", "invalid admin config: token is required when admin is enabled")
+		}
+		if c.Admin.LogBufferSize <= 0 {
+			errs.add("admin.log_buffer_size", "invalid admin config: log_buffer_size must be positive when admin is enabled")
+		}
+	}
+
+	errs = append(errs, c.Server.TLS.validate()...)
+
+	errs = append(errs, c.validateDurations()...)
+
+	for _, name := range providerNames {
+		provider := providers[name]
+		for _, field := range []string{"http_proxy", "https_proxy"} {
+			proxy := provider.HTTPProxy
+			if field == "https_proxy" {
+				proxy = provider.HTTPSProxy
+			}
+			if proxy == "" {
+				continue
+			}
+			if !strings.Contains(proxy, "://") {
+				proxy = "http://" + proxy
+			}
+			if u, err := url.Parse(proxy); err != nil || u.Host == "" {
+				errs.add("providers."+name+"."+field, "invalid proxy for provider %s: %q", name, proxy)
+			}
+		}
+	}
+
+	for _, name := range providerNames {
+		if providers[name].MaxResponseBytes < 0 {
+			errs.add("providers."+name+".max_response_bytes", "invalid max_response_bytes for provider %s: must not be negative", name)
+		}
+		for i, baseURL := range providers[name].BaseURLs {
+			if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs.add(fmt.Sprintf("providers.%s.base_urls[%d]", name, i), "invalid base_urls entry for provider %s: %q (must be an http or https URL)", name, baseURL)
+			}
+		}
+	}
+
+	for _, name := range providerNames {
+		mapping := providers[name].FieldMapping
+		for _, direction := range []string{"request", "response"} {
+			fields := mapping.Request
+			if direction == "response" {
+				fields = mapping.Response
+			}
+			paths := make([]string, 0, len(fields))
+			for path := range fields {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				field := fields[path]
+				if strings.Contains("."+path+".", "..") || field == "" || strings.Contains(field, ".") {
+					errs.add(fmt.Sprintf("providers.%s.field_mapping.%s.%s", name, direction, path), "invalid field_mapping for provider %s: %q -> %q (must map a dotted path to a field name)", name, path, field)
+				}
+			}
+		}
+	}
+
+	if azure := c.Providers.Azure; azure.Enabled {
+		if azure.Azure.Resource == "" && azure.BaseURL == "" {
+			errs.add("providers.azure.azure.resource", "invalid azure provider config: resource or base_url is required")
+		}
+		if azure.Azure.Deployment == "" && len(azure.Azure.Deployments) == 0 {
+			errs.add("providers.azure.azure.deployment", "invalid azure provider config: deployment or deployments is required")
+		}
+	}
+
+	if c.Batch.Enabled {
+		if c.Batch.MaxSize <= 0 {
+			errs.add("batch.max_size", "invalid batch config: max_size must be positive")
+		}
+		if c.Batch.Concurrency <= 0 {
+			errs.add("batch.concurrency", "invalid batch config: concurrency must be positive")
+		}
 	}
 
 	if c.Translator.Mode != "wasm" && c.Translator.Mode != "sidecar" && c.Translator.Mode != "native" {
-		return fmt.Errorf("invalid translator mode: %s (must be 'wasm', 'sidecar', or 'native')", c.Translator.Mode)
+		errs.add("translator.mode", "invalid translator mode: %s (must be 'wasm', 'sidecar', or 'native')", c.Translator.Mode)
 	}
 
-	return nil
+	return errs.err()
+}
+
+// Validate checks that an enabled TLS config names a readable, matching
+// certificate and key, so misconfiguration surfaces at load time rather than
+// when the server starts serving
+func (t TLSConfig) Validate() error {
+	return t.validate().err()
+}
+
+// validate returns every TLS problem, with paths under server.tls
+func (t TLSConfig) validate() ValidationErrors {
+	var errs ValidationErrors
+	if !t.Enabled {
+		return nil
+	}
+
+	if t.CertFile == "" {
+		errs.add("server.tls.cert_file", "invalid tls config: cert_file is required when tls is enabled")
+	}
+	if t.KeyFile == "" {
+		errs.add("server.tls.key_file", "invalid tls config: key_file is required when tls is enabled")
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+		errs.add("server.tls", "invalid tls config: %w", err)
+	}
+
+	return errs
 }
 
 // Save saves configuration to a file
@@ -145,14 +421,22 @@ func Save(path string, cfg *Config) error {
 func Default() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host: "localhost",
-			Port: 8080,
+			Host:                 "localhost",
+			Port:                 8080,
+			TCPKeepAlive:         30 * time.Second,
+			ErrorFormat:          ErrorFormatOpenAI,
+			StreamErrorEvents:    true,
+			MaxQueueDepth:        100,
+			QueueWaitTimeout:     30 * time.Second,
+			MaxStreamOutputBytes: 8 << 20, // 8 MiB
+			SelfTest:             SelfTestWarn,
+			ResponsesAPIVersion:  ResponsesAPIDefault,
 			TLS: TLSConfig{
 				Enabled: false,
 			},
 		},
 		Zai: ZaiConfig{
-			BaseURL:    "https://api.z.ai/api/coding/paas/v4", // Coding Plan endpoint
+			Plan:       ZaiPlanCoding, // base_url is derived from the plan unless set
 			APIKey:     "",
 			Timeout:    120 * time.Second,
 			MaxRetries: 3,
@@ -160,8 +444,10 @@ func Default() *Config {
 		},
 		Providers:    DefaultProvidersConfig(),
 		Codex: CodexConfig{
-			BaseURL:      "",
-			APIKeyHeader: "Authorization",
+			BaseURL:        "",
+			APIKeyHeader:   "Authorization",
+			ToolCallStatus: ToolCallStatusRequiresAction,
+			SSEEventLines:  true,
 		},
 		Translator: TranslatorConfig{
 			Mode:           "native",
@@ -169,9 +455,11 @@ func Default() *Config {
 			SidecarCommand: "node ./translator/index.js",
 		},
 		Session: SessionConfig{
-			Enabled:          true,
-			TTL:              3600 * time.Second,
-			MaxConversations: 1000,
+			Enabled:            true,
+			TTL:                3600 * time.Second,
+			MaxConversations:   1000,
+			Store:              "memory",
+			OversizedResponses: "skip",
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -182,6 +470,23 @@ func Default() *Config {
 			Enabled: true,
 			Path:    "/metrics",
 			Format:  "prometheus",
+
+			BackendConnections: true,
+			Tenants: MetricsTenantsConfig{
+				MaxTenants: 100,
+			},
+		},
+		Batch: BatchConfig{
+			Enabled:     true,
+			MaxSize:     100,
+			Concurrency: 4,
+		},
+		Admin: AdminConfig{
+			Enabled:       false,
+			LogBufferSize: 1000,
+		},
+		Debug: DebugConfig{
+			MaxSyntheticLatency: 30 * time.Second,
 		},
 	}
 }
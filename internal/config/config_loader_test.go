@@ -0,0 +1,214 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// validConfig returns the default config with the API key Validate requires
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Zai.APIKey = "test-key"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		paths  []string // Paths of the expected errors, in order; none means valid
+	}{
+		{
+			name:   "defaults with an API key",
+			modify: func(*Config) {},
+		},
+		{
+			name:   "no API key",
+			modify: func(c *Config) { c.Zai.APIKey = "" },
+			paths:  []string{"providers"},
+		},
+		{
+			name: "mock provider needs no API key",
+			modify: func(c *Config) {
+				c.Zai.APIKey = ""
+				c.Providers.Mock.Enabled = true
+			},
+		},
+		{
+			name:   "port out of range",
+			modify: func(c *Config) { c.Server.Port = 70000 },
+			paths:  []string{"server.port"},
+		},
+		{
+			name:   "negative duration",
+			modify: func(c *Config) { c.Providers.Zai.RetryDelay = -time.Second },
+			paths:  []string{"providers.zai.retry_delay"},
+		},
+		{
+			name:   "unknown responses API version",
+			modify: func(c *Config) { c.Server.ResponsesAPIVersion = "v9" },
+			paths:  []string{"server.responses_api_version"},
+		},
+		{
+			name:   "negative fallback parallelism",
+			modify: func(c *Config) { c.Providers.Fallback.Parallel = -1 },
+			paths:  []string{"providers.fallback.parallel"},
+		},
+		{
+			name:   "negative fallback probe timeout",
+			modify: func(c *Config) { c.Providers.Fallback.Timeout = -time.Second },
+			paths:  []string{"providers.fallback.timeout"},
+		},
+		{
+			name:   "unknown enforce_tool_choice mode",
+			modify: func(c *Config) { c.Providers.EnforceToolChoice = "always" },
+			paths:  []string{"providers.enforce_tool_choice"},
+		},
+		{
+			name:   "metrics username without password",
+			modify: func(c *Config) { c.Metrics.Auth.Username = "prom" },
+			paths:  []string{"metrics.auth"},
+		},
+		{
+			name:   "admin address without a port",
+			modify: func(c *Config) { c.Server.AdminAddr = "localhost" },
+			paths:  []string{"server.admin_addr"},
+		},
+		{
+			name:   "metrics listen address without a port",
+			modify: func(c *Config) { c.Metrics.ListenAddr = "localhost" },
+			paths:  []string{"metrics.listen_addr"},
+		},
+		{
+			name:   "negative retry budget",
+			modify: func(c *Config) { c.Providers.Zai.RetryMaxElapsed = -time.Second },
+			paths:  []string{"providers.zai.retry_max_elapsed"},
+		},
+		{
+			name: "passthrough auth needs no API key",
+			modify: func(c *Config) {
+				c.Zai.APIKey = ""
+				c.Providers.PassthroughAuth = true
+			},
+		},
+		{
+			name: "passthrough auth with client keys in Authorization",
+			modify: func(c *Config) {
+				c.Providers.PassthroughAuth = true
+				c.Codex.APIKeys = []string{"k1"}
+			},
+			paths: []string{"providers.passthrough_auth"},
+		},
+		{
+			name: "passthrough auth with client keys in another header",
+			modify: func(c *Config) {
+				c.Providers.PassthroughAuth = true
+				c.Codex.APIKeys = []string{"k1"}
+				c.Codex.APIKeyHeader = "X-Codex-Key"
+			},
+		},
+		{
+			name:   "negative cold start probe timeout",
+			modify: func(c *Config) { c.Providers.ColdStart.Timeout = -time.Second },
+			paths:  []string{"providers.cold_start.timeout"},
+		},
+		{
+			name:   "negative max tenants",
+			modify: func(c *Config) { c.Metrics.Tenants.MaxTenants = -1 },
+			paths:  []string{"metrics.tenants.max_tenants"},
+		},
+		{
+			name:   "negative health check concurrency",
+			modify: func(c *Config) { c.Providers.HealthCheckConcurrency = -1 },
+			paths:  []string{"providers.health_check_concurrency"},
+		},
+		{
+			name: "fallback response with an invalid status",
+			modify: func(c *Config) {
+				c.Providers.FallbackResponse.Enabled = true
+				c.Providers.FallbackResponse.Status = 42
+			},
+			paths: []string{"providers.fallback_response.status"},
+		},
+		{
+			name: "fallback response without a message",
+			modify: func(c *Config) {
+				c.Providers.FallbackResponse.Enabled = true
+				c.Providers.FallbackResponse.Message = ""
+			},
+			paths: []string{"providers.fallback_response.message"},
+		},
+		{
+			name: "every problem is reported",
+			modify: func(c *Config) {
+				c.Zai.APIKey = ""
+				c.Server.Port = 0
+				c.Server.ErrorFormat = "xml"
+				c.Providers.Zai.Timeout = -time.Second
+				c.Translator.Mode = "magic"
+				c.Server.TLS.Enabled = true
+			},
+			paths: []string{"server.port", "providers", "server.error_format", "server.tls.cert_file", "server.tls.key_file", "providers.zai.timeout", "translator.mode"},
+		},
+		{
+			name: "routing rule without metadata or a known provider",
+			modify: func(c *Config) {
+				c.Providers.RoutingRules = []RoutingRule{{Provider: "bedrock"}}
+			},
+			paths: []string{"providers.routing_rules[0].metadata", "providers.routing_rules[0].provider"},
+		},
+		{
+			name: "azure without resource or deployments",
+			modify: func(c *Config) {
+				c.Providers.Azure = ProviderConfig{Enabled: true, Type: "azure", APIKey: "az"}
+			},
+			paths: []string{"providers.azure.azure.resource", "providers.azure.azure.deployment"},
+		},
+		{
+			name: "field mapping to a dotted name",
+			modify: func(c *Config) {
+				c.Providers.Zai.FieldMapping.Request = map[string]string{"max_tokens": "a.b"}
+			},
+			paths: []string{"providers.zai.field_mapping.request.max_tokens"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.paths) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate() = %v, want ValidationErrors", err)
+			}
+			var paths []string
+			for _, fe := range errs {
+				paths = append(paths, fe.Path)
+			}
+			if !containsInOrder(paths, tt.paths) {
+				t.Errorf("error paths = %q, want %q among them in order", paths, tt.paths)
+			}
+		})
+	}
+}
+
+// containsInOrder reports whether want is a subsequence of got
+func containsInOrder(got, want []string) bool {
+	i := 0
+	for _, g := range got {
+		if i < len(want) && g == want[i] {
+			i++
+		}
+	}
+	return i == len(want)
+}
@@ -12,15 +12,81 @@ type Config struct {
 	Session         SessionConfig         `yaml:"session" mapstructure:"session"`
 	Logging         LoggingConfig         `yaml:"logging" mapstructure:"logging"`
 	Metrics         MetricsConfig         `yaml:"metrics" mapstructure:"metrics"`
+	Batch           BatchConfig           `yaml:"batch" mapstructure:"batch"`
+	Admin           AdminConfig           `yaml:"admin" mapstructure:"admin"`
+	Debug           DebugConfig           `yaml:"debug" mapstructure:"debug"`
 }
 
 // ServerConfig contains HTTP server configuration
 type ServerConfig struct {
-	Host string    `yaml:"host" mapstructure:"host"`
-	Port int       `yaml:"port" mapstructure:"port"`
-	TLS  TLSConfig `yaml:"tls" mapstructure:"tls"`
+	Host         string        `yaml:"host" mapstructure:"host"`
+	Port         int           `yaml:"port" mapstructure:"port"`
+	TLS          TLSConfig     `yaml:"tls" mapstructure:"tls"`
+	TCPKeepAlive time.Duration `yaml:"tcp_keepalive" mapstructure:"tcp_keepalive"` // 0 uses the OS default, negative disables
+	ErrorFormat  string        `yaml:"error_format" mapstructure:"error_format"`   // openai | anthropic
+
+	// AdminAddr serves /health, /metrics and /admin/* on a separate listener
+	// so they can be firewalled off from the public API port
+	AdminAddr string `yaml:"admin_addr,omitempty" mapstructure:"admin_addr"`
+
+	// StreamErrorEvents reports backend failures on streaming requests as a
+	// response.failed event on a 200 SSE response when the client accepts
+	// text/event-stream, instead of a plain HTTP error
+	StreamErrorEvents bool `yaml:"stream_error_events" mapstructure:"stream_error_events"`
+
+	// StrictRequestFields rejects requests with top-level fields the
+	// Responses API doesn't define, to surface client typos
+	StrictRequestFields bool `yaml:"strict_request_fields,omitempty" mapstructure:"strict_request_fields"`
+
+	// Backend dispatch limit. Requests over MaxConcurrentRequests wait in a
+	// FIFO queue of up to MaxQueueDepth for QueueWaitTimeout before a 429.
+	MaxConcurrentRequests int           `yaml:"max_concurrent_requests" mapstructure:"max_concurrent_requests"` // 0 disables
+	MaxQueueDepth         int           `yaml:"max_queue_depth" mapstructure:"max_queue_depth"`
+	QueueWaitTimeout      time.Duration `yaml:"queue_wait_timeout" mapstructure:"queue_wait_timeout"`
+
+	// MaxConnectionsPerIP caps the requests, open streams included, in flight
+	// from one client IP; requests past it get a 429. 0 disables the cap.
+	MaxConnectionsPerIP int `yaml:"max_connections_per_ip" mapstructure:"max_connections_per_ip"`
+
+	// MaxStreamOutputBytes caps the text and tool-call arguments accumulated
+	// for one streamed response. Past it the rest is dropped and the response
+	// ends as incomplete. 0 disables the cap.
+	MaxStreamOutputBytes int `yaml:"max_stream_output_bytes" mapstructure:"max_stream_output_bytes"`
+
+	// StreamProgressInterval repeats response.in_progress on a stream that
+	// has sent no event for this long, e.g. while the backend reasons without
+	// relaying it, so clients can show progress. 0 disables.
+	StreamProgressInterval time.Duration `yaml:"stream_progress_interval" mapstructure:"stream_progress_interval"`
+
+	// SelfTest runs canned requests through the transform pipeline at
+	// startup: warn logs failures, strict refuses to start on a failure
+	SelfTest string `yaml:"self_test" mapstructure:"self_test"` // off | warn | strict
+
+	// ResponsesAPIVersion pins the shape of response objects and stream
+	// events for clients built against an older Responses API
+	ResponsesAPIVersion string `yaml:"responses_api_version" mapstructure:"responses_api_version"` // v1 | v2
 }
 
+// Startup self-test modes for server.self_test
+const (
+	SelfTestOff    = "off"
+	SelfTestWarn   = "warn"
+	SelfTestStrict = "strict"
+)
+
+// Response schema versions for server.responses_api_version
+const (
+	ResponsesAPIV1      = "v1"           // Tool calls nested under the message item, streams end with response.done
+	ResponsesAPIV2      = "v2"           // Tool calls as function_call output items, no response.done
+	ResponsesAPIDefault = ResponsesAPIV1 // The shape existing clients get; v2 is opt-in
+)
+
+// Error envelope formats for server.error_format
+const (
+	ErrorFormatOpenAI    = "openai"
+	ErrorFormatAnthropic = "anthropic"
+)
+
 // TLSConfig contains TLS configuration
 type TLSConfig struct {
 	Enabled  bool   `yaml:"enabled" mapstructure:"enabled"`
@@ -30,6 +96,7 @@ type TLSConfig struct {
 
 // ZaiConfig contains z.ai API configuration (legacy)
 type ZaiConfig struct {
+	Plan       string        `yaml:"plan" mapstructure:"plan"` // coding | standard, selects base_url when unset
 	BaseURL    string        `yaml:"base_url" mapstructure:"base_url"`
 	APIKey     string        `yaml:"api_key" mapstructure:"api_key"`
 	Timeout    time.Duration `yaml:"timeout" mapstructure:"timeout"`
@@ -40,9 +107,47 @@ type ZaiConfig struct {
 // CodexConfig contains Codex CLI configuration
 type CodexConfig struct {
 	BaseURL      string `yaml:"base_url" mapstructure:"base_url"`
-	APIKeyHeader string `yaml:"api_key_header" mapstructure:"api_key_header"`
+	APIKeyHeader string `yaml:"api_key_header" mapstructure:"api_key_header"` // Header carrying the client's API key
+
+	// APIKeys are the client API keys accepted in APIKeyHeader. Empty
+	// disables client authentication.
+	APIKeys []string `yaml:"api_keys,omitempty" mapstructure:"api_keys"`
+
+	// ToolCallStatus is the response status reported when the model stops to
+	// call tools: requires_action tells the client to send tool outputs and
+	// continue, completed suits clients that only inspect the output items
+	ToolCallStatus string `yaml:"tool_call_status" mapstructure:"tool_call_status"`
+
+	// ExposeBackendModel adds the backend's model name to responses as
+	// x_backend_model alongside the echoed client model
+	ExposeBackendModel bool `yaml:"expose_backend_model" mapstructure:"expose_backend_model"`
+
+	// ExposeCost adds the estimated request cost in USD to responses as
+	// x_cost, for debugging providers.pricing
+	ExposeCost bool `yaml:"expose_cost" mapstructure:"expose_cost"`
+
+	// ForwardReasoningContent lets streams carry the backend's detailed
+	// reasoning to clients whose include asks for reasoning.content. The
+	// reasoning summary is relayed regardless.
+	ForwardReasoningContent bool `yaml:"forward_reasoning_content" mapstructure:"forward_reasoning_content"`
+
+	// SSEEventLines precedes each Responses API stream event's data with an
+	// event: line naming its type. Clients that read only the type in the
+	// data, or proxies that strip event: lines, can do without them.
+	SSEEventLines bool `yaml:"sse_event_lines" mapstructure:"sse_event_lines"`
+
+	// InstructionsTemplate is a text/template applied to the client's
+	// instructions before dispatch, with {{.Instructions}}, {{.Model}},
+	// {{.Date}} and {{.Now}}. Empty passes instructions through unchanged.
+	InstructionsTemplate string `yaml:"instructions_template,omitempty" mapstructure:"instructions_template"`
 }
 
+// Response statuses for codex.tool_call_status
+const (
+	ToolCallStatusRequiresAction = "requires_action"
+	ToolCallStatusCompleted      = "completed"
+)
+
 // TranslatorConfig contains translator configuration
 type TranslatorConfig struct {
 	Mode           string `yaml:"mode" mapstructure:"mode"` // wasm | sidecar | native
@@ -55,6 +160,14 @@ type SessionConfig struct {
 	Enabled          bool          `yaml:"enabled" mapstructure:"enabled"`
 	TTL              time.Duration `yaml:"ttl" mapstructure:"ttl"`
 	MaxConversations int           `yaml:"max_conversations" mapstructure:"max_conversations"`
+	Store            string        `yaml:"store" mapstructure:"store"` // memory | file
+	Path             string        `yaml:"path" mapstructure:"path"`   // File store location
+
+	// MaxResponseBytes caps the JSON size of a response kept for
+	// GET /v1/responses/{id}. OversizedResponses decides what happens to
+	// larger ones: skip doesn't store them, truncate cuts their output text.
+	MaxResponseBytes   int    `yaml:"max_response_bytes" mapstructure:"max_response_bytes"`   // 0 disables
+	OversizedResponses string `yaml:"oversized_responses" mapstructure:"oversized_responses"` // skip | truncate
 }
 
 // LoggingConfig contains logging configuration
@@ -62,6 +175,9 @@ type LoggingConfig struct {
 	Level  string `yaml:"level" mapstructure:"level"`   // debug | info | warn | error
 	Format string `yaml:"format" mapstructure:"format"` // json | text
 	File   string `yaml:"file" mapstructure:"file"`     // Optional file output
+
+	SlowRequestThreshold time.Duration `yaml:"slow_request_threshold" mapstructure:"slow_request_threshold"` // 0 disables
+	RedactHeaders        []string      `yaml:"redact_headers" mapstructure:"redact_headers"`                 // Masked in logs in addition to Authorization and X-Api-Key
 }
 
 // MetricsConfig contains metrics configuration
@@ -69,4 +185,61 @@ type MetricsConfig struct {
 	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
 	Path    string `yaml:"path" mapstructure:"path"`
 	Format  string `yaml:"format" mapstructure:"format"` // prometheus
+
+	BackendConnections bool `yaml:"backend_connections" mapstructure:"backend_connections"` // Trace backend connection reuse, DNS and connect time
+
+	// ListenAddr serves metrics on a separate address, such as an
+	// internal-only port, instead of the main one
+	ListenAddr string            `yaml:"listen_addr,omitempty" mapstructure:"listen_addr"`
+	Auth       MetricsAuthConfig `yaml:"auth,omitempty" mapstructure:"auth"`
+
+	Tenants MetricsTenantsConfig `yaml:"tenants" mapstructure:"tenants"`
+}
+
+// MetricsTenantsConfig labels request metrics by tenant. The tenant is read
+// from Header, or else looked up by the client's API key in APIKeys. Requests
+// with neither, and tenants beyond the first MaxTenants seen, are counted as
+// "unknown" to bound the label's cardinality; 0 disables the cap. With
+// neither Header nor APIKeys set, metrics are not labeled.
+type MetricsTenantsConfig struct {
+	Header     string            `yaml:"header,omitempty" mapstructure:"header"`     // e.g. X-Tenant-Id
+	APIKeys    map[string]string `yaml:"api_keys,omitempty" mapstructure:"api_keys"` // Client API key -> tenant
+	MaxTenants int               `yaml:"max_tenants" mapstructure:"max_tenants"`
+}
+
+// TenantUnknown is the tenant label of requests that can't be attributed
+const TenantUnknown = "unknown"
+
+// MetricsAuthConfig protects the metrics endpoint. Scrapers may send either
+// the bearer token or the basic auth credentials; with neither set the
+// endpoint is open.
+type MetricsAuthConfig struct {
+	BearerToken string `yaml:"bearer_token,omitempty" mapstructure:"bearer_token"`
+	Username    string `yaml:"username,omitempty" mapstructure:"username"`
+	Password    string `yaml:"password,omitempty" mapstructure:"password"`
+}
+
+// BatchConfig contains configuration for the JSON-lines batch endpoint
+type BatchConfig struct {
+	Enabled     bool `yaml:"enabled" mapstructure:"enabled"`
+	MaxSize     int  `yaml:"max_size" mapstructure:"max_size"`       // Maximum requests per batch
+	Concurrency int  `yaml:"concurrency" mapstructure:"concurrency"` // Requests processed in parallel
+}
+
+// AdminConfig contains configuration for the admin endpoints
+type AdminConfig struct {
+	Enabled       bool   `yaml:"enabled" mapstructure:"enabled"`
+	Token         string `yaml:"token" mapstructure:"token"`                     // Bearer token required on admin requests
+	LogBufferSize int    `yaml:"log_buffer_size" mapstructure:"log_buffer_size"` // Recent log lines kept for GET /admin/logs
+}
+
+// DebugConfig contains testing aids that must stay off in production
+type DebugConfig struct {
+	// SyntheticLatency honors the X-Codex-Delay and X-Codex-Token-Delay
+	// request headers, which delay dispatch to the backend and each streamed
+	// chunk, for testing client timeouts and load balancers
+	SyntheticLatency bool `yaml:"synthetic_latency" mapstructure:"synthetic_latency"`
+
+	// MaxSyntheticLatency caps the delay either header can ask for
+	MaxSyntheticLatency time.Duration `yaml:"max_synthetic_latency" mapstructure:"max_synthetic_latency"`
 }
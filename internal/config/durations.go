@@ -0,0 +1,89 @@
+package config
+
+import (
+	"sort"
+	"time"
+)
+
+// Fallbacks for durations where zero would otherwise mean "wait forever" or
+// "spin": a zero backend timeout disables the HTTP client timeout entirely and
+// a zero health check interval cannot drive a ticker.
+const (
+	DefaultBackendTimeout      = 120 * time.Second
+	DefaultHealthCheckInterval = 30 * time.Second
+	DefaultHealthCheckTimeout  = 10 * time.Second
+)
+
+// NormalizeDurations replaces zero durations that would be unsafe with their
+// defaults. Negative values are left for Validate to reject.
+func (c *Config) NormalizeDurations() {
+	if c.Zai.Timeout == 0 {
+		c.Zai.Timeout = DefaultBackendTimeout
+	}
+
+	// A provider without its own timeout inherits the legacy z.ai timeout
+	normalizeProvider(&c.Providers.Zai, c.Zai.Timeout)
+	normalizeProvider(&c.Providers.OpenAI, DefaultBackendTimeout)
+	normalizeProvider(&c.Providers.Anthropic, DefaultBackendTimeout)
+	normalizeProvider(&c.Providers.Azure, DefaultBackendTimeout)
+}
+
+func normalizeProvider(p *ProviderConfig, timeout time.Duration) {
+	if p.Timeout == 0 {
+		p.Timeout = timeout
+	}
+	if p.HealthCheck.Enabled {
+		if p.HealthCheck.Interval == 0 {
+			p.HealthCheck.Interval = DefaultHealthCheckInterval
+		}
+		if p.HealthCheck.Timeout == 0 {
+			p.HealthCheck.Timeout = DefaultHealthCheckTimeout
+		}
+	}
+}
+
+// validateDurations reports each negative duration. server.tcp_keepalive is
+// exempt since a negative value there disables keep-alives.
+func (c *Config) validateDurations() ValidationErrors {
+	durations := map[string]time.Duration{
+		"server.queue_wait_timeout":          c.Server.QueueWaitTimeout,
+		"server.stream_progress_interval":    c.Server.StreamProgressInterval,
+		"zai.timeout":                        c.Zai.Timeout,
+		"zai.retry_delay":                    c.Zai.RetryDelay,
+		"session.ttl":                        c.Session.TTL,
+		"logging.slow_request_threshold":     c.Logging.SlowRequestThreshold,
+		"providers.mock.latency":             c.Providers.Mock.Latency,
+		"providers.fallback.timeout":         c.Providers.Fallback.Timeout,
+		"providers.degraded_response_jitter": c.Providers.DegradedResponseJitter,
+		"providers.cold_start.timeout":       c.Providers.ColdStart.Timeout,
+		"debug.max_synthetic_latency":        c.Debug.MaxSyntheticLatency,
+	}
+	for name, provider := range map[string]ProviderConfig{
+		"zai":       c.Providers.Zai,
+		"openai":    c.Providers.OpenAI,
+		"anthropic": c.Providers.Anthropic,
+		"azure":     c.Providers.Azure,
+	} {
+		prefix := "providers." + name + "."
+		durations[prefix+"timeout"] = provider.Timeout
+		durations[prefix+"retry_delay"] = provider.RetryDelay
+		durations[prefix+"retry_max_elapsed"] = provider.RetryMaxElapsed
+		durations[prefix+"health_check.interval"] = provider.HealthCheck.Interval
+		durations[prefix+"health_check.timeout"] = provider.HealthCheck.Timeout
+	}
+
+	names := make([]string, 0, len(durations))
+	for name := range durations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs ValidationErrors
+	for _, name := range names {
+		if d := durations[name]; d < 0 {
+			errs.add(name, "invalid %s: %s (must not be negative)", name, d)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeDurations(t *testing.T) {
+	cfg := &Config{}
+	cfg.Zai.Timeout = 90 * time.Second
+	cfg.Providers.OpenAI.Timeout = 10 * time.Second
+	cfg.Providers.Anthropic.HealthCheck.Enabled = true
+	cfg.Providers.Azure.HealthCheck.Enabled = true
+	cfg.NormalizeDurations()
+
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"zai inherits zai.timeout", cfg.Providers.Zai.Timeout, 90 * time.Second},
+		{"openai keeps its own timeout", cfg.Providers.OpenAI.Timeout, 10 * time.Second},
+		{"anthropic gets the default", cfg.Providers.Anthropic.Timeout, DefaultBackendTimeout},
+		{"anthropic health check interval", cfg.Providers.Anthropic.HealthCheck.Interval, DefaultHealthCheckInterval},
+		{"anthropic health check timeout", cfg.Providers.Anthropic.HealthCheck.Timeout, DefaultHealthCheckTimeout},
+		{"azure gets the default", cfg.Providers.Azure.Timeout, DefaultBackendTimeout},
+		{"azure health check interval", cfg.Providers.Azure.HealthCheck.Interval, DefaultHealthCheckInterval},
+		{"disabled health check untouched", cfg.Providers.OpenAI.HealthCheck.Interval, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	t.Run("zero zai.timeout gets the default", func(t *testing.T) {
+		cfg := &Config{}
+		cfg.NormalizeDurations()
+		if cfg.Zai.Timeout != DefaultBackendTimeout || cfg.Providers.Zai.Timeout != DefaultBackendTimeout {
+			t.Errorf("timeouts = %v, %v, want %v", cfg.Zai.Timeout, cfg.Providers.Zai.Timeout, DefaultBackendTimeout)
+		}
+	})
+}
+
+func TestValidateDurations(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"zero slow request threshold", func(c *Config) { c.Logging.SlowRequestThreshold = 0 }, ""},
+		{"negative tcp keepalive disables it", func(c *Config) { c.Server.TCPKeepAlive = -1 }, ""},
+		{"negative zai timeout", func(c *Config) { c.Zai.Timeout = -time.Second }, "zai.timeout"},
+		{"negative session ttl", func(c *Config) { c.Session.TTL = -time.Minute }, "session.ttl"},
+		{"negative provider retry delay", func(c *Config) { c.Providers.OpenAI.RetryDelay = -time.Second }, "providers.openai.retry_delay"},
+		{"negative health check interval", func(c *Config) { c.Providers.Zai.HealthCheck.Interval = -time.Second }, "providers.zai.health_check.interval"},
+		{"negative stream progress interval", func(c *Config) { c.Server.StreamProgressInterval = -time.Second }, "server.stream_progress_interval"},
+		{"negative max synthetic latency", func(c *Config) { c.Debug.MaxSyntheticLatency = -time.Second }, "debug.max_synthetic_latency"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Zai.APIKey = "zai-key"
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+)
+
+// InstructionsData is the data available to an instructions template
+type InstructionsData struct {
+	Instructions string    // The client's instructions, empty if it sent none
+	Model        string    // Model name requested by the client
+	Date         string    // Current date as YYYY-MM-DD
+	Now          time.Time // Current time
+}
+
+// NewInstructionsData returns the template data for a request's instructions
+func NewInstructionsData(instructions, model string, now time.Time) InstructionsData {
+	return InstructionsData{
+		Instructions: instructions,
+		Model:        model,
+		Date:         now.Format("2006-01-02"),
+		Now:          now,
+	}
+}
+
+// InstructionsTemplate returns the instructions template in effect for a
+// provider: its own instructions_template, else codex.instructions_template
+func (c *Config) InstructionsTemplate(provider ProviderConfig) string {
+	if provider.InstructionsTemplate != "" {
+		return provider.InstructionsTemplate
+	}
+	return c.Codex.InstructionsTemplate
+}
+
+// ParseInstructionsTemplate parses an instructions template and checks it
+// executes against sample data, so references to unknown fields surface at
+// load time. An empty template returns nil.
+func ParseInstructionsTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("instructions").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instructions_template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, NewInstructionsData("", "", time.Now())); err != nil {
+		return nil, fmt.Errorf("invalid instructions_template: %w", err)
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestParseInstructionsTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantNil bool
+		wantErr bool
+	}{
+		{"empty", "", true, false},
+		{"valid", "{{.Date}}: {{.Instructions}}", false, false},
+		{"syntax error", "{{.Instructions", true, true},
+		{"unknown field", "{{.Unknown}}", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseInstructionsTemplate(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInstructionsTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (tmpl == nil) != tt.wantNil {
+				t.Errorf("template = %v, want nil %v", tmpl, tt.wantNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergeDefaults fills in settings missing from a YAML config file with their
+// default values. Existing values, key order and comments are kept. It returns
+// the merged document and the added settings as "path: value" lines, or just
+// the path for a section added as a whole.
+func MergeDefaults(data []byte) ([]byte, []string, error) {
+	defaults, err := yaml.Marshal(Default())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal defaults: %w", err)
+	}
+
+	var src yaml.Node
+	if err := yaml.Unmarshal(defaults, &src); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse defaults: %w", err)
+	}
+
+	var dst yaml.Node
+	if err := yaml.Unmarshal(data, &dst); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	// An empty file has no document content; take the defaults wholesale
+	if len(dst.Content) == 0 {
+		return defaults, []string{"(all settings)"}, nil
+	}
+	if dst.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("failed to parse config: top level must be a mapping")
+	}
+
+	var added []string
+	mergeMapping(dst.Content[0], src.Content[0], "", &added)
+	if len(added) == 0 {
+		return data, nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&dst); err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	return buf.Bytes(), added, nil
+}
+
+// mergeMapping appends the keys of src missing from dst, recursing into
+// mappings present in both
+func mergeMapping(dst, src *yaml.Node, prefix string, added *[]string) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		path := prefix + key.Value
+
+		existing := mappingValue(dst, key.Value)
+		if existing == nil || (existing.Tag == "!!null" && value.Kind == yaml.MappingNode) {
+			// An empty section, such as a bare "providers:", is filled in whole
+			if existing != nil {
+				*existing = *value
+			} else {
+				dst.Content = append(dst.Content, key, value)
+			}
+			if value.Kind == yaml.ScalarNode {
+				*added = append(*added, fmt.Sprintf("%s: %q", path, value.Value))
+			} else {
+				*added = append(*added, path)
+			}
+			continue
+		}
+
+		if existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			mergeMapping(existing, value, path+".", added)
+		}
+	}
+}
+
+// mappingValue returns the value for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMergeDefaults(t *testing.T) {
+	partial := `# my router
+server:
+  port: 9090 # custom port
+zai:
+  api_key: my-key
+`
+
+	merged, added, err := MergeDefaults([]byte(partial))
+	if err != nil {
+		t.Fatalf("MergeDefaults() error = %v", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(merged, &cfg); err != nil {
+		t.Fatalf("merged config does not parse: %v", err)
+	}
+	if cfg.Server.Port != 9090 || cfg.Zai.APIKey != "my-key" {
+		t.Errorf("existing values lost: port %d, api_key %q", cfg.Server.Port, cfg.Zai.APIKey)
+	}
+	if cfg.Server.Host != Default().Server.Host {
+		t.Errorf("server.host = %q, want default %q", cfg.Server.Host, Default().Server.Host)
+	}
+	if !strings.Contains(string(merged), "# custom port") {
+		t.Error("comments were not kept")
+	}
+
+	if !slices.Contains(added, `server.host: "localhost"`) {
+		t.Errorf("added = %v, want server.host listed", added)
+	}
+	if !slices.Contains(added, "providers") {
+		t.Errorf("added = %v, want the providers section listed", added)
+	}
+	for _, line := range added {
+		if strings.HasPrefix(line, "server.port") || strings.HasPrefix(line, "zai.api_key") {
+			t.Errorf("existing setting reported as added: %s", line)
+		}
+	}
+
+	t.Run("idempotent", func(t *testing.T) {
+		again, added, err := MergeDefaults(merged)
+		if err != nil {
+			t.Fatalf("MergeDefaults() error = %v", err)
+		}
+		if len(added) != 0 || string(again) != string(merged) {
+			t.Errorf("second merge added %v", added)
+		}
+	})
+
+	t.Run("empty section filled in", func(t *testing.T) {
+		_, added, err := MergeDefaults([]byte("providers:\n"))
+		if err != nil {
+			t.Fatalf("MergeDefaults() error = %v", err)
+		}
+		if !slices.Contains(added, "providers") {
+			t.Errorf("added = %v, want providers", added)
+		}
+	})
+
+	t.Run("not a mapping", func(t *testing.T) {
+		if _, _, err := MergeDefaults([]byte("- a\n- b\n")); err == nil {
+			t.Error("MergeDefaults() of a list succeeded")
+		}
+	})
+}
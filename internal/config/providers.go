@@ -9,23 +9,222 @@ type ProvidersConfig struct {
 	Zai             ProviderConfig `yaml:"zai" mapstructure:"zai"`
 	OpenAI          ProviderConfig `yaml:"openai" mapstructure:"openai"`
 	Anthropic       ProviderConfig `yaml:"anthropic,omitempty" mapstructure:"anthropic,omitempty"`
+	Azure           ProviderConfig `yaml:"azure,omitempty" mapstructure:"azure"` // Azure OpenAI
+	Mock            MockConfig     `yaml:"mock,omitempty" mapstructure:"mock"` // Offline test mode, replaces the backend
 	ProviderStrategy string        `yaml:"provider_strategy" mapstructure:"provider_strategy"`
 	Fallback        FallbackConfig `yaml:"fallback" mapstructure:"fallback"`
+	ColdStart       ColdStartConfig `yaml:"cold_start,omitempty" mapstructure:"cold_start"`
 	ModelMapping    map[string]string `yaml:"model_mapping" mapstructure:"model_mapping"`
+
+	// Strict fails startup when any enabled provider fails to initialize.
+	// Otherwise the providers that did are used, and startup only fails
+	// when none did.
+	Strict bool `yaml:"strict,omitempty" mapstructure:"strict"`
+
+	// HealthCheckConcurrency caps the scheduled health checks running at
+	// once across providers. 0 leaves them uncapped.
+	HealthCheckConcurrency int `yaml:"health_check_concurrency" mapstructure:"health_check_concurrency"`
+
+	// DefaultForUnmatchedModels sends models that match no provider's models
+	// list to the default (highest priority) provider instead of failing
+	DefaultForUnmatchedModels bool `yaml:"default_for_unmatched_models" mapstructure:"default_for_unmatched_models"`
+
+	// RoutingRules send requests to a provider by their metadata; the first
+	// matching rule wins and unmatched requests are routed by model
+	RoutingRules []RoutingRule `yaml:"routing_rules,omitempty" mapstructure:"routing_rules"`
+
+	// Sampling parameters applied when the client omits them. ModelDefaults
+	// overrides them per model, keyed by client or backend model name.
+	DefaultTemperature *float64                    `yaml:"default_temperature,omitempty" mapstructure:"default_temperature"`
+	DefaultTopP        *float64                    `yaml:"default_top_p,omitempty" mapstructure:"default_top_p"`
+	ModelDefaults      map[string]SamplingDefaults `yaml:"model_defaults,omitempty" mapstructure:"model_defaults"`
+
+	// ContextBudget is the estimated input token limit enforced by the
+	// truncation request parameter. 0 disables the check.
+	ContextBudget int `yaml:"context_budget,omitempty" mapstructure:"context_budget"`
+
+	// Pricing holds token prices for cost accounting, keyed by client or
+	// backend model name. Models without a price are not costed.
+	Pricing map[string]ModelPricing `yaml:"pricing,omitempty" mapstructure:"pricing"`
+
+	// DegradedResponseJitter is the maximum random delay added before
+	// returning a retryable backend error while the backend is degraded
+	// (several consecutive failures), spreading out client retries. 0 disables.
+	DegradedResponseJitter time.Duration `yaml:"degraded_response_jitter,omitempty" mapstructure:"degraded_response_jitter"`
+
+	// EnforceToolChoice decides what happens when tool_choice is "required"
+	// but the backend answers with text: off returns the text, retry asks
+	// again once with a stronger instruction, error fails the request
+	EnforceToolChoice string `yaml:"enforce_tool_choice,omitempty" mapstructure:"enforce_tool_choice"` // off | retry | error
+
+	// PassthroughAuth forwards each client's Authorization header to the
+	// backend unchanged instead of the configured API key, for deployments
+	// where clients bring their own backend keys
+	PassthroughAuth bool `yaml:"passthrough_auth,omitempty" mapstructure:"passthrough_auth"`
+
+	// FallbackResponse replaces the error when the backend can't be reached
+	// or keeps failing after retries, for clients that can't handle errors
+	FallbackResponse FallbackResponseConfig `yaml:"fallback_response" mapstructure:"fallback_response"`
+}
+
+// FallbackResponseConfig is a canned assistant reply returned, with Status,
+// in place of a backend failure. Client errors from the backend are still
+// returned as is.
+type FallbackResponseConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Message string `yaml:"message" mapstructure:"message"`
+	Status  int    `yaml:"status" mapstructure:"status"` // HTTP status of the canned response
+}
+
+// Modes for providers.enforce_tool_choice
+const (
+	EnforceToolChoiceOff   = "off"
+	EnforceToolChoiceRetry = "retry"
+	EnforceToolChoiceError = "error"
+)
+
+// RoutingRule routes requests whose metadata has every key and value in
+// Metadata to Provider
+type RoutingRule struct {
+	Metadata map[string]string `yaml:"metadata" mapstructure:"metadata"`
+	Provider string            `yaml:"provider" mapstructure:"provider"`
+}
+
+// ModelPricing is a model's token price in USD per million tokens
+type ModelPricing struct {
+	Input  float64 `yaml:"input" mapstructure:"input"`
+	Output float64 `yaml:"output" mapstructure:"output"`
+}
+
+// Cost returns the USD cost of a request's token usage
+func (p ModelPricing) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// PricingFor returns the price of the first of models that has one
+func (pc *ProvidersConfig) PricingFor(models ...string) (ModelPricing, bool) {
+	for _, model := range models {
+		if pricing, ok := pc.Pricing[model]; ok {
+			return pricing, true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// SamplingDefaults are per-model sampling parameters used when the client omits them
+type SamplingDefaults struct {
+	Temperature *float64 `yaml:"temperature,omitempty" mapstructure:"temperature"`
+	TopP        *float64 `yaml:"top_p,omitempty" mapstructure:"top_p"`
 }
 
+// SamplingDefaultsFor returns the default temperature and top_p for a model,
+// preferring a per-model override over the global default. Either may be nil.
+func (pc *ProvidersConfig) SamplingDefaultsFor(models ...string) (temperature, topP *float64) {
+	temperature, topP = pc.DefaultTemperature, pc.DefaultTopP
+	for _, model := range models {
+		if defaults, ok := pc.ModelDefaults[model]; ok {
+			if defaults.Temperature != nil {
+				temperature = defaults.Temperature
+			}
+			if defaults.TopP != nil {
+				topP = defaults.TopP
+			}
+			break
+		}
+	}
+	return temperature, topP
+}
+
+// DefaultMaxResponseBytes caps a backend response body when a provider sets no
+// max_response_bytes, so a misbehaving backend can't exhaust memory
+const DefaultMaxResponseBytes = 32 << 20
+
 // ProviderConfig contains provider-specific configuration
 type ProviderConfig struct {
 	Enabled     bool              `yaml:"enabled" mapstructure:"enabled"`
 	Type        string            `yaml:"type" mapstructure:"type"`
+	Plan        string            `yaml:"plan,omitempty" mapstructure:"plan"` // z.ai only: coding | standard
 	Priority    int               `yaml:"priority" mapstructure:"priority"`
 	BaseURL     string            `yaml:"base_url" mapstructure:"base_url"`
+	BaseURLs    []string          `yaml:"base_urls,omitempty" mapstructure:"base_urls"` // Warm standby endpoints tried after base_url on connection failure
 	APIKey      string            `yaml:"api_key" mapstructure:"api_key"`
 	Timeout     time.Duration     `yaml:"timeout" mapstructure:"timeout"`
 	MaxRetries  int               `yaml:"max_retries" mapstructure:"max_retries"`
 	RetryDelay  time.Duration     `yaml:"retry_delay" mapstructure:"retry_delay"`
-	Models      []string          `yaml:"models" mapstructure:"models"`
-	HealthCheck HealthCheckConfig `yaml:"health_check" mapstructure:"health_check"`
+	RetryMaxElapsed time.Duration `yaml:"retry_max_elapsed,omitempty" mapstructure:"retry_max_elapsed"` // Wall-clock budget across all attempts; 0 is unbounded
+	MaxResponseBytes int64       `yaml:"max_response_bytes,omitempty" mapstructure:"max_response_bytes"` // Largest backend response body read; 0 uses DefaultMaxResponseBytes
+	Models       []string           `yaml:"models" mapstructure:"models"`
+	HealthCheck  HealthCheckConfig  `yaml:"health_check" mapstructure:"health_check"`
+	Capabilities CapabilitiesConfig `yaml:"capabilities,omitempty" mapstructure:"capabilities"`
+
+	// CumulativeToolCallDeltas declares that the backend resends a tool
+	// call's whole id, name and arguments so far on every stream chunk
+	// instead of only the next piece
+	CumulativeToolCallDeltas bool `yaml:"cumulative_tool_call_deltas,omitempty" mapstructure:"cumulative_tool_call_deltas"`
+
+	// Outbound proxy for this provider; unset fields fall back to the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	HTTPProxy  string `yaml:"http_proxy,omitempty" mapstructure:"http_proxy"`
+	HTTPSProxy string `yaml:"https_proxy,omitempty" mapstructure:"https_proxy"`
+	NoProxy    string `yaml:"no_proxy,omitempty" mapstructure:"no_proxy"`
+
+	// InstructionsTemplate overrides codex.instructions_template for this provider
+	InstructionsTemplate string `yaml:"instructions_template,omitempty" mapstructure:"instructions_template"`
+
+	// Azure OpenAI only: the resource and the deployments serving each model
+	Azure AzureConfig `yaml:"azure,omitempty" mapstructure:"azure"`
+
+	// FieldMapping renames JSON fields for backends that deviate from the
+	// Chat Completions field names
+	FieldMapping FieldMapping `yaml:"field_mapping,omitempty" mapstructure:"field_mapping"`
+}
+
+// FieldMapping renames fields of the JSON sent to and received from a
+// backend. Keys are dotted paths such as "usage.prompt_tokens"; arrays along
+// the path are descended element by element. Values are the new field name,
+// which stays under the same parent.
+type FieldMapping struct {
+	Request  map[string]string `yaml:"request,omitempty" mapstructure:"request"`   // Chat Completions name -> backend name
+	Response map[string]string `yaml:"response,omitempty" mapstructure:"response"` // Backend name -> Chat Completions name
+}
+
+// AzureConfig locates an Azure OpenAI resource. Requests go to the deployment
+// mapped from the model name, or to Deployment when the model has none.
+type AzureConfig struct {
+	Resource    string            `yaml:"resource" mapstructure:"resource"` // https://{resource}.openai.azure.com unless base_url is set
+	Deployment  string            `yaml:"deployment" mapstructure:"deployment"`
+	APIVersion  string            `yaml:"api_version" mapstructure:"api_version"`
+	Deployments map[string]string `yaml:"deployments,omitempty" mapstructure:"deployments"` // model -> deployment
+}
+
+// MockConfig configures the canned responses served in test mode. When enabled,
+// requests never leave the router and no API key is required.
+type MockConfig struct {
+	Enabled   bool           `yaml:"enabled" mapstructure:"enabled"`
+	Text      string         `yaml:"text" mapstructure:"text"`
+	ToolCalls []MockToolCall `yaml:"tool_calls" mapstructure:"tool_calls"`
+	Latency   time.Duration  `yaml:"latency" mapstructure:"latency"` // Simulated backend latency
+}
+
+// MockToolCall is a canned tool call returned in test mode
+type MockToolCall struct {
+	Name      string `yaml:"name" mapstructure:"name"`
+	Arguments string `yaml:"arguments" mapstructure:"arguments"` // JSON-encoded arguments
+}
+
+// CapabilitiesConfig declares which request features a provider's backend
+// supports. Unset settings take the provider type's defaults, so an explicit
+// false is told apart from a missing one.
+type CapabilitiesConfig struct {
+	SupportsStreaming *bool `yaml:"supports_streaming,omitempty" mapstructure:"supports_streaming"`
+	SupportsTools     *bool `yaml:"supports_tools,omitempty" mapstructure:"supports_tools"`
+	SupportsVision    *bool `yaml:"supports_vision,omitempty" mapstructure:"supports_vision"`
+	SupportsReasoning *bool `yaml:"supports_reasoning,omitempty" mapstructure:"supports_reasoning"`
+	SupportsLogitBias *bool `yaml:"supports_logit_bias,omitempty" mapstructure:"supports_logit_bias"`
+
+	// OpenAI request identifiers, forwarded as is when supported
+	SupportsPromptCacheKey   *bool `yaml:"supports_prompt_cache_key,omitempty" mapstructure:"supports_prompt_cache_key"`
+	SupportsSafetyIdentifier *bool `yaml:"supports_safety_identifier,omitempty" mapstructure:"supports_safety_identifier"`
 }
 
 // HealthCheckConfig for provider health monitoring
@@ -34,6 +233,10 @@ type HealthCheckConfig struct {
 	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
 	Timeout  time.Duration `yaml:"timeout" mapstructure:"timeout"`
 	Endpoint string        `yaml:"endpoint" mapstructure:"endpoint"`
+
+	// Authenticated sends the API key with the probe (to base_url + /models
+	// unless endpoint is set) and marks the provider auth_failed on 401 or 403
+	Authenticated bool `yaml:"authenticated,omitempty" mapstructure:"authenticated"`
 }
 
 // FallbackConfig for provider failover
@@ -41,6 +244,15 @@ type FallbackConfig struct {
 	Enabled    bool          `yaml:"enabled" mapstructure:"enabled"`
 	Timeout    time.Duration `yaml:"timeout" mapstructure:"timeout"`
 	RetryCount int           `yaml:"retry_count" mapstructure:"retry_count"`
+	Parallel   int           `yaml:"parallel" mapstructure:"parallel"` // Fallback providers health-probed at once; 0 probes them one at a time
+}
+
+// ColdStartConfig probes providers concurrently when routing a model before
+// their health is known, picking the highest priority healthy one
+type ColdStartConfig struct {
+	Enabled   bool          `yaml:"enabled" mapstructure:"enabled"`
+	Providers []string      `yaml:"providers,omitempty" mapstructure:"providers"` // Providers probed; empty probes all supporting the model
+	Timeout   time.Duration `yaml:"timeout" mapstructure:"timeout"`                // Bound on each probe
 }
 
 // GetProviders returns all providers as a map for compatibility
@@ -55,6 +267,9 @@ func (pc *ProvidersConfig) GetProviders() map[string]ProviderConfig {
 	if pc.Anthropic.Enabled || pc.Anthropic.APIKey != "" {
 		providers["anthropic"] = pc.Anthropic
 	}
+	if pc.Azure.Enabled || pc.Azure.APIKey != "" {
+		providers["azure"] = pc.Azure
+	}
 	return providers
 }
 
@@ -67,6 +282,8 @@ func (pc *ProvidersConfig) SetProvider(name string, config ProviderConfig) {
 		pc.OpenAI = config
 	case "anthropic":
 		pc.Anthropic = config
+	case "azure":
+		pc.Azure = config
 	}
 }
 
@@ -77,7 +294,7 @@ func DefaultProvidersConfig() ProvidersConfig {
 			Enabled:    true,
 			Type:       "zai",
 			Priority:   1,
-			BaseURL:    "https://api.z.ai/api/coding/paas/v4", // Coding Plan endpoint
+			Plan:       "", // inherits zai.plan; base_url is derived from the plan unless set
 			Timeout:    120 * time.Second,
 			MaxRetries: 3,
 			RetryDelay: 1 * time.Second,
@@ -118,7 +335,15 @@ func DefaultProvidersConfig() ProvidersConfig {
 				Timeout:  10 * time.Second,
 			},
 		},
-		ProviderStrategy: "priority",
+		ProviderStrategy:       "priority",
+		EnforceToolChoice:      EnforceToolChoiceOff,
+		HealthCheckConcurrency: 4,
+		FallbackResponse: FallbackResponseConfig{
+			Message: "The service is temporarily unavailable. Please try again in a few minutes.",
+			Status:  200,
+		},
+
+		DefaultForUnmatchedModels: true,
 		Fallback: FallbackConfig{
 			Enabled:    true,
 			Timeout:    30 * time.Second,
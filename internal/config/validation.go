@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a validation problem with a single setting
+type FieldError struct {
+	Path string // Dotted path of the offending setting, e.g. "server.port"
+	Err  error
+}
+
+// Error returns the problem description; the path is reported separately
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects every problem found by Config.Validate, in the
+// order the settings are checked
+type ValidationErrors []*FieldError
+
+// Error joins all problems into one line
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual problems for errors.Is and errors.As
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// add records a problem with the setting at path
+func (e *ValidationErrors) add(path string, format string, args ...interface{}) {
+	e.addErr(path, fmt.Errorf(format, args...))
+}
+
+// addErr records err against the setting at path
+func (e *ValidationErrors) addErr(path string, err error) {
+	*e = append(*e, &FieldError{Path: path, Err: err})
+}
+
+// err returns the collected problems, or nil if there are none
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
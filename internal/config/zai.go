@@ -0,0 +1,53 @@
+package config
+
+import "fmt"
+
+// z.ai exposes the same Chat Completions API under two plans:
+//   - coding:   the GLM Coding Plan subscription, billed per plan quota
+//   - standard: the pay-as-you-go open platform, billed per token
+//
+// An API key only works against the endpoint of the plan it was issued for.
+const (
+	ZaiPlanCoding   = "coding"
+	ZaiPlanStandard = "standard"
+
+	ZaiCodingBaseURL   = "https://api.z.ai/api/coding/paas/v4"
+	ZaiStandardBaseURL = "https://api.z.ai/api/paas/v4"
+)
+
+// ZaiBaseURL returns the z.ai base URL for a plan. An empty plan selects the coding plan.
+func ZaiBaseURL(plan string) (string, error) {
+	switch plan {
+	case "", ZaiPlanCoding:
+		return ZaiCodingBaseURL, nil
+	case ZaiPlanStandard:
+		return ZaiStandardBaseURL, nil
+	default:
+		return "", fmt.Errorf("invalid z.ai plan: %s (must be '%s' or '%s')", plan, ZaiPlanCoding, ZaiPlanStandard)
+	}
+}
+
+// ApplyZaiPlan fills in z.ai base URLs that were not set explicitly from the configured plan
+func (c *Config) ApplyZaiPlan() error {
+	if c.Zai.BaseURL == "" {
+		baseURL, err := ZaiBaseURL(c.Zai.Plan)
+		if err != nil {
+			return err
+		}
+		c.Zai.BaseURL = baseURL
+	}
+
+	if c.Providers.Zai.BaseURL == "" {
+		plan := c.Providers.Zai.Plan
+		if plan == "" {
+			plan = c.Zai.Plan
+		}
+		baseURL, err := ZaiBaseURL(plan)
+		if err != nil {
+			return err
+		}
+		c.Providers.Zai.BaseURL = baseURL
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import "testing"
+
+func TestApplyZaiPlan(t *testing.T) {
+	tests := []struct {
+		name         string
+		plan         string
+		providerPlan string
+		baseURL      string // Explicit providers.zai.base_url
+		want         string
+		wantErr      bool
+	}{
+		{"default plan", "", "", "", ZaiCodingBaseURL, false},
+		{"coding plan", ZaiPlanCoding, "", "", ZaiCodingBaseURL, false},
+		{"standard plan", ZaiPlanStandard, "", "", ZaiStandardBaseURL, false},
+		{"provider plan overrides zai.plan", ZaiPlanCoding, ZaiPlanStandard, "", ZaiStandardBaseURL, false},
+		{"explicit base_url wins", ZaiPlanStandard, "", "https://proxy.example.com/v4", "https://proxy.example.com/v4", false},
+		{"unknown plan", "enterprise", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Zai.Plan = tt.plan
+			cfg.Providers.Zai.Plan = tt.providerPlan
+			cfg.Providers.Zai.BaseURL = tt.baseURL
+
+			err := cfg.ApplyZaiPlan()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ApplyZaiPlan() succeeded, want an error for plan %q", tt.plan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyZaiPlan() error = %v", err)
+			}
+			if cfg.Providers.Zai.BaseURL != tt.want {
+				t.Errorf("providers.zai.base_url = %s, want %s", cfg.Providers.Zai.BaseURL, tt.want)
+			}
+		})
+	}
+}
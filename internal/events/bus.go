@@ -0,0 +1,73 @@
+// Package events is a lightweight in-process bus for request lifecycle
+// events. The proxy publishes as a request moves through the router and
+// observability hooks such as metrics subscribe, keeping them off the
+// request path.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies a stage in a request's lifecycle
+type Type string
+
+const (
+	RequestReceived   Type = "request.received"
+	BackendDispatched Type = "backend.dispatched"
+	FirstToken        Type = "first_token" // First bytes of the backend response
+	RequestCompleted  Type = "request.completed"
+	RequestFailed     Type = "request.failed"
+)
+
+// Event is one lifecycle event. Every request publishes RequestReceived first
+// and ends with exactly one of RequestCompleted or RequestFailed.
+type Event struct {
+	Type      Type
+	RequestID string
+	Time      time.Time
+	Elapsed   time.Duration // Since RequestReceived
+	Model     string        // Client model, once the request is parsed
+	Tenant    string        // Tenant label when metrics.tenants is set
+	Stream    bool
+	Status    int // HTTP status, on RequestCompleted and RequestFailed
+}
+
+// Subscriber consumes events. Subscribers run synchronously on the
+// publishing goroutine, so they see a request's events in order and must
+// not block.
+type Subscriber func(Event)
+
+// Bus fans published events out to its subscribers. A nil Bus discards
+// events.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+// NewBus creates a bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a subscriber for all subsequent events
+func (b *Bus) Subscribe(s Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+}
+
+// Publish delivers an event to every subscriber in subscription order
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, s := range subscribers {
+		s(e)
+	}
+}
@@ -0,0 +1,29 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	bus.Subscribe(func(e Event) { got = append(got, "first:"+string(e.Type)) })
+	bus.Subscribe(func(e Event) { got = append(got, "second:"+string(e.Type)) })
+
+	bus.Publish(Event{Type: RequestReceived})
+	bus.Publish(Event{Type: RequestCompleted})
+
+	want := []string{
+		"first:request.received", "second:request.received",
+		"first:request.completed", "second:request.completed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+func TestNilBusDiscards(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Event{Type: RequestReceived})
+}
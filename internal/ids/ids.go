@@ -0,0 +1,34 @@
+// Package ids generates the identifiers the router assigns to responses,
+// output items, tool calls and requests. The generator is injected into the
+// proxy handler and providers so tests can substitute a deterministic one.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Generator returns new identifiers
+type Generator interface {
+	// NewID returns a unique identifier starting with prefix, e.g. "resp_"
+	NewID(prefix string) string
+}
+
+// Default is the generator used unless another is injected
+var Default Generator = UUID{}
+
+// UUID generates a random (version 4) UUID after the prefix, written as 32
+// hex digits without dashes
+type UUID struct{}
+
+// NewID returns prefix followed by a new random UUID
+func (UUID) NewID(prefix string) string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand doesn't fail on supported platforms
+		panic("ids: failed to read random bytes: " + err.Error())
+	}
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return prefix + hex.EncodeToString(b[:])
+}
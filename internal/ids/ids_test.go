@@ -0,0 +1,30 @@
+package ids
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestUUID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := UUID{}.NewID("resp_")
+		if !strings.HasPrefix(id, "resp_") {
+			t.Fatalf("NewID() = %q, want prefix resp_", id)
+		}
+
+		b, err := hex.DecodeString(strings.TrimPrefix(id, "resp_"))
+		if err != nil || len(b) != 16 {
+			t.Fatalf("NewID() = %q, want 32 hex digits after the prefix", id)
+		}
+		if b[6]>>4 != 4 || b[8]>>6 != 2 {
+			t.Fatalf("NewID() = %q, not a version 4 UUID", id)
+		}
+
+		if seen[id] {
+			t.Fatalf("NewID() repeated %q", id)
+		}
+		seen[id] = true
+	}
+}
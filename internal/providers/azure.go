@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used when none is configured
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureConfig locates an Azure OpenAI resource and its model deployments
+type AzureConfig struct {
+	Resource    string            // Resource name, giving https://{resource}.openai.azure.com
+	Deployment  string            // Deployment used for models without their own
+	APIVersion  string            // api-version query parameter
+	Deployments map[string]string // model -> deployment
+}
+
+// DeploymentFor returns the deployment serving a model
+func (c AzureConfig) DeploymentFor(model string) (string, error) {
+	if deployment, ok := c.Deployments[model]; ok && deployment != "" {
+		return deployment, nil
+	}
+	if c.Deployment != "" {
+		return c.Deployment, nil
+	}
+	return "", fmt.Errorf("no Azure deployment configured for model %s", model)
+}
+
+// AzureProvider implements Provider for Azure OpenAI. Requests are the same as
+// OpenAI's but address a deployment rather than a model, and authenticate
+// with an api-key header instead of a Bearer token.
+type AzureProvider struct {
+	*OpenAIProvider
+}
+
+// NewAzureProvider creates a new Azure OpenAI provider
+func NewAzureProvider() *AzureProvider {
+	p := &AzureProvider{
+		OpenAIProvider: &OpenAIProvider{
+			BaseProvider: NewBaseProvider("azure"),
+		},
+	}
+	p.newChatRequest = p.chatRequest
+	return p
+}
+
+// Initialize initializes the Azure provider. The base URL is derived from the
+// resource name unless set, and models default to those with a deployment,
+// or any model when a default deployment is configured.
+func (p *AzureProvider) Initialize(config ProviderConfig) error {
+	if config.BaseURL == "" {
+		if config.Azure.Resource == "" {
+			return fmt.Errorf("azure provider requires a resource or base_url")
+		}
+		config.BaseURL = fmt.Sprintf("https://%s.openai.azure.com", config.Azure.Resource)
+	}
+	if config.Azure.Deployment == "" && len(config.Azure.Deployments) == 0 {
+		return fmt.Errorf("azure provider requires a deployment or deployments")
+	}
+	if config.Azure.APIVersion == "" {
+		config.Azure.APIVersion = DefaultAzureAPIVersion
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 120 * time.Second
+	}
+	if len(config.Models) == 0 {
+		if config.Azure.Deployment != "" {
+			config.Models = []string{"*"}
+		} else {
+			for model := range config.Azure.Deployments {
+				config.Models = append(config.Models, model)
+			}
+			sort.Strings(config.Models)
+		}
+	}
+
+	return p.BaseProvider.Initialize(config)
+}
+
+// AzureChatURL returns the Chat Completions URL of the deployment serving a
+// model: {base_url}/openai/deployments/{deployment}/chat/completions?api-version=...
+func AzureChatURL(config ProviderConfig, model string) (string, error) {
+	deployment, err := config.Azure.DeploymentFor(model)
+	if err != nil {
+		return "", err
+	}
+
+	apiVersion := config.Azure.APIVersion
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		strings.TrimSuffix(config.BaseURL, "/"),
+		url.PathEscape(deployment),
+		url.QueryEscape(apiVersion),
+	), nil
+}
+
+// chatRequest builds a request to the model's deployment with an api-key header
+func (p *AzureProvider) chatRequest(ctx context.Context, config ProviderConfig, model string, body []byte) (*http.Request, error) {
+	chatURL, err := AzureChatURL(config, model)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", chatURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("api-key", config.APIKey)
+	return httpReq, nil
+}
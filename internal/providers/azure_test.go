@@ -0,0 +1,127 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureChatURL(t *testing.T) {
+	azure := AzureConfig{
+		Deployment:  "default-deploy",
+		APIVersion:  "2024-06-01",
+		Deployments: map[string]string{"gpt-4o": "gpt4o-prod"},
+	}
+
+	tests := []struct {
+		name    string
+		baseURL string
+		azure   AzureConfig
+		model   string
+		want    string
+		wantErr bool
+	}{
+		{"mapped deployment", "https://res.openai.azure.com", azure, "gpt-4o", "https://res.openai.azure.com/openai/deployments/gpt4o-prod/chat/completions?api-version=2024-06-01", false},
+		{"default deployment", "https://res.openai.azure.com/", azure, "gpt-4", "https://res.openai.azure.com/openai/deployments/default-deploy/chat/completions?api-version=2024-06-01", false},
+		{"default api version", "https://res.openai.azure.com", AzureConfig{Deployment: "d"}, "gpt-4", "https://res.openai.azure.com/openai/deployments/d/chat/completions?api-version=" + DefaultAzureAPIVersion, false},
+		{"deployment escaped", "https://res.openai.azure.com", AzureConfig{Deployment: "my deploy"}, "gpt-4", "https://res.openai.azure.com/openai/deployments/my%20deploy/chat/completions?api-version=" + DefaultAzureAPIVersion, false},
+		{"no deployment", "https://res.openai.azure.com", AzureConfig{Deployments: map[string]string{"gpt-4o": "x"}}, "gpt-4", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AzureChatURL(ProviderConfig{BaseURL: tt.baseURL, Azure: tt.azure}, tt.model)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AzureChatURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AzureChatURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAzureInitialize(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      ProviderConfig
+		wantErr     bool
+		wantBaseURL string
+		wantModels  []string
+	}{
+		{"base URL from resource", ProviderConfig{Azure: AzureConfig{Resource: "res", Deployment: "d"}}, false, "https://res.openai.azure.com", []string{"*"}},
+		{"models from deployments", ProviderConfig{Azure: AzureConfig{Resource: "res", Deployments: map[string]string{"b": "1", "a": "2"}}}, false, "https://res.openai.azure.com", []string{"a", "b"}},
+		{"explicit base URL", ProviderConfig{BaseURL: "https://proxy.example", Azure: AzureConfig{Deployment: "d"}}, false, "https://proxy.example", []string{"*"}},
+		{"no resource", ProviderConfig{Azure: AzureConfig{Deployment: "d"}}, true, "", nil},
+		{"no deployment", ProviderConfig{Azure: AzureConfig{Resource: "res"}}, true, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewAzureProvider()
+			tt.config.Type = ProviderTypeAzure
+			err := p.Initialize(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Initialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			config := p.GetConfig()
+			if config.BaseURL != tt.wantBaseURL {
+				t.Errorf("base URL = %s, want %s", config.BaseURL, tt.wantBaseURL)
+			}
+			if len(config.Models) != len(tt.wantModels) {
+				t.Fatalf("models = %v, want %v", config.Models, tt.wantModels)
+			}
+			for i := range tt.wantModels {
+				if config.Models[i] != tt.wantModels[i] {
+					t.Errorf("models = %v, want %v", config.Models, tt.wantModels)
+				}
+			}
+			if config.Azure.APIVersion != DefaultAzureAPIVersion {
+				t.Errorf("api version = %s, want %s", config.Azure.APIVersion, DefaultAzureAPIVersion)
+			}
+		})
+	}
+}
+
+func TestAzureExecuteAuth(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		io.WriteString(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewAzureProvider()
+	err := p.Initialize(ProviderConfig{
+		Name:    "azure",
+		Type:    ProviderTypeAzure,
+		BaseURL: server.URL,
+		APIKey:  "azure-key",
+		Azure:   AzureConfig{Deployment: "default-deploy", Deployments: map[string]string{"gpt-4o": "gpt4o-prod"}},
+	})
+	if err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	if _, err := p.Execute(context.Background(), map[string]interface{}{"model": "gpt-4o"}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got.URL.Path != "/openai/deployments/gpt4o-prod/chat/completions" {
+		t.Errorf("path = %s", got.URL.Path)
+	}
+	if v := got.URL.Query().Get("api-version"); v != DefaultAzureAPIVersion {
+		t.Errorf("api-version = %s, want %s", v, DefaultAzureAPIVersion)
+	}
+	if key := got.Header.Get("api-key"); key != "azure-key" {
+		t.Errorf("api-key header = %q, want azure-key", key)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "" {
+		t.Errorf("Authorization header = %q, want none", auth)
+	}
+}
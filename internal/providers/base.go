@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
@@ -9,6 +10,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/ids"
 )
 
 // BaseProvider provides common functionality for all providers
@@ -18,15 +21,28 @@ type BaseProvider struct {
 	client  *http.Client
 	metrics ProviderMetrics
 	mu      sync.RWMutex
+
+	// endpoints are tried in turn on connection failure
+	endpoints *Endpoints
+
+	// ids generates response and tool call IDs; nil uses ids.Default
+	ids ids.Generator
+
+	// closing is cancelled by ForceClose to abort in-flight requests
+	closing    context.Context
+	forceClose context.CancelFunc
 }
 
 // NewBaseProvider creates a new base provider
 func NewBaseProvider(name string) *BaseProvider {
+	closing, forceClose := context.WithCancel(context.Background())
 	return &BaseProvider{
 		name: name,
 		metrics: ProviderMetrics{
 			HealthStatus: HealthStateHealthy,
 		},
+		closing:    closing,
+		forceClose: forceClose,
 	}
 }
 
@@ -35,12 +51,19 @@ func (p *BaseProvider) Initialize(config ProviderConfig) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	// Fall back to per-type capabilities when none are configured
+	if config.Capabilities == (Capabilities{}) {
+		config.Capabilities = DefaultCapabilities(config.Type)
+	}
+
 	p.config = config
+	p.endpoints = NewEndpoints(config)
 
 	// Create HTTP client
 	p.client = &http.Client{
 		Timeout: config.Timeout,
 		Transport: &http.Transport{
+			Proxy:               config.Proxy.ProxyFunc(),
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     90 * time.Second,
@@ -62,6 +85,48 @@ func (p *BaseProvider) Shutdown() error {
 	return nil
 }
 
+// ForceClose aborts the provider's in-flight requests, releasing a Shutdown
+// that is blocked behind them. The provider must not be used afterwards.
+func (p *BaseProvider) ForceClose() {
+	if p.forceClose != nil {
+		p.forceClose()
+	}
+}
+
+// requestContext derives a backend request's context from ctx that is also
+// cancelled by ForceClose. The returned stop func must be called once the
+// request is done.
+func (p *BaseProvider) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if p.closing == nil {
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(p.closing, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// SetIDGenerator replaces the generator of the IDs the provider assigns
+func (p *BaseProvider) SetIDGenerator(g ids.Generator) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids = g
+}
+
+// newID returns a new ID with the given prefix
+func (p *BaseProvider) newID(prefix string) string {
+	p.mu.RLock()
+	g := p.ids
+	p.mu.RUnlock()
+
+	if g == nil {
+		return ids.Default.NewID(prefix)
+	}
+	return g.NewID(prefix)
+}
+
 // Name returns the provider name
 func (p *BaseProvider) Name() string {
 	return p.name
@@ -80,27 +145,33 @@ func (p *BaseProvider) SupportsModel(model string) bool {
 	defer p.mu.RUnlock()
 
 	for _, pattern := range p.config.Models {
-		// Support wildcard patterns
-		matched, err := filepath.Match(pattern, model)
-		if err == nil && matched {
+		if MatchModelPattern(pattern, model) {
 			return true
 		}
+	}
 
-		// Support prefix matching
-		if strings.HasSuffix(pattern, "-*") {
-			prefix := strings.TrimSuffix(pattern, "*")
-			if strings.HasPrefix(model, prefix) {
-				return true
-			}
-		}
+	return false
+}
+
+// MatchModelPattern reports whether a model matches one entry of a provider's
+// models list: a glob, a "name-*" prefix or an exact name
+func MatchModelPattern(pattern, model string) bool {
+	// Support wildcard patterns
+	matched, err := filepath.Match(pattern, model)
+	if err == nil && matched {
+		return true
+	}
 
-		// Exact match
-		if pattern == model {
+	// Support prefix matching
+	if strings.HasSuffix(pattern, "-*") {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if strings.HasPrefix(model, prefix) {
 			return true
 		}
 	}
 
-	return false
+	// Exact match
+	return pattern == model
 }
 
 // GetModels returns list of supported models
@@ -115,60 +186,140 @@ func (p *BaseProvider) GetModels() []string {
 
 // SupportsStreaming returns whether streaming is supported
 func (p *BaseProvider) SupportsStreaming() bool {
-	return true // Most providers support streaming
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Capabilities.Streaming
 }
 
 // SupportsTools returns whether tool calling is supported
 func (p *BaseProvider) SupportsTools() bool {
-	return true // Most providers support tools
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Capabilities.Tools
+}
+
+// SupportsVision returns whether image input is supported
+func (p *BaseProvider) SupportsVision() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Capabilities.Vision
 }
 
-// HealthCheck performs a health check
+// SupportsReasoning returns whether reasoning output is supported
+func (p *BaseProvider) SupportsReasoning() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Capabilities.Reasoning
+}
+
+// SupportsLogitBias returns whether the logit_bias parameter is supported
+func (p *BaseProvider) SupportsLogitBias() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Capabilities.LogitBias
+}
+
+// SupportsPromptCacheKey returns whether the prompt_cache_key parameter is supported
+func (p *BaseProvider) SupportsPromptCacheKey() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Capabilities.PromptCacheKey
+}
+
+// SupportsSafetyIdentifier returns whether the safety_identifier parameter is supported
+func (p *BaseProvider) SupportsSafetyIdentifier() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Capabilities.SafetyIdentifier
+}
+
+// HealthCheck performs a health check. The provider isn't locked while the
+// request runs, so a hanging backend doesn't hold up requests or Shutdown;
+// cancelling ctx aborts it. A probe cancelled by the caller, such as one
+// outrun by another provider's, says nothing about the backend and is not
+// recorded; one that runs out of time is.
 func (p *BaseProvider) HealthCheck(ctx context.Context) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	// Update last check time
+	previousCheck := p.metrics.LastHealthCheck
 	p.metrics.LastHealthCheck = time.Now()
+	client := p.client
+	config := p.config
+	p.mu.Unlock()
 
 	// Simple health check - try to connect to base URL
-	if p.client == nil {
-		p.metrics.HealthStatus = HealthStateUnhealthy
+	if client == nil {
+		p.setHealth(HealthStateUnhealthy, false, 0)
 		return fmt.Errorf("client not initialized")
 	}
 
-	// Create a simple GET request to health endpoint
-	healthURL := p.config.BaseURL
-	if p.config.HealthCheck.Endpoint != "" {
-		healthURL = p.config.HealthCheck.Endpoint
+	// Create a simple GET request to health endpoint. Authenticated probes
+	// list models, which needs a valid key but costs nothing.
+	healthURL := config.BaseURL
+	if config.HealthCheck.Authenticated {
+		healthURL = strings.TrimSuffix(config.BaseURL, "/") + "/models"
 	}
+	if config.HealthCheck.Endpoint != "" {
+		healthURL = config.HealthCheck.Endpoint
+	}
+
+	callerCtx := ctx
+	ctx, stop := p.requestContext(ctx)
+	defer stop()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
 	if err != nil {
-		p.metrics.HealthStatus = HealthStateUnhealthy
+		p.setHealth(HealthStateUnhealthy, false, 0)
 		return fmt.Errorf("failed to create health check request: %w", err)
 	}
+	if config.HealthCheck.Authenticated {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
 
 	start := time.Now()
-	resp, err := p.client.Do(req)
+	resp, err := client.Do(req)
 	latency := time.Since(start)
 
 	if err != nil {
-		p.metrics.HealthStatus = HealthStateUnhealthy
-		p.metrics.ConsecutiveFail++
+		if errors.Is(callerCtx.Err(), context.Canceled) {
+			p.mu.Lock()
+			p.metrics.LastHealthCheck = previousCheck
+			p.mu.Unlock()
+			return fmt.Errorf("health check cancelled: %w", err)
+		}
+		p.setHealth(HealthStateUnhealthy, true, 0)
 		return fmt.Errorf("health check failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if config.HealthCheck.Authenticated && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		p.setHealth(HealthStateAuthFailed, true, 0)
+		return fmt.Errorf("health check failed: API key rejected with status %d", resp.StatusCode)
+	}
+
 	// Update metrics
-	p.metrics.ConsecutiveFail = 0
-	p.metrics.HealthStatus = HealthStateHealthy
-	p.metrics.LastHealthCheck = time.Now()
-	p.metrics.AverageLatency = latency
+	p.setHealth(HealthStateHealthy, false, latency)
 
 	return nil
 }
 
+// setHealth records a health check outcome. A failed check counts towards
+// ConsecutiveFail when counted is set; a healthy one resets it and records
+// its latency.
+func (p *BaseProvider) setHealth(state HealthState, counted bool, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.metrics.HealthStatus = state
+	if state == HealthStateHealthy {
+		p.metrics.ConsecutiveFail = 0
+		p.metrics.LastHealthCheck = time.Now()
+		p.metrics.AverageLatency = latency
+	} else if counted {
+		p.metrics.ConsecutiveFail++
+	}
+}
+
 // GetMetrics returns provider metrics
 func (p *BaseProvider) GetMetrics() ProviderMetrics {
 	p.mu.RLock()
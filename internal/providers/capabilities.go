@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// Capabilities describes the request features a provider supports
+type Capabilities struct {
+	Streaming bool
+	Tools     bool
+	Vision    bool
+	Reasoning bool
+	LogitBias bool
+
+	PromptCacheKey   bool
+	SafetyIdentifier bool
+}
+
+// DefaultCapabilities returns the capabilities assumed for a provider type
+// when none are configured. It is the one table of defaults: configured
+// capabilities are resolved against it by CapabilitiesFromConfig.
+func DefaultCapabilities(providerType ProviderType) Capabilities {
+	switch providerType {
+	case ProviderTypeZai:
+		return Capabilities{Streaming: true, Tools: true, Vision: true, Reasoning: true}
+	case ProviderTypeOpenAI, ProviderTypeAzure:
+		return Capabilities{Streaming: true, Tools: true, Vision: true, Reasoning: true, LogitBias: true, PromptCacheKey: true, SafetyIdentifier: true}
+	case ProviderTypeAnthropic:
+		return Capabilities{Streaming: true, Tools: true, Vision: true}
+	case ProviderTypeMock:
+		return Capabilities{Streaming: true, Tools: true, Vision: true, Reasoning: true, LogitBias: true, PromptCacheKey: true, SafetyIdentifier: true}
+	default:
+		return Capabilities{Streaming: true}
+	}
+}
+
+// CapabilitiesFromConfig returns the capabilities of a provider of type
+// providerType: its type's defaults, overridden by each setting cfg sets
+func CapabilitiesFromConfig(providerType ProviderType, cfg appconfig.CapabilitiesConfig) Capabilities {
+	caps := DefaultCapabilities(providerType)
+	for _, setting := range []struct {
+		value *bool
+		field *bool
+	}{
+		{cfg.SupportsStreaming, &caps.Streaming},
+		{cfg.SupportsTools, &caps.Tools},
+		{cfg.SupportsVision, &caps.Vision},
+		{cfg.SupportsReasoning, &caps.Reasoning},
+		{cfg.SupportsLogitBias, &caps.LogitBias},
+		{cfg.SupportsPromptCacheKey, &caps.PromptCacheKey},
+		{cfg.SupportsSafetyIdentifier, &caps.SafetyIdentifier},
+	} {
+		if setting.value != nil {
+			*setting.field = *setting.value
+		}
+	}
+	return caps
+}
+
+// CheckCapabilities returns a ProviderError when the request needs a feature
+// the provider does not support. The error allows fallback so the caller can
+// try another provider.
+func CheckCapabilities(p Provider, req *ResponsesRequest) error {
+	unsupported := func(feature string) error {
+		return &ProviderError{
+			Provider:   p.Name(),
+			Code:       "unsupported_capability",
+			Message:    fmt.Sprintf("provider %s does not support %s", p.Name(), feature),
+			HTTPStatus: http.StatusBadRequest,
+			Retryable:  false,
+			Fallback:   true,
+		}
+	}
+
+	if req.Stream && !p.SupportsStreaming() {
+		return unsupported("streaming")
+	}
+	if len(req.Tools) > 0 && !p.SupportsTools() {
+		return unsupported("tools")
+	}
+	if requestHasImages(req.Input) && !p.SupportsVision() {
+		return unsupported("image input")
+	}
+
+	return nil
+}
+
+// requestHasImages reports whether the input contains any image content
+func requestHasImages(input interface{}) bool {
+	items, ok := input.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if itemType, _ := itemMap["type"].(string); itemType == "input_image" {
+			return true
+		}
+		if content, ok := itemMap["content"].([]interface{}); ok {
+			for _, part := range content {
+				if partMap, ok := part.(map[string]interface{}); ok {
+					if partType, _ := partMap["type"].(string); partType == "input_image" || partType == "image_url" {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// applyIdentifiers copies prompt_cache_key and safety_identifier to the Chat
+// Completions request, each only when the provider supports it
+func (p *BaseProvider) applyIdentifiers(req *ResponsesRequest, chatReq map[string]interface{}) {
+	if req.PromptCacheKey != "" {
+		if p.SupportsPromptCacheKey() {
+			chatReq["prompt_cache_key"] = req.PromptCacheKey
+		} else {
+			slog.Debug("dropping prompt_cache_key unsupported by provider", "provider", p.Name())
+		}
+	}
+	if req.SafetyIdentifier != "" {
+		if p.SupportsSafetyIdentifier() {
+			chatReq["safety_identifier"] = req.SafetyIdentifier
+		} else {
+			slog.Debug("dropping safety_identifier unsupported by provider", "provider", p.Name())
+		}
+	}
+}
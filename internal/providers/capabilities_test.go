@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"testing"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestCapabilitiesFromConfig(t *testing.T) {
+	no, yes := false, true
+
+	tests := []struct {
+		name         string
+		providerType ProviderType
+		cfg          appconfig.CapabilitiesConfig
+		want         Capabilities
+	}{
+		{
+			name:         "zai defaults include vision",
+			providerType: ProviderTypeZai,
+			want:         Capabilities{Streaming: true, Tools: true, Vision: true, Reasoning: true},
+		},
+		{
+			name:         "explicit false honoured",
+			providerType: ProviderTypeZai,
+			cfg:          appconfig.CapabilitiesConfig{SupportsVision: &no},
+			want:         Capabilities{Streaming: true, Tools: true, Reasoning: true},
+		},
+		{
+			name:         "explicit true added to the defaults",
+			providerType: ProviderTypeZai,
+			cfg:          appconfig.CapabilitiesConfig{SupportsLogitBias: &yes},
+			want:         Capabilities{Streaming: true, Tools: true, Vision: true, Reasoning: true, LogitBias: true},
+		},
+		{
+			name:         "anthropic defaults",
+			providerType: ProviderTypeAnthropic,
+			want:         Capabilities{Streaming: true, Tools: true, Vision: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CapabilitiesFromConfig(tt.providerType, tt.cfg); got != tt.want {
+				t.Errorf("CapabilitiesFromConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCapabilitiesExplicitFalse(t *testing.T) {
+	no := false
+	p, err := NewFactory().CreateProvider(string(ProviderTypeZai))
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	cfg := ProviderConfigFromConfig("zai", appconfig.ProviderConfig{
+		Capabilities: appconfig.CapabilitiesConfig{SupportsStreaming: &no},
+	})
+	if err := p.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	if err := CheckCapabilities(p, &ResponsesRequest{Input: "hi", Stream: true}); err == nil {
+		t.Error("streaming request accepted although supports_streaming is false")
+	}
+	if err := CheckCapabilities(p, &ResponsesRequest{Input: "hi"}); err != nil {
+		t.Errorf("CheckCapabilities() = %v for a request needing no disabled feature", err)
+	}
+}
@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// ColdStartOptions controls how SelectProviderForModel routes a model
+// before providers have a known health state
+type ColdStartOptions struct {
+	// Enabled probes candidates concurrently; otherwise
+	// SelectProviderForModel is GetProviderForModelOrDefault
+	Enabled bool
+
+	// Providers limits the candidates probed; empty probes every provider
+	// supporting the model
+	Providers []string
+
+	// Timeout bounds each probe; 0 leaves it to ctx
+	Timeout time.Duration
+}
+
+// ColdStartOptionsFromConfig converts providers.cold_start
+func ColdStartOptionsFromConfig(cfg appconfig.ColdStartConfig) ColdStartOptions {
+	return ColdStartOptions{
+		Enabled:   cfg.Enabled,
+		Providers: cfg.Providers,
+		Timeout:   cfg.Timeout,
+	}
+}
+
+// SetColdStartOptions sets how SelectProviderForModel probes candidates
+func (f *Factory) SetColdStartOptions(opts ColdStartOptions) {
+	f.coldStart = opts
+}
+
+// SelectProviderForModel picks the highest priority healthy provider
+// supporting model. Providers that have had a health check are judged by its
+// result; the rest are probed concurrently, so a down priority-1 provider on
+// a cold start costs one probe timeout rather than one per provider.
+// Selection waits only for the probes of providers ranked above the pick.
+func (f *Factory) SelectProviderForModel(ctx context.Context, model string) (Provider, error) {
+	if !f.coldStart.Enabled {
+		return f.GetProviderForModelOrDefault(model)
+	}
+
+	var candidates []Provider
+	for _, name := range f.registry.List() {
+		if len(f.coldStart.Providers) > 0 && !slices.Contains(f.coldStart.Providers, name) {
+			continue
+		}
+		if provider, ok := f.registry.Get(name); ok && provider.SupportsModel(model) {
+			candidates = append(candidates, provider)
+		}
+	}
+	if len(candidates) == 0 {
+		return f.GetProviderForModelOrDefault(model)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan error, len(candidates))
+	for i, provider := range candidates {
+		results[i] = make(chan error, 1)
+		metrics := provider.GetMetrics()
+		if !metrics.LastHealthCheck.IsZero() {
+			results[i] <- healthError(metrics.HealthStatus)
+			continue
+		}
+		go func() {
+			probeCtx, probeCancel := ctx, context.CancelFunc(func() {})
+			if f.coldStart.Timeout > 0 {
+				probeCtx, probeCancel = context.WithTimeout(ctx, f.coldStart.Timeout)
+			}
+			defer probeCancel()
+			results[i] <- provider.HealthCheck(probeCtx)
+		}()
+	}
+
+	errs := make([]error, 0, len(candidates))
+	for i, provider := range candidates {
+		err := <-results[i]
+		if err == nil {
+			return provider, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+	return nil, fmt.Errorf("no healthy provider for model %s: %w", model, errors.Join(errs...))
+}
+
+// healthError converts a recorded health state into a probe result
+func healthError(state HealthState) error {
+	switch state {
+	case HealthStateUnhealthy, HealthStateAuthFailed:
+		return fmt.Errorf("provider is %s", state)
+	}
+	return nil
+}
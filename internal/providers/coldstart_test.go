@@ -0,0 +1,151 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// coldStartBackends are the base URLs of backends in each probe outcome
+type coldStartBackends struct {
+	healthy, down, hanging string
+}
+
+func newColdStartBackends(t *testing.T) coldStartBackends {
+	t.Helper()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(healthy.Close)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close() // Connections are refused
+	hanging, _, _ := hangingBackend(t)
+	return coldStartBackends{healthy: healthy.URL, down: down.URL, hanging: hanging}
+}
+
+func TestSelectProviderForModel(t *testing.T) {
+	backends := newColdStartBackends(t)
+
+	tests := []struct {
+		name    string
+		opts    ColdStartOptions
+		first   string // Base URL of the priority-1 provider
+		second  string // Base URL of the priority-2 provider
+		want    string // Registered name of the pick; "" expects an error
+		maxTime time.Duration
+	}{
+		{"down priority-1 provider is skipped", ColdStartOptions{Enabled: true, Timeout: 5 * time.Second}, backends.down, backends.healthy, "second", time.Second},
+		{"hanging priority-1 provider costs one probe timeout", ColdStartOptions{Enabled: true, Timeout: 100 * time.Millisecond}, backends.hanging, backends.healthy, "second", time.Second},
+		{"healthy priority-1 provider doesn't wait for the rest", ColdStartOptions{Enabled: true, Timeout: 5 * time.Second}, backends.healthy, backends.hanging, "first", time.Second},
+		{"no healthy provider", ColdStartOptions{Enabled: true, Timeout: 5 * time.Second}, backends.down, backends.down, "", time.Second},
+		{"candidates limited to the configured providers", ColdStartOptions{Enabled: true, Providers: []string{"first"}, Timeout: 5 * time.Second}, backends.down, backends.healthy, "", time.Second},
+		{"disabled routes by priority alone", ColdStartOptions{}, backends.down, backends.healthy, "first", time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFactory()
+			f.SetColdStartOptions(tt.opts)
+			err := f.InitializeProviders(map[string]ProviderConfig{
+				"first":  {Type: ProviderTypeZai, Enabled: true, Priority: 1, BaseURL: tt.first, Timeout: time.Minute, Models: []string{"glm-*"}},
+				"second": {Type: ProviderTypeZai, Enabled: true, Priority: 2, BaseURL: tt.second, Timeout: time.Minute, Models: []string{"glm-*"}},
+			}, true)
+			if err != nil {
+				t.Fatalf("InitializeProviders() = %v", err)
+			}
+			defer f.ShutdownAll(context.Background())
+
+			start := time.Now()
+			got, err := f.SelectProviderForModel(context.Background(), "glm-5")
+			if elapsed := time.Since(start); elapsed > tt.maxTime {
+				t.Errorf("SelectProviderForModel() took %v", elapsed)
+			}
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("SelectProviderForModel() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectProviderForModel() error = %v", err)
+			}
+			if want, _ := f.GetProvider(tt.want); got != want {
+				t.Errorf("SelectProviderForModel() didn't pick %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectProviderForModelUsesKnownHealth(t *testing.T) {
+	backends := newColdStartBackends(t)
+
+	// Every probe of first fails with a dropped connection
+	var probes atomic.Int32
+	dropping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer dropping.Close()
+
+	f := NewFactory()
+	f.SetColdStartOptions(ColdStartOptions{Enabled: true, Timeout: 5 * time.Second})
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"first":  {Type: ProviderTypeZai, Enabled: true, Priority: 1, BaseURL: dropping.URL, Timeout: time.Minute, Models: []string{"glm-*"}},
+		"second": {Type: ProviderTypeZai, Enabled: true, Priority: 2, BaseURL: backends.healthy, Timeout: time.Minute, Models: []string{"glm-*"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	defer f.ShutdownAll(context.Background())
+
+	first, _ := f.GetProvider("first")
+	if err := first.HealthCheck(context.Background()); err == nil {
+		t.Fatal("HealthCheck() of the dropping backend succeeded")
+	}
+	probes.Store(0)
+
+	// The recorded failure rules first out without another probe
+	got, err := f.SelectProviderForModel(context.Background(), "glm-5")
+	if err != nil {
+		t.Fatalf("SelectProviderForModel() error = %v", err)
+	}
+	if second, _ := f.GetProvider("second"); got != second {
+		t.Error("SelectProviderForModel() picked the provider known to be unhealthy")
+	}
+	if n := probes.Load(); n != 0 {
+		t.Errorf("provider with a known health state probed %d times", n)
+	}
+}
+
+func TestSelectProviderForModelCancelledProbeNotRecorded(t *testing.T) {
+	backends := newColdStartBackends(t)
+	slow, _, cancelled := hangingBackend(t)
+
+	f := NewFactory()
+	f.SetColdStartOptions(ColdStartOptions{Enabled: true, Timeout: 5 * time.Second})
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"fast": {Type: ProviderTypeZai, Enabled: true, Priority: 1, BaseURL: backends.healthy, Timeout: time.Minute, Models: []string{"glm-*"}},
+		"slow": {Type: ProviderTypeZai, Enabled: true, Priority: 2, BaseURL: slow, Timeout: time.Minute, Models: []string{"glm-*"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	defer f.ShutdownAll(context.Background())
+
+	if _, err := f.SelectProviderForModel(context.Background(), "glm-5"); err != nil {
+		t.Fatalf("SelectProviderForModel() error = %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow probe not cancelled once fast won")
+	}
+	time.Sleep(20 * time.Millisecond) // Let the probe return
+
+	provider, _ := f.GetProvider("slow")
+	if m := provider.GetMetrics(); m.HealthStatus == HealthStateUnhealthy || m.ConsecutiveFail != 0 || !m.LastHealthCheck.IsZero() {
+		t.Errorf("cancelled probe recorded: status %s, %d failures, checked at %v", m.HealthStatus, m.ConsecutiveFail, m.LastHealthCheck)
+	}
+}
@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// baseURLs returns the provider's endpoints: base_url followed by the warm
+// standby base_urls, without blanks or duplicates
+func baseURLs(config ProviderConfig) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range append([]string{config.BaseURL}, config.BaseURLs...) {
+		u = strings.TrimSuffix(u, "/")
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// Endpoints are a provider's base_url and warm standby base_urls, tried in
+// turn on connection failure
+type Endpoints struct {
+	name string
+	urls []string
+
+	// active indexes the endpoint that last answered
+	active atomic.Int32
+}
+
+// NewEndpoints returns the endpoints of a provider
+func NewEndpoints(config ProviderConfig) *Endpoints {
+	urls := baseURLs(config)
+	if len(urls) == 0 {
+		urls = []string{config.BaseURL}
+	}
+	return &Endpoints{name: config.Name, urls: urls}
+}
+
+// Active returns the base URL of the endpoint in use
+func (e *Endpoints) Active() string {
+	return e.urls[int(e.active.Load())%len(e.urls)]
+}
+
+// Do sends the request newReq builds for an endpoint's base URL with client.
+// It starts at the endpoint that last answered and moves to the next one on a
+// connection failure; any HTTP response, error statuses included, is returned
+// as is. An endpoint that fails stays out of rotation until the ones after it
+// fail too.
+func (e *Endpoints) Do(ctx context.Context, client *http.Client, newReq func(baseURL string) (*http.Request, error)) (*http.Response, error) {
+	first := int(e.active.Load()) % len(e.urls)
+	var lastErr error
+	for i := range e.urls {
+		idx := (first + i) % len(e.urls)
+
+		req, err := newReq(e.urls[idx])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil {
+			e.active.Store(int32(idx))
+			return resp, nil
+		}
+
+		// A cancelled caller is not the endpoint's fault
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+
+		if len(e.urls) > 1 {
+			next := (idx + 1) % len(e.urls)
+			e.active.CompareAndSwap(int32(idx), int32(next))
+			slog.Warn("provider endpoint unreachable, failing over",
+				"provider", e.name, "base_url", e.urls[idx], "next", e.urls[next], "error", err)
+		}
+	}
+
+	return nil, lastErr
+}
+
+// doWithFailover sends the request built by newReq, which receives the
+// provider config with BaseURL set to one endpoint, failing over between the
+// provider's endpoints as Endpoints.Do does
+func (p *BaseProvider) doWithFailover(ctx context.Context, newReq func(config ProviderConfig) (*http.Request, error)) (*http.Response, error) {
+	config := p.GetConfig()
+	client := p.GetClient()
+
+	p.mu.RLock()
+	endpoints := p.endpoints
+	p.mu.RUnlock()
+	if endpoints == nil {
+		endpoints = NewEndpoints(config)
+	}
+
+	return endpoints.Do(ctx, client, func(baseURL string) (*http.Request, error) {
+		config.BaseURL = baseURL
+		return newReq(config)
+	})
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestEndpointsDo(t *testing.T) {
+	tests := []struct {
+		name       string
+		down       map[string]bool // Hosts refusing connections
+		wantTried  []string
+		wantActive string
+		wantErr    bool
+	}{
+		{"first endpoint answers", nil, []string{"a"}, "http://a", false},
+		{"fails over to the next", map[string]bool{"a": true}, []string{"a", "b"}, "http://b", false},
+		{"skips to the last", map[string]bool{"a": true, "b": true}, []string{"a", "b", "c"}, "http://c", false},
+		{"all down", map[string]bool{"a": true, "b": true, "c": true}, []string{"a", "b", "c"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried []string
+			client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				tried = append(tried, r.URL.Host)
+				if tt.down[r.URL.Host] {
+					return nil, errors.New("connection refused")
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})}
+
+			endpoints := NewEndpoints(ProviderConfig{Name: "test", BaseURL: "http://a/", BaseURLs: []string{"http://b", "http://a", "http://c"}})
+			resp, err := endpoints.Do(context.Background(), client, func(baseURL string) (*http.Request, error) {
+				return http.NewRequest(http.MethodPost, baseURL+"/chat/completions", nil)
+			})
+
+			if strings.Join(tried, ",") != strings.Join(tt.wantTried, ",") {
+				t.Errorf("tried %v, want %v", tried, tt.wantTried)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Do() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			if got := endpoints.Active(); got != tt.wantActive {
+				t.Errorf("Active() = %s, want %s", got, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestZaiFailsOverBetweenBaseURLs(t *testing.T) {
+	var hits []string
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, r.URL.Path)
+		io.WriteString(w, `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`)
+	}))
+	defer live.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close() // Refuses connections
+
+	p := NewZaiProvider()
+	err := p.Initialize(ProviderConfig{Name: "zai", Type: ProviderTypeZai, BaseURL: down.URL, BaseURLs: []string{live.URL}})
+	if err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Execute(context.Background(), map[string]interface{}{"model": "glm-5"}); err != nil {
+			t.Fatalf("request %d: Execute() error = %v", i+1, err)
+		}
+	}
+	if len(hits) != 2 {
+		t.Errorf("standby endpoint got %d requests, want 2", len(hits))
+	}
+	if got := p.endpoints.Active(); got != live.URL {
+		t.Errorf("active endpoint = %s, want the standby %s", got, live.URL)
+	}
+}
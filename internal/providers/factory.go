@@ -1,12 +1,30 @@
 package providers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/ids"
 )
 
 // Factory creates provider instances
 type Factory struct {
-	registry *Registry
+	registry     *Registry
+	routingRules []RoutingRule
+	health       healthScheduler
+	ids          ids.Generator // Applied to providers as they register; nil keeps ids.Default
+	fallback     FallbackOptions
+	coldStart    ColdStartOptions
+
+	// defaultForUnmatched sends models no provider supports to the default
+	// provider in GetProviderForModelOrDefault
+	defaultForUnmatched bool
 }
 
 // NewFactory creates a new provider factory
@@ -23,6 +41,10 @@ func (f *Factory) CreateProvider(providerType string) (Provider, error) {
 		return NewZaiProvider(), nil
 	case "openai":
 		return NewOpenAIProvider(), nil
+	case "mock":
+		return NewMockProvider(), nil
+	case "azure":
+		return NewAzureProvider(), nil
 	case "anthropic":
 		return nil, fmt.Errorf("anthropic provider not yet implemented")
 	default:
@@ -33,11 +55,15 @@ func (f *Factory) CreateProvider(providerType string) (Provider, error) {
 // RegisterProvider creates and registers a provider
 func (f *Factory) RegisterProvider(config ProviderConfig) error {
 	// Create provider
-	provider, err := f.CreateProvider(config.Type)
+	provider, err := f.CreateProvider(string(config.Type))
 	if err != nil {
 		return fmt.Errorf("failed to create provider %s: %w", config.Name, err)
 	}
 
+	if setter, ok := provider.(idSetter); ok && f.ids != nil {
+		setter.SetIDGenerator(f.ids)
+	}
+
 	// Register with registry
 	if err := f.registry.Register(provider, config); err != nil {
 		return fmt.Errorf("failed to register provider %s: %w", config.Name, err)
@@ -46,27 +72,147 @@ func (f *Factory) RegisterProvider(config ProviderConfig) error {
 	return nil
 }
 
+// idSetter is implemented by providers whose ID generator can be replaced
+type idSetter interface {
+	SetIDGenerator(g ids.Generator)
+}
+
+// SetIDGenerator sets the ID generator of registered providers and of those
+// registered later
+func (f *Factory) SetIDGenerator(g ids.Generator) {
+	f.ids = g
+	for _, provider := range f.registry.GetAll() {
+		if setter, ok := provider.(idSetter); ok {
+			setter.SetIDGenerator(g)
+		}
+	}
+}
+
 // GetRegistry returns the provider registry
 func (f *Factory) GetRegistry() *Registry {
 	return f.registry
 }
 
-// InitializeProviders initializes all providers from config
-func (f *Factory) InitializeProviders(configs map[string]ProviderConfig) error {
-	for name, config := range configs {
+// InitError is a provider that failed to initialize
+type InitError struct {
+	Provider string
+	Err      error
+}
+
+// Error describes the failure; RegisterProvider errors already name the provider
+func (e *InitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *InitError) Unwrap() error {
+	return e.Err
+}
+
+// InitErrors collects the providers that failed to initialize, by name
+type InitErrors []*InitError
+
+// Error joins all failures into one line
+func (e InitErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, ie := range e {
+		msgs[i] = ie.Error()
+	}
+	return "failed to initialize providers: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual failures for errors.Is and errors.As
+func (e InitErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, ie := range e {
+		errs[i] = ie
+	}
+	return errs
+}
+
+// InitializeProviders initializes the enabled providers from config. A
+// provider that fails is logged and skipped so the others can still serve.
+// InitErrors is returned if every enabled provider failed, or if any failed
+// and strict is set.
+func (f *Factory) InitializeProviders(configs map[string]ProviderConfig, strict bool) error {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failed InitErrors
+	initialized := 0
+	for _, name := range names {
+		config := configs[name]
 		if !config.Enabled {
 			continue
 		}
 
 		config.Name = name
 		if err := f.RegisterProvider(config); err != nil {
-			return fmt.Errorf("failed to initialize provider %s: %w", name, err)
+			slog.Error("provider failed to initialize", "provider", name, "error", err)
+			failed = append(failed, &InitError{Provider: name, Err: err})
+			continue
 		}
+		initialized++
+	}
+
+	if len(failed) > 0 && (strict || initialized == 0) {
+		return failed
+	}
+	if len(failed) > 0 {
+		slog.Warn("starting without providers that failed to initialize", "initialized", initialized, "failed", len(failed))
 	}
 
 	return nil
 }
 
+// ProviderConfigFromConfig converts the providers.<name> section of the
+// application config. The type defaults to the name.
+func ProviderConfigFromConfig(name string, cfg appconfig.ProviderConfig) ProviderConfig {
+	providerType := cfg.Type
+	if providerType == "" {
+		providerType = name
+	}
+	return ProviderConfig{
+		Name:             name,
+		Type:             ProviderType(providerType),
+		Plan:             cfg.Plan,
+		Enabled:          cfg.Enabled,
+		Priority:         cfg.Priority,
+		BaseURL:          cfg.BaseURL,
+		BaseURLs:         cfg.BaseURLs,
+		APIKey:           cfg.APIKey,
+		Timeout:          cfg.Timeout,
+		MaxRetries:       cfg.MaxRetries,
+		RetryDelay:       cfg.RetryDelay,
+		RetryMaxElapsed:  cfg.RetryMaxElapsed,
+		MaxResponseBytes: cfg.MaxResponseBytes,
+		Models:           cfg.Models,
+		HealthCheck: HealthCheckConfig{
+			Enabled:       cfg.HealthCheck.Enabled,
+			Interval:      cfg.HealthCheck.Interval,
+			Timeout:       cfg.HealthCheck.Timeout,
+			Endpoint:      cfg.HealthCheck.Endpoint,
+			Authenticated: cfg.HealthCheck.Authenticated,
+		},
+		Capabilities:     CapabilitiesFromConfig(ProviderType(providerType), cfg.Capabilities),
+		CumulativeToolCallDeltas: cfg.CumulativeToolCallDeltas,
+		Proxy: ProxyConfig{
+			HTTPProxy:  cfg.HTTPProxy,
+			HTTPSProxy: cfg.HTTPSProxy,
+			NoProxy:    cfg.NoProxy,
+		},
+		Azure: AzureConfig{
+			Resource:    cfg.Azure.Resource,
+			Deployment:  cfg.Azure.Deployment,
+			APIVersion:  cfg.Azure.APIVersion,
+			Deployments: cfg.Azure.Deployments,
+		},
+	}
+}
+
 // GetProvider retrieves a provider by name
 func (f *Factory) GetProvider(name string) (Provider, error) {
 	provider, exists := f.registry.Get(name)
@@ -81,6 +227,29 @@ func (f *Factory) GetProviderForModel(model string) (Provider, error) {
 	return f.registry.GetByModel(model)
 }
 
+// SetDefaultForUnmatched sets whether GetProviderForModelOrDefault falls back
+// to the default provider for models no provider supports
+func (f *Factory) SetDefaultForUnmatched(enabled bool) {
+	f.defaultForUnmatched = enabled
+}
+
+// GetProviderForModelOrDefault finds a provider that supports the model or,
+// when enabled with SetDefaultForUnmatched and none does, returns the default
+// provider
+func (f *Factory) GetProviderForModelOrDefault(model string) (Provider, error) {
+	provider, err := f.registry.GetByModel(model)
+	if err == nil || !f.defaultForUnmatched {
+		return provider, err
+	}
+
+	provider, defaultErr := f.registry.GetDefault()
+	if defaultErr != nil {
+		return nil, err
+	}
+	slog.Debug("no provider matches model, using the default provider", "model", model, "provider", provider.Name())
+	return provider, nil
+}
+
 // GetDefaultProvider returns the default (highest priority) provider
 func (f *Factory) GetDefaultProvider() (Provider, error) {
 	return f.registry.GetDefault()
@@ -91,7 +260,54 @@ func (f *Factory) ListProviders() []string {
 	return f.registry.List()
 }
 
-// HealthCheckAll runs health checks on all providers
-func (f *Factory) HealthCheckAll() map[string]error {
-	return f.registry.HealthCheck()
+// HealthCheckAll runs health checks on all providers; cancelling ctx aborts
+// the checks still running
+func (f *Factory) HealthCheckAll(ctx context.Context) map[string]error {
+	return f.registry.HealthCheck(ctx)
+}
+
+// forceCloser is implemented by providers that can abort their in-flight
+// requests when a graceful shutdown takes too long
+type forceCloser interface {
+	ForceClose()
+}
+
+// ShutdownAll stops scheduled health checks, then removes every registered
+// provider and shuts them down concurrently. Providers that haven't finished
+// when ctx is done are force-closed and the context's error is returned.
+func (f *Factory) ShutdownAll(ctx context.Context) error {
+	// Scheduled health checks would otherwise hold providers up
+	f.StopHealthChecks()
+
+	providers := f.registry.RemoveAll()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(providers))
+	for i, provider := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := provider.Shutdown(); err != nil {
+				errs[i] = fmt.Errorf("failed to shutdown provider %s: %w", provider.Name(), err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return errors.Join(errs...)
+	case <-ctx.Done():
+		for _, provider := range providers {
+			if fc, ok := provider.(forceCloser); ok {
+				fc.ForceClose()
+			}
+		}
+		return fmt.Errorf("provider shutdown timed out, in-flight requests aborted: %w", ctx.Err())
+	}
 }
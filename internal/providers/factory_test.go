@@ -0,0 +1,179 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stuckProvider's Shutdown blocks until it is force-closed
+type stuckProvider struct {
+	*MockProvider
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newStuckProvider() *stuckProvider {
+	return &stuckProvider{MockProvider: NewMockProvider(), closed: make(chan struct{})}
+}
+
+func (p *stuckProvider) Shutdown() error {
+	<-p.closed
+	return nil
+}
+
+func (p *stuckProvider) ForceClose() {
+	p.closeOnce.Do(func() { close(p.closed) })
+}
+
+func TestShutdownAll(t *testing.T) {
+	t.Run("every provider is shut down", func(t *testing.T) {
+		f := NewFactory()
+		tracked := []*trackedProvider{newTrackedProvider(), newTrackedProvider(), newTrackedProvider()}
+		for i, p := range tracked {
+			config := ProviderConfig{Name: string(rune('a' + i)), Type: ProviderTypeMock, Enabled: true, Priority: i}
+			if err := f.GetRegistry().Register(p, config); err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+		}
+
+		if err := f.ShutdownAll(context.Background()); err != nil {
+			t.Fatalf("ShutdownAll() error = %v", err)
+		}
+		for i, p := range tracked {
+			if p.shutdown != 1 {
+				t.Errorf("provider %d shut down %d times, want 1", i, p.shutdown)
+			}
+		}
+		if names := f.ListProviders(); len(names) != 0 {
+			t.Errorf("providers still registered: %v", names)
+		}
+	})
+
+	t.Run("stuck provider is force-closed at the deadline", func(t *testing.T) {
+		f := NewFactory()
+		stuck, tracked := newStuckProvider(), newTrackedProvider()
+		f.GetRegistry().Register(stuck, ProviderConfig{Name: "stuck", Type: ProviderTypeMock, Enabled: true})
+		f.GetRegistry().Register(tracked, ProviderConfig{Name: "tracked", Type: ProviderTypeMock, Enabled: true, Priority: 1})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := f.ShutdownAll(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ShutdownAll() error = %v, want deadline exceeded", err)
+		}
+
+		select {
+		case <-stuck.closed:
+		default:
+			t.Error("stuck provider was not force-closed")
+		}
+	})
+}
+
+func TestForceCloseAbortsRequests(t *testing.T) {
+	p := NewBaseProvider("test")
+	ctx, stop := p.requestContext(context.Background())
+	defer stop()
+
+	p.ForceClose()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request context not cancelled by ForceClose")
+	}
+}
+
+func TestInitializeProviders(t *testing.T) {
+	good := ProviderConfig{Type: ProviderTypeZai, Enabled: true, Priority: 1, BaseURL: "http://zai.invalid"}
+	bad := ProviderConfig{Type: ProviderTypeAzure, Enabled: true, Priority: 2} // No resource or deployment
+
+	tests := []struct {
+		name       string
+		configs    map[string]ProviderConfig
+		strict     bool
+		wantFailed []string
+		wantErr    bool
+		wantNames  []string
+	}{
+		{"one good and one bad", map[string]ProviderConfig{"zai": good, "azure": bad}, false, nil, false, []string{"zai"}},
+		{"strict fails on any error", map[string]ProviderConfig{"zai": good, "azure": bad}, true, []string{"azure"}, true, []string{"zai"}},
+		{"all bad", map[string]ProviderConfig{"azure": bad}, false, []string{"azure"}, true, nil},
+		{"disabled providers skipped", map[string]ProviderConfig{"zai": good, "azure": {Type: ProviderTypeAzure}}, true, nil, false, []string{"zai"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFactory()
+			err := f.InitializeProviders(tt.configs, tt.strict)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitializeProviders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var initErrs InitErrors
+				if !errors.As(err, &initErrs) {
+					t.Fatalf("error = %v, want InitErrors", err)
+				}
+				var failed []string
+				for _, ie := range initErrs {
+					failed = append(failed, ie.Provider)
+				}
+				if !slices.Equal(failed, tt.wantFailed) {
+					t.Errorf("failed providers = %v, want %v", failed, tt.wantFailed)
+				}
+			}
+
+			if names := f.ListProviders(); !slices.Equal(names, tt.wantNames) {
+				t.Errorf("registered providers = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+// sequenceIDs numbers the IDs it generates
+type sequenceIDs struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (g *sequenceIDs) NewID(prefix string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("%s%d", prefix, g.n)
+}
+
+func TestFactorySetIDGenerator(t *testing.T) {
+	f := NewFactory()
+	defer f.ShutdownAll(context.Background())
+
+	if err := f.RegisterProvider(ProviderConfig{Name: "early", Type: ProviderTypeMock, Enabled: true}); err != nil {
+		t.Fatalf("RegisterProvider() error = %v", err)
+	}
+	f.SetIDGenerator(&sequenceIDs{})
+	if err := f.RegisterProvider(ProviderConfig{Name: "late", Type: ProviderTypeMock, Enabled: true, Priority: 1}); err != nil {
+		t.Fatalf("RegisterProvider() error = %v", err)
+	}
+
+	var got []string
+	for _, name := range []string{"early", "late"} {
+		provider, err := f.GetProvider(name)
+		if err != nil {
+			t.Fatalf("GetProvider(%q) error = %v", name, err)
+		}
+		resp, err := provider.Execute(context.Background(), map[string]interface{}{"model": "m"})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		got = append(got, resp.(map[string]interface{})["id"].(string))
+	}
+
+	if want := []string{"chatcmpl-mock-1", "chatcmpl-mock-2"}; !slices.Equal(got, want) {
+		t.Errorf("IDs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// FallbackOptions controls how SelectFallback probes candidate providers
+type FallbackOptions struct {
+	// Parallel is how many candidates are probed at once; 0 or 1 probes
+	// them one at a time
+	Parallel int
+
+	// Timeout bounds each probe; 0 leaves it to ctx
+	Timeout time.Duration
+}
+
+// FallbackOptionsFromConfig converts providers.fallback
+func FallbackOptionsFromConfig(cfg appconfig.FallbackConfig) FallbackOptions {
+	return FallbackOptions{
+		Parallel: cfg.Parallel,
+		Timeout:  cfg.Timeout,
+	}
+}
+
+// SetFallbackOptions sets how SelectFallback probes candidates
+func (f *Factory) SetFallbackOptions(opts FallbackOptions) {
+	f.fallback = opts
+}
+
+// SelectFallback picks the provider to retry a request on after failed
+// couldn't serve it: another provider supporting model whose health probe
+// passes. Providers already marked unhealthy or auth_failed are skipped
+// without a probe. Candidates are probed one at a time in priority order or,
+// with FallbackOptions.Parallel above 1, in batches of that size where the
+// first probe to pass wins, so a slow or dead backend doesn't hold up a
+// healthy one.
+func (f *Factory) SelectFallback(ctx context.Context, failed Provider, model string) (Provider, error) {
+	var candidates []Provider
+	for _, name := range f.registry.List() {
+		provider, ok := f.registry.Get(name)
+		if !ok || provider == failed || !provider.SupportsModel(model) {
+			continue
+		}
+		if status := provider.GetMetrics().HealthStatus; status == HealthStateUnhealthy || status == HealthStateAuthFailed {
+			continue
+		}
+		candidates = append(candidates, provider)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no fallback provider supports model: %s", model)
+	}
+
+	batch := f.fallback.Parallel
+	if batch < 1 {
+		batch = 1
+	}
+
+	var errs []error
+	for start := 0; start < len(candidates); start += batch {
+		end := min(start+batch, len(candidates))
+		provider, err := f.probeBatch(ctx, candidates[start:end])
+		if err == nil {
+			return provider, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("no healthy fallback provider for model %s: %w", model, errors.Join(errs...))
+}
+
+// probeBatch health-checks candidates concurrently and returns the first to
+// pass, cancelling the probes still running
+func (f *Factory) probeBatch(ctx context.Context, candidates []Provider) (Provider, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type probeResult struct {
+		provider Provider
+		err      error
+	}
+	results := make(chan probeResult, len(candidates))
+	for _, provider := range candidates {
+		go func() {
+			probeCtx, probeCancel := ctx, context.CancelFunc(func() {})
+			if f.fallback.Timeout > 0 {
+				probeCtx, probeCancel = context.WithTimeout(ctx, f.fallback.Timeout)
+			}
+			defer probeCancel()
+			results <- probeResult{provider, provider.HealthCheck(probeCtx)}
+		}()
+	}
+
+	errs := make([]error, 0, len(candidates))
+	for range candidates {
+		result := <-results
+		if result.err == nil {
+			return result.provider, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", result.provider.Name(), result.err))
+	}
+	return nil, errors.Join(errs...)
+}
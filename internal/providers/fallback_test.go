@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newFallbackFactory registers the zai providers primary, slow and fast, in
+// that priority order. slow's backend never answers; the others answer at once.
+func newFallbackFactory(t *testing.T, opts FallbackOptions) *Factory {
+	t.Helper()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(healthy.Close)
+	hanging, _, _ := hangingBackend(t)
+
+	f := NewFactory()
+	f.SetFallbackOptions(opts)
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"primary": {Type: ProviderTypeZai, Enabled: true, Priority: 1, BaseURL: healthy.URL, Timeout: time.Minute, Models: []string{"glm-*"}},
+		"slow":    {Type: ProviderTypeZai, Enabled: true, Priority: 2, BaseURL: hanging, Timeout: time.Minute, Models: []string{"glm-*"}},
+		"fast":    {Type: ProviderTypeZai, Enabled: true, Priority: 3, BaseURL: healthy.URL, Timeout: time.Minute, Models: []string{"glm-*"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	t.Cleanup(func() { f.ShutdownAll(context.Background()) })
+	return f
+}
+
+func TestSelectFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    FallbackOptions
+		want    string
+		maxTime time.Duration
+	}{
+		{"parallel probes pick the healthy provider first", FallbackOptions{Parallel: 2, Timeout: 5 * time.Second}, "fast", time.Second},
+		{"serial probes wait out the slow provider", FallbackOptions{Timeout: 100 * time.Millisecond}, "fast", 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFallbackFactory(t, tt.opts)
+			primary, _ := f.GetProvider("primary")
+
+			start := time.Now()
+			got, err := f.SelectFallback(context.Background(), primary, "glm-5")
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("SelectFallback() error = %v", err)
+			}
+			if want, _ := f.GetProvider(tt.want); got != want {
+				t.Errorf("SelectFallback() didn't pick %s", tt.want)
+			}
+			if elapsed > tt.maxTime {
+				t.Errorf("SelectFallback() took %v, want under %v", elapsed, tt.maxTime)
+			}
+		})
+	}
+}
+
+func TestSelectFallbackSkipsUnhealthy(t *testing.T) {
+	for _, state := range []HealthState{HealthStateUnhealthy, HealthStateAuthFailed} {
+		t.Run(string(state), func(t *testing.T) {
+			f := newFallbackFactory(t, FallbackOptions{Parallel: 2, Timeout: 5 * time.Second})
+			primary, _ := f.GetProvider("primary")
+			fast, _ := f.GetProvider("fast")
+			fast.(*ZaiProvider).setHealth(state, true, 0)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if _, err := f.SelectFallback(ctx, primary, "glm-5"); err == nil {
+				t.Error("SelectFallback() succeeded with only the slow provider left")
+			}
+		})
+	}
+
+	f := newFallbackFactory(t, FallbackOptions{})
+	primary, _ := f.GetProvider("primary")
+	if _, err := f.SelectFallback(context.Background(), primary, "other-model"); err == nil {
+		t.Error("SelectFallback() for an unsupported model succeeded")
+	}
+}
+
+func TestSelectFallbackCancelledProbeNotRecorded(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	hanging, _, cancelled := hangingBackend(t)
+
+	f := NewFactory()
+	f.SetFallbackOptions(FallbackOptions{Parallel: 2, Timeout: 5 * time.Second})
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"primary": {Type: ProviderTypeZai, Enabled: true, Priority: 1, BaseURL: healthy.URL, Timeout: time.Minute, Models: []string{"glm-*"}},
+		"slow":    {Type: ProviderTypeZai, Enabled: true, Priority: 2, BaseURL: hanging, Timeout: time.Minute, Models: []string{"glm-*"}},
+		"fast":    {Type: ProviderTypeZai, Enabled: true, Priority: 3, BaseURL: healthy.URL, Timeout: time.Minute, Models: []string{"glm-*"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	defer f.ShutdownAll(context.Background())
+
+	primary, _ := f.GetProvider("primary")
+	if _, err := f.SelectFallback(context.Background(), primary, "glm-5"); err != nil {
+		t.Fatalf("SelectFallback() error = %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow probe not cancelled once fast won")
+	}
+	time.Sleep(20 * time.Millisecond) // Let the probe return
+
+	slow, _ := f.GetProvider("slow")
+	if m := slow.GetMetrics(); m.HealthStatus == HealthStateUnhealthy || m.ConsecutiveFail != 0 || !m.LastHealthCheck.IsZero() {
+		t.Errorf("cancelled probe recorded: status %s, %d failures, checked at %v", m.HealthStatus, m.ConsecutiveFail, m.LastHealthCheck)
+	}
+}
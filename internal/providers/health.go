@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// healthScheduler runs periodic provider health checks until stopped
+type healthScheduler struct {
+	mu          sync.Mutex
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	concurrency int // Checks running at once; 0 is uncapped
+}
+
+// configGetter is implemented by providers exposing their configuration
+type configGetter interface {
+	GetConfig() ProviderConfig
+}
+
+// SetHealthCheckConcurrency caps the scheduled health checks running at
+// once; 0 leaves them uncapped. It applies from the next StartHealthChecks.
+func (f *Factory) SetHealthCheckConcurrency(n int) {
+	f.health.mu.Lock()
+	defer f.health.mu.Unlock()
+	f.health.concurrency = n
+}
+
+// StartHealthChecks checks each registered provider with health checks
+// enabled every health_check.interval, each check bounded by
+// health_check.timeout. Providers' first checks are staggered across their
+// interval so they don't all fire on the same tick, and at most the
+// SetHealthCheckConcurrency limit run at once. Checks run until ctx is
+// cancelled or StopHealthChecks (or ShutdownAll) is called; either aborts
+// checks in flight. Calling it again restarts the checks for the current
+// providers.
+func (f *Factory) StartHealthChecks(ctx context.Context) {
+	f.health.mu.Lock()
+	defer f.health.mu.Unlock()
+
+	f.health.stopLocked()
+	ctx, f.health.cancel = context.WithCancel(ctx)
+
+	type scheduled struct {
+		provider Provider
+		check    HealthCheckConfig
+	}
+	var checks []scheduled
+	for _, provider := range f.registry.GetAll() {
+		cg, ok := provider.(configGetter)
+		if !ok {
+			continue
+		}
+		check := cg.GetConfig().HealthCheck
+		if !check.Enabled || check.Interval <= 0 {
+			continue
+		}
+		checks = append(checks, scheduled{provider: provider, check: check})
+	}
+
+	var slots chan struct{}
+	if f.health.concurrency > 0 {
+		slots = make(chan struct{}, f.health.concurrency)
+	}
+	for i, s := range checks {
+		offset := s.check.Interval * time.Duration(i) / time.Duration(len(checks))
+		f.health.wg.Add(1)
+		go func() {
+			defer f.health.wg.Done()
+			runHealthChecks(ctx, s.provider, s.check, offset, slots)
+		}()
+	}
+}
+
+// StopHealthChecks cancels scheduled health checks, aborting any in flight,
+// and waits for them to return
+func (f *Factory) StopHealthChecks() {
+	f.health.mu.Lock()
+	defer f.health.mu.Unlock()
+	f.health.stopLocked()
+}
+
+func (s *healthScheduler) stopLocked() {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.wg.Wait()
+}
+
+// runHealthChecks checks a provider on every tick, the first offset later
+// than the others would be, until ctx is cancelled. Each check holds one of
+// slots while it runs; a nil slots is uncapped.
+func runHealthChecks(ctx context.Context, provider Provider, check HealthCheckConfig, offset time.Duration, slots chan struct{}) {
+	if offset > 0 {
+		timer := time.NewTimer(offset)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if slots != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+		}
+		checkCtx, cancel := ctx, context.CancelFunc(func() {})
+		if check.Timeout > 0 {
+			checkCtx, cancel = context.WithTimeout(ctx, check.Timeout)
+		}
+		err := provider.HealthCheck(checkCtx)
+		cancel()
+		if slots != nil {
+			<-slots
+		}
+
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("provider health check failed", "provider", provider.Name(), "error", err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hangingBackend blocks every request until the client gives up, reporting
+// each request on started and each abandoned one on cancelled
+func hangingBackend(t *testing.T) (url string, started, cancelled chan struct{}) {
+	t.Helper()
+	started, cancelled = make(chan struct{}, 16), make(chan struct{}, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+		cancelled <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, started, cancelled
+}
+
+func TestShutdownCancelsHangingHealthCheck(t *testing.T) {
+	url, started, cancelled := hangingBackend(t)
+
+	f := NewFactory()
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"zai": {
+			Type:        ProviderTypeZai,
+			Enabled:     true,
+			BaseURL:     url,
+			Timeout:     time.Minute,
+			HealthCheck: HealthCheckConfig{Enabled: true, Interval: 10 * time.Millisecond},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+
+	f.StartHealthChecks(context.Background())
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no health check reached the backend")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := f.ShutdownAll(ctx); err != nil {
+		t.Fatalf("ShutdownAll() = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ShutdownAll() took %v with a health check in flight", elapsed)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight health check was not cancelled")
+	}
+}
+
+func TestStartHealthChecksStopsWithContext(t *testing.T) {
+	url, started, cancelled := hangingBackend(t)
+
+	f := NewFactory()
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"zai": {
+			Type:        ProviderTypeZai,
+			Enabled:     true,
+			BaseURL:     url,
+			Timeout:     time.Minute,
+			HealthCheck: HealthCheckConfig{Enabled: true, Interval: 10 * time.Millisecond},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	defer f.ShutdownAll(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.StartHealthChecks(ctx)
+	<-started
+	cancel()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health check outlived its context")
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	url, _, cancelled := hangingBackend(t)
+
+	f := NewFactory()
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"zai": {
+			Type:        ProviderTypeZai,
+			Enabled:     true,
+			BaseURL:     url,
+			Timeout:     time.Minute,
+			HealthCheck: HealthCheckConfig{Enabled: true, Interval: 10 * time.Millisecond, Timeout: 20 * time.Millisecond},
+		},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	defer f.ShutdownAll(context.Background())
+
+	f.StartHealthChecks(context.Background())
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health check ran past health_check.timeout")
+	}
+}
+
+func TestAuthenticatedHealthCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticated bool
+		status        int
+		wantPath      string
+		wantAuth      string
+		wantState     HealthState
+		wantErr       bool
+	}{
+		{"connectivity probe ignores a rejected key", false, http.StatusUnauthorized, "/", "", HealthStateHealthy, false},
+		{"accepted key", true, http.StatusOK, "/models", "Bearer probe-key", HealthStateHealthy, false},
+		{"401 is auth_failed", true, http.StatusUnauthorized, "/models", "Bearer probe-key", HealthStateAuthFailed, true},
+		{"403 is auth_failed", true, http.StatusForbidden, "/models", "Bearer probe-key", HealthStateAuthFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotAuth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			f := NewFactory()
+			err := f.InitializeProviders(map[string]ProviderConfig{
+				"zai": {
+					Type:        ProviderTypeZai,
+					Enabled:     true,
+					APIKey:      "probe-key",
+					BaseURL:     srv.URL + "/",
+					Timeout:     time.Minute,
+					HealthCheck: HealthCheckConfig{Authenticated: tt.authenticated},
+				},
+			}, true)
+			if err != nil {
+				t.Fatalf("InitializeProviders() = %v", err)
+			}
+			defer f.ShutdownAll(context.Background())
+			provider, _ := f.GetProvider("zai")
+
+			err = provider.HealthCheck(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HealthCheck() error = %v, want error %v", err, tt.wantErr)
+			}
+			if gotPath != tt.wantPath || gotAuth != tt.wantAuth {
+				t.Errorf("probe = %s with Authorization %q, want %s with %q", gotPath, gotAuth, tt.wantPath, tt.wantAuth)
+			}
+			if state := provider.GetMetrics().HealthStatus; state != tt.wantState {
+				t.Errorf("health = %s, want %s", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestHealthChecksStaggeredAndBounded(t *testing.T) {
+	const providers = 4
+	const interval = 200 * time.Millisecond
+
+	var (
+		mu       sync.Mutex
+		first    = make(map[string]time.Time) // Path -> first probe
+		inFlight int
+		maxSeen  int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if _, ok := first[r.URL.Path]; !ok {
+			first[r.URL.Path] = time.Now()
+		}
+		inFlight++
+		maxSeen = max(maxSeen, inFlight)
+		mu.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	configs := make(map[string]ProviderConfig)
+	for i := range providers {
+		name := fmt.Sprintf("p%d", i)
+		configs[name] = ProviderConfig{
+			Type:        ProviderTypeZai,
+			Enabled:     true,
+			BaseURL:     srv.URL + "/" + name + "/",
+			Timeout:     time.Minute,
+			HealthCheck: HealthCheckConfig{Enabled: true, Interval: interval},
+		}
+	}
+	f := NewFactory()
+	if err := f.InitializeProviders(configs, true); err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	defer f.ShutdownAll(context.Background())
+	f.SetHealthCheckConcurrency(1)
+
+	f.StartHealthChecks(context.Background())
+	time.Sleep(2 * interval)
+	f.StopHealthChecks()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(first) != providers {
+		t.Fatalf("%d providers probed, want %d", len(first), providers)
+	}
+	if maxSeen > 1 {
+		t.Errorf("%d health checks ran at once, want at most 1", maxSeen)
+	}
+
+	// First probes are spread across the interval rather than on one tick
+	times := make([]time.Time, 0, providers)
+	for _, at := range first {
+		times = append(times, at)
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	if spread := times[len(times)-1].Sub(times[0]); spread < interval/2 {
+		t.Errorf("first probes spread over %v, want them staggered across the %v interval", spread, interval)
+	}
+}
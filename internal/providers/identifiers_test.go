@@ -0,0 +1,47 @@
+package providers
+
+import "testing"
+
+func TestTransformRequestIdentifiers(t *testing.T) {
+	tests := []struct {
+		name         string
+		providerType ProviderType
+		wantForward  bool
+	}{
+		{"openai supports both", ProviderTypeOpenAI, true},
+		{"zai drops both", ProviderTypeZai, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewFactory().CreateProvider(string(tt.providerType))
+			if err != nil {
+				t.Fatalf("CreateProvider() error = %v", err)
+			}
+			cfg := ProviderConfig{Name: string(tt.providerType), Type: tt.providerType, Capabilities: DefaultCapabilities(tt.providerType)}
+			if err := p.Initialize(cfg); err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+
+			out, err := p.TransformRequest(&ResponsesRequest{
+				Model:            "gpt-4",
+				Input:            "hi",
+				PromptCacheKey:   "repo-123",
+				SafetyIdentifier: "user-hash",
+			})
+			if err != nil {
+				t.Fatalf("TransformRequest() error = %v", err)
+			}
+			chatReq := out.(map[string]interface{})
+			for field, want := range map[string]string{"prompt_cache_key": "repo-123", "safety_identifier": "user-hash"} {
+				got, forwarded := chatReq[field]
+				if forwarded != tt.wantForward {
+					t.Errorf("%s forwarded = %v, want %v", field, forwarded, tt.wantForward)
+				}
+				if forwarded && got != want {
+					t.Errorf("%s = %v, want %q", field, got, want)
+				}
+			}
+		})
+	}
+}
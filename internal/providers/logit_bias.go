@@ -0,0 +1,68 @@
+package providers
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// Allowed range of a logit_bias value
+const (
+	MinLogitBias = -100
+	MaxLogitBias = 100
+)
+
+// ValidateLogitBias checks that every key is a token ID and every bias is
+// within [MinLogitBias, MaxLogitBias]
+func ValidateLogitBias(bias map[string]float64) error {
+	for token, value := range bias {
+		if id, err := strconv.Atoi(token); err != nil || id < 0 {
+			return fmt.Errorf("invalid logit_bias: key %q is not a token ID", token)
+		}
+		if value < MinLogitBias || value > MaxLogitBias {
+			return fmt.Errorf("invalid logit_bias: bias %v for token %s is outside [%d, %d]", value, token, MinLogitBias, MaxLogitBias)
+		}
+	}
+	return nil
+}
+
+// ParseLogitBias converts a decoded JSON logit_bias value to a validated
+// token ID to bias map
+func ParseLogitBias(v interface{}) (map[string]float64, error) {
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid logit_bias: must be an object mapping token IDs to biases")
+	}
+
+	bias := make(map[string]float64, len(raw))
+	for token, value := range raw {
+		number, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("invalid logit_bias: bias for token %s must be a number", token)
+		}
+		bias[token] = number
+	}
+
+	if err := ValidateLogitBias(bias); err != nil {
+		return nil, err
+	}
+	return bias, nil
+}
+
+// applyLogitBias validates the request's logit_bias and copies it to the
+// Chat Completions request when the provider supports it, otherwise drops it
+func (p *BaseProvider) applyLogitBias(req *ResponsesRequest, chatReq map[string]interface{}) error {
+	if len(req.LogitBias) == 0 {
+		return nil
+	}
+	if err := ValidateLogitBias(req.LogitBias); err != nil {
+		return err
+	}
+
+	if !p.SupportsLogitBias() {
+		slog.Debug("dropping logit_bias unsupported by provider", "provider", p.Name())
+		return nil
+	}
+	chatReq["logit_bias"] = req.LogitBias
+	return nil
+}
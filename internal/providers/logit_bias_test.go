@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseLogitBias(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", `{"50256":-100,"1234":5.5}`, false},
+		{"bounds", `{"1":-100,"2":100}`, false},
+		{"empty", `{}`, false},
+		{"not an object", `[1,2]`, true},
+		{"non-numeric key", `{"hello":1}`, true},
+		{"negative key", `{"-1":1}`, true},
+		{"bias too low", `{"1":-101}`, true},
+		{"bias too high", `{"1":100.5}`, true},
+		{"bias not a number", `{"1":"5"}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(tt.value), &v); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ParseLogitBias(v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLogitBias(%s) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransformRequestLogitBias(t *testing.T) {
+	tests := []struct {
+		name        string
+		caps        Capabilities
+		bias        map[string]float64
+		wantForward bool
+		wantErr     bool
+	}{
+		{"forwarded when supported", Capabilities{LogitBias: true}, map[string]float64{"50256": -100}, true, false},
+		{"dropped when unsupported", Capabilities{Streaming: true}, map[string]float64{"50256": -100}, false, false},
+		{"invalid rejected", Capabilities{LogitBias: true}, map[string]float64{"50256": 200}, false, true},
+		{"invalid rejected when unsupported", Capabilities{Streaming: true}, map[string]float64{"x": 1}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewOpenAIProvider()
+			if err := p.Initialize(ProviderConfig{Name: "openai", Type: ProviderTypeOpenAI, Capabilities: tt.caps}); err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+
+			out, err := p.TransformRequest(&ResponsesRequest{Model: "gpt-4", Input: "hi", LogitBias: tt.bias})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TransformRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			_, forwarded := out.(map[string]interface{})["logit_bias"]
+			if forwarded != tt.wantForward {
+				t.Errorf("logit_bias forwarded = %v, want %v", forwarded, tt.wantForward)
+			}
+		})
+	}
+}
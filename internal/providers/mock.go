@@ -0,0 +1,314 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MockConfig contains the canned output returned by the mock provider
+type MockConfig struct {
+	Text      string
+	ToolCalls []MockToolCall
+	Latency   time.Duration // Delay before the response (or first chunk) is produced
+}
+
+// MockToolCall is a canned tool call returned by the mock provider
+type MockToolCall struct {
+	Name      string
+	Arguments string // JSON-encoded arguments
+}
+
+// MockProvider implements Provider without any network access. It returns
+// canned Chat Completions responses, which makes it possible to run the full
+// transform and streaming pipeline offline and without API keys.
+type MockProvider struct {
+	*OpenAIProvider
+}
+
+// NewMockProvider creates a new mock provider
+func NewMockProvider() *MockProvider {
+	return &MockProvider{
+		OpenAIProvider: &OpenAIProvider{
+			BaseProvider: NewBaseProvider("mock"),
+		},
+	}
+}
+
+// Initialize initializes the mock provider
+func (p *MockProvider) Initialize(config ProviderConfig) error {
+	if config.Type == "" {
+		config.Type = ProviderTypeMock
+	}
+	if len(config.Models) == 0 {
+		config.Models = []string{"*"}
+	}
+	if config.Mock.Text == "" && len(config.Mock.ToolCalls) == 0 {
+		config.Mock.Text = "This is a canned response from the mock provider."
+	}
+
+	return p.BaseProvider.Initialize(config)
+}
+
+// Execute returns the canned Chat Completions response
+func (p *MockProvider) Execute(ctx context.Context, req interface{}) (interface{}, error) {
+	start := time.Now()
+
+	if err := p.wait(ctx); err != nil {
+		p.RecordRequest(false, time.Since(start))
+		return nil, err
+	}
+
+	mock := p.GetConfig().Mock
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": mock.Text,
+	}
+	finishReason := "stop"
+	if len(mock.ToolCalls) > 0 {
+		message["tool_calls"] = p.toolCalls(false)
+		finishReason = "tool_calls"
+	}
+
+	p.RecordRequest(true, time.Since(start))
+	return map[string]interface{}{
+		"id":      p.newID("chatcmpl-mock-"),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   requestModel(req),
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index":         0,
+				"message":       message,
+				"finish_reason": finishReason,
+			},
+		},
+		"usage": p.usage(),
+	}, nil
+}
+
+// ExecuteStream streams the canned response as Chat Completions chunks,
+// one per word of text followed by one per tool call
+func (p *MockProvider) ExecuteStream(ctx context.Context, req interface{}) (<-chan interface{}, error) {
+	start := time.Now()
+
+	if err := p.wait(ctx); err != nil {
+		p.RecordRequest(false, time.Since(start))
+		return nil, err
+	}
+
+	mock := p.GetConfig().Mock
+	id := p.newID("chatcmpl-mock-")
+	created := time.Now().Unix()
+	model := requestModel(req)
+
+	chunk := func(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   model,
+			"choices": []interface{}{
+				map[string]interface{}{
+					"index":         0,
+					"delta":         delta,
+					"finish_reason": finishReason,
+				},
+			},
+		}
+	}
+
+	eventChan := make(chan interface{}, 100)
+
+	go func() {
+		defer close(eventChan)
+
+		send := func(event interface{}) bool {
+			select {
+			case eventChan <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !send(chunk(map[string]interface{}{"role": "assistant"}, nil)) {
+			return
+		}
+
+		for _, word := range strings.SplitAfter(mock.Text, " ") {
+			if word == "" {
+				continue
+			}
+			if !send(chunk(map[string]interface{}{"content": word}, nil)) {
+				return
+			}
+		}
+
+		for _, tc := range p.toolCalls(true) {
+			if !send(chunk(map[string]interface{}{"tool_calls": []interface{}{tc}}, nil)) {
+				return
+			}
+		}
+
+		finishReason := "stop"
+		if len(mock.ToolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+		final := chunk(map[string]interface{}{}, finishReason)
+		final["usage"] = p.usage()
+		if !send(final) {
+			return
+		}
+
+		send(map[string]interface{}{
+			"type": "done",
+			"data": nil,
+		})
+
+		p.RecordRequest(true, time.Since(start))
+	}()
+
+	return eventChan, nil
+}
+
+// HealthCheck always succeeds since there is no backend
+func (p *MockProvider) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// RoundTrip serves Chat Completions requests from the canned response so the
+// mock can stand in for the backend transport of an http.Client
+func (p *MockProvider) RoundTrip(req *http.Request) (*http.Response, error) {
+	var chatReq map[string]interface{}
+	if req.Body != nil {
+		defer req.Body.Close()
+		if err := json.NewDecoder(req.Body).Decode(&chatReq); err != nil {
+			return nil, fmt.Errorf("failed to parse request: %w", err)
+		}
+	}
+
+	header := make(http.Header)
+
+	if stream, _ := chatReq["stream"].(bool); stream {
+		events, err := p.ExecuteStream(req.Context(), chatReq)
+		if err != nil {
+			return nil, err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			for event := range events {
+				if m, ok := event.(map[string]interface{}); ok && m["type"] == "done" {
+					fmt.Fprint(pw, "data: [DONE]\n\n")
+					continue
+				}
+				data, _ := json.Marshal(event)
+				fmt.Fprintf(pw, "data: %s\n\n", data)
+			}
+			pw.Close()
+		}()
+
+		header.Set("Content-Type", "text/event-stream")
+		return &http.Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       pr,
+			Request:    req,
+		}, nil
+	}
+
+	resp, err := p.Execute(req.Context(), chatReq)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// wait simulates backend latency, returning early if the context is cancelled
+func (p *MockProvider) wait(ctx context.Context) error {
+	latency := p.GetConfig().Mock.Latency
+	if latency <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// toolCalls renders the canned tool calls in Chat Completions format. Stream
+// deltas carry an index so clients can assemble them.
+func (p *MockProvider) toolCalls(indexed bool) []interface{} {
+	calls := []interface{}{}
+	for i, tc := range p.GetConfig().Mock.ToolCalls {
+		args := tc.Arguments
+		if args == "" {
+			args = "{}"
+		}
+		call := map[string]interface{}{
+			"id":   p.newID("call_mock_"),
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":      tc.Name,
+				"arguments": args,
+			},
+		}
+		if indexed {
+			call["index"] = i
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// usage returns a rough token count for the canned output
+func (p *MockProvider) usage() map[string]interface{} {
+	outputTokens := len(strings.Fields(p.GetConfig().Mock.Text)) + len(p.GetConfig().Mock.ToolCalls)
+	return map[string]interface{}{
+		"prompt_tokens":     0,
+		"completion_tokens": outputTokens,
+		"total_tokens":      outputTokens,
+	}
+}
+
+// requestModel extracts the model from a transformed request, defaulting to "mock"
+func requestModel(req interface{}) string {
+	if m, ok := req.(map[string]interface{}); ok {
+		if model, ok := m["model"].(string); ok && model != "" {
+			return model
+		}
+	}
+	return "mock"
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 )
@@ -13,13 +12,45 @@ import (
 // OpenAIProvider implements Provider for OpenAI backend
 type OpenAIProvider struct {
 	*BaseProvider
+
+	// newChatRequest builds the Chat Completions HTTP request for a model;
+	// AzureProvider replaces it to address a deployment
+	newChatRequest func(ctx context.Context, config ProviderConfig, model string, body []byte) (*http.Request, error)
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
 func NewOpenAIProvider() *OpenAIProvider {
-	return &OpenAIProvider{
+	p := &OpenAIProvider{
 		BaseProvider: NewBaseProvider("openai"),
 	}
+	p.newChatRequest = p.chatRequest
+	return p
+}
+
+// chatModel returns the model named in a Chat Completions request body
+func chatModel(req interface{}) string {
+	if m, ok := req.(map[string]interface{}); ok {
+		model, _ := m["model"].(string)
+		return model
+	}
+	return ""
+}
+
+// chatRequest builds a request to {base_url}/chat/completions with a Bearer token
+func (p *OpenAIProvider) chatRequest(ctx context.Context, config ProviderConfig, model string, body []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		config.BaseURL+"/chat/completions",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
+	return httpReq, nil
 }
 
 // Initialize initializes the OpenAI provider
@@ -84,8 +115,15 @@ func (p *OpenAIProvider) TransformRequest(req *ResponsesRequest) (interface{}, e
 	if req.TopP != nil {
 		chatReq["top_p"] = *req.TopP
 	}
+	if err := p.applyLogitBias(req, chatReq); err != nil {
+		return nil, err
+	}
+	p.applyIdentifiers(req, chatReq)
 	if req.Stream {
 		chatReq["stream"] = true
+		if len(req.StreamOptions) > 0 {
+			chatReq["stream_options"] = req.StreamOptions
+		}
 	}
 
 	return chatReq, nil
@@ -99,7 +137,7 @@ func (p *OpenAIProvider) TransformResponse(resp interface{}) (*ResponsesResponse
 	}
 
 	responsesResp := &ResponsesResponse{
-		ID:        fmt.Sprintf("resp_%d", time.Now().UnixNano()),
+		ID:        p.newID("resp_"),
 		Object:    "response",
 		CreatedAt: time.Now().Unix(),
 		Status:    "completed",
@@ -138,6 +176,10 @@ func (p *OpenAIProvider) TransformStreamEvent(event interface{}) (*ResponsesStre
 		return nil, fmt.Errorf("invalid event type")
 	}
 
+	if chunk["type"] == "tool_call" {
+		return toolCallStreamEvent(chunk), nil
+	}
+
 	if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
 		if choice, ok := choices[0].(map[string]interface{}); ok {
 			if delta, ok := choice["delta"].(map[string]interface{}); ok {
@@ -165,30 +207,20 @@ func (p *OpenAIProvider) Execute(ctx context.Context, req interface{}) (interfac
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	config := p.GetConfig()
-	httpReq, err := http.NewRequestWithContext(
-		ctx,
-		"POST",
-		config.BaseURL+"/chat/completions",
-		bytes.NewReader(body),
-	)
-	if err != nil {
-		p.RecordRequest(false, 0)
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
+	ctx, stop := p.requestContext(ctx)
+	defer stop()
 
-	client := p.GetClient()
-	httpResp, err := client.Do(httpReq)
+	model := chatModel(req)
+	httpResp, err := p.doWithRetry(ctx, func(config ProviderConfig) (*http.Request, error) {
+		return p.newChatRequest(ctx, config, model, body)
+	})
 	if err != nil {
 		p.RecordRequest(false, time.Since(start))
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer httpResp.Body.Close()
 
-	respBody, err := io.ReadAll(httpResp.Body)
+	respBody, err := ReadResponse(httpResp.Body, p.GetConfig().MaxResponseBytes)
 	if err != nil {
 		p.RecordRequest(false, time.Since(start))
 		return nil, fmt.Errorf("failed to read response: %w", err)
@@ -2,11 +2,9 @@ package providers
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -23,36 +21,32 @@ func (p *OpenAIProvider) ExecuteStream(ctx context.Context, req interface{}) (<-
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Create HTTP request
-	config := p.GetConfig()
-	httpReq, err := http.NewRequestWithContext(
-		ctx,
-		"POST",
-		config.BaseURL+"/chat/completions",
-		bytes.NewReader(body),
-	)
-	if err != nil {
-		p.RecordRequest(false, 0)
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+	// Create HTTP request, aborted if the provider is force-closed; stop is
+	// handed to the reader goroutine once the stream is open
+	ctx, stop := p.requestContext(ctx)
 
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
-	httpReq.Header.Set("Accept", "text/event-stream")
+	model := chatModel(req)
 
 	// Execute request
-	client := p.GetClient()
-	httpResp, err := client.Do(httpReq)
+	httpResp, err := p.doWithRetry(ctx, func(config ProviderConfig) (*http.Request, error) {
+		httpReq, err := p.newChatRequest(ctx, config, model, body)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Accept", "text/event-stream")
+		return httpReq, nil
+	})
 	if err != nil {
+		stop()
 		p.RecordRequest(false, time.Since(start))
 		return nil, fmt.Errorf("streaming request failed: %w", err)
 	}
 
 	// Check status code
 	if httpResp.StatusCode != http.StatusOK {
+		defer stop()
 		defer httpResp.Body.Close()
-		respBody, _ := io.ReadAll(httpResp.Body)
+		respBody, _ := ReadResponse(httpResp.Body, p.GetConfig().MaxResponseBytes)
 		p.RecordRequest(false, time.Since(start))
 		return nil, &ProviderError{
 			Provider:   p.name,
@@ -70,9 +64,11 @@ func (p *OpenAIProvider) ExecuteStream(ctx context.Context, req interface{}) (<-
 	// Start goroutine to read SSE stream
 	go func() {
 		defer close(eventChan)
+		defer stop()
 		defer httpResp.Body.Close()
 
 		scanner := bufio.NewScanner(httpResp.Body)
+		toolCalls := NewToolCallAccumulator(p.GetConfig().CumulativeToolCallDeltas)
 
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -83,6 +79,10 @@ func (p *OpenAIProvider) ExecuteStream(ctx context.Context, req interface{}) (<-
 				
 				// Check for end of stream
 				if data == "[DONE]" {
+					for _, event := range toolCalls.Flush() {
+						eventChan <- event
+					}
+
 					eventChan <- map[string]interface{}{
 						"type": "done",
 						"data": nil,
@@ -96,6 +96,15 @@ func (p *OpenAIProvider) ExecuteStream(ctx context.Context, req interface{}) (<-
 					continue
 				}
 
+				// Reassemble streamed tool calls and emit them, complete,
+				// ahead of the chunk that finishes the response
+				toolCalls.Add(chunk)
+				if chunkFinishReason(chunk) != "" {
+					for _, event := range toolCalls.Flush() {
+						eventChan <- event
+					}
+				}
+
 				// Send chunk to channel
 				eventChan <- chunk
 			}
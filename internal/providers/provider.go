@@ -2,6 +2,8 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
 	"time"
 )
 
@@ -13,6 +15,8 @@ const (
 	ProviderTypeZai       ProviderType = "zai"
 	ProviderTypeAnthropic ProviderType = "anthropic"
 	ProviderTypeCustom    ProviderType = "custom"
+	ProviderTypeMock      ProviderType = "mock"
+	ProviderTypeAzure     ProviderType = "azure"
 )
 
 // HealthState represents the health status of a provider
@@ -22,6 +26,10 @@ const (
 	HealthStateHealthy   HealthState = "healthy"
 	HealthStateDegraded  HealthState = "degraded"
 	HealthStateUnhealthy HealthState = "unhealthy"
+
+	// HealthStateAuthFailed means an authenticated health probe was refused
+	// with 401 or 403: the backend is up but the API key is not accepted
+	HealthStateAuthFailed HealthState = "auth_failed"
 )
 
 // Provider defines the interface for LLM backends
@@ -47,6 +55,8 @@ type Provider interface {
 	SupportsModel(model string) bool
 	SupportsStreaming() bool
 	SupportsTools() bool
+	SupportsVision() bool
+	SupportsReasoning() bool
 	GetModels() []string
 
 	// Health
@@ -58,15 +68,24 @@ type Provider interface {
 type ProviderConfig struct {
 	Name        string
 	Type        ProviderType
+	Plan        string // z.ai only: coding | standard
 	Enabled     bool
 	Priority    int
 	BaseURL     string
+	BaseURLs    []string // Warm standby endpoints tried after BaseURL on connection failure
 	APIKey      string
 	Timeout     time.Duration
 	MaxRetries  int
 	RetryDelay  time.Duration
-	Models      []string
-	HealthCheck HealthCheckConfig
+	RetryMaxElapsed time.Duration // Wall-clock budget across all attempts; 0 is unbounded
+	MaxResponseBytes int64 // Largest response body read; 0 uses the default
+	Models       []string
+	HealthCheck  HealthCheckConfig
+	Capabilities Capabilities
+	CumulativeToolCallDeltas bool // Tool call deltas resend the whole value so far
+	Mock         MockConfig // mock only: canned output
+	Azure        AzureConfig // azure only: resource and deployments
+	Proxy        ProxyConfig
 }
 
 // HealthCheckConfig contains health check configuration
@@ -75,6 +94,10 @@ type HealthCheckConfig struct {
 	Interval time.Duration
 	Timeout  time.Duration
 	Endpoint string
+
+	// Authenticated sends the API key with the probe, by default to the
+	// models endpoint, so a rejected key is reported as auth_failed
+	Authenticated bool
 }
 
 // ProviderMetrics contains provider performance metrics
@@ -102,10 +125,39 @@ type ResponsesRequest struct {
 	ToolChoice         interface{}              `json:"tool_choice,omitempty"`
 	ParallelToolCalls  bool                     `json:"parallel_tool_calls,omitempty"`
 	Stream             bool                     `json:"stream,omitempty"`
+	StreamOptions      map[string]interface{}   `json:"stream_options,omitempty"`
 	PreviousResponseID string                   `json:"previous_response_id,omitempty"`
 	Conversation       *Conversation            `json:"conversation,omitempty"`
 	Include            []string                 `json:"include,omitempty"`
 	Metadata           map[string]interface{}   `json:"metadata,omitempty"`
+	Truncation         string                   `json:"truncation,omitempty"` // auto | disabled
+	Background         bool                     `json:"background,omitempty"`
+	LogitBias          map[string]float64       `json:"logit_bias,omitempty"` // token ID -> bias in [-100, 100]
+	Reasoning          map[string]interface{}   `json:"reasoning,omitempty"`  // effort, summary
+	Store              *bool                    `json:"store,omitempty"`
+	PromptCacheKey     string                   `json:"prompt_cache_key,omitempty"`  // Groups requests sharing a prompt prefix for caching
+	SafetyIdentifier   string                   `json:"safety_identifier,omitempty"` // Stable, hashed end-user ID for abuse detection
+}
+
+// UnmarshalJSON accepts max_tokens as a deprecated alias for max_output_tokens
+func (r *ResponsesRequest) UnmarshalJSON(data []byte) error {
+	type responsesRequest ResponsesRequest
+	aux := struct {
+		*responsesRequest
+		MaxTokens *int `json:"max_tokens,omitempty"`
+	}{responsesRequest: (*responsesRequest)(r)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	// Prefer max_output_tokens when both are present
+	if aux.MaxTokens != nil && r.MaxOutputTokens == nil {
+		slog.Warn("max_tokens is deprecated on the Responses API, use max_output_tokens", "max_tokens", *aux.MaxTokens)
+		r.MaxOutputTokens = aux.MaxTokens
+	}
+
+	return nil
 }
 
 // ResponsesResponse represents a Responses API response
@@ -132,6 +184,36 @@ type Tool struct {
 	Function   *FunctionDefinition    `json:"function,omitempty"`
 }
 
+// UnmarshalJSON defaults a missing type to "function" and accepts the flat
+// Responses API form, where name, description and parameters sit on the tool
+// itself rather than under "function"
+func (t *Tool) UnmarshalJSON(data []byte) error {
+	type tool Tool
+	aux := struct {
+		*tool
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	}{tool: (*tool)(t)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if t.Function == nil && aux.Name != "" {
+		t.Function = &FunctionDefinition{
+			Name:        aux.Name,
+			Description: aux.Description,
+			Parameters:  aux.Parameters,
+		}
+	}
+	if t.Type == "" && t.Function != nil {
+		t.Type = "function"
+	}
+
+	return nil
+}
+
 // FunctionDefinition defines a function
 type FunctionDefinition struct {
 	Name        string                 `json:"name"`
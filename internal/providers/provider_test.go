@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestResponsesRequestMaxTokensAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *int
+	}{
+		{"alias only", `{"model":"glm-5","max_tokens":100}`, intPtr(100)},
+		{"canonical only", `{"model":"glm-5","max_output_tokens":200}`, intPtr(200)},
+		{"canonical wins over alias", `{"model":"glm-5","max_tokens":100,"max_output_tokens":200}`, intPtr(200)},
+		{"neither", `{"model":"glm-5"}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req ResponsesRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if req.Model != "glm-5" {
+				t.Errorf("Model = %q, want glm-5", req.Model)
+			}
+			switch {
+			case tt.want == nil && req.MaxOutputTokens != nil:
+				t.Errorf("MaxOutputTokens = %d, want unset", *req.MaxOutputTokens)
+			case tt.want != nil && (req.MaxOutputTokens == nil || *req.MaxOutputTokens != *tt.want):
+				t.Errorf("MaxOutputTokens = %v, want %d", req.MaxOutputTokens, *tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestToolMissingType(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     string
+		wantType string
+		wantName string
+	}{
+		{"flat without type", `{"name":"lookup","parameters":{"type":"object"}}`, "function", "lookup"},
+		{"nested without type", `{"function":{"name":"lookup"}}`, "function", "lookup"},
+		{"flat with type", `{"type":"function","name":"lookup"}`, "function", "lookup"},
+		{"other tool type kept", `{"type":"web_search"}`, "web_search", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req ResponsesRequest
+			if err := json.Unmarshal([]byte(`{"model":"glm-5","tools":[`+tt.tool+`]}`), &req); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			tool := req.Tools[0]
+			if tool.Type != tt.wantType {
+				t.Errorf("Type = %q, want %q", tool.Type, tt.wantType)
+			}
+			name := ""
+			if tool.Function != nil {
+				name = tool.Function.Name
+			}
+			if name != tt.wantName {
+				t.Errorf("Function.Name = %q, want %q", name, tt.wantName)
+			}
+		})
+	}
+
+	t.Run("forwarded to the backend as a function", func(t *testing.T) {
+		var req ResponsesRequest
+		json.Unmarshal([]byte(`{"model":"glm-5","input":"hi","tools":[{"name":"lookup"}]}`), &req)
+
+		chatReq, err := NewZaiProvider().TransformRequest(&req)
+		if err != nil {
+			t.Fatalf("TransformRequest() error = %v", err)
+		}
+		tools, _ := chatReq.(map[string]interface{})["tools"].([]map[string]interface{})
+		if len(tools) != 1 || tools[0]["type"] != "function" {
+			t.Fatalf("tools = %v, want one function tool", tools)
+		}
+		if fn := tools[0]["function"].(map[string]interface{}); fn["name"] != "lookup" {
+			t.Errorf("function name = %v, want lookup", fn["name"])
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ProxyConfig selects the outbound HTTP proxy for a provider. Unset fields
+// fall back to the standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables.
+type ProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+// ProxyFunc returns a function for http.Transport.Proxy that applies the config
+func (c ProxyConfig) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	if c == (ProxyConfig{}) {
+		return http.ProxyFromEnvironment
+	}
+
+	httpProxy := firstNonEmpty(c.HTTPProxy, os.Getenv("HTTP_PROXY"), os.Getenv("http_proxy"))
+	httpsProxy := firstNonEmpty(c.HTTPSProxy, os.Getenv("HTTPS_PROXY"), os.Getenv("https_proxy"))
+	noProxy := firstNonEmpty(c.NoProxy, os.Getenv("NO_PROXY"), os.Getenv("no_proxy"))
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+
+		proxy := httpProxy
+		if req.URL.Scheme == "https" {
+			proxy = httpsProxy
+		}
+		if proxy == "" {
+			return nil, nil
+		}
+
+		return ParseProxyURL(proxy)
+	}
+}
+
+// ParseProxyURL parses a proxy address, assuming http:// when no scheme is given
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	return url.Parse(proxy)
+}
+
+// bypassProxy reports whether a URL's host matches the comma-separated
+// no_proxy list. Entries may be "*", a host (matching it and its
+// subdomains), a host:port, an IP or a CIDR range.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host := u.Hostname()
+	port := u.Port()
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+
+		entry = strings.TrimPrefix(entry, ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
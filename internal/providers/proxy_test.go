@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProxyFunc(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ProxyConfig
+		env    map[string]string
+		url    string
+		want   string // "" means no proxy
+	}{
+		{"http proxy", ProxyConfig{HTTPProxy: "proxy.corp:3128"}, nil, "http://api.example.com/v1", "http://proxy.corp:3128"},
+		{"https proxy", ProxyConfig{HTTPProxy: "http://plain:80", HTTPSProxy: "https://secure.corp:8443"}, nil, "https://api.example.com/v1", "https://secure.corp:8443"},
+		{"no_proxy host", ProxyConfig{HTTPSProxy: "proxy.corp:3128", NoProxy: "internal.corp"}, nil, "https://llm.internal.corp/v1", ""},
+		{"no_proxy cidr", ProxyConfig{HTTPSProxy: "proxy.corp:3128", NoProxy: "10.0.0.0/8"}, nil, "https://10.1.2.3/v1", ""},
+		{"no_proxy port mismatch", ProxyConfig{HTTPSProxy: "proxy.corp:3128", NoProxy: "api.example.com:8443"}, nil, "https://api.example.com/v1", "http://proxy.corp:3128"},
+		{"config overrides environment", ProxyConfig{HTTPSProxy: "config.corp:1"}, map[string]string{"HTTPS_PROXY": "http://env.corp:2"}, "https://api.example.com", "http://config.corp:1"},
+		{"environment fills unset fields", ProxyConfig{HTTPProxy: "config.corp:1"}, map[string]string{"HTTPS_PROXY": "http://env.corp:2"}, "https://api.example.com", "http://env.corp:2"},
+		{"no proxy configured", ProxyConfig{NoProxy: "localhost"}, nil, "https://api.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, tt.url, nil)
+			proxy, err := tt.config.ProxyFunc()(req)
+			if err != nil {
+				t.Fatalf("proxy func error = %v", err)
+			}
+
+			got := ""
+			if proxy != nil {
+				got = proxy.String()
+			}
+			if got != tt.want {
+				t.Errorf("proxy = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitializeSetsTransportProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("https_proxy", "")
+
+	p := NewBaseProvider("zai")
+	if err := p.Initialize(ProviderConfig{Type: "zai", Proxy: ProxyConfig{HTTPSProxy: "proxy.corp:3128"}}); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	transport, ok := p.client.Transport.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		t.Fatal("transport proxy not set")
+	}
+	req, _ := http.NewRequest(http.MethodPost, "https://api.z.ai/v1/chat/completions", nil)
+	proxy, _ := transport.Proxy(req)
+	if proxy == nil || proxy.Host != "proxy.corp:3128" {
+		t.Errorf("proxy = %v, want proxy.corp:3128", proxy)
+	}
+}
@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 )
 
@@ -21,7 +22,9 @@ func NewRegistry() *Registry {
 	}
 }
 
-// Register adds a provider to the registry
+// Register adds a provider to the registry. Registering a name that is already
+// taken replaces the existing provider, which is shut down once the new one
+// has initialized; if initialization fails the existing provider is kept.
 func (r *Registry) Register(provider Provider, config ProviderConfig) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -31,6 +34,14 @@ func (r *Registry) Register(provider Provider, config ProviderConfig) error {
 		return fmt.Errorf("failed to initialize provider %s: %w", config.Name, err)
 	}
 
+	// Shut down the instance being replaced so it doesn't leak connections
+	if previous, exists := r.providers[config.Name]; exists && previous != provider {
+		slog.Info("replacing registered provider", "provider", config.Name)
+		if err := previous.Shutdown(); err != nil {
+			slog.Warn("failed to shutdown replaced provider", "provider", config.Name, "error", err)
+		}
+	}
+
 	// Store provider
 	r.providers[config.Name] = provider
 
@@ -130,14 +141,32 @@ func (r *Registry) Unregister(name string) error {
 	return nil
 }
 
-// HealthCheck runs health checks on all providers
-func (r *Registry) HealthCheck() map[string]error {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// RemoveAll removes every provider from the registry without shutting them
+// down, returning them in priority order followed by any disabled providers
+func (r *Registry) RemoveAll() []Provider {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := make([]Provider, 0, len(r.providers))
+	for _, name := range r.order {
+		removed = append(removed, r.providers[name])
+		delete(r.providers, name)
+	}
+	for _, provider := range r.providers {
+		removed = append(removed, provider)
+	}
+
+	r.providers = make(map[string]Provider)
+	r.order = []string{}
+	return removed
+}
 
+// HealthCheck runs health checks on all providers. The registry isn't locked
+// while they run, and cancelling ctx aborts the checks still running.
+func (r *Registry) HealthCheck(ctx context.Context) map[string]error {
 	results := make(map[string]error)
-	for name, provider := range r.providers {
-		results[name] = provider.HealthCheck(context.Background())
+	for name, provider := range r.GetAll() {
+		results[name] = provider.HealthCheck(ctx)
 	}
 	return results
 }
@@ -161,7 +190,7 @@ func (r *Registry) updateOrder(name string, priority int, enabled bool) {
 	// Insert at correct position based on priority
 	// Lower priority number = higher priority
 	inserted := false
-	for i, n := range newOrder {
+	for i := range newOrder {
 		// Get priority of existing provider (would need to store this)
 		// For now, just append
 		if i >= priority-1 {
@@ -0,0 +1,84 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+// trackedProvider records whether it was shut down and can fail initialization
+type trackedProvider struct {
+	*MockProvider
+	initErr  error
+	shutdown int
+}
+
+func newTrackedProvider() *trackedProvider {
+	return &trackedProvider{MockProvider: NewMockProvider()}
+}
+
+func (p *trackedProvider) Initialize(config ProviderConfig) error {
+	if p.initErr != nil {
+		return p.initErr
+	}
+	return p.MockProvider.Initialize(config)
+}
+
+func (p *trackedProvider) Shutdown() error {
+	p.shutdown++
+	return p.MockProvider.Shutdown()
+}
+
+func TestRegisterReplacesProvider(t *testing.T) {
+	config := ProviderConfig{Name: "primary", Type: ProviderTypeMock, Enabled: true, Priority: 1}
+
+	t.Run("previous instance is shut down", func(t *testing.T) {
+		r := NewRegistry()
+		first, second := newTrackedProvider(), newTrackedProvider()
+		if err := r.Register(first, config); err != nil {
+			t.Fatalf("first Register() error = %v", err)
+		}
+		if err := r.Register(second, config); err != nil {
+			t.Fatalf("second Register() error = %v", err)
+		}
+
+		if first.shutdown != 1 {
+			t.Errorf("replaced provider shut down %d times, want 1", first.shutdown)
+		}
+		if second.shutdown != 0 {
+			t.Errorf("new provider shut down %d times, want 0", second.shutdown)
+		}
+		if got, _ := r.Get("primary"); got != second {
+			t.Error("registry does not hold the new provider")
+		}
+		if names := r.List(); len(names) != 1 {
+			t.Errorf("List() = %v, want one provider", names)
+		}
+	})
+
+	t.Run("failed initialization keeps the previous instance", func(t *testing.T) {
+		r := NewRegistry()
+		first, broken := newTrackedProvider(), newTrackedProvider()
+		broken.initErr = errors.New("bad config")
+		r.Register(first, config)
+
+		if err := r.Register(broken, config); err == nil {
+			t.Fatal("Register() with failing provider succeeded")
+		}
+		if first.shutdown != 0 {
+			t.Error("previous provider shut down although the replacement failed")
+		}
+		if got, _ := r.Get("primary"); got != first {
+			t.Error("registry no longer holds the previous provider")
+		}
+	})
+
+	t.Run("re-registering the same instance does not shut it down", func(t *testing.T) {
+		r := NewRegistry()
+		p := newTrackedProvider()
+		r.Register(p, config)
+		r.Register(p, config)
+		if p.shutdown != 0 {
+			t.Errorf("provider shut down %d times, want 0", p.shutdown)
+		}
+	})
+}
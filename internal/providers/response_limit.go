@@ -0,0 +1,31 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// ErrResponseTooLarge is returned when a backend response body exceeds the
+// provider's max_response_bytes
+var ErrResponseTooLarge = errors.New("backend response too large")
+
+// ReadResponse reads a backend response body of at most maxBytes, or
+// appconfig.DefaultMaxResponseBytes when maxBytes is zero. A larger body is
+// not buffered; ErrResponseTooLarge is returned instead.
+func ReadResponse(body io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes <= 0 {
+		maxBytes = appconfig.DefaultMaxResponseBytes
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrResponseTooLarge, maxBytes)
+	}
+	return data, nil
+}
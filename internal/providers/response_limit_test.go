@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		maxBytes int64
+		wantErr  bool
+	}{
+		{"within limit", "hello", 10, false},
+		{"at limit", "hello", 5, false},
+		{"over limit", "hello!", 5, true},
+		{"default limit", "hello", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ReadResponse(strings.NewReader(tt.body), tt.maxBytes)
+			if tt.wantErr {
+				if !errors.Is(err, ErrResponseTooLarge) {
+					t.Errorf("ReadResponse() error = %v, want ErrResponseTooLarge", err)
+				}
+				return
+			}
+			if err != nil || string(data) != tt.body {
+				t.Errorf("ReadResponse() = %q, %v, want %q", data, err, tt.body)
+			}
+		})
+	}
+}
+
+func TestExecuteOversizedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"`+strings.Repeat("x", 4096)+`"}}]}`)
+	}))
+	defer server.Close()
+
+	p := NewZaiProvider()
+	if err := p.Initialize(ProviderConfig{Name: "zai", Type: ProviderTypeZai, BaseURL: server.URL, MaxResponseBytes: 1024}); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	_, err := p.Execute(context.Background(), map[string]interface{}{"model": "glm-5"})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("Execute() error = %v, want ErrResponseTooLarge", err)
+	}
+}
@@ -0,0 +1,93 @@
+package providers
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// maxRetryBackoff caps the exponential backoff between retries
+const maxRetryBackoff = 30 * time.Second
+
+// RetryPolicy bounds how often and for how long a failed backend request is
+// retried
+type RetryPolicy struct {
+	MaxRetries int           // Attempts after the first
+	Delay      time.Duration // Wait before the first retry, doubled for each one after
+	MaxElapsed time.Duration // Wall-clock budget across all attempts; 0 is unbounded
+}
+
+// backoff returns the wait before retry n (from 0): Delay doubled n times,
+// capped at maxRetryBackoff, with the upper half jittered so clients that
+// failed together don't retry in lockstep
+func (p RetryPolicy) backoff(n int) time.Duration {
+	if p.Delay <= 0 {
+		return 0
+	}
+	d := p.Delay
+	for i := 0; i < n && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxRetryBackoff)
+	return d/2 + rand.N(d/2+1)
+}
+
+// RetryableStatus reports whether a backend status is worth retrying
+func RetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// Do calls attempt until it succeeds or fails with a status that isn't
+// retryable. Transport errors and retryable statuses are retried up to
+// MaxRetries times with exponential backoff, but never past MaxElapsed: once
+// the next retry would start after the budget runs out, the last response or
+// error is returned immediately. Cancelling ctx stops the wait. The bodies of
+// responses given up on are closed.
+func (p RetryPolicy) Do(ctx context.Context, attempt func() (*http.Response, error)) (*http.Response, error) {
+	start := time.Now()
+	for retry := 0; ; retry++ {
+		resp, err := attempt()
+		if err == nil && !RetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if retry >= p.MaxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		delay := p.backoff(retry)
+		if p.MaxElapsed > 0 && time.Since(start)+delay >= p.MaxElapsed {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// retryPolicy returns the policy set by max_retries, retry_delay and
+// retry_max_elapsed
+func (c ProviderConfig) retryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: c.MaxRetries,
+		Delay:      c.RetryDelay,
+		MaxElapsed: c.RetryMaxElapsed,
+	}
+}
+
+// doWithRetry sends the request built by newReq, failing over between
+// endpoints, and retries it per the provider's retry policy
+func (p *BaseProvider) doWithRetry(ctx context.Context, newReq func(config ProviderConfig) (*http.Request, error)) (*http.Response, error) {
+	return p.GetConfig().retryPolicy().Do(ctx, func() (*http.Response, error) {
+		return p.doWithFailover(ctx, newReq)
+	})
+}
@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	errConn := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		results    []int // Status per attempt; 0 is a connection error
+		wantCalls  int
+		wantStatus int // 0 expects an error
+	}{
+		{"success", RetryPolicy{MaxRetries: 3}, []int{200}, 1, 200},
+		{"client error not retried", RetryPolicy{MaxRetries: 3}, []int{400}, 1, 400},
+		{"5xx retried until success", RetryPolicy{MaxRetries: 3}, []int{503, 502, 200}, 3, 200},
+		{"429 retried", RetryPolicy{MaxRetries: 1}, []int{429, 200}, 2, 200},
+		{"connection error retried", RetryPolicy{MaxRetries: 2}, []int{0, 200}, 2, 200},
+		{"retries exhausted returns last status", RetryPolicy{MaxRetries: 2}, []int{500, 500, 503}, 3, 503},
+		{"retries exhausted returns last error", RetryPolicy{MaxRetries: 1}, []int{500, 0}, 2, 0},
+		{"no retries", RetryPolicy{}, []int{500}, 1, 500},
+		{"elapsed budget stops retries", RetryPolicy{MaxRetries: 5, Delay: time.Hour, MaxElapsed: time.Second}, []int{500, 200}, 1, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			resp, err := tt.policy.Do(context.Background(), func() (*http.Response, error) {
+				status := tt.results[calls]
+				calls++
+				if status == 0 {
+					return nil, errConn
+				}
+				return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("attempts = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantStatus == 0 {
+				if !errors.Is(err, errConn) {
+					t.Errorf("Do() error = %v, want %v", err, errConn)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyMaxElapsed(t *testing.T) {
+	const attemptTime = 10 * time.Millisecond
+	policy := RetryPolicy{MaxRetries: 100, Delay: 20 * time.Millisecond, MaxElapsed: 150 * time.Millisecond}
+
+	calls := 0
+	start := time.Now()
+	resp, err := policy.Do(context.Background(), func() (*http.Response, error) {
+		calls++
+		time.Sleep(attemptTime) // A slow failing backend
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	elapsed := time.Since(start)
+
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Do() = %v, %v; want the last 503", resp, err)
+	}
+	// No retry starts past the budget, though the last one may run over it by
+	// its own length, plus scheduling slack
+	if limit := policy.MaxElapsed + 2*attemptTime; elapsed > limit {
+		t.Errorf("Do() took %v, past the %v budget plus an attempt", elapsed, policy.MaxElapsed)
+	}
+	if calls < 2 || calls > 5 {
+		t.Errorf("attempts = %d, want the few that fit in the budget", calls)
+	}
+}
+
+func TestRetryPolicyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxRetries: 3, Delay: time.Hour}
+
+	calls := 0
+	_, err := policy.Do(ctx, func() (*http.Response, error) {
+		calls++
+		cancel()
+		return nil, errors.New("connection reset")
+	})
+	if calls != 1 || err == nil {
+		t.Errorf("Do() = %d attempts, error %v; want 1 attempt and an error", calls, err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{Delay: time.Second}
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{0, 500 * time.Millisecond, time.Second},
+		{1, time.Second, 2 * time.Second},
+		{3, 4 * time.Second, 8 * time.Second},
+		{10, maxRetryBackoff / 2, maxRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			if d := policy.backoff(tt.retry); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d) = %v, want within [%v, %v]", tt.retry, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxRetries: 3, Delay: time.Hour}
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	calls := 0
+	_, err := policy.Do(ctx, func() (*http.Response, error) {
+		calls++
+		return nil, errors.New("connection reset")
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do() took %v after the context was cancelled", elapsed)
+	}
+	if calls != 1 || err == nil {
+		t.Errorf("Do() = %d attempts, error %v; want 1 attempt and an error", calls, err)
+	}
+}
@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// RoutingRule sends requests whose metadata matches to a named provider
+type RoutingRule struct {
+	Metadata map[string]string // Every key must be present with this value
+	Provider string
+}
+
+// RoutingRulesFromConfig converts providers.routing_rules
+func RoutingRulesFromConfig(rules []appconfig.RoutingRule) []RoutingRule {
+	converted := make([]RoutingRule, 0, len(rules))
+	for _, rule := range rules {
+		converted = append(converted, RoutingRule{
+			Metadata: rule.Metadata,
+			Provider: rule.Provider,
+		})
+	}
+	return converted
+}
+
+// Matches reports whether request metadata has every key and value of the
+// rule. Non-string metadata values are compared in their printed form, so
+// "true" matches a boolean true.
+func (r RoutingRule) Matches(metadata map[string]interface{}) bool {
+	if len(r.Metadata) == 0 {
+		return false
+	}
+
+	for key, want := range r.Metadata {
+		value, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		got, ok := value.(string)
+		if !ok {
+			got = fmt.Sprint(value)
+		}
+		if got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// SetRoutingRules sets the metadata rules consulted by SelectProvider, in order
+func (f *Factory) SetRoutingRules(rules []RoutingRule) {
+	f.routingRules = rules
+}
+
+// SelectProvider picks the provider for a request: the target of the first
+// routing rule matching its metadata, or else the one SelectProviderForModel
+// picks for its model. Rules naming a provider that isn't registered are
+// skipped.
+func (f *Factory) SelectProvider(ctx context.Context, req *ResponsesRequest) (Provider, error) {
+	for _, rule := range f.routingRules {
+		if !rule.Matches(req.Metadata) {
+			continue
+		}
+		if provider, ok := f.registry.Get(rule.Provider); ok {
+			return provider, nil
+		}
+		slog.Debug("routing rule names an unregistered provider, skipping", "provider", rule.Provider)
+	}
+
+	return f.SelectProviderForModel(ctx, req.Model)
+}
@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"context"
+	"testing"
+)
+
+// newTestFactory registers a z.ai provider for glm-* models at priority 1
+// and an OpenAI provider for gpt-* models at priority 2
+func newTestFactory(t *testing.T) *Factory {
+	t.Helper()
+	f := NewFactory()
+	err := f.InitializeProviders(map[string]ProviderConfig{
+		"zai":    {Type: ProviderTypeZai, Enabled: true, Priority: 1, BaseURL: "http://zai.invalid", Models: []string{"glm-*"}},
+		"openai": {Type: ProviderTypeOpenAI, Enabled: true, Priority: 2, BaseURL: "http://openai.invalid", Models: []string{"gpt-*"}},
+	}, true)
+	if err != nil {
+		t.Fatalf("InitializeProviders() = %v", err)
+	}
+	t.Cleanup(func() { f.ShutdownAll(context.Background()) })
+	return f
+}
+
+func TestSelectProvider(t *testing.T) {
+	rules := []RoutingRule{
+		{Metadata: map[string]string{"tier": "premium"}, Provider: "openai"},
+		{Metadata: map[string]string{"team": "search"}, Provider: "anthropic"}, // Not registered
+		{Metadata: map[string]string{"batch": "true"}, Provider: "zai"},
+		{Metadata: map[string]string{"tier": "premium", "region": "eu"}, Provider: "zai"},
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		metadata map[string]interface{}
+		want     string
+	}{
+		{"model match", "glm-5", nil, "zai"},
+		{"other model match", "gpt-4", nil, "openai"},
+		{"rule wins over model", "glm-5", map[string]interface{}{"tier": "premium"}, "openai"},
+		{"first matching rule wins", "glm-5", map[string]interface{}{"tier": "premium", "region": "eu"}, "openai"},
+		{"rule value must match", "glm-5", map[string]interface{}{"tier": "basic"}, "zai"},
+		{"unregistered rule target is skipped", "gpt-4", map[string]interface{}{"team": "search"}, "openai"},
+		{"non-string metadata compared printed", "gpt-4", map[string]interface{}{"batch": true}, "zai"},
+	}
+
+	f := newTestFactory(t)
+	f.SetRoutingRules(rules)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := f.SelectProvider(context.Background(), &ResponsesRequest{Model: tt.model, Metadata: tt.metadata})
+			if err != nil {
+				t.Fatalf("SelectProvider() error = %v", err)
+			}
+			if provider.Name() != tt.want {
+				t.Errorf("SelectProvider() = %s, want %s", provider.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectProviderUnmatchedModel(t *testing.T) {
+	f := newTestFactory(t)
+	if provider, err := f.SelectProvider(context.Background(), &ResponsesRequest{Model: "llama-3"}); err == nil {
+		t.Errorf("SelectProvider() = %s, want an error for a model no provider serves", provider.Name())
+	}
+}
+
+func TestRoutingRuleMatches(t *testing.T) {
+	rule := RoutingRule{Metadata: map[string]string{"tier": "premium", "region": "eu"}, Provider: "openai"}
+
+	tests := []struct {
+		name     string
+		rule     RoutingRule
+		metadata map[string]interface{}
+		want     bool
+	}{
+		{"all keys match", rule, map[string]interface{}{"tier": "premium", "region": "eu", "extra": "x"}, true},
+		{"missing key", rule, map[string]interface{}{"tier": "premium"}, false},
+		{"different value", rule, map[string]interface{}{"tier": "premium", "region": "us"}, false},
+		{"no metadata", rule, nil, false},
+		{"empty rule never matches", RoutingRule{Provider: "openai"}, map[string]interface{}{"tier": "premium"}, false},
+		{"number printed", RoutingRule{Metadata: map[string]string{"priority": "2"}}, map[string]interface{}{"priority": float64(2)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(tt.metadata); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutingRulesFromConfig(t *testing.T) {
+	rules := RoutingRulesFromConfig(nil)
+	if rules == nil || len(rules) != 0 {
+		t.Errorf("RoutingRulesFromConfig(nil) = %#v, want an empty slice", rules)
+	}
+}
+
+func TestGetProviderForModelOrDefault(t *testing.T) {
+	tests := []struct {
+		name                string
+		model               string
+		defaultForUnmatched bool
+		want                string // Registered name of the chosen provider; "" expects an error
+	}{
+		{"matched model", "gpt-4o", true, "openai"},
+		{"matched model without fallthrough", "gpt-4o", false, "openai"},
+		{"unmatched model falls through to the default", "custom-model", true, "zai"},
+		{"unmatched model without fallthrough", "custom-model", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFactory(t)
+			f.SetDefaultForUnmatched(tt.defaultForUnmatched)
+
+			got, err := f.GetProviderForModelOrDefault(tt.model)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("GetProviderForModelOrDefault(%q) succeeded, want an error", tt.model)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetProviderForModelOrDefault(%q) error = %v", tt.model, err)
+			}
+			if want, _ := f.GetProvider(tt.want); got != want {
+				t.Errorf("GetProviderForModelOrDefault(%q) = %s, want %s", tt.model, got.Name(), tt.want)
+			}
+		})
+	}
+}
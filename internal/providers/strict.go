@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// UnknownRequestFields returns the top-level fields of a Responses API
+// request body that ResponsesRequest doesn't define, sorted. The deprecated
+// max_tokens alias is known.
+func UnknownRequestFields(body []byte) ([]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	// Decode into the plain struct, without ResponsesRequest.UnmarshalJSON,
+	// so DisallowUnknownFields applies. Each field is checked on its own with
+	// a null value, which any field accepts, to report all of them.
+	type responsesRequest ResponsesRequest
+	var unknown []string
+	for name := range fields {
+		key, _ := json.Marshal(name)
+		dec := json.NewDecoder(bytes.NewReader(append(append([]byte("{"), key...), ":null}"...)))
+		dec.DisallowUnknownFields()
+
+		var req struct {
+			*responsesRequest
+			MaxTokens *int `json:"max_tokens"`
+		}
+		req.responsesRequest = &responsesRequest{}
+		if err := dec.Decode(&req); err != nil {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
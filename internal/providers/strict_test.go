@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnknownRequestFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{"known fields", `{"model":"glm-5","input":"hi","stream":true,"tools":[],"reasoning":{"effort":"low"},"store":false}`, nil, false},
+		{"max_tokens alias", `{"model":"glm-5","max_tokens":100}`, nil, false},
+		{"typo", `{"model":"glm-5","max_token":100}`, []string{"max_token"}, false},
+		{"every unknown field, sorted", `{"zeta":1,"model":"glm-5","alpha":true}`, []string{"alpha", "zeta"}, false},
+		{"not an object", `[1,2]`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnknownRequestFields([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnknownRequestFields() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnknownRequestFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package providers
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ToolCallAccumulator reassembles tool calls streamed as Chat Completions
+// deltas. Backends may split a tool call's id, name and arguments across
+// chunks, so each is accumulated per tool call index until the stream
+// finishes.
+type ToolCallAccumulator struct {
+	calls      map[int]*streamedToolCall
+	cumulative bool
+}
+
+type streamedToolCall struct {
+	id        string
+	name      string
+	arguments string
+}
+
+// NewToolCallAccumulator creates an empty accumulator for one stream.
+// cumulative declares that the backend resends each value whole; see
+// MergeToolCallFragment.
+func NewToolCallAccumulator(cumulative bool) *ToolCallAccumulator {
+	return &ToolCallAccumulator{calls: make(map[int]*streamedToolCall), cumulative: cumulative}
+}
+
+// Add accumulates the tool call fragments in a Chat Completions chunk
+func (a *ToolCallAccumulator) Add(chunk map[string]interface{}) {
+	choices, _ := chunk["choices"].([]interface{})
+	for _, choice := range choices {
+		choiceMap, _ := choice.(map[string]interface{})
+		delta, _ := choiceMap["delta"].(map[string]interface{})
+		toolCalls, _ := delta["tool_calls"].([]interface{})
+
+		for _, tc := range toolCalls {
+			tcMap, ok := tc.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			index := 0
+			if idx, ok := tcMap["index"].(float64); ok {
+				index = int(idx)
+			}
+
+			call, exists := a.calls[index]
+			if !exists {
+				call = &streamedToolCall{}
+				a.calls[index] = call
+			}
+
+			if id, ok := tcMap["id"].(string); ok {
+				call.id = MergeToolCallFragment(call.id, id, a.cumulative)
+			}
+			if fn, ok := tcMap["function"].(map[string]interface{}); ok {
+				if name, ok := fn["name"].(string); ok {
+					call.name = MergeToolCallFragment(call.name, name, a.cumulative)
+				}
+				if args, ok := fn["arguments"].(string); ok {
+					call.arguments = MergeToolCallFragment(call.arguments, args, a.cumulative)
+				}
+			}
+		}
+	}
+}
+
+// Flush returns one "tool_call" event per accumulated tool call, in index
+// order, and resets the accumulator. Each event carries the reassembled call
+// in Chat Completions form.
+func (a *ToolCallAccumulator) Flush() []map[string]interface{} {
+	indexes := make([]int, 0, len(a.calls))
+	for idx := range a.calls {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	events := make([]map[string]interface{}, 0, len(indexes))
+	for _, idx := range indexes {
+		call := a.calls[idx]
+		events = append(events, map[string]interface{}{
+			"type": "tool_call",
+			"tool_call": map[string]interface{}{
+				"index": idx,
+				"id":    call.id,
+				"type":  "function",
+				"function": map[string]interface{}{
+					"name":      call.name,
+					"arguments": call.arguments,
+				},
+			},
+		})
+	}
+
+	a.calls = make(map[int]*streamedToolCall)
+	return events
+}
+
+// MergeToolCallFragment appends a streamed id, name or arguments fragment to
+// the value received so far. Backends configured with
+// cumulative_tool_call_deltas resend the whole value on every chunk instead
+// of the next piece; for those a fragment that already starts with the value
+// so far replaces it rather than duplicating it. Other fragments are always
+// appended, since an argument piece may legitimately repeat what came before.
+func MergeToolCallFragment(current, fragment string, cumulative bool) string {
+	if cumulative && strings.HasPrefix(fragment, current) {
+		return fragment
+	}
+	return current + fragment
+}
+
+// chunkFinishReason returns the finish_reason of a Chat Completions chunk, if any
+func chunkFinishReason(chunk map[string]interface{}) string {
+	choices, _ := chunk["choices"].([]interface{})
+	for _, choice := range choices {
+		choiceMap, _ := choice.(map[string]interface{})
+		if reason, ok := choiceMap["finish_reason"].(string); ok && reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// toolCallStreamEvent transforms a reassembled "tool_call" event into a
+// completed function_call output item
+func toolCallStreamEvent(event map[string]interface{}) *ResponsesStreamEvent {
+	call, _ := event["tool_call"].(map[string]interface{})
+	fn, _ := call["function"].(map[string]interface{})
+
+	return &ResponsesStreamEvent{
+		Type: "response.output_item.done",
+		Data: map[string]interface{}{
+			"item": map[string]interface{}{
+				"type":      "function_call",
+				"status":    "completed",
+				"call_id":   call["id"],
+				"name":      fn["name"],
+				"arguments": fn["arguments"],
+			},
+		},
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,140 @@
+package providers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// toolCallChunk builds a Chat Completions chunk from its JSON form
+func toolCallChunk(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		t.Fatalf("chunk %q: %v", data, err)
+	}
+	return chunk
+}
+
+func TestToolCallAccumulator(t *testing.T) {
+	tests := []struct {
+		name       string
+		cumulative bool
+		chunks     []string
+		want       []map[string]string // id, name, arguments per call
+	}{
+		{
+			name: "name split across chunks",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_","arguments":""}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"weather","arguments":"{\"city\":"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+			},
+			want: []map[string]string{{"id": "call_1", "name": "get_weather", "arguments": `{"city":"Paris"}`}},
+		},
+		{
+			name: "id split across chunks",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_","function":{"name":"f"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"abc"}]}}]}`,
+			},
+			want: []map[string]string{{"id": "call_abc", "name": "f", "arguments": ""}},
+		},
+		{
+			name:       "cumulative backend resends whole values",
+			cumulative: true,
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+			},
+			want: []map[string]string{{"id": "call_1", "name": "get_weather", "arguments": `{"city":"Paris"}`}},
+		},
+		{
+			name: "repeated argument pieces appended",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"f","arguments":"a"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ab"}}]}}]}`,
+			},
+			want: []map[string]string{{"id": "call_1", "name": "f", "arguments": "aab"}},
+		},
+		{
+			name: "calls in index order",
+			chunks: []string{
+				`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"b"}}]}}]}`,
+				`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"a"}}]}}]}`,
+			},
+			want: []map[string]string{
+				{"id": "call_1", "name": "a", "arguments": ""},
+				{"id": "call_2", "name": "b", "arguments": ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := NewToolCallAccumulator(tt.cumulative)
+			for _, chunk := range tt.chunks {
+				acc.Add(toolCallChunk(t, chunk))
+			}
+
+			events := acc.Flush()
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d tool calls, want %d", len(events), len(tt.want))
+			}
+			for i, event := range events {
+				call := event["tool_call"].(map[string]interface{})
+				fn := call["function"].(map[string]interface{})
+				got := map[string]string{
+					"id":        call["id"].(string),
+					"name":      fn["name"].(string),
+					"arguments": fn["arguments"].(string),
+				}
+				for k, v := range tt.want[i] {
+					if got[k] != v {
+						t.Errorf("call %d %s = %q, want %q", i, k, got[k], v)
+					}
+				}
+			}
+
+			if rest := acc.Flush(); len(rest) != 0 {
+				t.Errorf("Flush() did not reset, %d calls left", len(rest))
+			}
+		})
+	}
+}
+
+func TestToolCallStreamEvent(t *testing.T) {
+	acc := NewToolCallAccumulator(false)
+	acc.Add(toolCallChunk(t, `{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_","arguments":"{}"}}]}}]}`))
+	acc.Add(toolCallChunk(t, `{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"weather"}}]}}]}`))
+
+	p := NewZaiProvider()
+	event, err := p.TransformStreamEvent(acc.Flush()[0])
+	if err != nil {
+		t.Fatalf("TransformStreamEvent() error = %v", err)
+	}
+	if event.Type != "response.output_item.done" {
+		t.Fatalf("event type = %s", event.Type)
+	}
+	item := event.Data.(map[string]interface{})["item"].(map[string]interface{})
+	if item["name"] != "get_weather" || item["call_id"] != "call_1" {
+		t.Errorf("item = %v, want get_weather call_1", item)
+	}
+}
+
+func TestMergeToolCallFragment(t *testing.T) {
+	tests := []struct {
+		current, fragment string
+		cumulative        bool
+		want              string
+	}{
+		{"a", "ab", false, "aab"},
+		{"a", "ab", true, "ab"},
+		{"ab", "c", true, "abc"},
+		{"", "x", true, "x"},
+	}
+	for _, tt := range tests {
+		if got := MergeToolCallFragment(tt.current, tt.fragment, tt.cumulative); got != tt.want {
+			t.Errorf("MergeToolCallFragment(%q, %q, %v) = %q, want %q", tt.current, tt.fragment, tt.cumulative, got, tt.want)
+		}
+	}
+}
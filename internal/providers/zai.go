@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
+
+	appconfig "github.com/plasmadev/codex-api-router/internal/config"
 )
 
+// DefaultZaiModels are the models served by a z.ai provider without a models list
+var DefaultZaiModels = []string{"glm-*", "chatglm-*"}
+
 // ZaiProvider implements Provider for z.ai backend
 type ZaiProvider struct {
 	*BaseProvider
@@ -27,7 +31,11 @@ func NewZaiProvider() *ZaiProvider {
 func (p *ZaiProvider) Initialize(config ProviderConfig) error {
 	// Set defaults
 	if config.BaseURL == "" {
-		config.BaseURL = "https://api.z.ai/api/paas/v4"
+		baseURL, err := appconfig.ZaiBaseURL(config.Plan)
+		if err != nil {
+			return err
+		}
+		config.BaseURL = baseURL
 	}
 	if config.Timeout == 0 {
 		config.Timeout = 120 * time.Second
@@ -36,7 +44,7 @@ func (p *ZaiProvider) Initialize(config ProviderConfig) error {
 		config.MaxRetries = 3
 	}
 	if len(config.Models) == 0 {
-		config.Models = []string{"glm-*", "chatglm-*"}
+		config.Models = DefaultZaiModels
 	}
 
 	return p.BaseProvider.Initialize(config)
@@ -90,8 +98,15 @@ func (p *ZaiProvider) TransformRequest(req *ResponsesRequest) (interface{}, erro
 	if req.TopP != nil {
 		chatReq["top_p"] = *req.TopP
 	}
+	if err := p.applyLogitBias(req, chatReq); err != nil {
+		return nil, err
+	}
+	p.applyIdentifiers(req, chatReq)
 	if req.Stream {
 		chatReq["stream"] = true
+		if len(req.StreamOptions) > 0 {
+			chatReq["stream_options"] = req.StreamOptions
+		}
 	}
 
 	// Transform tools if present
@@ -112,7 +127,7 @@ func (p *ZaiProvider) TransformResponse(resp interface{}) (*ResponsesResponse, e
 
 	// Transform to Responses API format
 	responsesResp := &ResponsesResponse{
-		ID:        fmt.Sprintf("resp_%d", time.Now().UnixNano()),
+		ID:        p.newID("resp_"),
 		Object:    "response",
 		CreatedAt: time.Now().Unix(),
 		Status:    "completed",
@@ -179,6 +194,10 @@ func (p *ZaiProvider) TransformStreamEvent(event interface{}) (*ResponsesStreamE
 		return nil, fmt.Errorf("invalid event type")
 	}
 
+	if chunk["type"] == "tool_call" {
+		return toolCallStreamEvent(chunk), nil
+	}
+
 	// Extract delta
 	if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
 		if choice, ok := choices[0].(map[string]interface{}); ok {
@@ -221,26 +240,13 @@ func (p *ZaiProvider) Execute(ctx context.Context, req interface{}) (interface{}
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Create HTTP request
-	config := p.GetConfig()
-	httpReq, err := http.NewRequestWithContext(
-		ctx,
-		"POST",
-		config.BaseURL+"/chat/completions",
-		bytes.NewReader(body),
-	)
-	if err != nil {
-		p.RecordRequest(false, 0)
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
+	// Execute request, aborted if the provider is force-closed
+	ctx, stop := p.requestContext(ctx)
+	defer stop()
 
-	// Execute request
-	client := p.GetClient()
-	httpResp, err := client.Do(httpReq)
+	httpResp, err := p.doWithRetry(ctx, func(config ProviderConfig) (*http.Request, error) {
+		return p.chatRequest(ctx, config, body, false)
+	})
 	if err != nil {
 		p.RecordRequest(false, time.Since(start))
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -248,7 +254,7 @@ func (p *ZaiProvider) Execute(ctx context.Context, req interface{}) (interface{}
 	defer httpResp.Body.Close()
 
 	// Read response
-	respBody, err := io.ReadAll(httpResp.Body)
+	respBody, err := ReadResponse(httpResp.Body, p.GetConfig().MaxResponseBytes)
 	if err != nil {
 		p.RecordRequest(false, time.Since(start))
 		return nil, fmt.Errorf("failed to read response: %w", err)
@@ -278,6 +284,25 @@ func (p *ZaiProvider) Execute(ctx context.Context, req interface{}) (interface{}
 	return resp, nil
 }
 
+// chatRequest builds a request to {base_url}/chat/completions with a Bearer token
+func (p *ZaiProvider) chatRequest(ctx context.Context, config ProviderConfig, body []byte, stream bool) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		config.BaseURL+"/chat/completions",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	return httpReq, nil
+}
 
 // Helper methods
 
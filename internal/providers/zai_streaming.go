@@ -2,11 +2,9 @@ package providers
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -23,36 +21,25 @@ func (p *ZaiProvider) ExecuteStream(ctx context.Context, req interface{}) (<-cha
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Create HTTP request
-	config := p.GetConfig()
-	httpReq, err := http.NewRequestWithContext(
-		ctx,
-		"POST",
-		config.BaseURL+"/chat/completions",
-		bytes.NewReader(body),
-	)
-	if err != nil {
-		p.RecordRequest(false, 0)
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
-	httpReq.Header.Set("Accept", "text/event-stream")
+	// Create HTTP request, aborted if the provider is force-closed; stop is
+	// handed to the reader goroutine once the stream is open
+	ctx, stop := p.requestContext(ctx)
 
 	// Execute request
-	client := p.GetClient()
-	httpResp, err := client.Do(httpReq)
+	httpResp, err := p.doWithRetry(ctx, func(config ProviderConfig) (*http.Request, error) {
+		return p.chatRequest(ctx, config, body, true)
+	})
 	if err != nil {
+		stop()
 		p.RecordRequest(false, time.Since(start))
 		return nil, fmt.Errorf("streaming request failed: %w", err)
 	}
 
 	// Check status code
 	if httpResp.StatusCode != http.StatusOK {
+		defer stop()
 		defer httpResp.Body.Close()
-		respBody, _ := io.ReadAll(httpResp.Body)
+		respBody, _ := ReadResponse(httpResp.Body, p.GetConfig().MaxResponseBytes)
 		p.RecordRequest(false, time.Since(start))
 		return nil, &ProviderError{
 			Provider:   p.name,
@@ -70,10 +57,12 @@ func (p *ZaiProvider) ExecuteStream(ctx context.Context, req interface{}) (<-cha
 	// Start goroutine to read SSE stream
 	go func() {
 		defer close(eventChan)
+		defer stop()
 		defer httpResp.Body.Close()
 
 		scanner := bufio.NewScanner(httpResp.Body)
 		var eventData strings.Builder
+		toolCalls := NewToolCallAccumulator(p.GetConfig().CumulativeToolCallDeltas)
 
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -84,6 +73,10 @@ func (p *ZaiProvider) ExecuteStream(ctx context.Context, req interface{}) (<-cha
 				
 				// Check for end of stream
 				if data == "[DONE]" {
+					for _, event := range toolCalls.Flush() {
+						eventChan <- event
+					}
+
 					// Send completion event
 					eventChan <- map[string]interface{}{
 						"type": "done",
@@ -99,6 +92,15 @@ func (p *ZaiProvider) ExecuteStream(ctx context.Context, req interface{}) (<-cha
 					continue
 				}
 
+				// Reassemble streamed tool calls and emit them, complete,
+				// ahead of the chunk that finishes the response
+				toolCalls.Add(chunk)
+				if chunkFinishReason(chunk) != "" {
+					for _, event := range toolCalls.Flush() {
+						eventChan <- event
+					}
+				}
+
 				// Send chunk to channel
 				eventChan <- chunk
 			} else if line == "" && eventData.Len() > 0 {
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// LogBuffer keeps the most recent log lines in a fixed-size ring and fans new
+// lines out to followers. It is an io.Writer so it can be teed into the
+// logger's output; each Write is expected to be one log line.
+type LogBuffer struct {
+	mu          sync.Mutex
+	lines       []string
+	next        int
+	full        bool
+	subscribers map[chan string]struct{}
+}
+
+// NewLogBuffer creates a ring buffer holding up to size lines
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &LogBuffer{
+		lines:       make([]string, size),
+		subscribers: make(map[chan string]struct{}),
+	}
+}
+
+// Write records a log line
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	line := string(bytes.TrimRight(p, "\n"))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	// Slow followers drop lines rather than block logging
+	for ch := range b.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// Recent returns the buffered lines, oldest first
+func (b *LogBuffer) Recent() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+	recent := make([]string, 0, len(b.lines))
+	recent = append(recent, b.lines[b.next:]...)
+	recent = append(recent, b.lines[:b.next]...)
+	return recent
+}
+
+// Subscribe returns a channel receiving new lines and a function to stop receiving them
+func (b *LogBuffer) Subscribe() (<-chan string, func()) {
+	ch := make(chan string, 100)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// AdminLogsHandler streams buffered log lines as server-sent events. With
+// ?follow=true the stream stays open and new lines are sent as they are
+// logged. Requests must carry the admin token as a bearer token.
+func AdminLogsHandler(buf *LogBuffer, token string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !adminAuthorized(w, r, token, logger) {
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// Subscribe before replaying so no line falls between the two
+		var lines <-chan string
+		follow := r.URL.Query().Get("follow") == "true"
+		if follow {
+			var unsubscribe func()
+			lines, unsubscribe = buf.Subscribe()
+			defer unsubscribe()
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		for _, line := range buf.Recent() {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+		flusher.Flush()
+
+		if !follow {
+			return
+		}
+
+		for {
+			select {
+			case line := <-lines:
+				fmt.Fprintf(w, "data: %s\n\n", line)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// StoreFlushResult reports what POST /admin/store/flush removed
+type StoreFlushResult struct {
+	Conversations int `json:"conversations"`
+	Responses     int `json:"responses"`
+}
+
+// FlushStore removes stored conversations and responses. With an empty id
+// everything is removed, responses kept for Idempotency-Key replay included;
+// otherwise only the conversation and response stored
+// under that response ID.
+func (h *ProxyHandler) FlushStore(id string) (StoreFlushResult, error) {
+	var result StoreFlushResult
+
+	if id == "" {
+		result.Responses = h.responses.Clear()
+		h.idempotent.Clear()
+		if h.store != nil {
+			n, err := h.store.Clear()
+			result.Conversations = n
+			if err != nil {
+				return result, err
+			}
+		}
+		return result, nil
+	}
+
+	if h.responses.Delete(id) {
+		result.Responses = 1
+	}
+	if h.store != nil {
+		if _, ok := h.store.Get(id); ok {
+			result.Conversations = 1
+		}
+		if err := h.store.Delete(id); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// AdminStoreFlushHandler serves POST /admin/store/flush, which clears the
+// stored responses and conversations. With ?conversation_id= only that
+// conversation and its response are removed. Requests must carry the admin
+// token as a bearer token.
+func AdminStoreFlushHandler(proxy *ProxyHandler, token string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !adminAuthorized(w, r, token, logger) {
+			return
+		}
+
+		id := r.URL.Query().Get("conversation_id")
+		result, err := proxy.FlushStore(id)
+		if err != nil {
+			logger.Error("failed to flush store", "conversation_id", id, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logger.Info("store flushed", "conversation_id", id, "conversations", result.Conversations, "responses", result.Responses)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"flushed": result})
+	}
+}
+
+// adminAuthorized checks the admin bearer token, answering 401 when it is
+// missing or wrong
+func adminAuthorized(w http.ResponseWriter, r *http.Request, token string, logger *slog.Logger) bool {
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		logger.Warn("unauthorized admin request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
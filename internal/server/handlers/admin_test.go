@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/session"
+)
+
+func TestLogBufferBounded(t *testing.T) {
+	buf := NewLogBuffer(3)
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		buf.Write([]byte(line))
+	}
+
+	want := []string{"two", "three", "four"}
+	if got := buf.Recent(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recent() = %v, want %v", got, want)
+	}
+}
+
+func TestAdminLogsHandler(t *testing.T) {
+	buf := NewLogBuffer(10)
+	buf.Write([]byte("first line\n"))
+	buf.Write([]byte("second line\n"))
+	srv := httptest.NewServer(AdminLogsHandler(buf, "admin-token", discardLogger()))
+	defer srv.Close()
+
+	get := func(t *testing.T, query, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			if resp := get(t, "", token); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("token %q: status = %d, want 401", token, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("replays recent lines", func(t *testing.T) {
+		resp := get(t, "", "admin-token")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("Content-Type = %q, want text/event-stream", ct)
+		}
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				lines = append(lines, data)
+			}
+		}
+		if want := []string{"first line", "second line"}; !reflect.DeepEqual(lines, want) {
+			t.Errorf("lines = %v, want %v", lines, want)
+		}
+	})
+
+	t.Run("follow streams new lines", func(t *testing.T) {
+		resp := get(t, "?follow=true", "admin-token")
+		lines := make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+					lines <- data
+				}
+			}
+			close(lines)
+		}()
+
+		for _, want := range []string{"first line", "second line"} {
+			if got := <-lines; got != want {
+				t.Fatalf("replayed line = %q, want %q", got, want)
+			}
+		}
+
+		buf.Write([]byte("live line\n"))
+		select {
+		case got := <-lines:
+			if got != "live line" {
+				t.Errorf("followed line = %q, want %q", got, "live line")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("new line not streamed")
+		}
+	})
+}
+
+func TestAdminStoreFlushHandler(t *testing.T) {
+	// seed returns a handler storing a conversation and a response under each ID
+	seed := func(t *testing.T, ids ...string) *ProxyHandler {
+		t.Helper()
+		h := NewProxyHandler(config.Default(), discardLogger())
+		for _, id := range ids {
+			h.responses.Save(id, "", map[string]interface{}{"id": id, "object": "response"})
+			if err := h.store.Save(&session.Conversation{ID: id}); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+		}
+		return h
+	}
+
+	flush := func(h *ProxyHandler, method, query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/store/flush"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		AdminStoreFlushHandler(h, "admin-token", discardLogger())(rec, req)
+		return rec
+	}
+
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) StoreFlushResult {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		var body struct {
+			Flushed StoreFlushResult `json:"flushed"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Body, err)
+		}
+		return body.Flushed
+	}
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		h := seed(t, "resp_1")
+		for _, token := range []string{"", "wrong"} {
+			if rec := flush(h, http.MethodPost, "", token); rec.Code != http.StatusUnauthorized {
+				t.Errorf("token %q: status = %d, want 401", token, rec.Code)
+			}
+		}
+		if _, ok := h.store.Get("resp_1"); !ok {
+			t.Error("unauthorized request flushed the store")
+		}
+	})
+
+	t.Run("POST only", func(t *testing.T) {
+		if rec := flush(seed(t), http.MethodGet, "", "admin-token"); rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want 405", rec.Code)
+		}
+	})
+
+	t.Run("full flush", func(t *testing.T) {
+		h := seed(t, "resp_1", "resp_2")
+		got := decode(t, flush(h, http.MethodPost, "", "admin-token"))
+		if want := (StoreFlushResult{Conversations: 2, Responses: 2}); got != want {
+			t.Errorf("flushed = %+v, want %+v", got, want)
+		}
+		for _, id := range []string{"resp_1", "resp_2"} {
+			if _, ok := h.store.Get(id); ok {
+				t.Errorf("conversation %s survived the flush", id)
+			}
+			if _, ok := h.responses.Get(id); ok {
+				t.Errorf("response %s survived the flush", id)
+			}
+		}
+	})
+
+	t.Run("targeted delete", func(t *testing.T) {
+		h := seed(t, "resp_1", "resp_2")
+		got := decode(t, flush(h, http.MethodPost, "?conversation_id=resp_1", "admin-token"))
+		if want := (StoreFlushResult{Conversations: 1, Responses: 1}); got != want {
+			t.Errorf("flushed = %+v, want %+v", got, want)
+		}
+		if _, ok := h.store.Get("resp_1"); ok {
+			t.Error("targeted conversation survived")
+		}
+		if _, ok := h.store.Get("resp_2"); !ok {
+			t.Error("other conversation was removed")
+		}
+		if _, ok := h.responses.Get("resp_2"); !ok {
+			t.Error("other response was removed")
+		}
+
+		got = decode(t, flush(h, http.MethodPost, "?conversation_id=resp_unknown", "admin-token"))
+		if got != (StoreFlushResult{}) {
+			t.Errorf("flushing an unknown conversation = %+v, want nothing removed", got)
+		}
+	})
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/plasmadev/codex-api-router/internal/providers"
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+)
+
+// anthropicStream reports whether the request asked for Anthropic-shaped streaming
+func anthropicStream(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, middleware.AnthropicStreamPrefix)
+}
+
+// anthropicStopReason maps a Chat Completions finish_reason to an Anthropic stop_reason
+func anthropicStopReason(reason string) string {
+	switch reason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+// transformStreamAnthropic relays a Chat Completions stream as Anthropic
+// Messages events: message_start, then a content_block_start/delta/stop
+// sequence per text or tool_use block, message_delta and message_stop. Like
+// transformStream it returns the response ID and, if the stream completed,
+// the assembled assistant message.
+func (h *ProxyHandler) transformStreamAnthropic(body io.ReadCloser, w http.ResponseWriter, flusher http.Flusher, target *backend, clientModel string) (string, map[string]interface{}) {
+	reader := bufio.NewReader(body)
+	messageID := h.newID("msg_")
+
+	emit := func(event map[string]interface{}) {
+		eventData, _ := json.Marshal(event)
+		fmt.Fprintf(w, "event: %s\n", event["type"])
+		fmt.Fprintf(w, "data: %s\n\n", string(eventData))
+		flusher.Flush()
+	}
+
+	sentStart := false
+	blockIndex := -1   // index of the open content block
+	textBlock := false // whether the open block is text
+	fullText := ""
+	backendModel := ""
+	finishReason := ""
+	var usage map[string]interface{}
+
+	// Tool calls by Chat Completions index; each is its own content block
+	toolCalls := make(map[int]map[string]interface{})
+	toolBlocks := make(map[int]int)
+	completed := false
+
+	// Whether the backend resends tool call fragments whole
+	cumulative := target != nil && target.config.CumulativeToolCallDeltas
+
+	closeBlock := func() {
+		if blockIndex >= 0 {
+			emit(map[string]interface{}{"type": "content_block_stop", "index": blockIndex})
+		}
+	}
+
+	// startToolBlock opens a tool call's tool_use block with the id and name
+	// received so far, and sends any arguments already accumulated
+	startToolBlock := func(index int) {
+		tcInfo := toolCalls[index]
+		if tcInfo["id"] == "" {
+			tcInfo["id"] = h.newID("toolu_")
+		}
+
+		closeBlock()
+		blockIndex++
+		textBlock = false
+		toolBlocks[index] = blockIndex
+		emit(map[string]interface{}{
+			"type":  "content_block_start",
+			"index": blockIndex,
+			"content_block": map[string]interface{}{
+				"type":  "tool_use",
+				"id":    tcInfo["id"],
+				"name":  tcInfo["name"],
+				"input": map[string]interface{}{},
+			},
+		})
+		if args := tcInfo["arguments"].(string); args != "" {
+			emit(map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": blockIndex,
+				"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": args},
+			})
+		}
+	}
+
+	// finish closes the open block and ends the message. Only the first
+	// call counts.
+	finish := func() {
+		if completed {
+			return
+		}
+
+		// Tool calls that never streamed arguments still get a block
+		for _, idx := range sortedKeys(toolCalls) {
+			if _, started := toolBlocks[idx]; !started {
+				startToolBlock(idx)
+			}
+		}
+		closeBlock()
+
+		outputTokens := interface{}(0)
+		if usage != nil {
+			outputTokens = usage["completion_tokens"]
+		}
+		messageDelta := map[string]interface{}{
+			"type": "message_delta",
+			"delta": map[string]interface{}{
+				"stop_reason":   anthropicStopReason(finishReason),
+				"stop_sequence": nil,
+			},
+			"usage": map[string]interface{}{"output_tokens": outputTokens},
+		}
+		if cost, ok := h.recordCost(h.providerName(target), clientModel, backendModel, usage); ok && h.cfg.Codex.ExposeCost {
+			messageDelta["x_cost"] = cost
+		}
+		emit(messageDelta)
+		emit(map[string]interface{}{"type": "message_stop"})
+		completed = true
+	}
+
+	// Output limit: text and tool-call arguments past it are dropped and the
+	// message stops as if it hit max_tokens
+	limit := outputLimit{max: h.cfg.Server.MaxStreamOutputBytes}
+	truncate := func() {
+		h.logger.Warn("stream output exceeds the output limit, ending response as incomplete", "limit", limit.max)
+		finishReason = "length"
+		finish()
+	}
+
+	for {
+		line, err := limit.readLine(reader)
+		if errors.Is(err, errLineTooLong) {
+			switch {
+			case sentStart:
+				truncate()
+			case !responseStarted(w):
+				// Nothing of the message was relayed
+				h.logger.Warn("first stream event exceeds the output limit, failing message", "limit", limit.max)
+				h.writeError(w, http.StatusBadGateway, "api_error", "backend_response_too_large", eventTooLargeMessage)
+			default:
+				h.logger.Warn("first stream event exceeds the output limit, failing message", "limit", limit.max)
+				emit(map[string]interface{}{
+					"type": "error",
+					"error": map[string]interface{}{
+						"type":    middleware.AnthropicErrorType(http.StatusBadGateway, "api_error"),
+						"message": eventTooLargeMessage,
+					},
+				})
+			}
+			break
+		}
+		if err != nil {
+			if err != io.EOF {
+				h.logger.Error("error reading stream", "error", err)
+			}
+			break
+		}
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		if data == "[DONE]" {
+			finish()
+			drainStream(body)
+			break
+		}
+
+		var chunk map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			transformErrorsStream.Add(1)
+			h.logger.Debug("failed to parse chunk", "error", err)
+			continue
+		}
+		h.remapResponse(chunk, target)
+
+		if u, ok := chunk["usage"].(map[string]interface{}); ok {
+			usage = u
+		}
+
+		if !sentStart {
+			backendModel, _ = chunk["model"].(string)
+			message := map[string]interface{}{
+				"id":            messageID,
+				"type":          "message",
+				"role":          "assistant",
+				"content":       []interface{}{},
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+				"usage":         map[string]interface{}{"input_tokens": 0, "output_tokens": 0},
+			}
+			h.setResponseModel(message, clientModel, backendModel)
+			emit(map[string]interface{}{"type": "message_start", "message": message})
+			sentStart = true
+		}
+
+		truncated, fits := false, true
+		choices, _ := chunk["choices"].([]interface{})
+		for _, choice := range choices {
+			choiceMap, ok := choice.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if reason, ok := choiceMap["finish_reason"].(string); ok && reason != "" {
+				finishReason = reason
+			}
+			delta, ok := chunkDelta(choiceMap, fullText, len(toolCalls) > 0)
+			if !ok {
+				continue
+			}
+
+			content, _ := delta["content"].(string)
+			if content, fits = limit.fit(content); !fits {
+				truncated = true
+			}
+			if content != "" {
+				if blockIndex < 0 || !textBlock {
+					closeBlock()
+					blockIndex++
+					textBlock = true
+					emit(map[string]interface{}{
+						"type":          "content_block_start",
+						"index":         blockIndex,
+						"content_block": map[string]interface{}{"type": "text", "text": ""},
+					})
+				}
+				fullText += content
+				emit(map[string]interface{}{
+					"type":  "content_block_delta",
+					"index": blockIndex,
+					"delta": map[string]interface{}{"type": "text_delta", "text": content},
+				})
+			}
+
+			toolCallsDelta, _ := delta["tool_calls"].([]interface{})
+			for _, tc := range toolCallsDelta {
+				tcMap, ok := tc.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				index := 0
+				if idx, ok := tcMap["index"].(float64); ok {
+					index = int(idx)
+				}
+				fn, _ := tcMap["function"].(map[string]interface{})
+
+				tcInfo, exists := toolCalls[index]
+				if !exists {
+					tcInfo = map[string]interface{}{"id": "", "name": "", "arguments": ""}
+					toolCalls[index] = tcInfo
+				}
+
+				// The id and name may be split across chunks, so the tool_use
+				// block is only started once arguments begin or the stream ends
+				if id, ok := tcMap["id"].(string); ok && id != "" {
+					tcInfo["id"] = providers.MergeToolCallFragment(tcInfo["id"].(string), id, cumulative)
+				}
+				if name, ok := fn["name"].(string); ok && name != "" {
+					tcInfo["name"] = providers.MergeToolCallFragment(tcInfo["name"].(string), name, cumulative)
+				}
+
+				// Only the part not yet received is forwarded when the
+				// backend resends the arguments whole
+				args, _ := fn["arguments"].(string)
+				current := tcInfo["arguments"].(string)
+				args = providers.MergeToolCallFragment(current, args, cumulative)[len(current):]
+				if args, fits = limit.fit(args); !fits {
+					truncated = true
+				}
+				if args != "" {
+					tcInfo["arguments"] = tcInfo["arguments"].(string) + args
+					if _, started := toolBlocks[index]; !started {
+						startToolBlock(index)
+					} else if toolBlocks[index] == blockIndex && !textBlock {
+						// Blocks can't interleave; arguments for an earlier tool
+						// call are still accumulated for the stored conversation
+						emit(map[string]interface{}{
+							"type":  "content_block_delta",
+							"index": blockIndex,
+							"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": args},
+						})
+					}
+				}
+			}
+		}
+
+		if truncated {
+			truncate()
+			break
+		}
+	}
+
+	if !completed {
+		return messageID, nil
+	}
+
+	assistant := map[string]interface{}{
+		"role":    "assistant",
+		"content": fullText,
+	}
+	if len(toolCalls) > 0 {
+		calls := make([]interface{}, 0, len(toolCalls))
+		for _, idx := range sortedKeys(toolCalls) {
+			tcInfo := toolCalls[idx]
+			calls = append(calls, map[string]interface{}{
+				"id":   tcInfo["id"],
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      tcInfo["name"],
+					"arguments": tcInfo["arguments"],
+				},
+			})
+		}
+		assistant["tool_calls"] = calls
+	}
+
+	return messageID, assistant
+}
+
+// writeAnthropicStreamError reports a backend failure as an Anthropic error
+// event on a 200 SSE response
+func (h *ProxyHandler) writeAnthropicStreamError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	errorEvent := map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    middleware.AnthropicErrorType(status, "api_error"),
+			"message": message,
+		},
+	}
+	eventData, _ := json.Marshal(errorEvent)
+	fmt.Fprintf(w, "event: error\n")
+	fmt.Fprintf(w, "data: %s\n\n", string(eventData))
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string
+		stop   string
+	}{
+		{
+			name: "text",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			},
+			want: []string{
+				"message_start",
+				"content_block_start",
+				"content_block_delta",
+				"content_block_delta",
+				"content_block_stop",
+				"message_delta",
+				"message_stop",
+			},
+			stop: "end_turn",
+		},
+		{
+			name: "text then tool call",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","content":"calling"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			want: []string{
+				"message_start",
+				"content_block_start",
+				"content_block_delta",
+				"content_block_stop",
+				"content_block_start",
+				"content_block_delta",
+				"content_block_delta",
+				"content_block_stop",
+				"message_delta",
+				"message_stop",
+			},
+			stop: "tool_use",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProxyHandler(testConfig(newTestBackend(t, replyStream(tt.chunks...))), discardLogger())
+
+			req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/responses", strings.NewReader(`{"model":"glm-5","input":"hi","stream":true}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			events := streamEvents(t, rec.Body)
+			var types []string
+			for _, event := range events {
+				types = append(types, event["type"].(string))
+			}
+			if !reflect.DeepEqual(types, tt.want) {
+				t.Errorf("event types = %q, want %q", types, tt.want)
+			}
+
+			delta := eventOfType(events, "message_delta")
+			if d, _ := delta["delta"].(map[string]interface{}); d["stop_reason"] != tt.stop {
+				t.Errorf("stop_reason = %v, want %s", d["stop_reason"], tt.stop)
+			}
+			message, _ := eventOfType(events, "message_start")["message"].(map[string]interface{})
+			if message["model"] != "glm-5" || message["role"] != "assistant" {
+				t.Errorf("message_start message = %v", message)
+			}
+		})
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	backend := newTestBackend(t, replyJSON(http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`))
+	cfg := testConfig(backend)
+	cfg.Providers.Zai.MaxRetries = 0
+	h := NewProxyHandler(cfg, discardLogger())
+
+	req := httptest.NewRequest(http.MethodPost, "/anthropic/v1/responses", strings.NewReader(`{"model":"glm-5","input":"hi","stream":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	event := eventOfType(streamEvents(t, rec.Body), "error")
+	if event == nil {
+		t.Fatalf("no error event in %s", rec.Body)
+	}
+	errObj, _ := event["error"].(map[string]interface{})
+	if errObj["type"] != "rate_limit_error" || errObj["message"] != "slow down" {
+		t.Errorf("error = %v, want rate_limit_error with the backend message", errObj)
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import "github.com/plasmadev/codex-api-router/internal/config"
+
+// responseSchema describes how one Responses API version shapes responses
+type responseSchema struct {
+	// functionCallItems emits tool calls in non-streaming responses as
+	// function_call output items, as streams do, rather than as tool_calls
+	// on the message item
+	functionCallItems bool
+
+	// doneEvent ends streams with a response.done event after the terminal
+	// response.completed or response.incomplete
+	doneEvent bool
+}
+
+// responseSchemas are the shipped schema variants by server.responses_api_version
+var responseSchemas = map[string]responseSchema{
+	config.ResponsesAPIV1: {functionCallItems: false, doneEvent: true},
+	config.ResponsesAPIV2: {functionCallItems: true, doneEvent: false},
+}
+
+// schema returns the response schema selected by server.responses_api_version,
+// v1 when unset
+func (h *ProxyHandler) schema() responseSchema {
+	if s, ok := responseSchemas[h.cfg.Server.ResponsesAPIVersion]; ok {
+		return s
+	}
+	return responseSchemas[config.ResponsesAPIDefault]
+}
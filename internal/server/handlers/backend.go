@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/providers"
+)
+
+// dispatchTypes are the provider types requests can be routed to: they take
+// the Chat Completions requests the handler builds, at base_url +
+// /chat/completions or, for Azure, at the model's deployment
+var dispatchTypes = map[providers.ProviderType]bool{
+	providers.ProviderTypeZai:    true,
+	providers.ProviderTypeOpenAI: true,
+	providers.ProviderTypeAzure:  true,
+}
+
+// registerProviders registers the enabled providers requests can be routed
+// to. One that fails to initialize is logged and left out of routing; the
+// error is returned when none could be, or when providers.strict is set.
+func registerProviders(factory *providers.Factory, cfg *config.Config) error {
+	configs := make(map[string]providers.ProviderConfig)
+	for name, provider := range cfg.Providers.GetProviders() {
+		pc := providers.ProviderConfigFromConfig(name, provider)
+		if !dispatchTypes[pc.Type] {
+			continue
+		}
+		configs[name] = pc
+	}
+	return factory.InitializeProviders(configs, cfg.Providers.Strict)
+}
+
+// backend is a provider requests can be dispatched to. Its providers section
+// decides how requests are shaped for it and sent.
+type backend struct {
+	name      string                // Provider name, as under providers
+	config    config.ProviderConfig // Capabilities, field mapping, retry and response size settings
+	provider  providers.Provider    // Registered provider; nil for z.ai when none is registered
+	client    *http.Client          // Sends requests with the provider's timeout and proxy
+	endpoints *providers.Endpoints  // base_url and base_urls, failed over between
+	apiKey    string
+	health    backendHealth // Consecutive failures, for degraded-mode jitter
+
+	// Azure OpenAI is addressed by deployment and authenticated with an
+	// api-key header
+	azure       bool
+	azureConfig providers.AzureConfig
+
+	// Capabilities deciding which requests are accepted and which optional
+	// fields are forwarded: the configured ones, with the provider type's
+	// defaults for unset settings
+	capabilities providers.Capabilities
+
+	// Template applied to request instructions; nil passes them through
+	instructions *template.Template
+}
+
+// newBackends returns the backends of the providers registered with factory,
+// keyed by name. z.ai is always among them: it also serves requests when no
+// provider is registered or in mock mode, with the legacy zai section filling
+// in its base URL, API key and timeout. A non-nil transport replaces z.ai's,
+// as the mock provider does.
+func newBackends(cfg *config.Config, factory *providers.Factory, transport http.RoundTripper, logger *slog.Logger) map[string]*backend {
+	sections := cfg.Providers.GetProviders()
+	backends := make(map[string]*backend)
+	for _, name := range factory.ListProviders() {
+		provider, err := factory.GetProvider(name)
+		if err != nil || name == "zai" {
+			continue
+		}
+		cg, ok := provider.(interface {
+			GetConfig() providers.ProviderConfig
+		})
+		if !ok {
+			continue
+		}
+		b := newBackend(cfg, sections[name], cg.GetConfig(), logger)
+		b.provider = provider
+		backends[name] = b
+	}
+
+	pc := providers.ProviderConfigFromConfig("zai", cfg.Providers.Zai)
+	if pc.BaseURL == "" {
+		pc.BaseURL = cfg.Zai.BaseURL
+	}
+	if pc.APIKey == "" {
+		pc.APIKey = cfg.Zai.APIKey
+	}
+	zai := newBackend(cfg, cfg.Providers.Zai, pc, logger)
+	if zai.client.Timeout == 0 {
+		zai.client.Timeout = cfg.Zai.Timeout
+	}
+	if transport != nil {
+		zai.client.Transport = transport
+	}
+	if !cfg.Providers.Mock.Enabled {
+		zai.provider, _ = factory.GetProvider("zai")
+	}
+	backends["zai"] = zai
+
+	return backends
+}
+
+// newBackend returns the backend for a providers section, reaching the
+// provider as pc describes
+func newBackend(cfg *config.Config, section config.ProviderConfig, pc providers.ProviderConfig, logger *slog.Logger) *backend {
+	instructions, err := config.ParseInstructionsTemplate(cfg.InstructionsTemplate(section))
+	if err != nil {
+		logger.Error("failed to parse instructions template, instructions are passed through", "provider", pc.Name, "error", err)
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:               pc.Proxy.ProxyFunc(),
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if cfg.Metrics.Enabled && cfg.Metrics.BackendConnections {
+		transport = &tracingTransport{next: transport}
+	}
+
+	return &backend{
+		name:   pc.Name,
+		config: section,
+		client: &http.Client{
+			Timeout:   section.Timeout,
+			Transport: transport,
+		},
+		endpoints:    providers.NewEndpoints(pc),
+		apiKey:       pc.APIKey,
+		azure:        pc.Type == providers.ProviderTypeAzure,
+		azureConfig:  pc.Azure,
+		capabilities: pc.Capabilities,
+		instructions: instructions,
+	}
+}
+
+// chatURL returns the Chat Completions URL for a backend model at one of the
+// backend's endpoints
+func (b *backend) chatURL(baseURL, model string) (string, error) {
+	if b.azure {
+		return providers.AzureChatURL(providers.ProviderConfig{BaseURL: baseURL, Azure: b.azureConfig}, model)
+	}
+	return baseURL + "/chat/completions", nil
+}
+
+// request returns a copy of backendReq for a backend model sent to baseURL,
+// with its own copy of the body so it can be sent after earlier attempts.
+// Unless the client's credentials are passed through, it authenticates with
+// the backend's key.
+func (b *backend) request(backendReq *http.Request, baseURL, model string, passthroughAuth bool) (*http.Request, error) {
+	chatURL, err := b.chatURL(baseURL, model)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(chatURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req := backendReq.Clone(backendReq.Context())
+	req.URL, req.Host = u, u.Host
+	if backendReq.GetBody != nil {
+		if req.Body, err = backendReq.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case passthroughAuth:
+	case b.azure:
+		req.Header.Del("Authorization")
+		req.Header.Set("api-key", b.apiKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+	return req, nil
+}
+
+// resolveBackend picks the backend serving a request for a backend model:
+// the target of the first providers.routing_rules entry matching its
+// metadata, else the highest priority provider whose models list matches the
+// model, else the default provider. With providers.cold_start, providers not
+// yet health checked are probed first so a down one is passed over. In mock
+// mode every request goes to z.ai.
+func (h *ProxyHandler) resolveBackend(ctx context.Context, model string, metadata map[string]interface{}) *backend {
+	if !h.cfg.Providers.Mock.Enabled && h.providers != nil {
+		req := &providers.ResponsesRequest{Model: model, Metadata: metadata}
+		if provider, err := h.providers.SelectProvider(ctx, req); err == nil {
+			if b, ok := h.backends[provider.Name()]; ok {
+				return b
+			}
+		}
+	}
+	return h.backends["zai"]
+}
+
+// route is where a request is dispatched: the backend it was routed to,
+// replaced by the fallback provider when that one fails, and the backend model
+type route struct {
+	backend *backend
+	model   string
+
+	// The Responses API request, reshaped for a fallback provider
+	req map[string]interface{}
+}
+
+type routeKey struct{}
+
+// withBackend records the backend the Responses API request req for a
+// backend model was routed to
+func withBackend(ctx context.Context, b *backend, model string, req map[string]interface{}) context.Context {
+	return context.WithValue(ctx, routeKey{}, &route{backend: b, model: model, req: req})
+}
+
+// routeFromContext returns the route of the request with ctx, or nil for
+// requests not routed by resolveBackend
+func routeFromContext(ctx context.Context) *route {
+	rt, _ := ctx.Value(routeKey{}).(*route)
+	return rt
+}
+
+// routedBackend returns the backend the request with ctx was routed to, or
+// z.ai for requests not routed by resolveBackend
+func (h *ProxyHandler) routedBackend(ctx context.Context) *backend {
+	if rt := routeFromContext(ctx); rt != nil {
+		return rt.backend
+	}
+	return h.backends["zai"]
+}
+
+// backendFor returns the backend of a registered provider, or nil
+func (h *ProxyHandler) backendFor(provider providers.Provider) *backend {
+	for _, b := range h.backends {
+		if b.provider == provider {
+			return b
+		}
+	}
+	return nil
+}
+
+// providerName names a backend for metrics and cost
+func (h *ProxyHandler) providerName(b *backend) string {
+	if h.cfg.Providers.Mock.Enabled {
+		return "mock"
+	}
+	return b.name
+}
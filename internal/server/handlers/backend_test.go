@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestRouting(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		rules      []config.RoutingRule
+		wantOpenAI bool
+		wantKey    bool // Whether prompt_cache_key reaches the backend
+	}{
+		{"z.ai model", `{"model":"glm-5","input":"hi","prompt_cache_key":"k"}`, nil, false, false},
+		{"openai model", `{"model":"gpt-4","input":"hi","prompt_cache_key":"k"}`, nil, true, true},
+		{"unmatched model goes to the default", `{"model":"llama-3","input":"hi"}`, nil, false, false},
+		{
+			"routing rule on metadata",
+			`{"model":"glm-5","input":"hi","metadata":{"tier":"premium"}}`,
+			[]config.RoutingRule{{Metadata: map[string]string{"tier": "premium"}, Provider: "openai"}},
+			true, false,
+		},
+		{
+			"routing rule not matching",
+			`{"model":"glm-5","input":"hi","metadata":{"tier":"basic"}}`,
+			[]config.RoutingRule{{Metadata: map[string]string{"tier": "premium"}, Provider: "openai"}},
+			false, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			openai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			cfg := testConfig(zai)
+			cfg.Providers.OpenAI.Enabled = true
+			cfg.Providers.OpenAI.APIKey = "openai-key"
+			cfg.Providers.OpenAI.BaseURL = openai.URL
+			cfg.Providers.RoutingRules = tt.rules
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, tt.body, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			want, other, wantAuth := zai, openai, "Bearer zai-key"
+			if tt.wantOpenAI {
+				want, other, wantAuth = openai, zai, "Bearer openai-key"
+			}
+			calls := want.received()
+			if len(calls) != 1 || len(other.received()) != 0 {
+				t.Fatalf("routed backend got %d requests, other got %d; want 1 and 0", len(calls), len(other.received()))
+			}
+			if calls[0].header.Get("Authorization") != wantAuth {
+				t.Errorf("Authorization = %q, want %q", calls[0].header.Get("Authorization"), wantAuth)
+			}
+			if calls[0].path != "/chat/completions" {
+				t.Errorf("path = %s, want /chat/completions", calls[0].path)
+			}
+			if _, ok := calls[0].body["prompt_cache_key"]; ok != tt.wantKey {
+				t.Errorf("prompt_cache_key forwarded = %v, want %v", ok, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestRoutingAzureDeployment(t *testing.T) {
+	var gotPath, gotKey string
+	azure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotKey = r.URL.Path, r.Header.Get("api-key")
+		replyJSON(http.StatusOK, chatCompletion)(w, r)
+	}))
+	defer azure.Close()
+
+	zai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+	cfg := testConfig(zai)
+	cfg.Providers.Azure = config.ProviderConfig{
+		Enabled:  true,
+		Type:     "azure",
+		Priority: 2,
+		APIKey:   "azure-key",
+		BaseURL:  azure.URL,
+		Models:   []string{"gpt-4o"},
+		Azure:    config.AzureConfig{Resource: "res", Deployments: map[string]string{"gpt-4o": "prod-4o"}},
+	}
+	h := NewProxyHandler(cfg, discardLogger())
+
+	if rec := postResponses(h, `{"model":"gpt-4o","input":"hi"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if gotPath != "/openai/deployments/prod-4o/chat/completions" {
+		t.Errorf("path = %s, want the prod-4o deployment", gotPath)
+	}
+	if gotKey != "azure-key" {
+		t.Errorf("api-key = %q, want azure-key", gotKey)
+	}
+}
+
+func TestRoutedProviderSettings(t *testing.T) {
+	zai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+	openai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+	cfg := testConfig(zai)
+	cfg.Providers.Zai.InstructionsTemplate = "zai {{.Instructions}}"
+	cfg.Providers.OpenAI.Enabled = true
+	cfg.Providers.OpenAI.APIKey = "openai-key"
+	cfg.Providers.OpenAI.BaseURL = openai.URL
+	cfg.Providers.OpenAI.InstructionsTemplate = "openai {{.Instructions}}"
+	h := NewProxyHandler(cfg, discardLogger())
+
+	for _, tt := range []struct {
+		model   string
+		backend *testBackend
+		want    string
+	}{
+		{"glm-5", zai, "zai x"},
+		{"gpt-4", openai, "openai x"},
+	} {
+		if rec := postResponses(h, `{"model":"`+tt.model+`","input":"hi","instructions":"x"}`, nil); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", tt.model, rec.Code, rec.Body)
+		}
+		calls := tt.backend.received()
+		messages, _ := calls[len(calls)-1].body["messages"].([]interface{})
+		var system string
+		for _, m := range messages {
+			if msg := m.(map[string]interface{}); msg["role"] == "system" {
+				system, _ = msg["content"].(string)
+			}
+		}
+		if system != tt.want {
+			t.Errorf("%s: system message = %q, want the routed provider's template %q", tt.model, system, tt.want)
+		}
+	}
+}
+
+func TestProviderInitError(t *testing.T) {
+	tests := []struct {
+		name    string
+		zai     bool
+		strict  bool
+		wantErr bool
+	}{
+		{"bad provider left out", true, false, false},
+		{"strict fails on the bad provider", true, true, true},
+		{"no provider initialized", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(newTestBackend(t, replyJSON(http.StatusOK, chatCompletion)))
+			cfg.Providers.Zai.Enabled = tt.zai
+			cfg.Providers.Strict = tt.strict
+			cfg.Providers.Azure = config.ProviderConfig{Enabled: true, Type: "azure", Priority: 2} // No resource or deployment
+			h := NewProxyHandler(cfg, discardLogger())
+			defer h.Shutdown(context.Background())
+
+			if err := h.InitError(); (err != nil) != tt.wantErr {
+				t.Errorf("InitError() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
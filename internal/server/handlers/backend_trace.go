@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// Backend connection pool behaviour (metrics.backend_connections)
+	backendConnReused   atomic.Int64
+	backendConnNew      atomic.Int64
+	backendConnectCount atomic.Int64
+	backendConnectNanos atomic.Int64
+	backendDNSCount     atomic.Int64
+	backendDNSNanos     atomic.Int64
+)
+
+// tracingTransport records connection reuse, DNS and connect latency for
+// every request it sends to the backend
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip attaches a ClientTrace to the request and passes it on
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := httptrace.WithClientTrace(req.Context(), newBackendTrace())
+	return t.next.RoundTrip(req.WithContext(ctx))
+}
+
+// CloseIdleConnections lets http.Client.CloseIdleConnections reach the
+// wrapped transport
+func (t *tracingTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// newBackendTrace returns the hooks feeding the backend connection metrics.
+// Dials to several addresses may race for one request, so connect starts are
+// tracked per address.
+func newBackendTrace() *httptrace.ClientTrace {
+	var (
+		mu       sync.Mutex
+		dnsStart time.Time
+		connects = make(map[string]time.Time)
+	)
+
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				backendConnReused.Add(1)
+			} else {
+				backendConnNew.Add(1)
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			start := dnsStart
+			mu.Unlock()
+			if info.Err == nil && !start.IsZero() {
+				backendDNSCount.Add(1)
+				backendDNSNanos.Add(int64(time.Since(start)))
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			connects[network+" "+addr] = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			start, ok := connects[network+" "+addr]
+			delete(connects, network+" "+addr)
+			mu.Unlock()
+			if err == nil && ok {
+				backendConnectCount.Add(1)
+				backendConnectNanos.Add(int64(time.Since(start)))
+			}
+		},
+	}
+}
+
+// backendConnMetrics renders the backend connection metrics
+func backendConnMetrics() string {
+	seconds := func(nanos *atomic.Int64) string {
+		return fmt.Sprintf("%.6f", time.Duration(nanos.Load()).Seconds())
+	}
+
+	return `# HELP codex_router_backend_conn_reused_total Backend requests sent on a reused pooled connection
+# TYPE codex_router_backend_conn_reused_total counter
+codex_router_backend_conn_reused_total ` + fmt.Sprint(backendConnReused.Load()) + `
+
+# HELP codex_router_backend_conn_new_total Backend requests that had to open a new connection
+# TYPE codex_router_backend_conn_new_total counter
+codex_router_backend_conn_new_total ` + fmt.Sprint(backendConnNew.Load()) + `
+
+# HELP codex_router_backend_connect_seconds Time spent establishing backend TCP connections
+# TYPE codex_router_backend_connect_seconds summary
+codex_router_backend_connect_seconds_sum ` + seconds(&backendConnectNanos) + `
+codex_router_backend_connect_seconds_count ` + fmt.Sprint(backendConnectCount.Load()) + `
+
+# HELP codex_router_backend_dns_seconds Time spent resolving backend host names
+# TYPE codex_router_backend_dns_seconds summary
+codex_router_backend_dns_seconds_sum ` + seconds(&backendDNSNanos) + `
+codex_router_backend_dns_seconds_count ` + fmt.Sprint(backendDNSCount.Load()) + `
+
+`
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// metricValue returns the value of the named metric series from /metrics
+func metricValue(t *testing.T, series string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler(discardLogger())(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, series+" "); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatalf("metric %q: %v", line, err)
+			}
+			return f
+		}
+	}
+	t.Fatalf("metric %s not found", series)
+	return 0
+}
+
+func TestTracingTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &tracingTransport{next: &http.Transport{}}}
+	defer client.CloseIdleConnections()
+
+	series := []string{
+		"codex_router_backend_conn_new_total",
+		"codex_router_backend_conn_reused_total",
+		"codex_router_backend_connect_seconds_count",
+	}
+	before := make(map[string]float64)
+	for _, s := range series {
+		before[s] = metricValue(t, s)
+	}
+
+	// The second request reuses the first one's connection
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	for _, s := range series {
+		if got := metricValue(t, s) - before[s]; got != 1 {
+			t.Errorf("%s increased by %v, want 1", s, got)
+		}
+	}
+}
+
+func TestBackendTraceDNS(t *testing.T) {
+	before := metricValue(t, "codex_router_backend_dns_seconds_count")
+
+	trace := newBackendTrace()
+	trace.DNSStart(httptrace.DNSStartInfo{Host: "backend.example"})
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+
+	failed := newBackendTrace()
+	failed.DNSStart(httptrace.DNSStartInfo{Host: "backend.invalid"})
+	failed.DNSDone(httptrace.DNSDoneInfo{Err: io.ErrUnexpectedEOF})
+
+	if got := metricValue(t, "codex_router_backend_dns_seconds_count") - before; got != 1 {
+		t.Errorf("dns count increased by %v, want 1 for the successful lookup only", got)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type backgroundIDKey struct{}
+
+// Background response statuses, as reported by GET /v1/responses/{id}
+const (
+	statusQueued     = "queued"
+	statusInProgress = "in_progress"
+	statusFailed     = "failed"
+)
+
+// backgroundJob is a request accepted with background: true
+type backgroundJob struct {
+	id          string
+	requestID   string // X-Request-Id of the request that queued it
+	clientModel string
+	createdAt   int64
+	history     []map[string]interface{}
+}
+
+// startBackground accepts a background request: it stores and returns a
+// queued response immediately, then runs the request in a worker goroutine
+// that records in_progress and the final response under the same ID.
+func (h *ProxyHandler) startBackground(w http.ResponseWriter, r *http.Request, backendReq *http.Request, history []map[string]interface{}, clientModel string) {
+	job := &backgroundJob{
+		id:          h.newID("resp_"),
+		requestID:   lifecycleFromContext(r.Context()).requestID(),
+		clientModel: clientModel,
+		createdAt:   lifecycleFromContext(r.Context()).createdAt(),
+		history:     history,
+	}
+	queued := h.backgroundResponse(job, statusQueued)
+	h.responses.Save(job.id, job.requestID, queued)
+
+	// The job outlives the client request, so it must not be cancelled with
+	// it, and reports the request's outcome itself
+	lifecycleFromContext(r.Context()).detach()
+	ctx := context.WithValue(context.WithoutCancel(r.Context()), backgroundIDKey{}, job.id)
+	go h.runBackground(job, r.WithContext(ctx), backendReq.WithContext(ctx))
+
+	h.logger.Info("background response queued", "response_id", job.id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(queued)
+}
+
+// runBackground is the worker for a background job. It waits for a dispatch
+// slot like any other request, then runs it as a non-streaming request.
+func (h *ProxyHandler) runBackground(job *backgroundJob, r *http.Request, backendReq *http.Request) {
+	status := http.StatusOK
+	defer func() { lifecycleFromContext(r.Context()).finish(status) }()
+
+	if err := h.queue.acquire(r.Context()); err != nil {
+		status = http.StatusTooManyRequests
+		h.saveBackgroundFailure(job, status, []byte(err.Error()))
+		return
+	}
+	release := h.queue.slot()
+	defer release()
+	r = r.WithContext(withSlot(r.Context(), release))
+
+	h.responses.Save(job.id, job.requestID, h.backgroundResponse(job, statusInProgress))
+
+	rec := newBufferedResponseWriter()
+	h.handleNonStreamingResponse(rec, r, backendReq, job.history, job.clientModel)
+
+	if rec.status != http.StatusOK {
+		status = rec.status
+		h.saveBackgroundFailure(job, status, rec.body.Bytes())
+		return
+	}
+
+	var completed map[string]interface{}
+	if err := json.Unmarshal(rec.body.Bytes(), &completed); err != nil {
+		status = http.StatusBadGateway
+		h.saveBackgroundFailure(job, status, rec.body.Bytes())
+		return
+	}
+	completed["created_at"] = job.createdAt
+	completed["background"] = true
+	h.responses.Save(job.id, job.requestID, completed)
+	h.logger.Info("background response completed", "response_id", job.id)
+}
+
+func (h *ProxyHandler) saveBackgroundFailure(job *backgroundJob, status int, body []byte) {
+	h.logger.Warn("background response failed", "response_id", job.id, "status", status)
+
+	failed := h.backgroundResponse(job, statusFailed)
+	failed["error"] = map[string]interface{}{
+		"code":    fmt.Sprintf("http_%d", status),
+		"message": backendErrorMessage(status, body),
+	}
+	h.responses.Save(job.id, job.requestID, failed)
+}
+
+// backgroundResponse builds the response reported while a background job
+// has not completed
+func (h *ProxyHandler) backgroundResponse(job *backgroundJob, status string) map[string]interface{} {
+	resp := map[string]interface{}{
+		"id":         job.id,
+		"object":     "response",
+		"created_at": job.createdAt,
+		"status":     status,
+		"background": true,
+		"output":     []interface{}{},
+	}
+	h.setResponseModel(resp, job.clientModel, "")
+	return resp
+}
+
+// backgroundIDFromContext returns the response ID reserved for a background
+// job, or "" for a foreground request
+func backgroundIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(backgroundIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getResponse polls GET /v1/responses/{id}
+func getResponse(t *testing.T, h http.Handler, id string) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/responses/"+id, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, body %s", id, rec.Code, rec.Body)
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp
+}
+
+// waitForStatus polls the response until it reports status
+func waitForStatus(t *testing.T, h http.Handler, id, status string) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp := getResponse(t, h, id)
+		if resp["status"] == status {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("response status = %v, want %s", resp["status"], status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBackgroundLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		replyJSON(http.StatusOK, chatCompletion)(w, r)
+	})
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi","background":true}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var queued map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &queued)
+	if queued["status"] != statusQueued || queued["background"] != true {
+		t.Fatalf("initial response = %v, want a queued background response", queued)
+	}
+	id, _ := queued["id"].(string)
+
+	// The worker is blocked on the backend
+	waitForStatus(t, h, id, statusInProgress)
+
+	close(release)
+	completed := waitForStatus(t, h, id, "completed")
+	if completed["id"] != id {
+		t.Errorf("completed id = %v, want %s", completed["id"], id)
+	}
+	if completed["created_at"] != queued["created_at"] {
+		t.Errorf("created_at = %v, want %v from the queued response", completed["created_at"], queued["created_at"])
+	}
+	output, _ := completed["output"].([]interface{})
+	if len(output) == 0 {
+		t.Error("completed response has no output")
+	}
+}
+
+func TestBackgroundFailure(t *testing.T) {
+	backend := newTestBackend(t, replyJSON(http.StatusBadRequest, `{"error":{"message":"model not found"}}`))
+	cfg := testConfig(backend)
+	cfg.Providers.Zai.MaxRetries = 0
+	h := NewProxyHandler(cfg, discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi","background":true}`, nil)
+	var queued map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &queued)
+
+	failed := waitForStatus(t, h, queued["id"].(string), statusFailed)
+	errObj, _ := failed["error"].(map[string]interface{})
+	if errObj["code"] != "http_400" || errObj["message"] != "model not found" {
+		t.Errorf("error = %v, want code http_400 with the backend message", errObj)
+	}
+}
+
+func TestBackgroundRejectsStreaming(t *testing.T) {
+	backend := newTestBackend(t, replyChat())
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi","background":true,"stream":true}`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if len(backend.received()) != 0 {
+		t.Error("rejected request reached the backend")
+	}
+}
+
+func TestBackgroundResponseTooLarge(t *testing.T) {
+	long := `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"` + strings.Repeat("x", 4096) + `"},"finish_reason":"stop"}]}`
+	backend := newTestBackend(t, replyJSON(http.StatusOK, long))
+	cfg := testConfig(backend)
+	cfg.Session.MaxResponseBytes = 2048
+	h := NewProxyHandler(cfg, discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi","background":true}`, nil)
+	var queued map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &queued)
+	id, _ := queued["id"].(string)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/responses/"+id, nil))
+		if rec.Code == http.StatusNotFound {
+			if code := decodeError(t, rec)["code"]; code != "response_too_large" {
+				t.Errorf("error code = %v, want response_too_large", code)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET status = %d, want 404 once the oversized response completes", rec.Code)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+)
+
+// maxBatchLineSize is the largest single JSON line accepted in a batch
+const maxBatchLineSize = 10 * 1024 * 1024
+
+// BatchHandler handles newline-delimited JSON batches of Responses API requests
+type BatchHandler struct {
+	cfg    *config.Config
+	logger *slog.Logger
+	proxy  *ProxyHandler
+}
+
+// batchResult is a single line of the batch output
+type batchResult struct {
+	Index    int             `json:"index"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    interface{}     `json:"error,omitempty"`
+}
+
+// NewBatchHandler creates a new batch handler that runs each item through the proxy
+func NewBatchHandler(cfg *config.Config, logger *slog.Logger, proxy *ProxyHandler) *BatchHandler {
+	return &BatchHandler{
+		cfg:    cfg,
+		logger: logger,
+		proxy:  proxy,
+	}
+}
+
+// writeError writes an error response in the configured envelope format
+func (h *BatchHandler) writeError(w http.ResponseWriter, status int, errType, code, message string) {
+	middleware.WriteError(w, h.cfg.Server.ErrorFormat, status, errType, code, message)
+}
+
+// ServeHTTP handles the batch request
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", fmt.Sprintf("Method %s not allowed", r.Method))
+		return
+	}
+	defer r.Body.Close()
+
+	// Read all lines up front so the batch size can be enforced before any work starts
+	lines := [][]byte{}
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		lines = append(lines, append([]byte(nil), line...))
+	}
+	if err := scanner.Err(); err != nil {
+		h.logger.Error("failed to read batch body", "error", err)
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "", "Failed to read batch body")
+		return
+	}
+
+	if len(lines) > h.cfg.Batch.MaxSize {
+		h.writeError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "", fmt.Sprintf("Batch contains %d requests, maximum is %d", len(lines), h.cfg.Batch.MaxSize))
+		return
+	}
+
+	h.logger.Info("processing batch", "size", len(lines), "concurrency", h.cfg.Batch.Concurrency)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	// Results are written as they complete, tagged with their input index
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, h.cfg.Batch.Concurrency)
+
+	for i, line := range lines {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(index int, line []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := h.processItem(r, index, line)
+			data, _ := json.Marshal(result)
+
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			w.Write(append(data, '\n'))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}(i, line)
+	}
+
+	wg.Wait()
+}
+
+// processItem runs a single batch line through the proxy pipeline
+func (h *BatchHandler) processItem(r *http.Request, index int, line []byte) batchResult {
+	var req map[string]interface{}
+	if err := json.Unmarshal(line, &req); err != nil {
+		return batchResult{
+			Index:  index,
+			Status: http.StatusBadRequest,
+			Error: map[string]interface{}{
+				"type":    "invalid_request_error",
+				"message": "Invalid JSON in batch line",
+			},
+		}
+	}
+
+	// Batch items are always answered as complete responses
+	req["stream"] = false
+	body, _ := json.Marshal(req)
+
+	itemReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, "/v1/responses", bytes.NewReader(body))
+	if err != nil {
+		return batchResult{
+			Index:  index,
+			Status: http.StatusInternalServerError,
+			Error: map[string]interface{}{
+				"type":    "internal_error",
+				"message": "Failed to create batch item request",
+			},
+		}
+	}
+	itemReq.Header.Set("Content-Type", "application/json")
+
+	// Items carry the batch's credential for providers.passthrough_auth
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		itemReq.Header.Set("Authorization", auth)
+	}
+
+	rec := newBufferedResponseWriter()
+	h.proxy.handleCreateResponse(rec, itemReq)
+
+	result := batchResult{Index: index, Status: rec.status}
+	if rec.status < http.StatusBadRequest {
+		result.Response = json.RawMessage(rec.body.Bytes())
+		if !json.Valid(result.Response) {
+			result.Response = nil
+			result.Error = map[string]interface{}{
+				"type":    "api_error",
+				"message": "Invalid response from backend",
+			}
+		}
+		return result
+	}
+
+	// Surface the proxy's error envelope when there is one
+	var envelope map[string]interface{}
+	if err := json.Unmarshal(rec.body.Bytes(), &envelope); err == nil && envelope["error"] != nil {
+		result.Error = envelope["error"]
+	} else {
+		result.Error = map[string]interface{}{
+			"type":    "api_error",
+			"message": rec.body.String(),
+		}
+	}
+
+	h.logger.Warn("batch item failed", "index", index, "status", rec.status)
+	return result
+}
+
+// bufferedResponseWriter captures a handler's response in memory
+type bufferedResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	const ok = `{"model":"glm-5","input":"hi"}`
+
+	tests := []struct {
+		name        string
+		lines       []string
+		maxSize     int
+		concurrency int
+		wantStatus  int
+		wantItems   map[int]int // Item index -> status
+	}{
+		{
+			name:        "one failing item",
+			lines:       []string{ok, `{"model":`, ok},
+			maxSize:     10,
+			concurrency: 2,
+			wantStatus:  http.StatusOK,
+			wantItems:   map[int]int{0: http.StatusOK, 1: http.StatusBadRequest, 2: http.StatusOK},
+		},
+		{
+			name:        "blank lines skipped",
+			lines:       []string{ok, "", ok},
+			maxSize:     2,
+			concurrency: 1,
+			wantStatus:  http.StatusOK,
+			wantItems:   map[int]int{0: http.StatusOK, 1: http.StatusOK},
+		},
+		{
+			name:        "over the size limit",
+			lines:       []string{ok, ok, ok},
+			maxSize:     2,
+			concurrency: 1,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "fan-out bounded by concurrency",
+			lines:       []string{ok, ok, ok, ok, ok, ok},
+			maxSize:     10,
+			concurrency: 2,
+			wantStatus:  http.StatusOK,
+			wantItems:   map[int]int{0: 200, 1: 200, 2: 200, 3: 200, 4: 200, 5: 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, peak atomic.Int64
+			backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				replyJSON(http.StatusOK, chatCompletion)(w, r)
+			})
+			cfg := testConfig(backend)
+			cfg.Batch.MaxSize = tt.maxSize
+			cfg.Batch.Concurrency = tt.concurrency
+			h := NewBatchHandler(cfg, discardLogger(), NewProxyHandler(cfg, discardLogger()))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/responses/batch", strings.NewReader(strings.Join(tt.lines, "\n")))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if n := len(backend.received()); n != 0 {
+					t.Errorf("backend received %d requests for a rejected batch", n)
+				}
+				return
+			}
+
+			got := make(map[int]int)
+			scanner := bufio.NewScanner(rec.Body)
+			for scanner.Scan() {
+				var result batchResult
+				if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+					t.Fatalf("result line %q: %v", scanner.Text(), err)
+				}
+				if result.Status == http.StatusOK && result.Response == nil {
+					t.Errorf("item %d succeeded without a response", result.Index)
+				}
+				if result.Status != http.StatusOK && result.Error == nil {
+					t.Errorf("item %d failed without an error", result.Index)
+				}
+				got[result.Index] = result.Status
+			}
+			if len(got) != len(tt.wantItems) {
+				t.Fatalf("results = %v, want %v", got, tt.wantItems)
+			}
+			for index, status := range tt.wantItems {
+				if got[index] != status {
+					t.Errorf("item %d status = %d, want %d", index, got[index], status)
+				}
+			}
+			if p := peak.Load(); p > int64(tt.concurrency) {
+				t.Errorf("peak concurrent backend requests = %d, want at most %d", p, tt.concurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/providers"
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+)
+
+// ProviderCapabilities describes one enabled provider in the capabilities response
+type ProviderCapabilities struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Models    []string `json:"models"`
+	Streaming bool     `json:"streaming"`
+	Tools     bool     `json:"tools"`
+	Vision    bool     `json:"vision"`
+	Reasoning bool     `json:"reasoning"`
+	LogitBias bool     `json:"logit_bias"`
+
+	PromptCacheKey   bool `json:"prompt_cache_key"`
+	SafetyIdentifier bool `json:"safety_identifier"`
+}
+
+// CapabilitiesResponse is the body of GET /v1/capabilities. Features is true
+// for a feature when at least one enabled provider supports it.
+type CapabilitiesResponse struct {
+	Object    string                 `json:"object"`
+	Version   string                 `json:"version"`
+	Providers []ProviderCapabilities `json:"providers"`
+	Models    []string               `json:"models"`
+	Features  map[string]bool        `json:"features"`
+}
+
+// CapabilitiesHandler reports the router's enabled providers, models and
+// features so clients can adapt their requests to the deployment
+func CapabilitiesHandler(cfg *config.Config, version string) http.HandlerFunc {
+	body := buildCapabilities(cfg, version)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			middleware.WriteError(w, cfg.Server.ErrorFormat, http.StatusMethodNotAllowed, "invalid_request_error", "", "Method not allowed")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func buildCapabilities(cfg *config.Config, version string) CapabilitiesResponse {
+	resp := CapabilitiesResponse{
+		Object:    "capabilities",
+		Version:   version,
+		Providers: []ProviderCapabilities{},
+		Features: map[string]bool{
+			"streaming":  false,
+			"tools":      false,
+			"vision":     false,
+			"reasoning":  false,
+			"logit_bias": false,
+
+			"prompt_cache_key":  false,
+			"safety_identifier": false,
+		},
+	}
+
+	enabled := make(map[string]config.ProviderConfig)
+	if cfg.Providers.Mock.Enabled {
+		// Mock mode replaces every backend
+		enabled["mock"] = config.ProviderConfig{Type: string(providers.ProviderTypeMock)}
+	} else {
+		for name, p := range cfg.Providers.GetProviders() {
+			if p.Enabled {
+				enabled[name] = p
+			}
+		}
+	}
+
+	models := make(map[string]bool)
+	for name, p := range enabled {
+		caps := providers.CapabilitiesFromConfig(providers.ProviderType(p.Type), p.Capabilities)
+
+		resp.Providers = append(resp.Providers, ProviderCapabilities{
+			Name:      name,
+			Type:      p.Type,
+			Models:    append([]string{}, p.Models...),
+			Streaming: caps.Streaming,
+			Tools:     caps.Tools,
+			Vision:    caps.Vision,
+			Reasoning: caps.Reasoning,
+			LogitBias: caps.LogitBias,
+
+			PromptCacheKey:   caps.PromptCacheKey,
+			SafetyIdentifier: caps.SafetyIdentifier,
+		})
+
+		resp.Features["streaming"] = resp.Features["streaming"] || caps.Streaming
+		resp.Features["tools"] = resp.Features["tools"] || caps.Tools
+		resp.Features["vision"] = resp.Features["vision"] || caps.Vision
+		resp.Features["reasoning"] = resp.Features["reasoning"] || caps.Reasoning
+		resp.Features["logit_bias"] = resp.Features["logit_bias"] || caps.LogitBias
+		resp.Features["prompt_cache_key"] = resp.Features["prompt_cache_key"] || caps.PromptCacheKey
+		resp.Features["safety_identifier"] = resp.Features["safety_identifier"] || caps.SafetyIdentifier
+
+		for _, model := range p.Models {
+			models[model] = true
+		}
+	}
+
+	// Client-facing names that are mapped onto backend models are accepted too
+	if len(enabled) > 0 {
+		for model := range cfg.Providers.ModelMapping {
+			models[model] = true
+		}
+	}
+
+	resp.Models = make([]string, 0, len(models))
+	for model := range models {
+		resp.Models = append(resp.Models, model)
+	}
+	sort.Strings(resp.Models)
+	sort.Slice(resp.Providers, func(i, j int) bool {
+		return resp.Providers[i].Name < resp.Providers[j].Name
+	})
+
+	return resp
+}
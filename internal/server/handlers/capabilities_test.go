@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestCapabilitiesHandler(t *testing.T) {
+	get := func(t *testing.T, cfg *config.Config) CapabilitiesResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		CapabilitiesHandler(cfg, "1.2.3")(rec, httptest.NewRequest(http.MethodGet, "/v1/capabilities", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		var resp CapabilitiesResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	names := func(resp CapabilitiesResponse) []string {
+		var names []string
+		for _, p := range resp.Providers {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	t.Run("default config", func(t *testing.T) {
+		resp := get(t, config.Default())
+
+		if resp.Version != "1.2.3" {
+			t.Errorf("version = %q, want 1.2.3", resp.Version)
+		}
+		if got := names(resp); !reflect.DeepEqual(got, []string{"zai"}) {
+			t.Errorf("providers = %v, want [zai]", got)
+		}
+		want := map[string]bool{"streaming": true, "tools": true, "vision": true, "reasoning": true, "logit_bias": false, "prompt_cache_key": false, "safety_identifier": false}
+		if !reflect.DeepEqual(resp.Features, want) {
+			t.Errorf("features = %v, want %v", resp.Features, want)
+		}
+		models := make(map[string]bool)
+		for _, m := range resp.Models {
+			models[m] = true
+		}
+		for _, m := range []string{"glm-5", "glm-4.7"} {
+			if !models[m] {
+				t.Errorf("model %q missing from %v", m, resp.Models)
+			}
+		}
+		if models["gpt-4"] {
+			t.Error("model of disabled openai provider reported")
+		}
+	})
+
+	t.Run("features are the union of enabled providers", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Providers.OpenAI.Enabled = true
+		cfg.Providers.OpenAI.Models = []string{"gpt-4o"}
+		cfg.Providers.ModelMapping = map[string]string{"codex": "glm-5"}
+		resp := get(t, cfg)
+
+		if got := names(resp); !reflect.DeepEqual(got, []string{"openai", "zai"}) {
+			t.Errorf("providers = %v, want [openai zai]", got)
+		}
+		if !resp.Features["vision"] {
+			t.Error("vision not reported although openai supports it")
+		}
+		models := make(map[string]bool)
+		for _, m := range resp.Models {
+			models[m] = true
+		}
+		for _, m := range []string{"gpt-4o", "glm-5", "codex"} {
+			if !models[m] {
+				t.Errorf("model %q missing from %v", m, resp.Models)
+			}
+		}
+	})
+
+	t.Run("disabled providers are omitted", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Providers.Zai.Enabled = false
+		resp := get(t, cfg)
+
+		if len(resp.Providers) != 0 || len(resp.Models) != 0 || resp.Features["streaming"] {
+			t.Errorf("got %+v, want no providers, models or features", resp)
+		}
+	})
+
+	t.Run("mock mode reports only the mock provider", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Providers.Mock.Enabled = true
+		resp := get(t, cfg)
+
+		if got := names(resp); !reflect.DeepEqual(got, []string{"mock"}) {
+			t.Errorf("providers = %v, want [mock]", got)
+		}
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		CapabilitiesHandler(config.Default(), "1.2.3")(rec, httptest.NewRequest(http.MethodPost, "/v1/capabilities", nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want 405", rec.Code)
+		}
+	})
+}
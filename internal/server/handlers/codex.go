@@ -0,0 +1,51 @@
+package handlers
+
+import "net/http"
+
+// codexHeaders are the identifying headers Codex CLI sends with each request
+type codexHeaders struct {
+	SessionID      string
+	ConversationID string
+	AccountID      string
+	Originator     string
+	Version        string
+}
+
+// parseCodexHeaders reads the Codex CLI headers from a request. Any may be empty.
+func parseCodexHeaders(r *http.Request) codexHeaders {
+	return codexHeaders{
+		SessionID:      r.Header.Get("Session_id"),
+		ConversationID: r.Header.Get("Conversation_id"),
+		AccountID:      r.Header.Get("Chatgpt-Account-Id"),
+		Originator:     r.Header.Get("Originator"),
+		Version:        r.Header.Get("Version"),
+	}
+}
+
+// logAttrs returns the non-empty headers as slog key/value pairs
+func (c codexHeaders) logAttrs() []any {
+	var attrs []any
+	for _, kv := range [][2]string{
+		{"codex_session_id", c.SessionID},
+		{"codex_conversation_id", c.ConversationID},
+		{"codex_account_id", c.AccountID},
+		{"codex_originator", c.Originator},
+		{"codex_version", c.Version},
+	} {
+		if kv[1] != "" {
+			attrs = append(attrs, kv[0], kv[1])
+		}
+	}
+	return attrs
+}
+
+// propagate forwards the session and conversation IDs to the backend so
+// requests can be correlated in its logs
+func (c codexHeaders) propagate(backendReq *http.Request) {
+	if c.SessionID != "" {
+		backendReq.Header.Set("Session_id", c.SessionID)
+	}
+	if c.ConversationID != "" {
+		backendReq.Header.Set("Conversation_id", c.ConversationID)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/providers"
+)
+
+// degradedAfterFailures is how many consecutive retryable failures mark a
+// backend degraded; one success clears it
+const degradedAfterFailures = 3
+
+// backendHealth tracks whether a backend is currently failing
+type backendHealth struct {
+	consecutiveFailures atomic.Int64
+}
+
+func (b *backendHealth) degraded() bool {
+	return b.consecutiveFailures.Load() >= degradedAfterFailures
+}
+
+// retryableStatus reports whether a backend status is worth a client retry
+func retryableStatus(status int) bool {
+	return providers.RetryableStatus(status)
+}
+
+// recordBackendResult updates the health of the backend the request with ctx
+// was routed to from its response status, where 0 means the backend could
+// not be reached. A request the client cancelled says nothing about the
+// backend and is not counted. For retryable failures while that backend is
+// degraded it waits a random delay of up to providers.degraded_response_jitter
+// before the caller reports the error, so clients retrying in lockstep are
+// spread out. The request's dispatch slot is freed first so the wait doesn't
+// hold up requests queued behind it.
+func (h *ProxyHandler) recordBackendResult(ctx context.Context, status int) {
+	if status == 0 && ctx.Err() != nil {
+		return
+	}
+	target := h.routedBackend(ctx)
+	if status != 0 && !retryableStatus(status) {
+		target.health.consecutiveFailures.Store(0)
+		return
+	}
+	target.health.consecutiveFailures.Add(1)
+
+	jitter := h.cfg.Providers.DegradedResponseJitter
+	if jitter <= 0 || !target.health.degraded() {
+		return
+	}
+
+	delay := rand.N(jitter)
+	h.logger.Debug("backend degraded, delaying error response", "provider", target.name, "delay_ms", delay.Milliseconds())
+	releaseSlot(ctx)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestRecordBackendResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int // 0 is a connection failure
+		wantCount int64
+	}{
+		{"success clears", []int{503, 503, 200}, 0},
+		{"client error clears", []int{503, 400}, 0},
+		{"retryable statuses count", []int{503, 429, 500}, 3},
+		{"connection failures count", []int{0, 0}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProxyHandler(config.Default(), discardLogger())
+
+			for _, status := range tt.statuses {
+				h.recordBackendResult(context.Background(), status)
+			}
+			if got := h.backends["zai"].health.consecutiveFailures.Load(); got != tt.wantCount {
+				t.Errorf("consecutive failures = %d, want %d", got, tt.wantCount)
+			}
+			if want := tt.wantCount >= degradedAfterFailures; h.backends["zai"].health.degraded() != want {
+				t.Errorf("degraded() = %v, want %v", h.backends["zai"].health.degraded(), want)
+			}
+		})
+	}
+}
+
+func TestRecordBackendResultClientCancelled(t *testing.T) {
+	h := NewProxyHandler(config.Default(), discardLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h.recordBackendResult(ctx, 0)
+	h.recordBackendResult(ctx, 0)
+	if got := h.backends["zai"].health.consecutiveFailures.Load(); got != 0 {
+		t.Errorf("consecutive failures = %d after client cancellations, want 0", got)
+	}
+}
+
+func TestDegradedJitter(t *testing.T) {
+	const jitter = 20 * time.Millisecond
+	const calls = 20
+
+	// timeCalls reports how long calls retryable failures take to record once
+	// the handler has seen failures before them
+	timeCalls := func(h *ProxyHandler, failures int) time.Duration {
+		for i := 0; i < failures; i++ {
+			h.backends["zai"].health.consecutiveFailures.Add(1)
+		}
+		start := time.Now()
+		for i := 0; i < calls; i++ {
+			h.recordBackendResult(context.Background(), http.StatusServiceUnavailable)
+			h.backends["zai"].health.consecutiveFailures.Store(int64(failures))
+		}
+		return time.Since(start)
+	}
+
+	newHandler := func(jitter time.Duration) *ProxyHandler {
+		cfg := config.Default()
+		cfg.Providers.DegradedResponseJitter = jitter
+		return NewProxyHandler(cfg, discardLogger())
+	}
+
+	// Expected total delay while degraded is calls*jitter/2
+	if elapsed := timeCalls(newHandler(jitter), degradedAfterFailures); elapsed < calls*jitter/8 {
+		t.Errorf("degraded: %d errors took %v, want a jitter delay", calls, elapsed)
+	}
+	if elapsed := timeCalls(newHandler(jitter), 0); elapsed > jitter {
+		t.Errorf("healthy: %d errors took %v, want no delay", calls, elapsed)
+	}
+	if elapsed := timeCalls(newHandler(0), degradedAfterFailures); elapsed > jitter {
+		t.Errorf("disabled: %d errors took %v, want no delay", calls, elapsed)
+	}
+
+	t.Run("cancelled client is not held", func(t *testing.T) {
+		h := newHandler(time.Hour)
+		h.backends["zai"].health.consecutiveFailures.Store(degradedAfterFailures)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		start := time.Now()
+		h.recordBackendResult(ctx, http.StatusServiceUnavailable)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("cancelled request waited %v", elapsed)
+		}
+	})
+}
+
+func TestDegradedPerBackend(t *testing.T) {
+	cfg := config.Default()
+	cfg.Providers.OpenAI.Enabled = true
+	cfg.Providers.OpenAI.APIKey = "openai-key"
+	h := NewProxyHandler(cfg, discardLogger())
+	openai := withBackend(context.Background(), h.backends["openai"], "gpt-4", nil)
+
+	for i := 0; i < degradedAfterFailures; i++ {
+		h.recordBackendResult(openai, http.StatusServiceUnavailable)
+	}
+	if !h.backends["openai"].health.degraded() {
+		t.Error("openai not degraded after its failures")
+	}
+	if h.backends["zai"].health.degraded() {
+		t.Error("zai degraded by openai's failures")
+	}
+}
+
+func TestDegradedJitterFreesSlot(t *testing.T) {
+	backend := newTestBackend(t, replyJSON(http.StatusServiceUnavailable, `{"error":{"message":"overloaded"}}`))
+	cfg := testConfig(backend)
+	cfg.Providers.Zai.MaxRetries = 0
+	cfg.Providers.DegradedResponseJitter = time.Hour
+	cfg.Server.MaxConcurrentRequests = 1
+	h := NewProxyHandler(cfg, discardLogger())
+	h.backends["zai"].health.consecutiveFailures.Store(degradedAfterFailures)
+
+	// The failed request waits out its jitter delay until cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(`{"model":"glm-5","input":"hi"}`)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Its slot is free for other requests while it waits
+	deadline := time.Now().Add(2 * time.Second)
+	for len(backend.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("request never reached the backend")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for {
+		acquireCtx, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := h.queue.acquire(acquireCtx)
+		stop()
+		if err == nil {
+			h.queue.release()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dispatch slot held during the jitter delay")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+)
+
+// Bounds on draining a backend stream after its [DONE]
+const (
+	maxStreamDrain     = 64 << 10
+	streamDrainTimeout = time.Second
+)
+
+// eventStream writes Responses API SSE events, numbering them in the order
+// they are written so sequence numbers are contiguous from zero. Events may
+// be emitted from more than one goroutine.
+type eventStream struct {
+	mu         sync.Mutex
+	w          io.Writer
+	flusher    http.Flusher
+	eventLines bool // Name each event in an event: line (codex.sse_event_lines)
+	seq        int
+	tracker    middleware.StreamTracker // Told of each event, so a panic can end the stream; nil outside Recovery
+	check      sequenceCheck
+	lastEmit   time.Time
+}
+
+func newEventStream(w io.Writer, flusher http.Flusher, eventLines bool) *eventStream {
+	s := &eventStream{w: w, flusher: flusher, eventLines: eventLines}
+	if rw, ok := w.(http.ResponseWriter); ok {
+		s.tracker = middleware.FindStreamTracker(rw)
+	}
+	return s
+}
+
+// emit assigns the next sequence number to an event and writes it, under its
+// type as the SSE event name unless event lines are off
+func (s *eventStream) emit(event map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := s.seq
+	event["sequence_number"] = seq
+	s.check.observe(seq)
+	s.seq++
+
+	writeEvent(s.w, event, s.eventLines)
+	s.flusher.Flush()
+	if s.tracker != nil {
+		s.tracker.TrackEvent(seq, s.eventLines)
+	}
+	s.lastEmit = time.Now()
+}
+
+// heartbeat emits the event progress returns whenever interval passes
+// without any other event, until stop is called. stop waits for a heartbeat
+// being written, so the stream isn't written to once it returns, and only
+// its first call counts.
+func (s *eventStream) heartbeat(interval time.Duration, progress func() map[string]interface{}) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+			}
+
+			s.mu.Lock()
+			wait := interval - time.Since(s.lastEmit)
+			s.mu.Unlock()
+			if wait <= 0 {
+				s.emit(progress())
+				wait = interval
+			}
+			timer.Reset(wait)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// writeEvent writes one SSE event: an event: line naming its type, when
+// eventLines is set, and its data
+func writeEvent(w io.Writer, event map[string]interface{}, eventLines bool) {
+	eventData, _ := json.Marshal(event)
+	if eventLines {
+		fmt.Fprintf(w, "event: %s\n", event["type"])
+	}
+	fmt.Fprintf(w, "data: %s\n\n", string(eventData))
+}
+
+// drainStream discards what a backend sends after [DONE], such as a repeated
+// [DONE] or a trailing empty chunk, so the connection can be reused. A
+// backend with more than maxStreamDrain left, or that doesn't end the body
+// within streamDrainTimeout, has its body closed instead.
+func drainStream(body io.ReadCloser) {
+	timer := time.AfterFunc(streamDrainTimeout, func() { body.Close() })
+	defer timer.Stop()
+	io.Copy(io.Discard, io.LimitReader(body, maxStreamDrain))
+}
+
+// sortedKeys returns the tool call indexes in ascending order so items are
+// finalized in the order they were added
+func sortedKeys(m map[int]map[string]interface{}) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
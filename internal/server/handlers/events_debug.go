@@ -0,0 +1,19 @@
+//go:build debug
+
+package handlers
+
+import "fmt"
+
+// sequenceCheck asserts that emitted sequence numbers start at zero and are
+// strictly increasing without gaps. It is only compiled into debug builds
+// (go build -tags debug).
+type sequenceCheck struct {
+	next int
+}
+
+func (c *sequenceCheck) observe(seq int) {
+	if seq != c.next {
+		panic(fmt.Sprintf("stream sequence_number %d out of order, expected %d", seq, c.next))
+	}
+	c.next++
+}
@@ -0,0 +1,18 @@
+//go:build debug
+
+package handlers
+
+import "testing"
+
+func TestSequenceCheckPanicsOnGap(t *testing.T) {
+	var c sequenceCheck
+	c.observe(0)
+	c.observe(1)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("observe did not panic on a skipped sequence number")
+		}
+	}()
+	c.observe(3)
+}
@@ -0,0 +1,8 @@
+//go:build !debug
+
+package handlers
+
+// sequenceCheck is a no-op outside debug builds
+type sequenceCheck struct{}
+
+func (sequenceCheck) observe(int) {}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamSequenceNumbers(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string // lifecycle events expected in this order
+	}{
+		{
+			name: "text",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			},
+			want: []string{
+				"response.created",
+				"response.in_progress",
+				"response.output_item.added",
+				"response.content_part.added",
+				"response.output_text.delta",
+				"response.output_text.delta",
+				"response.output_text.done",
+				"response.content_part.done",
+				"response.output_item.done",
+				"response.completed",
+			},
+		},
+		{
+			name: "reasoning interleaved with text",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"think"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"a"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"reasoning_content":"more"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"b"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			},
+			want: []string{
+				"response.created",
+				"response.output_text.delta",
+				"response.output_text.delta",
+				"response.completed",
+			},
+		},
+		{
+			name: "text then tool calls",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","content":"calling"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"a","arguments":"{}"}}]}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"b","arguments":"{\"x\":"}}]}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"1}"}}]}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			},
+			want: []string{
+				"response.created",
+				"response.output_text.delta",
+				"response.function_call_arguments.delta",
+				"response.function_call_arguments.done",
+				"response.function_call_arguments.done",
+				"response.completed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProxyHandler(testConfig(newTestBackend(t, replyStream(tt.chunks...))), discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			var types []string
+			for i, event := range streamEvents(t, rec.Body) {
+				types = append(types, event["type"].(string))
+				if seq, _ := event["sequence_number"].(float64); int(seq) != i {
+					t.Errorf("event %d (%s) sequence_number = %v, want %d", i, event["type"], event["sequence_number"], i)
+				}
+			}
+			if !containsInOrder(types, tt.want) {
+				t.Errorf("event types = %q, want %q in order", types, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamEventLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventLines bool
+	}{
+		{"with event lines", true},
+		{"data lines only", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyStream(
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			))
+			cfg := testConfig(backend)
+			cfg.Codex.SSEEventLines = tt.eventLines
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			if got := strings.Contains(rec.Body.String(), "event: "); got != tt.eventLines {
+				t.Errorf("event lines written = %v, want %v", got, tt.eventLines)
+			}
+
+			// The data keeps its type either way
+			events := streamEvents(t, rec.Body)
+			if len(events) == 0 {
+				t.Fatal("no events")
+			}
+			for _, event := range events {
+				if _, ok := event["type"].(string); !ok {
+					t.Errorf("event %v has no type", event)
+				}
+			}
+			if eventOfType(events, "response.completed") == nil {
+				t.Error("no response.completed")
+			}
+		})
+	}
+}
+
+// containsInOrder reports whether want is a subsequence of got
+func containsInOrder(got, want []string) bool {
+	i := 0
+	for _, g := range got {
+		if i < len(want) && g == want[i] {
+			i++
+		}
+	}
+	return i == len(want)
+}
+
+func TestDuplicateDone(t *testing.T) {
+	backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"}}]}`+"\n\n")
+		io.WriteString(w, `data: {"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+		io.WriteString(w, `data: {"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":" trailing"}}]}`+"\n\n")
+		io.WriteString(w, "data: {}\n\n")
+	})
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	for _, path := range []string{"/v1/responses", "/anthropic/v1/responses"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"model":"glm-5","input":"hi","stream":true}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if strings.Contains(rec.Body.String(), "trailing") {
+				t.Errorf("data after the first [DONE] was relayed: %s", rec.Body)
+			}
+			counts := make(map[string]int)
+			for _, event := range streamEvents(t, rec.Body) {
+				counts[event["type"].(string)]++
+			}
+			final := "response.completed"
+			if path != "/v1/responses" {
+				final = "message_stop"
+			}
+			if counts[final] != 1 {
+				t.Errorf("%s sent %d times, want once", final, counts[final])
+			}
+		})
+	}
+}
+
+func TestDrainStreamGivesUp(t *testing.T) {
+	// A backend that never ends its body after [DONE]
+	body, pw := io.Pipe()
+	defer pw.Close()
+	go io.WriteString(pw, "data: [DONE]\n\n")
+
+	done := make(chan struct{})
+	go func() {
+		drainStream(body)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(streamDrainTimeout + 5*time.Second):
+		t.Fatal("drainStream blocked on an open body")
+	}
+}
+
+func TestStreamProgressHeartbeats(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantMore bool // More than the initial response.in_progress
+	}{
+		{"heartbeats during a silent backend", 20 * time.Millisecond, true},
+		{"disabled", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, `data: {"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant"}}]}`+"\n\n")
+				w.(http.Flusher).Flush()
+				time.Sleep(200 * time.Millisecond) // Reasoning without relaying it
+				io.WriteString(w, `data: {"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`+"\n\n")
+				io.WriteString(w, "data: [DONE]\n\n")
+			})
+			cfg := testConfig(backend)
+			cfg.Server.StreamProgressInterval = tt.interval
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+			inProgress := 0
+			for i, event := range streamEvents(t, rec.Body) {
+				if seq, _ := event["sequence_number"].(float64); int(seq) != i {
+					t.Errorf("event %d (%s) sequence_number = %v, want %d", i, event["type"], event["sequence_number"], i)
+				}
+				if event["type"] == "response.in_progress" {
+					inProgress++
+				}
+			}
+			if got := inProgress > 1; got != tt.wantMore {
+				t.Errorf("%d response.in_progress events, want heartbeats %v", inProgress, tt.wantMore)
+			}
+		})
+	}
+}
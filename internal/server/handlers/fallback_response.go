@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// writeFallbackResponse answers a request the backend failed with the canned
+// reply of providers.fallback_response, shaped like a normal response in the
+// format the client asked for. It reports false, writing nothing, when the
+// feature is off.
+func (h *ProxyHandler) writeFallbackResponse(w http.ResponseWriter, r *http.Request, clientModel string, stream bool) bool {
+	fallback := h.cfg.Providers.FallbackResponse
+	if !fallback.Enabled {
+		return false
+	}
+
+	// The request still failed, whatever status the client sees
+	lc := lifecycleFromContext(r.Context())
+	lc.finish(http.StatusBadGateway)
+	h.logger.Warn("backend failed, returning the fallback response", "status", fallback.Status)
+
+	if !stream {
+		chatResp := map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{
+					"index":         0,
+					"message":       map[string]interface{}{"role": "assistant", "content": fallback.Message},
+					"finish_reason": "stop",
+				},
+			},
+		}
+		responsesResp := h.transformResponse(chatResp, clientModel, lc.createdAt())
+		responsesResp["x_fallback_response"] = true
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(fallback.Status)
+		json.NewEncoder(w).Encode(responsesResp)
+		return true
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(fallback.Status)
+
+	// Relay the message as a one-chunk backend stream
+	content, _ := json.Marshal(fallback.Message)
+	body := io.NopCloser(strings.NewReader(fmt.Sprintf(
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":%s}}]}\n\n"+
+			"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"+
+			"data: [DONE]\n\n", content)))
+	if anthropicStream(r) {
+		h.transformStreamAnthropic(body, w, flusher, h.routedBackend(r.Context()), clientModel)
+	} else {
+		h.transformStream(body, w, flusher, h.routedBackend(r.Context()), clientModel, lc.createdAt(), false)
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestFallbackResponse(t *testing.T) {
+	const message = "Try again later."
+	unavailable := replyJSON(http.StatusServiceUnavailable, `{"error":{"message":"overloaded"}}`)
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		reply      http.HandlerFunc
+		stream     bool
+		wantStatus int
+		wantCanned bool
+	}{
+		{"backend failure", true, unavailable, false, http.StatusOK, true},
+		{"backend failure streamed", true, unavailable, true, http.StatusOK, true},
+		{"disabled returns the real error", false, unavailable, false, http.StatusServiceUnavailable, false},
+		{"client error returned as is", true, replyJSON(http.StatusBadRequest, `{"error":{"message":"bad"}}`), false, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(newTestBackend(t, tt.reply))
+			cfg.Providers.Zai.MaxRetries = 0
+			cfg.Providers.FallbackResponse.Enabled = tt.enabled
+			cfg.Providers.FallbackResponse.Message = message
+			h := NewProxyHandler(cfg, discardLogger())
+
+			body := `{"model":"glm-5","input":"hi"}`
+			if tt.stream {
+				body = `{"model":"glm-5","input":"hi","stream":true}`
+			}
+			rec := postResponses(h, body, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			var text string
+			if tt.stream {
+				if done := eventOfType(streamEvents(t, rec.Body), "response.output_text.done"); done != nil {
+					text, _ = done["text"].(string)
+				}
+			} else {
+				var resp map[string]interface{}
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+				if output, _ := resp["output"].([]interface{}); len(output) == 1 {
+					content, _ := output[0].(map[string]interface{})["content"].([]interface{})
+					if len(content) == 1 {
+						text, _ = content[0].(map[string]interface{})["text"].(string)
+					}
+				}
+				if canned, _ := resp["x_fallback_response"].(bool); canned != tt.wantCanned {
+					t.Errorf("x_fallback_response = %v, want %v", canned, tt.wantCanned)
+				}
+			}
+			if got := text == message; got != tt.wantCanned {
+				t.Errorf("text = %q, want canned reply %v", text, tt.wantCanned)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+)
+
+// renameFields applies a field mapping to a JSON object in place. Each key is
+// a dotted path whose last segment is renamed to the mapped name; arrays along
+// the path are descended element by element. Paths are applied in sorted order
+// so overlapping mappings behave the same on every request.
+func renameFields(obj map[string]interface{}, mapping map[string]string) {
+	if len(mapping) == 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(mapping))
+	for path := range mapping {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		renameField(obj, strings.Split(path, "."), mapping[path])
+	}
+}
+
+// renameField renames the field at path under node, if present
+func renameField(node interface{}, path []string, name string) {
+	switch v := node.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			renameField(elem, path, name)
+		}
+	case map[string]interface{}:
+		if len(path) > 1 {
+			renameField(v[path[0]], path[1:], name)
+			return
+		}
+		if value, ok := v[path[0]]; ok && path[0] != name {
+			delete(v, path[0])
+			v[name] = value
+		}
+	}
+}
+
+// remapRequest renames Chat Completions request fields to the backend's names
+func (h *ProxyHandler) remapRequest(chatReq map[string]interface{}, target *backend) {
+	renameFields(chatReq, target.config.FieldMapping.Request)
+}
+
+// remapResponse renames a backend response or stream chunk's fields to their
+// Chat Completions names
+func (h *ProxyHandler) remapResponse(chatResp map[string]interface{}, target *backend) {
+	renameFields(chatResp, target.config.FieldMapping.Response)
+}
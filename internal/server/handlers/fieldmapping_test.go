@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRenameFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		obj     string
+		mapping map[string]string
+		want    string
+	}{
+		{"top level", `{"max_tokens":10,"model":"m"}`, map[string]string{"max_tokens": "max_new_tokens"}, `{"max_new_tokens":10,"model":"m"}`},
+		{"nested", `{"usage":{"prompt_tokens":1}}`, map[string]string{"usage.prompt_tokens": "input_tokens"}, `{"usage":{"input_tokens":1}}`},
+		{"through arrays", `{"choices":[{"message":{"text":"a"}},{"message":{"text":"b"}}]}`, map[string]string{"choices.message.text": "content"}, `{"choices":[{"message":{"content":"a"}},{"message":{"content":"b"}}]}`},
+		{"absent field", `{"model":"m"}`, map[string]string{"max_tokens": "max_new_tokens"}, `{"model":"m"}`},
+		{"no mapping", `{"max_tokens":10}`, nil, `{"max_tokens":10}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var obj, want map[string]interface{}
+			json.Unmarshal([]byte(tt.obj), &obj)
+			json.Unmarshal([]byte(tt.want), &want)
+
+			renameFields(obj, tt.mapping)
+			if !reflect.DeepEqual(obj, want) {
+				t.Errorf("renameFields() = %v, want %v", obj, want)
+			}
+		})
+	}
+}
+
+func TestFieldMapping(t *testing.T) {
+	// The backend takes max_new_tokens and reports usage as input/output tokens
+	quirky := `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"input_tokens":7,"output_tokens":3,"total_tokens":10}}`
+	backend := newTestBackend(t, replyJSON(http.StatusOK, quirky))
+	cfg := testConfig(backend)
+	cfg.Providers.Zai.FieldMapping.Request = map[string]string{"max_tokens": "max_new_tokens"}
+	cfg.Providers.Zai.FieldMapping.Response = map[string]string{
+		"usage.input_tokens":  "prompt_tokens",
+		"usage.output_tokens": "completion_tokens",
+	}
+	h := NewProxyHandler(cfg, discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi","max_output_tokens":100}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	sent := backend.received()[0].body
+	if _, ok := sent["max_tokens"]; ok {
+		t.Error("backend received max_tokens, want it renamed")
+	}
+	if sent["max_new_tokens"] != float64(100) {
+		t.Errorf("max_new_tokens = %v, want 100", sent["max_new_tokens"])
+	}
+
+	var resp struct {
+		Usage map[string]interface{} `json:"usage"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Usage["input_tokens"] != float64(7) || resp.Usage["output_tokens"] != float64(3) {
+		t.Errorf("usage = %v, want the backend's renamed counts", resp.Usage)
+	}
+}
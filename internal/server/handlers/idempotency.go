@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+	"github.com/plasmadev/codex-api-router/internal/session"
+)
+
+// Idempotency-Key lets a client retry a request without it being run twice:
+// a completed non-streaming response is stored under the key and replayed
+// for later requests with the same key
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 256
+)
+
+// idempotencyKey returns the key a request's response is stored under for
+// replay: its Idempotency-Key scoped to the client's credential, so clients
+// can't replay each other's responses. It is "" without a usable header.
+func (h *ProxyHandler) idempotencyKey(r *http.Request) string {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return ""
+	}
+	credential := sha256.Sum256([]byte(middleware.ClientCredential(r, h.cfg.Codex.APIKeyHeader)))
+	return hex.EncodeToString(credential[:8]) + ":" + key
+}
+
+// inFlightKeys are the idempotency keys of requests being handled, each with
+// the hash of its request body. The zero value is ready to use.
+type inFlightKeys struct {
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+// beginIdempotent claims a request's Idempotency-Key for as long as it is
+// handled. A key already served is answered with the stored response, under
+// the X-Request-Id of the request that produced it so logs correlate across
+// the replay; one still being handled gets a 409, and either with a
+// different request body a 422. It reports whether the request should go on,
+// and returns the func releasing the key once it is handled.
+func (h *ProxyHandler) beginIdempotent(w http.ResponseWriter, r *http.Request, body []byte) (release func(), ok bool) {
+	key := h.idempotencyKey(r)
+	if key == "" {
+		return func() {}, true
+	}
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	h.inFlight.mu.Lock()
+	defer h.inFlight.mu.Unlock()
+
+	if stored, ok := h.idempotent.Get(key); ok {
+		if stored.RequestHash != hash {
+			h.writeIdempotencyKeyReused(w)
+			return nil, false
+		}
+		h.replayIdempotent(w, r, stored)
+		return nil, false
+	}
+	if inFlight, ok := h.inFlight.keys[key]; ok {
+		if inFlight != hash {
+			h.writeIdempotencyKeyReused(w)
+			return nil, false
+		}
+		h.writeError(w, http.StatusConflict, "invalid_request_error", "idempotency_key_in_use",
+			"A request with this Idempotency-Key is still being handled; retry once it completes")
+		return nil, false
+	}
+
+	if h.inFlight.keys == nil {
+		h.inFlight.keys = make(map[string]string)
+	}
+	h.inFlight.keys[key] = hash
+	return func() {
+		h.inFlight.mu.Lock()
+		defer h.inFlight.mu.Unlock()
+		delete(h.inFlight.keys, key)
+	}, true
+}
+
+// writeIdempotencyKeyReused rejects a request reusing an Idempotency-Key
+// with a different body
+func (h *ProxyHandler) writeIdempotencyKeyReused(w http.ResponseWriter) {
+	h.writeError(w, http.StatusUnprocessableEntity, "invalid_request_error", "idempotency_key_reused",
+		"Idempotency-Key was already used with a different request body")
+}
+
+// replayIdempotent answers a request with the response stored under its
+// Idempotency-Key
+func (h *ProxyHandler) replayIdempotent(w http.ResponseWriter, r *http.Request, stored session.StoredResponse) {
+	h.logger.Info("replaying idempotent response",
+		"request_id", lifecycleFromContext(r.Context()).requestID(),
+		"original_request_id", stored.RequestID,
+	)
+	if stored.RequestID != "" {
+		w.Header().Set(middleware.RequestIDHeader, stored.RequestID)
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stored.Body)
+}
+
+// saveIdempotent keeps a completed response for replay when the request
+// carries an Idempotency-Key claimed by beginIdempotent
+func (h *ProxyHandler) saveIdempotent(r *http.Request, resp map[string]interface{}) {
+	key := h.idempotencyKey(r)
+	if key == "" {
+		return
+	}
+
+	h.inFlight.mu.Lock()
+	defer h.inFlight.mu.Unlock()
+	if hash, ok := h.inFlight.keys[key]; ok {
+		h.idempotent.SaveForRequest(key, lifecycleFromContext(r.Context()).requestID(), hash, resp)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+)
+
+// sequentialIDs generates req_1, req_2, ... so tests can tell requests apart
+type sequentialIDs struct{ n int }
+
+func (g *sequentialIDs) NewID(prefix string) string {
+	g.n++
+	return prefix + strconv.Itoa(g.n)
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	const body = `{"model":"glm-5","input":"hi"}`
+
+	tests := []struct {
+		name         string
+		first        map[string]string
+		second       map[string]string
+		wantReplay   bool
+		wantBackend  int
+		wantSecondID string
+	}{
+		{
+			name:         "replayed under the original request ID",
+			first:        map[string]string{"Idempotency-Key": "k1"},
+			second:       map[string]string{"Idempotency-Key": "k1"},
+			wantReplay:   true,
+			wantBackend:  1,
+			wantSecondID: "req_1",
+		},
+		{
+			name:         "client request ID kept for the replay",
+			first:        map[string]string{"Idempotency-Key": "k1", "X-Request-Id": "client-1"},
+			second:       map[string]string{"Idempotency-Key": "k1", "X-Request-Id": "client-2"},
+			wantReplay:   true,
+			wantBackend:  1,
+			wantSecondID: "client-1",
+		},
+		{
+			name:         "different keys both sent",
+			first:        map[string]string{"Idempotency-Key": "k1"},
+			second:       map[string]string{"Idempotency-Key": "k2"},
+			wantBackend:  2,
+			wantSecondID: "req_2",
+		},
+		{
+			name:         "other credentials don't share keys",
+			first:        map[string]string{"Idempotency-Key": "k1", "Authorization": "Bearer a"},
+			second:       map[string]string{"Idempotency-Key": "k1", "Authorization": "Bearer b"},
+			wantBackend:  2,
+			wantSecondID: "req_2",
+		},
+		{
+			name:         "no key",
+			wantBackend:  2,
+			wantSecondID: "req_2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			h := NewProxyHandler(testConfig(zai), discardLogger())
+			handler := middleware.RequestID(h, h.logger, &sequentialIDs{})
+
+			first := postResponses(handler, body, tt.first)
+			second := postResponses(handler, body, tt.second)
+			if first.Code != http.StatusOK || second.Code != http.StatusOK {
+				t.Fatalf("statuses = %d, %d; bodies %s, %s", first.Code, second.Code, first.Body, second.Body)
+			}
+
+			if got := len(zai.received()); got != tt.wantBackend {
+				t.Errorf("backend requests = %d, want %d", got, tt.wantBackend)
+			}
+			if got := second.Header().Get(idempotentReplayedHeader) == "true"; got != tt.wantReplay {
+				t.Errorf("replayed = %v, want %v", got, tt.wantReplay)
+			}
+			if got := second.Header().Get(middleware.RequestIDHeader); got != tt.wantSecondID {
+				t.Errorf("second X-Request-Id = %q, want %q", got, tt.wantSecondID)
+			}
+			if tt.wantReplay && second.Body.String() != first.Body.String() {
+				t.Errorf("replayed body = %s, want %s", second.Body, first.Body)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyReusedWithOtherBody(t *testing.T) {
+	zai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+	h := NewProxyHandler(testConfig(zai), discardLogger())
+	key := map[string]string{"Idempotency-Key": "k1"}
+
+	if rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, key); rec.Code != http.StatusOK {
+		t.Fatalf("first status = %d: %s", rec.Code, rec.Body)
+	}
+	rec := postResponses(h, `{"model":"glm-5","input":"something else"}`, key)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body)
+	}
+	if code := decodeError(t, rec)["code"]; code != "idempotency_key_reused" {
+		t.Errorf("code = %v, want idempotency_key_reused", code)
+	}
+	if n := len(zai.received()); n != 1 {
+		t.Errorf("backend requests = %d, want 1", n)
+	}
+}
+
+func TestIdempotencyKeyInFlight(t *testing.T) {
+	const body = `{"model":"glm-5","input":"hi"}`
+	key := map[string]string{"Idempotency-Key": "k1"}
+
+	arrived, release := make(chan struct{}), make(chan struct{})
+	zai := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		replyJSON(http.StatusOK, chatCompletion)(w, r)
+	})
+	h := NewProxyHandler(testConfig(zai), discardLogger())
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- postResponses(h, body, key) }()
+	<-arrived
+
+	rec := postResponses(h, body, key)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("concurrent status = %d, want 409: %s", rec.Code, rec.Body)
+	} else if code := decodeError(t, rec)["code"]; code != "idempotency_key_in_use" {
+		t.Errorf("code = %v, want idempotency_key_in_use", code)
+	}
+	if rec := postResponses(h, `{"model":"glm-5","input":"other"}`, key); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("concurrent status with another body = %d, want 422", rec.Code)
+	}
+
+	close(release)
+	if rec := <-first; rec.Code != http.StatusOK {
+		t.Fatalf("first status = %d: %s", rec.Code, rec.Body)
+	}
+
+	// Once the first completes, a retry is replayed
+	rec = postResponses(h, body, key)
+	if rec.Code != http.StatusOK || rec.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("retry status = %d, replayed %q; want a 200 replay", rec.Code, rec.Header().Get(idempotentReplayedHeader))
+	}
+	if n := len(zai.received()); n != 1 {
+		t.Errorf("backend requests = %d, want 1", n)
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// renderInstructions applies the instructions template of the backend, if
+// any, to the client's instructions. If the template fails the instructions are sent
+// unchanged.
+func (h *ProxyHandler) renderInstructions(target *backend, instructions, model string) string {
+	if target.instructions == nil {
+		return instructions
+	}
+
+	var sb strings.Builder
+	if err := target.instructions.Execute(&sb, config.NewInstructionsData(instructions, model, time.Now())); err != nil {
+		h.logger.Warn("failed to render instructions template, using client instructions", "error", err)
+		return instructions
+	}
+	return strings.TrimSpace(sb.String())
+}
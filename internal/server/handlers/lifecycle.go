@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/events"
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+)
+
+// lifecycle publishes one request's events on the handler's bus. It travels
+// in the request context so the dispatch paths can report their stages. All
+// methods are safe on a nil lifecycle.
+type lifecycle struct {
+	bus    *events.Bus
+	id     string
+	start  time.Time
+	model  string
+	stream bool
+	tenant string
+
+	firstToken sync.Once
+	finished   atomic.Bool
+	detached   atomic.Bool // A background job reports the outcome instead
+}
+
+type lifecycleKey struct{}
+
+// startLifecycle publishes RequestReceived and returns the request's
+// lifecycle along with a context carrying it. The lifecycle takes the
+// request's ID from the RequestID middleware, or a new one without it.
+func (h *ProxyHandler) startLifecycle(ctx context.Context, tenant string) (*lifecycle, context.Context) {
+	id := middleware.RequestIDFromContext(ctx)
+	if id == "" {
+		id = h.newID("req_")
+	}
+	lc := &lifecycle{bus: h.bus, id: id, start: time.Now(), tenant: tenant}
+	lc.publish(events.RequestReceived, 0)
+	return lc, context.WithValue(ctx, lifecycleKey{}, lc)
+}
+
+// lifecycleFromContext returns the request's lifecycle, or nil
+func lifecycleFromContext(ctx context.Context) *lifecycle {
+	lc, _ := ctx.Value(lifecycleKey{}).(*lifecycle)
+	return lc
+}
+
+// requestID is the ID the request is handled under, or "" without a lifecycle
+func (lc *lifecycle) requestID() string {
+	if lc == nil {
+		return ""
+	}
+	return lc.id
+}
+
+// createdAt is the created_at of the request's response: the Unix time the
+// request was received, shared by the response object, its stream events and
+// any stored copy. Without a lifecycle it is the current time.
+func (lc *lifecycle) createdAt() int64 {
+	if lc == nil {
+		return time.Now().Unix()
+	}
+	return lc.start.Unix()
+}
+
+func (lc *lifecycle) publish(t events.Type, status int) {
+	if lc == nil {
+		return
+	}
+	now := time.Now()
+	lc.bus.Publish(events.Event{
+		Type:      t,
+		RequestID: lc.id,
+		Time:      now,
+		Elapsed:   now.Sub(lc.start),
+		Model:     lc.model,
+		Stream:    lc.stream,
+		Tenant:    lc.tenant,
+		Status:    status,
+	})
+}
+
+// watchFirstToken wraps the backend response body so its first read
+// publishes FirstToken
+func (lc *lifecycle) watchFirstToken(resp *http.Response) {
+	if lc == nil {
+		return
+	}
+	resp.Body = &firstReadBody{ReadCloser: resp.Body, onFirstRead: func() {
+		lc.firstToken.Do(func() { lc.publish(events.FirstToken, 0) })
+	}}
+}
+
+// detach hands the terminal event to a background job that outlives the request
+func (lc *lifecycle) detach() {
+	if lc != nil {
+		lc.detached.Store(true)
+	}
+}
+
+// finish publishes the terminal event for a response status: RequestFailed
+// for 4xx and 5xx, otherwise RequestCompleted. Only the first call counts.
+func (lc *lifecycle) finish(status int) {
+	if lc == nil || !lc.finished.CompareAndSwap(false, true) {
+		return
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status >= http.StatusBadRequest {
+		lc.publish(events.RequestFailed, status)
+	} else {
+		lc.publish(events.RequestCompleted, status)
+	}
+}
+
+// firstReadBody calls onFirstRead the first time data is read
+type firstReadBody struct {
+	io.ReadCloser
+	onFirstRead func()
+}
+
+func (b *firstReadBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.onFirstRead != nil {
+		b.onFirstRead()
+		b.onFirstRead = nil
+	}
+	return n, err
+}
+
+// statusWriter records the status code written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// responseStarted reports whether a status has been written through w,
+// looking through wrappers for the handler's statusWriter. Without one the
+// response is assumed to have started.
+func responseStarted(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *statusWriter:
+			return rw.status != 0
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return true
+		}
+	}
+}
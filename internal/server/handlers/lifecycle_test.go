@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/events"
+)
+
+// recordEvents subscribes to h's bus and returns the events published so far
+func recordEvents(h *ProxyHandler) func() []events.Event {
+	var mu sync.Mutex
+	var got []events.Event
+	h.Events().Subscribe(func(e events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e)
+	})
+	return func() []events.Event {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]events.Event(nil), got...)
+	}
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		reply      http.HandlerFunc
+		body       string
+		want       []events.Type
+		wantStatus int
+	}{
+		{
+			name:       "completed",
+			reply:      replyJSON(http.StatusOK, chatCompletion),
+			body:       `{"model":"glm-5","input":"hi"}`,
+			want:       []events.Type{events.RequestReceived, events.BackendDispatched, events.FirstToken, events.RequestCompleted},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "streamed",
+			reply:      replyStream(`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`),
+			body:       `{"model":"glm-5","input":"hi","stream":true}`,
+			want:       []events.Type{events.RequestReceived, events.BackendDispatched, events.FirstToken, events.RequestCompleted},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "backend error",
+			reply:      replyJSON(http.StatusBadRequest, `{"error":{"message":"bad"}}`),
+			body:       `{"model":"glm-5","input":"hi"}`,
+			want:       []events.Type{events.RequestReceived, events.BackendDispatched, events.FirstToken, events.RequestFailed},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid request",
+			reply:      replyJSON(http.StatusOK, chatCompletion),
+			body:       `{"model":`,
+			want:       []events.Type{events.RequestReceived, events.RequestFailed},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProxyHandler(testConfig(newTestBackend(t, tt.reply)), discardLogger())
+			published := recordEvents(h)
+
+			postResponses(h, tt.body, nil)
+
+			got := published()
+			types := make([]events.Type, len(got))
+			for i, e := range got {
+				types[i] = e.Type
+			}
+			if !reflect.DeepEqual(types, tt.want) {
+				t.Fatalf("events = %v, want %v", types, tt.want)
+			}
+
+			terminal := got[len(got)-1]
+			if terminal.Status != tt.wantStatus {
+				t.Errorf("terminal status = %d, want %d", terminal.Status, tt.wantStatus)
+			}
+			for _, e := range got {
+				if e.RequestID != got[0].RequestID {
+					t.Errorf("event %s has request ID %q, want %q", e.Type, e.RequestID, got[0].RequestID)
+				}
+			}
+		})
+	}
+}
@@ -4,15 +4,137 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
+
+	"github.com/plasmadev/codex-api-router/internal/events"
 )
 
 var (
 	requestCount    atomic.Int64
 	errorCount      atomic.Int64
 	totalLatencyMs atomic.Int64
+
+	// Requests that have finished, which totalLatencyMs is averaged over so
+	// requests still in flight don't drag the average down
+	finishedCount atomic.Int64
+
+	// Time from request receipt to the first backend response bytes
+	firstTokenCount atomic.Int64
+	firstTokenMs    atomic.Int64
+
+	// Parse failures while transforming, by direction
+	transformErrorsRequest  atomic.Int64
+	transformErrorsResponse atomic.Int64
+	transformErrorsStream   atomic.Int64
+
+	// Dispatch queue state (server.max_concurrent_requests)
+	queueDepth           atomic.Int64
+	queuedRequests       atomic.Int64
+	queueWaitMs          atomic.Int64
+	queueRejectedFull    atomic.Int64
+	queueRejectedTimeout atomic.Int64
+
+	// Estimated spend in USD by model and provider (providers.pricing)
+	costMu     sync.Mutex
+	costTotals = make(map[costKey]float64)
+
+	// Requests and errors by tenant label (metrics.tenants)
+	tenantMu       sync.Mutex
+	tenantRequests = make(map[string]int64)
+	tenantErrors   = make(map[string]int64)
 )
 
+type costKey struct {
+	model    string
+	provider string
+}
+
+// MetricsCollector is the event bus subscriber behind the request, error,
+// latency and first-token metrics
+func MetricsCollector(e events.Event) {
+	switch e.Type {
+	case events.RequestReceived:
+		requestCount.Add(1)
+		addTenantCount(tenantRequests, e.Tenant)
+	case events.FirstToken:
+		firstTokenCount.Add(1)
+		firstTokenMs.Add(e.Elapsed.Milliseconds())
+	case events.RequestCompleted:
+		finishedCount.Add(1)
+		totalLatencyMs.Add(e.Elapsed.Milliseconds())
+	case events.RequestFailed:
+		errorCount.Add(1)
+		addTenantCount(tenantErrors, e.Tenant)
+		finishedCount.Add(1)
+		totalLatencyMs.Add(e.Elapsed.Milliseconds())
+	}
+}
+
+// addTenantCount counts a request against its tenant label, if it has one
+func addTenantCount(counts map[string]int64, tenant string) {
+	if tenant == "" {
+		return
+	}
+	tenantMu.Lock()
+	defer tenantMu.Unlock()
+	counts[tenant]++
+}
+
+// counterSeries renders a counter's samples: one per tenant label once any
+// request has been labeled, otherwise the unlabeled total
+func counterSeries(name string, total int64, byTenant map[string]int64) string {
+	tenantMu.Lock()
+	defer tenantMu.Unlock()
+
+	if len(byTenant) == 0 {
+		return fmt.Sprintf("%s %d", name, total)
+	}
+	tenants := make([]string, 0, len(byTenant))
+	for tenant := range byTenant {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+
+	lines := make([]string, len(tenants))
+	for i, tenant := range tenants {
+		lines[i] = fmt.Sprintf("%s{tenant=%q} %d", name, tenant, byTenant[tenant])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// addCost records the estimated cost of a request
+func addCost(model, provider string, usd float64) {
+	costMu.Lock()
+	defer costMu.Unlock()
+	costTotals[costKey{model: model, provider: provider}] += usd
+}
+
+// costMetrics renders the cost counter, one series per model and provider
+func costMetrics() string {
+	costMu.Lock()
+	defer costMu.Unlock()
+
+	keys := make([]costKey, 0, len(costTotals))
+	for key := range costTotals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].model != keys[j].model {
+			return keys[i].model < keys[j].model
+		}
+		return keys[i].provider < keys[j].provider
+	})
+
+	out := "# HELP codex_router_cost_usd_total Estimated spend in USD from providers.pricing\n# TYPE codex_router_cost_usd_total counter\n"
+	for _, key := range keys {
+		out += fmt.Sprintf("codex_router_cost_usd_total{model=%q,provider=%q} %.6f\n", key.model, key.provider, costTotals[key])
+	}
+	return out
+}
+
 // MetricsHandler returns Prometheus-style metrics
 func MetricsHandler(logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -23,23 +145,57 @@ func MetricsHandler(logger *slog.Logger) http.HandlerFunc {
 		latency := totalLatencyMs.Load()
 
 		var avgLatency float64
-		if reqs > 0 {
-			avgLatency = float64(latency) / float64(reqs)
+		if finished := finishedCount.Load(); finished > 0 {
+			avgLatency = float64(latency) / float64(finished)
+		}
+
+		var avgFirstToken float64
+		if count := firstTokenCount.Load(); count > 0 {
+			avgFirstToken = float64(firstTokenMs.Load()) / float64(count)
+		}
+
+		var avgQueueWait float64
+		if queued := queuedRequests.Load(); queued > 0 {
+			avgQueueWait = float64(queueWaitMs.Load()) / float64(queued)
 		}
 
 		metrics := `# HELP codex_router_requests_total Total number of requests
 # TYPE codex_router_requests_total counter
-codex_router_requests_total ` + fmt.Sprint(reqs) + `
+` + counterSeries("codex_router_requests_total", reqs, tenantRequests) + `
 
 # HELP codex_router_errors_total Total number of errors
 # TYPE codex_router_errors_total counter
-codex_router_errors_total ` + fmt.Sprint(errs) + `
+` + counterSeries("codex_router_errors_total", errs, tenantErrors) + `
 
 # HELP codex_router_latency_avg_ms Average request latency in milliseconds
 # TYPE codex_router_latency_avg_ms gauge
 codex_router_latency_avg_ms ` + fmt.Sprintf("%.2f", avgLatency) + `
 
-# HELP codex_router_up Server is up
+# HELP codex_router_first_token_avg_ms Average time from request receipt to the first backend response bytes in milliseconds
+# TYPE codex_router_first_token_avg_ms gauge
+codex_router_first_token_avg_ms ` + fmt.Sprintf("%.2f", avgFirstToken) + `
+
+# HELP codex_router_transform_errors_total Total number of payloads that failed to parse during transformation
+# TYPE codex_router_transform_errors_total counter
+codex_router_transform_errors_total{direction="request"} ` + fmt.Sprint(transformErrorsRequest.Load()) + `
+codex_router_transform_errors_total{direction="response"} ` + fmt.Sprint(transformErrorsResponse.Load()) + `
+codex_router_transform_errors_total{direction="stream"} ` + fmt.Sprint(transformErrorsStream.Load()) + `
+
+# HELP codex_router_queue_depth Requests waiting for a backend dispatch slot
+# TYPE codex_router_queue_depth gauge
+codex_router_queue_depth ` + fmt.Sprint(queueDepth.Load()) + `
+
+# HELP codex_router_queue_wait_avg_ms Average time queued requests waited in milliseconds
+# TYPE codex_router_queue_wait_avg_ms gauge
+codex_router_queue_wait_avg_ms ` + fmt.Sprintf("%.2f", avgQueueWait) + `
+
+# HELP codex_router_queue_rejected_total Requests rejected by the dispatch queue, by reason
+# TYPE codex_router_queue_rejected_total counter
+codex_router_queue_rejected_total{reason="full"} ` + fmt.Sprint(queueRejectedFull.Load()) + `
+codex_router_queue_rejected_total{reason="timeout"} ` + fmt.Sprint(queueRejectedTimeout.Load()) + `
+
+` + costMetrics() + `
+` + backendConnMetrics() + `# HELP codex_router_up Server is up
 # TYPE codex_router_up gauge
 codex_router_up 1
 `
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/events"
+)
+
+// counterTotal sums every series of a counter on the metrics endpoint,
+// whether or not it is labeled by tenant
+func counterTotal(t *testing.T, name string) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler(discardLogger())(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	total := 0
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if !strings.HasPrefix(line, name+" ") && !strings.HasPrefix(line, name+"{") {
+			continue
+		}
+		n, err := strconv.Atoi(line[strings.LastIndex(line, " ")+1:])
+		if err != nil {
+			t.Fatalf("metric %q: %v", line, err)
+		}
+		total += n
+	}
+	return total
+}
+
+func TestRequestCounters(t *testing.T) {
+	const requests = 5
+	cfg := testConfig(newTestBackend(t, replyJSON(http.StatusOK, chatCompletion)))
+	h := NewProxyHandler(cfg, discardLogger())
+	h.Events().Subscribe(MetricsCollector)
+
+	reqsBefore := counterTotal(t, "codex_router_requests_total")
+	errsBefore := counterTotal(t, "codex_router_errors_total")
+
+	for range requests {
+		if rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+	}
+	postResponses(h, `{"model":`, nil) // Malformed, so it fails
+
+	if got := counterTotal(t, "codex_router_requests_total") - reqsBefore; got != requests+1 {
+		t.Errorf("requests_total increased by %d, want %d", got, requests+1)
+	}
+	if got := counterTotal(t, "codex_router_errors_total") - errsBefore; got != 1 {
+		t.Errorf("errors_total increased by %d, want 1", got)
+	}
+}
+
+func TestAverageLatencyIgnoresInFlight(t *testing.T) {
+	MetricsCollector(events.Event{Type: events.RequestReceived})
+	MetricsCollector(events.Event{Type: events.RequestCompleted, Elapsed: 40 * time.Millisecond})
+	before := metricValue(t, "codex_router_latency_avg_ms")
+
+	// Requests still in flight don't count towards the average
+	for range 10 {
+		MetricsCollector(events.Event{Type: events.RequestReceived})
+	}
+	if got := metricValue(t, "codex_router_latency_avg_ms"); got != before {
+		t.Errorf("average latency = %v with requests in flight, want %v", got, before)
+	}
+}
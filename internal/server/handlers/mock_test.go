@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestMockProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		mock     config.MockConfig
+		stream   bool
+		wantText string
+	}{
+		{"text", config.MockConfig{Text: "canned reply"}, false, "canned reply"},
+		{"streamed text", config.MockConfig{Text: "canned reply"}, true, "canned reply"},
+		{"default text", config.MockConfig{}, false, "This is a canned response from the mock provider."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default() // No API key or backend: nothing leaves the process
+			cfg.Providers.Mock = tt.mock
+			cfg.Providers.Mock.Enabled = true
+			h := NewProxyHandler(cfg, discardLogger())
+
+			body := `{"model":"glm-5","input":"hi"}`
+			if tt.stream {
+				body = `{"model":"glm-5","input":"hi","stream":true}`
+			}
+			rec := postResponses(h, body, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+			}
+
+			if tt.stream {
+				var text strings.Builder
+				for _, event := range streamEvents(t, rec.Body) {
+					if event["type"] == "response.output_text.delta" {
+						text.WriteString(event["delta"].(string))
+					}
+				}
+				if text.String() != tt.wantText {
+					t.Errorf("streamed text = %q, want %q", text.String(), tt.wantText)
+				}
+				return
+			}
+
+			var resp struct {
+				Output []struct {
+					Type    string `json:"type"`
+					Content []struct {
+						Text string `json:"text"`
+					} `json:"content"`
+				} `json:"output"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response %s: %v", rec.Body, err)
+			}
+			var text string
+			for _, item := range resp.Output {
+				for _, part := range item.Content {
+					text += part.Text
+				}
+			}
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+		})
+	}
+}
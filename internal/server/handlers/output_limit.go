@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// errLineTooLong is returned by readLine for a line over the output limit
+var errLineTooLong = errors.New("stream line exceeds the output limit")
+
+// eventTooLargeMessage reports a backend stream whose first event is over
+// the output limit, so nothing of the response could be relayed
+const eventTooLargeMessage = "Backend stream event exceeds the output limit (server.max_stream_output_bytes)"
+
+// maxEventOverhead is the room allowed on top of the output limit for the
+// JSON envelope of a single stream line
+const maxEventOverhead = 64 << 10
+
+// outputLimit caps the text and tool-call arguments accumulated for one
+// streamed response, so a runaway backend cannot grow them without bound.
+// A max of 0 disables the cap.
+type outputLimit struct {
+	max  int
+	used int
+}
+
+// fit returns the part of s that fits in the remaining allowance, cut at a
+// rune boundary, and reports whether all of s fit
+func (l *outputLimit) fit(s string) (string, bool) {
+	if l.max <= 0 {
+		return s, true
+	}
+
+	room := l.max - l.used
+	if len(s) <= room {
+		l.used += len(s)
+		return s, true
+	}
+
+	cut := max(room, 0)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	l.used += cut
+	return s[:cut], false
+}
+
+// readLine reads one line, like ReadString('\n'). A line longer than the
+// limit plus maxEventOverhead is discarded without being buffered whole, and
+// errLineTooLong is returned once its end has been consumed.
+func (l *outputLimit) readLine(reader *bufio.Reader) (string, error) {
+	if l.max <= 0 {
+		return reader.ReadString('\n')
+	}
+
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(line) > l.max+maxEventOverhead {
+				tooLong = true
+				line = nil
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLong && (err == nil || err == io.EOF) {
+			return "", errLineTooLong
+		}
+		return string(line), err
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOutputLimitFit(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		inputs   []string
+		want     []string
+		wantFits []bool
+	}{
+		{"disabled", 0, []string{"hello", "world"}, []string{"hello", "world"}, []bool{true, true}},
+		{"within", 10, []string{"hello", "world"}, []string{"hello", "world"}, []bool{true, true}},
+		{"cut", 8, []string{"hello", "world"}, []string{"hello", "wor"}, []bool{true, false}},
+		{"exhausted", 5, []string{"hello", "world"}, []string{"hello", ""}, []bool{true, false}},
+		{"rune boundary", 4, []string{"ab", "éé"}, []string{"ab", "é"}, []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := outputLimit{max: tt.max}
+			for i, in := range tt.inputs {
+				got, fits := limit.fit(in)
+				if got != tt.want[i] || fits != tt.wantFits[i] {
+					t.Errorf("fit(%q) = %q, %v, want %q, %v", in, got, fits, tt.want[i], tt.wantFits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOutputLimitReadLine(t *testing.T) {
+	long := strings.Repeat("x", 10+maxEventOverhead+1)
+	reader := bufio.NewReaderSize(strings.NewReader("short\n"+long+"\nafter\n"), 16)
+	limit := outputLimit{max: 10}
+
+	if line, err := limit.readLine(reader); err != nil || line != "short\n" {
+		t.Fatalf("readLine() = %q, %v", line, err)
+	}
+	if _, err := limit.readLine(reader); !errors.Is(err, errLineTooLong) {
+		t.Fatalf("readLine() error = %v, want errLineTooLong", err)
+	}
+	if line, err := limit.readLine(reader); err != nil || line != "after\n" {
+		t.Errorf("readLine() after a long line = %q, %v", line, err)
+	}
+}
+
+func TestStreamOutputLimit(t *testing.T) {
+	chunk := func(text string) string {
+		return `{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"` + text + `"}}]}`
+	}
+	backend := newTestBackend(t, replyStream(chunk("0123456789"), chunk("abcdefghij"), chunk("never sent")))
+	cfg := testConfig(backend)
+	cfg.Server.MaxStreamOutputBytes = 15
+	h := NewProxyHandler(cfg, discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+	events := streamEvents(t, rec.Body)
+
+	if eventOfType(events, "response.completed") != nil {
+		t.Error("truncated stream sent response.completed")
+	}
+	incomplete := eventOfType(events, "response.incomplete")
+	if incomplete == nil {
+		t.Fatalf("no response.incomplete event in %v", events)
+	}
+	resp := incomplete["response"].(map[string]interface{})
+	if resp["status"] != "incomplete" {
+		t.Errorf("status = %v, want incomplete", resp["status"])
+	}
+
+	done := eventOfType(events, "response.output_text.done")
+	if done == nil || done["text"] != "0123456789abcde" {
+		t.Errorf("output_text.done = %v, want text cut at 15 bytes", done)
+	}
+}
+
+func TestStreamFirstEventOverLimit(t *testing.T) {
+	huge := strings.Repeat("x", maxEventOverhead+100)
+
+	t.Run("nothing sent fails with 502", func(t *testing.T) {
+		backend := newTestBackend(t, replyStream(
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"`+huge+`"}}]}`,
+		))
+		cfg := testConfig(backend)
+		cfg.Server.MaxStreamOutputBytes = 10
+		h := NewProxyHandler(cfg, discardLogger())
+
+		rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+		if rec.Code != http.StatusBadGateway {
+			t.Fatalf("status = %d, want 502: %s", rec.Code, rec.Body)
+		}
+		if code := decodeError(t, rec)["code"]; code != "backend_response_too_large" {
+			t.Errorf("code = %v, want backend_response_too_large", code)
+		}
+	})
+
+	t.Run("started stream gets created then failed", func(t *testing.T) {
+		cfg := testConfig(newTestBackend(t, replyJSON(http.StatusServiceUnavailable, `{"error":{"message":"overloaded"}}`)))
+		cfg.Providers.Zai.MaxRetries = 0
+		cfg.Providers.FallbackResponse.Enabled = true
+		cfg.Providers.FallbackResponse.Message = huge
+		cfg.Server.MaxStreamOutputBytes = 10
+		h := NewProxyHandler(cfg, discardLogger())
+
+		rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+		events := streamEvents(t, rec.Body)
+		if len(events) != 2 || events[0]["type"] != "response.created" || events[1]["type"] != "response.failed" {
+			t.Fatalf("events = %v, want response.created then response.failed", events)
+		}
+		resp := events[1]["response"].(map[string]interface{})
+		if resp["status"] != "failed" || resp["id"] != events[0]["response"].(map[string]interface{})["id"] {
+			t.Errorf("failed response = %v", resp)
+		}
+	})
+}
@@ -3,44 +3,232 @@ package handlers
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/events"
+	"github.com/plasmadev/codex-api-router/internal/ids"
+	"github.com/plasmadev/codex-api-router/internal/providers"
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+	"github.com/plasmadev/codex-api-router/internal/session"
 )
 
+// defaultStreamOptions are applied beneath any stream_options sent by the client
+var defaultStreamOptions = map[string]interface{}{
+	"include_usage": true,
+}
+
 // ProxyHandler handles proxying requests to the backend
 type ProxyHandler struct {
 	cfg    *config.Config
 	logger *slog.Logger
-	client *http.Client
+	store  session.ConversationStore // nil when sessions are disabled
+	queue  *dispatchQueue            // nil when concurrency is unlimited
+
+	// Responses kept for GET /v1/responses/{id}, such as background jobs
+	responses *session.ResponseStore
+
+	// Responses kept for replay by Idempotency-Key, and the keys of requests
+	// still being handled
+	idempotent *session.ResponseStore
+	inFlight   inFlightKeys
+
+	// Tenant labels for request metrics; nil unless metrics.tenants is set
+	tenants *tenantLabeler
+
+	// Request lifecycle events for observability subscribers
+	bus *events.Bus
+
+	// Providers created by the handler, shut down with it
+	providers *providers.Factory
+
+	// Backends requests are dispatched to, by provider name
+	backends map[string]*backend
+
+	// Generates response, item and tool call IDs; nil uses ids.Default
+	ids ids.Generator
+
+	// Why providers failed to initialize, when startup should not go on
+	initErr error
 }
 
 // NewProxyHandler creates a new proxy handler
 func NewProxyHandler(cfg *config.Config, logger *slog.Logger) *ProxyHandler {
-	// Use provider timeout if available, fallback to legacy config
-	timeout := cfg.Providers.Zai.Timeout
-	if timeout == 0 {
-		timeout = cfg.Zai.Timeout
+	factory := providers.NewFactory()
+	factory.SetFallbackOptions(providers.FallbackOptionsFromConfig(cfg.Providers.Fallback))
+	factory.SetDefaultForUnmatched(cfg.Providers.DefaultForUnmatchedModels)
+	factory.SetColdStartOptions(providers.ColdStartOptionsFromConfig(cfg.Providers.ColdStart))
+	factory.SetRoutingRules(providers.RoutingRulesFromConfig(cfg.Providers.RoutingRules))
+	factory.SetHealthCheckConcurrency(cfg.Providers.HealthCheckConcurrency)
+
+	// In test mode the mock provider answers in-process instead of the backend
+	var transport http.RoundTripper
+	var initErr error
+	if cfg.Providers.Mock.Enabled {
+		mock, err := newMockTransport(factory, cfg.Providers.Mock)
+		if err != nil {
+			logger.Error("failed to initialize mock provider", "error", err)
+		} else {
+			logger.Warn("mock provider enabled, backend requests will return canned responses")
+			transport = mock
+		}
+	} else {
+		if initErr = registerProviders(factory, cfg); initErr != nil {
+			logger.Error("providers failed to initialize", "error", initErr)
+		}
+	}
+
+	// Conversations are stored so requests can continue them via previous_response_id
+	var store session.ConversationStore
+	if cfg.Session.Enabled {
+		var err error
+		store, err = session.NewStore(cfg.Session)
+		if err != nil {
+			logger.Error("failed to initialize conversation store, previous_response_id is disabled", "error", err)
+			store = nil
+		}
 	}
 
+	// Responses kept for GET, capped in size
+	responses := session.NewResponseStore(cfg.Session.TTL, cfg.Session.MaxConversations)
+	responses.LimitSize(cfg.Session.MaxResponseBytes, cfg.Session.OversizedResponses)
+	idempotent := session.NewResponseStore(cfg.Session.TTL, cfg.Session.MaxConversations)
+	idempotent.LimitSize(cfg.Session.MaxResponseBytes, session.OversizedSkip)
+
 	return &ProxyHandler{
 		cfg:    cfg,
 		logger: logger,
-		client: &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+		store:  store,
+		queue:  newDispatchQueue(cfg.Server.MaxConcurrentRequests, cfg.Server.MaxQueueDepth, cfg.Server.QueueWaitTimeout),
+		bus:    events.NewBus(),
+
+		providers: factory,
+		backends:  newBackends(cfg, factory, transport, logger),
+
+		responses:  responses,
+		idempotent: idempotent,
+		tenants:    newTenantLabeler(cfg),
+		initErr:    initErr,
+	}
+}
+
+// InitError returns the error that should stop startup: no enabled provider
+// could be initialized, or one could not and providers.strict is set
+func (h *ProxyHandler) InitError() error {
+	return h.initErr
+}
+
+// boolFields are the request fields coerced to booleans by normalizeBoolFields
+var boolFields = []string{"stream", "background", "store", "parallel_tool_calls"}
+
+// normalizeBoolFields converts string forms of known boolean request fields,
+// such as "stream": "true", to booleans. Unrecognized strings are left as is.
+func normalizeBoolFields(req map[string]interface{}) {
+	for _, field := range boolFields {
+		s, ok := req[field].(string)
+		if !ok {
+			continue
+		}
+		if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+			req[field] = b
+		}
+	}
+}
+
+// isJSONContentType reports whether a Content-Type header names JSON.
+// Parameters such as charset are allowed.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// Events returns the bus on which the handler publishes request lifecycle
+// events, for observability subscribers
+func (h *ProxyHandler) Events() *events.Bus {
+	return h.bus
+}
+
+// SetIDGenerator replaces the generator of the IDs the handler and its
+// providers assign, e.g. with a deterministic one in tests
+func (h *ProxyHandler) SetIDGenerator(g ids.Generator) {
+	h.ids = g
+	h.providers.SetIDGenerator(g)
+}
+
+// newID returns a new ID with the given prefix
+func (h *ProxyHandler) newID(prefix string) string {
+	if h.ids == nil {
+		return ids.Default.NewID(prefix)
+	}
+	return h.ids.NewID(prefix)
+}
+
+// StartHealthChecks runs scheduled health checks for the handler's providers
+// until ctx is cancelled or the handler shuts down
+func (h *ProxyHandler) StartHealthChecks(ctx context.Context) {
+	h.providers.StartHealthChecks(ctx)
+}
+
+// Shutdown releases the handler's backend connections, shutting down its
+// providers within ctx's deadline, and writes out the conversation store
+func (h *ProxyHandler) Shutdown(ctx context.Context) error {
+	for _, b := range h.backends {
+		b.client.CloseIdleConnections()
+	}
+	if h.store != nil {
+		if err := h.store.Close(); err != nil {
+			h.logger.Error("failed to write conversation store", "error", err)
+		}
+	}
+	return h.providers.ShutdownAll(ctx)
+}
+
+// writeError writes an error response in the configured envelope format
+func (h *ProxyHandler) writeError(w http.ResponseWriter, status int, errType, code, message string) {
+	middleware.WriteError(w, h.cfg.Server.ErrorFormat, status, errType, code, message)
+}
+
+// newMockTransport registers a mock provider from config with the factory for
+// use as the backend transport
+func newMockTransport(factory *providers.Factory, cfg config.MockConfig) (*providers.MockProvider, error) {
+	toolCalls := make([]providers.MockToolCall, 0, len(cfg.ToolCalls))
+	for _, tc := range cfg.ToolCalls {
+		toolCalls = append(toolCalls, providers.MockToolCall{
+			Name:      tc.Name,
+			Arguments: tc.Arguments,
+		})
+	}
+
+	err := factory.RegisterProvider(providers.ProviderConfig{
+		Name:    "mock",
+		Type:    providers.ProviderTypeMock,
+		Enabled: true,
+		Mock: providers.MockConfig{
+			Text:      cfg.Text,
+			ToolCalls: toolCalls,
+			Latency:   cfg.Latency,
 		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := factory.GetProvider("mock")
+	if err != nil {
+		return nil, err
 	}
+	return provider.(*providers.MockProvider), nil
 }
 
 // ServeHTTP handles the proxy request
@@ -48,11 +236,12 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = time.Now() // Record start time for metrics (not used yet)
 
 	// Log incoming request
-	h.logger.Info("incoming request",
+	attrs := []any{
 		"method", r.Method,
 		"path", r.URL.Path,
 		"remote_addr", r.RemoteAddr,
-	)
+	}
+	h.logger.Info("incoming request", append(attrs, parseCodexHeaders(r).logAttrs()...)...)
 
 	// Handle GET requests for retrieving responses
 	if r.Method == http.MethodGet {
@@ -74,16 +263,28 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Method not allowed
 	h.logger.Warn("method not allowed", "method", r.Method)
-	w.WriteHeader(http.StatusMethodNotAllowed)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]interface{}{
-			"type":    "invalid_request_error",
-			"message": fmt.Sprintf("Method %s not allowed", r.Method),
-		},
-	})
+	h.writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", fmt.Sprintf("Method %s not allowed", r.Method))
 }
 
 func (h *ProxyHandler) handleCreateResponse(w http.ResponseWriter, r *http.Request) {
+	lc, ctx := h.startLifecycle(r.Context(), h.tenants.label(r))
+	r = r.WithContext(ctx)
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+	defer func() {
+		if !lc.detached.Load() {
+			lc.finish(sw.status)
+		}
+	}()
+
+	// Reject other media types up front rather than with a JSON parse error
+	if contentType := r.Header.Get("Content-Type"); !isJSONContentType(contentType) {
+		h.logger.Warn("unsupported content type", "content_type", contentType)
+		h.writeError(w, http.StatusUnsupportedMediaType, "invalid_request_error", "unsupported_media_type",
+			fmt.Sprintf("Content-Type must be application/json, got %q", contentType))
+		return
+	}
+
 	// Read request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -93,20 +294,37 @@ func (h *ProxyHandler) handleCreateResponse(w http.ResponseWriter, r *http.Reque
 	}
 	defer r.Body.Close()
 
+	// A retried request with an Idempotency-Key already served gets the same
+	// response, and one still being handled a conflict
+	releaseKey, ok := h.beginIdempotent(w, r, body)
+	if !ok {
+		return
+	}
+	defer releaseKey()
+
 	// Parse the Responses API request
 	var req map[string]interface{}
 	if err := json.Unmarshal(body, &req); err != nil {
+		transformErrorsRequest.Add(1)
 		h.logger.Error("failed to parse request", "error", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": map[string]interface{}{
-				"type":    "invalid_request_error",
-				"message": "Invalid JSON in request body",
-			},
-		})
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid JSON in request body")
 		return
 	}
 
+	// In strict mode, typos such as max_token are an error rather than ignored
+	if h.cfg.Server.StrictRequestFields {
+		if unknown, _ := providers.UnknownRequestFields(body); len(unknown) > 0 {
+			h.writeError(w, http.StatusBadRequest, "invalid_request_error", "unknown_parameter",
+				"Unknown request fields: "+strings.Join(unknown, ", "))
+			return
+		}
+	}
+
+	// Accept "true"/"false" strings for boolean fields before anything reads them
+	normalizeBoolFields(req)
+	lc.model, _ = req["model"].(string)
+	lc.stream, _ = req["stream"].(bool)
+
 	// Log request details
 	h.logger.Debug("request parsed",
 		"model", req["model"],
@@ -114,31 +332,77 @@ func (h *ProxyHandler) handleCreateResponse(w http.ResponseWriter, r *http.Reque
 		"has_instructions", req["instructions"] != nil,
 	)
 
+	if bias, ok := req["logit_bias"]; ok && bias != nil {
+		if _, err := providers.ParseLogitBias(bias); err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+			return
+		}
+	}
+
+	// Route to the provider serving the backend model; its settings shape the
+	// request. Responses echo the model the client asked for, not the mapped one.
+	clientModel, _ := req["model"].(string)
+	metadata, _ := req["metadata"].(map[string]interface{})
+	backendModel := h.mapModel(clientModel)
+	target := h.resolveBackend(r.Context(), backendModel, metadata)
+	r = r.WithContext(withBackend(r.Context(), target, backendModel, req))
+
+	// Reject requests that need features the backend does not support
+	if err := h.validateCapabilities(req, target); err != nil {
+		h.logger.Warn("request rejected by provider capabilities", "error", err)
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "unsupported_capability", err.Error())
+		return
+	}
+	r = r.WithContext(h.withReasoningContent(r.Context(), req))
+
+	timing := middleware.TimingFromContext(r.Context())
+
 	// Transform Responses API request to Chat Completions format
-	chatReq := h.transformRequest(req)
+	transformStart := time.Now()
+	chatReq := h.transformRequest(req, target)
+	timing.AddTransform(time.Since(transformStart))
 
-	// Marshal chat completions request
-	chatBody, err := json.Marshal(chatReq)
+	// Continue a stored conversation when previous_response_id is given
+	history, err := h.stitchConversation(req, chatReq)
 	if err != nil {
-		h.logger.Error("failed to marshal chat request", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		h.logger.Warn("previous response not found", "error", err)
+		h.writeError(w, http.StatusNotFound, "invalid_request_error", "previous_response_not_found", err.Error())
+		return
+	}
+
+	// Empty input is only valid when previous_response_id supplies the conversation
+	if len(history) == 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "missing_required_parameter", "input is required")
 		return
 	}
 
-	// Use provider base URL if available, fallback to legacy config
-	baseURL := h.cfg.Providers.Zai.BaseURL
-	if baseURL == "" {
-		baseURL = h.cfg.Zai.BaseURL
+	// Fit the conversation into the context budget per the truncation strategy
+	if err := h.applyTruncation(req, chatReq); err != nil {
+		h.logger.Warn("request exceeds context budget", "error", err)
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", err.Error())
+		return
 	}
 
-	// Debug log the transformed request
+	// Rename fields for backends with non-standard names, then marshal
+	h.remapRequest(chatReq, target)
+	chatBody, err := json.Marshal(chatReq)
+	if err != nil {
+		h.logger.Error("failed to marshal chat request", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	baseURL := target.endpoints.Active()
 
 	// Log request model for verification
-	h.logger.Info(">>> REQUEST TO ZAI", "model", chatReq["model"], "backend_url", baseURL)
+	h.logger.Info(">>> REQUEST TO ZAI", "model", chatReq["model"], "provider", target.name, "backend_url", baseURL)
 	h.logger.Info("sending to backend", "body", string(chatBody))
 
-	// Create backend request
-	backendURL := baseURL + "/chat/completions"
+	// Create backend request; doBackend addresses each attempt to an endpoint
+	backendURL, err := target.chatURL(baseURL, backendModel)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "model_not_found", err.Error())
+		return
+	}
 	backendReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, backendURL, bytes.NewReader(chatBody))
 	if err != nil {
 		h.logger.Error("failed to create backend request", "error", err)
@@ -148,13 +412,17 @@ func (h *ProxyHandler) handleCreateResponse(w http.ResponseWriter, r *http.Reque
 
 	// Set headers
 	backendReq.Header.Set("Content-Type", "application/json")
-
-	// Use provider API key if available, fallback to legacy Zai config
-	apiKey := h.cfg.Providers.Zai.APIKey
-	if apiKey == "" {
-		apiKey = h.cfg.Zai.APIKey
+	parseCodexHeaders(r).propagate(backendReq)
+
+	// With passthrough auth the client's own key goes to the backend;
+	// otherwise doBackend adds the key of the provider it sends to
+	if h.cfg.Providers.PassthroughAuth {
+		if middleware.ClientCredential(r, "Authorization") == "" {
+			h.writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "An Authorization header with the backend API key is required")
+			return
+		}
+		backendReq.Header.Set("Authorization", r.Header.Get("Authorization"))
 	}
-	backendReq.Header.Set("Authorization", "Bearer "+apiKey)
 
 	// Check if streaming is requested
 	streaming := false
@@ -162,61 +430,149 @@ func (h *ProxyHandler) handleCreateResponse(w http.ResponseWriter, r *http.Reque
 		streaming = s
 	}
 
+	// Responses clients can't keep waiting on run in the background and are polled
+	if background, _ := req["background"].(bool); background {
+		if streaming {
+			h.writeError(w, http.StatusBadRequest, "invalid_request_error", "", "background mode does not support streaming")
+			return
+		}
+		h.startBackground(w, r, backendReq, history, clientModel)
+		return
+	}
+
+	// Debug latency the client asked for (debug.synthetic_latency)
+	latency, err := h.parseSyntheticLatency(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	if err := sleepContext(r.Context(), latency.dispatch); err != nil {
+		h.logger.Warn("client gave up during synthetic delay", "error", err)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), syntheticLatencyKey{}, latency))
+
+	// Wait for a dispatch slot when the backend is at capacity
+	err = h.queue.acquire(r.Context())
+	h.queue.setHeaders(w.Header())
+	if err != nil {
+		h.logger.Warn("request rejected by dispatch queue", "error", err)
+		if errors.Is(err, errQueueFull) || errors.Is(err, errQueueTimeout) {
+			w.Header().Set("Retry-After", "1")
+			h.writeError(w, http.StatusTooManyRequests, "rate_limit_error", "queue_unavailable", "Server is at capacity: "+err.Error())
+		}
+		return
+	}
+	release := h.queue.slot()
+	defer release()
+	r = r.WithContext(withSlot(r.Context(), release))
+
+	timing.MarkDispatch()
 	if streaming {
-		h.handleStreamingResponse(w, r, backendReq)
+		h.handleStreamingResponse(w, r, backendReq, history, clientModel)
 	} else {
-		h.handleNonStreamingResponse(w, r, backendReq)
+		h.handleNonStreamingResponse(w, r, backendReq, history, clientModel)
 	}
 }
 
-func (h *ProxyHandler) handleNonStreamingResponse(w http.ResponseWriter, r *http.Request, backendReq *http.Request) {
+func (h *ProxyHandler) handleNonStreamingResponse(w http.ResponseWriter, r *http.Request, backendReq *http.Request, history []map[string]interface{}, clientModel string) {
+	timing := middleware.TimingFromContext(r.Context())
+
 	// Execute backend request
-	resp, err := h.client.Do(backendReq)
+	lc := lifecycleFromContext(r.Context())
+	lc.publish(events.BackendDispatched, 0)
+	backendStart := time.Now()
+	resp, err := h.doBackend(backendReq)
 	if err != nil {
 		h.logger.Error("backend request failed", "error", err)
-		w.WriteHeader(http.StatusBadGateway)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": map[string]interface{}{
-				"type":    "api_error",
-				"message": "Failed to reach backend server",
-			},
-		})
+		h.recordBackendResult(r.Context(), 0)
+		if h.writeFallbackResponse(w, r, clientModel, false) {
+			return
+		}
+		h.writeError(w, http.StatusBadGateway, "api_error", "", "Failed to reach backend server")
 		return
 	}
 	defer resp.Body.Close()
+	lc.watchFirstToken(resp)
+	copyRateLimitHeaders(w.Header(), resp.Header)
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	// Read response body, bounded so a runaway backend can't exhaust memory
+	target := h.routedBackend(r.Context())
+	body, err := providers.ReadResponse(resp.Body, target.config.MaxResponseBytes)
+	timing.AddBackend(time.Since(backendStart))
 	if err != nil {
 		h.logger.Error("failed to read backend response", "error", err)
+		if errors.Is(err, providers.ErrResponseTooLarge) {
+			h.writeError(w, http.StatusBadGateway, "api_error", "backend_response_too_large",
+				fmt.Sprintf("Backend response is too large (providers.%s.max_response_bytes)", target.name))
+			return
+		}
 		w.WriteHeader(http.StatusBadGateway)
 		return
 	}
 
 	// Check for non-OK status
+	h.recordBackendResult(r.Context(), resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
-		h.logger.Warn("backend returned non-OK status",
-			"status", resp.StatusCode,
-			"body", string(body),
-		)
-		w.WriteHeader(resp.StatusCode)
-		w.Write(body)
+		if retryableStatus(resp.StatusCode) && h.writeFallbackResponse(w, r, clientModel, false) {
+			return
+		}
+		h.writeBackendError(w, resp, body)
 		return
 	}
 
 	// Parse Chat Completions response
 	var chatResp map[string]interface{}
 	if err := json.Unmarshal(body, &chatResp); err != nil {
+		transformErrorsResponse.Add(1)
 		h.logger.Error("failed to parse backend response", "error", err)
 		w.WriteHeader(http.StatusBadGateway)
 		return
 	}
+	h.remapResponse(chatResp, target)
+
+	// Some backends report errors in a 200 body; relay them rather than an
+	// empty completed response
+	if embedded, ok := embeddedBackendError(chatResp); ok {
+		h.logger.Warn("backend returned an error with status 200", "status", embedded.status, "body", string(body))
+		h.writeError(w, embedded.status, embedded.errType, embedded.code, embedded.message)
+		return
+	}
+
+	// Hold the backend to tool_choice "required" per providers.enforce_tool_choice
+	chatResp, err = h.enforceToolChoice(backendReq, chatResp)
+	if err != nil {
+		h.logger.Warn("tool_choice required not honored", "error", err)
+		h.writeError(w, http.StatusBadGateway, "api_error", "tool_choice_not_honored", err.Error())
+		return
+	}
 
 	// Transform to Responses API format
 
 	// Log z.ai response for verification
 	h.logger.Info("<<< RESPONSE FROM ZAI", "model", chatResp["model"], "status", resp.StatusCode)
-	responsesResp := h.transformResponse(chatResp)
+	transformStart := time.Now()
+	responsesResp := h.transformResponse(chatResp, clientModel, lifecycleFromContext(r.Context()).createdAt())
+	timing.AddTransform(time.Since(transformStart))
+
+	usage, _ := chatResp["usage"].(map[string]interface{})
+	backendModel, _ := chatResp["model"].(string)
+	if cost, ok := h.recordCost(h.providerName(target), clientModel, backendModel, usage); ok && h.cfg.Codex.ExposeCost {
+		responsesResp["x_cost"] = cost
+	}
+
+	// Background jobs report under the ID they were queued with, and store
+	// their response themselves; others are kept for GET /v1/responses/{id}
+	if id := backgroundIDFromContext(r.Context()); id != "" {
+		responsesResp["id"] = id
+	} else if responseID, ok := responsesResp["id"].(string); ok {
+		h.responses.Save(responseID, lifecycleFromContext(r.Context()).requestID(), responsesResp)
+		h.saveIdempotent(r, responsesResp)
+	}
+
+	if responseID, ok := responsesResp["id"].(string); ok {
+		h.saveConversation(responseID, history, assistantMessage(chatResp))
+	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
@@ -224,29 +580,48 @@ func (h *ProxyHandler) handleNonStreamingResponse(w http.ResponseWriter, r *http
 	json.NewEncoder(w).Encode(responsesResp)
 }
 
-func (h *ProxyHandler) handleStreamingResponse(w http.ResponseWriter, r *http.Request, backendReq *http.Request) {
+func (h *ProxyHandler) handleStreamingResponse(w http.ResponseWriter, r *http.Request, backendReq *http.Request, history []map[string]interface{}, clientModel string) {
+	timing := middleware.TimingFromContext(r.Context())
+
 	// Execute backend request
-	resp, err := h.client.Do(backendReq)
+	lc := lifecycleFromContext(r.Context())
+	lc.publish(events.BackendDispatched, 0)
+	backendStart := time.Now()
+	resp, err := h.doBackend(backendReq)
+	timing.AddBackend(time.Since(backendStart))
 	if err != nil {
 		h.logger.Error("backend request failed", "error", err)
-		w.WriteHeader(http.StatusBadGateway)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": map[string]interface{}{
-				"type":    "api_error",
-				"message": "Failed to reach backend server",
-			},
-		})
+		h.recordBackendResult(r.Context(), 0)
+		if h.writeFallbackResponse(w, r, clientModel, true) {
+			return
+		}
+		if h.streamErrorsAsEvents(r) {
+			h.streamError(w, r, http.StatusBadGateway, "Failed to reach backend server")
+			return
+		}
+		h.writeError(w, http.StatusBadGateway, "api_error", "", "Failed to reach backend server")
 		return
 	}
 	defer resp.Body.Close()
+	lc.watchFirstToken(resp)
+	copyRateLimitHeaders(w.Header(), resp.Header)
 
 	// Check for non-OK status
+	h.recordBackendResult(r.Context(), resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
-		h.logger.Warn("backend returned non-OK status for stream",
-			"status", resp.StatusCode,
-		)
-		w.WriteHeader(resp.StatusCode)
-		io.Copy(w, resp.Body)
+		body, _ := providers.ReadResponse(resp.Body, h.routedBackend(r.Context()).config.MaxResponseBytes)
+		if retryableStatus(resp.StatusCode) && h.writeFallbackResponse(w, r, clientModel, true) {
+			return
+		}
+		if h.streamErrorsAsEvents(r) {
+			h.logger.Warn("backend returned non-OK status for stream",
+				"status", resp.StatusCode,
+				"body", string(body),
+			)
+			h.streamError(w, r, resp.StatusCode, backendErrorMessage(resp.StatusCode, body))
+			return
+		}
+		h.writeBackendError(w, resp, body)
 		return
 	}
 
@@ -263,58 +638,278 @@ func (h *ProxyHandler) handleStreamingResponse(w http.ResponseWriter, r *http.Re
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
-	// Transform and stream events
-	h.transformStream(resp.Body, w, flusher)
+	// Transform and stream events; this includes waiting on the backend for
+	// each chunk, so it is counted as transform time
+	transformStart := time.Now()
+	body := newDelayedStream(r.Context(), resp.Body, syntheticLatencyFromContext(r.Context()).token)
+	var responseID string
+	var assistant map[string]interface{}
+	if anthropicStream(r) {
+		responseID, assistant = h.transformStreamAnthropic(body, w, flusher, h.routedBackend(r.Context()), clientModel)
+	} else {
+		responseID, assistant = h.transformStream(body, w, flusher, h.routedBackend(r.Context()), clientModel, lifecycleFromContext(r.Context()).createdAt(), reasoningContentRequested(r.Context()))
+	}
+	timing.AddTransform(time.Since(transformStart))
+
+	h.saveConversation(responseID, history, assistant)
 }
 
-func (h *ProxyHandler) handleGetResponse(w http.ResponseWriter, r *http.Request) {
-	// Extract response ID from path
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 4 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
+// writeBackendError relays a backend error response to the client. JSON error
+// bodies pass through; anything else (e.g. an HTML page from a gateway) is
+// replaced by a JSON error envelope so clients can always parse the error.
+func (h *ProxyHandler) writeBackendError(w http.ResponseWriter, resp *http.Response, body []byte) {
+	if json.Valid(body) {
+		h.logger.Warn("backend returned non-OK status",
+			"status", resp.StatusCode,
+			"body", string(body),
+		)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.StatusCode)
+		w.Write(body)
+		return
+	}
+
+	h.logger.Warn("backend returned non-OK status with non-JSON body",
+		"status", resp.StatusCode,
+		"content_type", resp.Header.Get("Content-Type"),
+	)
+	h.logger.Debug("raw backend error body", "status", resp.StatusCode, "body", string(body))
+
+	h.writeError(w, resp.StatusCode, "api_error", "", backendErrorMessage(resp.StatusCode, nil))
+}
+
+// streamErrorsAsEvents reports whether a failed stream should be reported as a
+// response.failed event rather than a plain HTTP error. Only clients that
+// asked for an event stream get the event form.
+func (h *ProxyHandler) streamErrorsAsEvents(r *http.Request) bool {
+	return h.cfg.Server.StreamErrorEvents && strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamError reports a streaming failure as an event in the stream format
+// the client asked for
+func (h *ProxyHandler) streamError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	// The client sees a 200 event stream, but the request still failed
+	lifecycleFromContext(r.Context()).finish(status)
+
+	if anthropicStream(r) {
+		h.writeAnthropicStreamError(w, status, message)
+		return
+	}
+	h.writeStreamError(w, status, message, lifecycleFromContext(r.Context()).createdAt())
+}
+
+// writeStreamError reports a failure as a response.failed SSE event on a 200
+// event stream, so EventSource clients can surface the error instead of
+// failing to parse a non-SSE response
+func (h *ProxyHandler) writeStreamError(w http.ResponseWriter, status int, message string, createdAt int64) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	failedEvent := map[string]interface{}{
+		"type":            "response.failed",
+		"sequence_number": 0,
+		"response": map[string]interface{}{
+			"id":         h.newID("resp_"),
+			"object":     "response",
+			"created_at": createdAt,
+			"status":     "failed",
+			"output":     []interface{}{},
 			"error": map[string]interface{}{
-				"type":    "invalid_request_error",
-				"message": "Invalid response ID",
+				"code":    fmt.Sprintf("http_%d", status),
+				"message": message,
 			},
-		})
+		},
+	}
+	writeEvent(w, failedEvent, h.cfg.Codex.SSEEventLines)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// backendErrorMessage extracts error.message from a backend error body,
+// falling back to a generic message for the status
+func backendErrorMessage(status int, body []byte) string {
+	var envelope struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Error.Message != "" {
+		return envelope.Error.Message
+	}
+	return fmt.Sprintf("Backend returned HTTP %d %s", status, http.StatusText(status))
+}
+
+// backendError is an error a backend reported in the body of a 200 response
+type backendError struct {
+	status  int
+	errType string
+	code    string
+	message string
+}
+
+// embeddedBackendError extracts the error object of a response that has no
+// choices. The status is the error's own status or code when that is an HTTP
+// error status, and 502 otherwise.
+func embeddedBackendError(resp map[string]interface{}) (backendError, bool) {
+	if _, ok := resp["choices"]; ok {
+		return backendError{}, false
+	}
+	errObj, ok := resp["error"].(map[string]interface{})
+	if !ok {
+		return backendError{}, false
+	}
+
+	embedded := backendError{status: http.StatusBadGateway, errType: "api_error"}
+	if t, _ := errObj["type"].(string); t != "" {
+		embedded.errType = t
+	}
+	embedded.message, _ = errObj["message"].(string)
+	if embedded.message == "" {
+		embedded.message = "Backend returned an error without a message"
+	}
+
+	for _, field := range []string{"status", "code"} {
+		var status int
+		switch v := errObj[field].(type) {
+		case float64:
+			status = int(v)
+		case string:
+			status, _ = strconv.Atoi(v)
+		}
+		if status >= http.StatusBadRequest && status <= 599 {
+			embedded.status = status
+			break
+		}
+	}
+	if code, ok := errObj["code"].(string); ok {
+		embedded.code = code
+	}
+	return embedded, true
+}
+
+func (h *ProxyHandler) handleGetResponse(w http.ResponseWriter, r *http.Request) {
+	// Extract response ID from path
+	responseID, ok := responseIDFromPath(r.URL)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid response ID")
 		return
 	}
-	responseID := parts[3]
 
-	// For now, return not implemented since we don't have response storage
-	h.logger.Debug("get response not implemented", "response_id", responseID)
-	w.WriteHeader(http.StatusNotImplemented)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]interface{}{
-			"type":    "invalid_request_error",
-			"message": "Response retrieval not implemented in proxy mode",
-		},
-	})
+	stored, ok := h.responses.Get(responseID)
+	if !ok && h.responses.TooLarge(responseID) {
+		h.logger.Debug("response too large to store", "response_id", responseID)
+		h.writeError(w, http.StatusNotFound, "invalid_request_error", "response_too_large", fmt.Sprintf("Response with id '%s' was too large to store (session.max_response_bytes)", responseID))
+		return
+	}
+	if !ok {
+		h.logger.Debug("response not found", "response_id", responseID)
+		h.writeError(w, http.StatusNotFound, "invalid_request_error", "", fmt.Sprintf("Response with id '%s' not found", responseID))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stored.Body)
 }
 
 func (h *ProxyHandler) handleDeleteResponse(w http.ResponseWriter, r *http.Request) {
 	// Extract response ID from path
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 4 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": map[string]interface{}{
-				"type":    "invalid_request_error",
-				"message": "Invalid response ID",
-			},
-		})
+	responseID, ok := responseIDFromPath(r.URL)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid response ID")
 		return
 	}
-	responseID := parts[3]
 
 	// For now, return not implemented
 	h.logger.Debug("delete response not implemented", "response_id", responseID)
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
-// transformRequest transforms Responses API request to Chat Completions format
-func (h *ProxyHandler) transformRequest(req map[string]interface{}) map[string]interface{} {
+// responseRoutePrefixes are the routes that take a response ID, most specific first
+var responseRoutePrefixes = []string{"/v1/responses/", "/responses/"}
+
+// responseIDFromPath extracts the response ID from a versioned or unversioned
+// response route. The escaped path is used so IDs containing encoded slashes
+// survive intact; the returned ID is unescaped.
+func responseIDFromPath(u *url.URL) (string, bool) {
+	path := u.EscapedPath()
+	for _, prefix := range responseRoutePrefixes {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+
+		raw := strings.TrimSuffix(strings.TrimPrefix(path, prefix), "/")
+		if raw == "" || strings.Contains(raw, "/") {
+			return "", false
+		}
+
+		id, err := url.PathUnescape(raw)
+		if err != nil || id == "" {
+			return "", false
+		}
+		return id, true
+	}
+
+	return "", false
+}
+
+// validateCapabilities checks the request against the capabilities of the
+// provider it is routed to
+func (h *ProxyHandler) validateCapabilities(req map[string]interface{}, target *backend) error {
+	caps := target.capabilities
+
+	if stream, _ := req["stream"].(bool); stream && !caps.Streaming {
+		return fmt.Errorf("streaming is not supported by the configured provider")
+	}
+	if tools, ok := req["tools"].([]interface{}); ok && len(tools) > 0 && !caps.Tools {
+		return fmt.Errorf("tools are not supported by the configured provider")
+	}
+	if reasoning, ok := req["reasoning"]; ok && reasoning != nil && !caps.Reasoning {
+		return fmt.Errorf("reasoning is not supported by the configured provider")
+	}
+	if !caps.Vision && inputHasImages(req["input"]) {
+		return fmt.Errorf("image input is not supported by the configured provider")
+	}
+
+	return nil
+}
+
+// inputHasImages reports whether the Responses API input contains image content
+func inputHasImages(input interface{}) bool {
+	items, ok := input.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if itemType, _ := itemMap["type"].(string); itemType == "input_image" {
+			return true
+		}
+		if content, ok := itemMap["content"].([]interface{}); ok {
+			for _, part := range content {
+				if partMap, ok := part.(map[string]interface{}); ok {
+					if partType, _ := partMap["type"].(string); partType == "input_image" || partType == "image_url" {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// transformRequest transforms Responses API request to Chat Completions
+// format for the backend it is routed to
+func (h *ProxyHandler) transformRequest(req map[string]interface{}, target *backend) map[string]interface{} {
 	chatReq := make(map[string]interface{})
 
 	// Copy model with mapping
@@ -330,8 +925,10 @@ func (h *ProxyHandler) transformRequest(req map[string]interface{}) map[string]i
 	// Transform input to messages
 	messages := []map[string]interface{}{}
 
-	// Add instructions if present
-	if instructions, ok := req["instructions"].(string); ok && instructions != "" {
+	// Add instructions if present, after the instructions template
+	instructions, _ := req["instructions"].(string)
+	model, _ := req["model"].(string)
+	if instructions = h.renderInstructions(target, instructions, model); instructions != "" {
 		messages = append(messages, map[string]interface{}{
 			"role":    "system",
 			"content": instructions,
@@ -342,19 +939,39 @@ func (h *ProxyHandler) transformRequest(req map[string]interface{}) map[string]i
 	if input, ok := req["input"]; ok {
 		switch v := input.(type) {
 		case string:
-			messages = append(messages, map[string]interface{}{
-				"role":    "user",
-				"content": v,
-			})
+			if v != "" {
+				messages = append(messages, map[string]interface{}{
+					"role":    "user",
+					"content": v,
+				})
+			}
 		case []interface{}:
+			// Reasoning items precede the assistant turn they belong to
+			pendingReasoning := ""
 			for _, item := range v {
 				if itemMap, ok := item.(map[string]interface{}); ok {
+					if itemType, _ := itemMap["type"].(string); itemType == "reasoning" {
+						if !target.supportsReasoning() {
+							h.logger.Debug("dropping reasoning input item, provider does not support reasoning")
+							continue
+						}
+						pendingReasoning += reasoningContent(itemMap)
+						continue
+					}
+
 					msg := h.transformInputItem(itemMap)
 					if msg != nil {
+						if role, _ := msg["role"].(string); role == "assistant" && pendingReasoning != "" {
+							msg["reasoning_content"] = pendingReasoning
+							pendingReasoning = ""
+						}
 						messages = append(messages, msg)
 					}
 				}
 			}
+			if pendingReasoning != "" {
+				h.logger.Debug("dropping reasoning input item with no following assistant turn")
+			}
 		}
 	}
 
@@ -366,14 +983,65 @@ func (h *ProxyHandler) transformRequest(req map[string]interface{}) map[string]i
 	}
 	if maxTokens, ok := req["max_output_tokens"]; ok && maxTokens != nil {
 		chatReq["max_tokens"] = maxTokens
+	} else if maxTokens, ok := req["max_tokens"]; ok && maxTokens != nil {
+		// Chat Completions clients sometimes send max_tokens on a Responses request
+		h.logger.Warn("max_tokens is deprecated on the Responses API, use max_output_tokens", "max_tokens", maxTokens)
+		chatReq["max_tokens"] = maxTokens
 	}
 	if topP, ok := req["top_p"]; ok && topP != nil {
 		chatReq["top_p"] = topP
 	}
+	if bias, ok := req["logit_bias"]; ok && bias != nil {
+		// Validated in handleCreateResponse
+		if target.capabilities.LogitBias {
+			chatReq["logit_bias"] = bias
+		} else {
+			h.logger.Debug("dropping logit_bias unsupported by the backend")
+		}
+	}
+	if key, ok := req["prompt_cache_key"].(string); ok && key != "" {
+		if target.capabilities.PromptCacheKey {
+			chatReq["prompt_cache_key"] = key
+		} else {
+			h.logger.Debug("dropping prompt_cache_key unsupported by the backend")
+		}
+	}
+	if id, ok := req["safety_identifier"].(string); ok && id != "" {
+		if target.capabilities.SafetyIdentifier {
+			chatReq["safety_identifier"] = id
+		} else {
+			h.logger.Debug("dropping safety_identifier unsupported by the backend")
+		}
+	}
+
+	// Fill in configured sampling defaults the client left unset
+	clientModel, _ := req["model"].(string)
+	backendModel, _ := chatReq["model"].(string)
+	defaultTemp, defaultTopP := h.cfg.Providers.SamplingDefaultsFor(clientModel, backendModel)
+	if _, ok := chatReq["temperature"]; !ok && defaultTemp != nil {
+		chatReq["temperature"] = *defaultTemp
+	}
+	if _, ok := chatReq["top_p"]; !ok && defaultTopP != nil {
+		chatReq["top_p"] = *defaultTopP
+	}
 	if stream, ok := req["stream"]; ok {
 		chatReq["stream"] = stream
 	}
 
+	// Forward client stream_options layered over the router defaults
+	if streamOpts, ok := req["stream_options"].(map[string]interface{}); ok {
+		if stream, _ := req["stream"].(bool); stream {
+			merged := map[string]interface{}{}
+			for k, v := range defaultStreamOptions {
+				merged[k] = v
+			}
+			for k, v := range streamOpts {
+				merged[k] = v
+			}
+			chatReq["stream_options"] = merged
+		}
+	}
+
 	// Transform tools (only if present and non-empty)
 	if tools, ok := req["tools"].([]interface{}); ok && len(tools) > 0 {
 		chatReq["tools"] = h.transformTools(tools)
@@ -401,6 +1069,54 @@ func (h *ProxyHandler) mapModel(model string) string {
 	return model
 }
 
+// recordCost estimates the cost of a request served by provider from its Chat
+// Completions usage and providers.pricing, logging it and adding it to the
+// cost metric. It reports false when there is no usage or the model has no price.
+func (h *ProxyHandler) recordCost(provider, clientModel, backendModel string, usage map[string]interface{}) (float64, bool) {
+	if usage == nil {
+		return 0, false
+	}
+	pricing, ok := h.cfg.Providers.PricingFor(clientModel, backendModel)
+	if !ok {
+		return 0, false
+	}
+
+	inputTokens, _ := usage["prompt_tokens"].(float64)
+	outputTokens, _ := usage["completion_tokens"].(float64)
+	cost := pricing.Cost(int(inputTokens), int(outputTokens))
+
+	// Spend is attributed to the model actually billed
+	model := backendModel
+	if model == "" {
+		model = clientModel
+	}
+	addCost(model, provider, cost)
+
+	h.logger.Info("request cost",
+		"model", model,
+		"provider", provider,
+		"input_tokens", int(inputTokens),
+		"output_tokens", int(outputTokens),
+		"cost_usd", cost,
+	)
+	return cost, true
+}
+
+// setResponseModel sets a response's model to the one the client requested,
+// falling back to reverse-mapping the backend model when the request had none.
+// With codex.expose_backend_model the backend model is added as x_backend_model.
+func (h *ProxyHandler) setResponseModel(resp map[string]interface{}, clientModel, backendModel string) {
+	model := clientModel
+	if model == "" {
+		model = h.reverseMapModel(backendModel)
+	}
+	resp["model"] = model
+
+	if h.cfg.Codex.ExposeBackendModel && backendModel != "" {
+		resp["x_backend_model"] = backendModel
+	}
+}
+
 // reverseMapModel maps a backend model name back to the original model name
 func (h *ProxyHandler) reverseMapModel(backendModel string) string {
 	// Check provider model mapping for reverse lookup
@@ -517,11 +1233,45 @@ func (h *ProxyHandler) transformInputItem(item map[string]interface{}) map[strin
 	return nil
 }
 
+// supportsReasoning reports whether the backend can resume prior reasoning
+func (b *backend) supportsReasoning() bool {
+	return b.capabilities.Reasoning
+}
+
+// reasoningContent extracts the reasoning to forward from a reasoning input
+// item: the encrypted content when present, otherwise its text
+func reasoningContent(item map[string]interface{}) string {
+	if encrypted, ok := item["encrypted_content"].(string); ok && encrypted != "" {
+		return encrypted
+	}
+
+	var text strings.Builder
+	for _, key := range []string{"content", "summary"} {
+		parts, _ := item[key].([]interface{})
+		for _, part := range parts {
+			if partMap, ok := part.(map[string]interface{}); ok {
+				if t, ok := partMap["text"].(string); ok {
+					text.WriteString(t)
+				}
+			}
+		}
+		if text.Len() > 0 {
+			break
+		}
+	}
+	return text.String()
+}
+
 func (h *ProxyHandler) transformTools(tools []interface{}) []map[string]interface{} {
 	transformed := make([]map[string]interface{}, 0, len(tools))
 
 	for _, tool := range tools {
 		if toolMap, ok := tool.(map[string]interface{}); ok {
+			// A tool without a type is a function tool; forward it as one
+			if toolType, _ := toolMap["type"].(string); toolType == "" {
+				h.logger.Debug("tool without type treated as function", "name", toolMap["name"])
+			}
+
 			// Responses API format: tool has "type" and "function" fields
 			// Chat Completions format is the same, but we need to extract from "function"
 			var name, description string
@@ -565,12 +1315,13 @@ func (h *ProxyHandler) transformTools(tools []interface{}) []map[string]interfac
 	return transformed
 }
 
-// transformResponse transforms Chat Completions response to Responses API format
-func (h *ProxyHandler) transformResponse(resp map[string]interface{}) map[string]interface{} {
+// transformResponse transforms Chat Completions response to Responses API
+// format, stamped with the request's createdAt rather than the backend's clock
+func (h *ProxyHandler) transformResponse(resp map[string]interface{}, clientModel string, createdAt int64) map[string]interface{} {
 	responsesResp := map[string]interface{}{
-		"id":         "resp_" + generateID(),
+		"id":         h.newID("resp_"),
 		"object":     "response",
-		"created_at": time.Now().Unix(),
+		"created_at": createdAt,
 		"status":     "completed",
 	}
 
@@ -582,7 +1333,7 @@ func (h *ProxyHandler) transformResponse(resp map[string]interface{}) map[string
 			if message, ok := choice["message"].(map[string]interface{}); ok {
 				msg := map[string]interface{}{
 					"type":    "message",
-					"id":      "msg_" + generateID(),
+					"id":      h.newID("msg_"),
 					"status":  "completed",
 					"role":    "assistant",
 					"content": []map[string]interface{}{},
@@ -591,51 +1342,72 @@ func (h *ProxyHandler) transformResponse(resp map[string]interface{}) map[string
 				if content, ok := message["content"].(string); ok {
 					msg["content"] = []map[string]interface{}{
 						{
-							"type": "output_text",
-							"text": content,
+							"type":        "output_text",
+							"text":        content,
+							"annotations": []interface{}{},
 						},
 					}
 				}
 
-				if _, ok := message["tool_calls"].([]interface{}); ok {
-					// Transform tool calls to Responses API format
-					if toolCalls, ok := message["tool_calls"].([]interface{}); ok && len(toolCalls) > 0 {
-
-						transformedToolCalls := []map[string]interface{}{}
-						for _, tc := range toolCalls {
-							if tcMap, ok := tc.(map[string]interface{}); ok {
-								tcID, _ := tcMap["id"].(string)
-								tcType, _ := tcMap["type"].(string)
-
-								var fnName, fnArgs string
-								if fn, ok := tcMap["function"].(map[string]interface{}); ok {
-									fnName, _ = fn["name"].(string)
-									if args, ok := fn["arguments"].(string); ok {
-										fnArgs = args
-									}
-								}
+				// Transform tool calls to Responses API format. A choice may
+				// carry text explaining a call as well as the call itself.
+				if toolCalls, ok := message["tool_calls"].([]interface{}); ok && len(toolCalls) > 0 {
+					transformedToolCalls := []map[string]interface{}{}
+					for _, tc := range toolCalls {
+						if tcMap, ok := tc.(map[string]interface{}); ok {
+							tcID, _ := tcMap["id"].(string)
+							tcType, _ := tcMap["type"].(string)
+							if tcType == "" {
+								tcType = "function"
+							}
 
-								transformedToolCalls = append(transformedToolCalls, map[string]interface{}{
-									"id":   tcID,
-									"type": tcType,
-									"function": map[string]interface{}{
-										"name":      fnName,
-										"arguments": fnArgs,
-									},
-								})
+							var fnName, fnArgs string
+							if fn, ok := tcMap["function"].(map[string]interface{}); ok {
+								fnName, _ = fn["name"].(string)
+								fnArgs = stringifyArguments(fn["arguments"])
 							}
+
+							transformedToolCalls = append(transformedToolCalls, map[string]interface{}{
+								"id":   tcID,
+								"type": tcType,
+								"function": map[string]interface{}{
+									"name":      fnName,
+									"arguments": fnArgs,
+								},
+							})
 						}
-						msg["tool_calls"] = transformedToolCalls
 					}
+					msg["tool_calls"] = transformedToolCalls
+				}
+
+				// Newer schemas carry tool calls as function_call items after
+				// the message with the text, which is dropped if there is none
+				if calls, ok := msg["tool_calls"].([]map[string]interface{}); ok && h.schema().functionCallItems {
+					delete(msg, "tool_calls")
+					if text, _ := message["content"].(string); text != "" {
+						output = append(output, msg)
+					}
+					for _, call := range calls {
+						fn, _ := call["function"].(map[string]interface{})
+						output = append(output, map[string]interface{}{
+							"type":      "function_call",
+							"id":        h.newID("fc_"),
+							"status":    "completed",
+							"call_id":   call["id"],
+							"name":      fn["name"],
+							"arguments": fn["arguments"],
+						})
+					}
+				} else {
+					output = append(output, msg)
 				}
-				output = append(output, msg)
 			}
 
 			responsesResp["output"] = output
 
 			// Map finish reason
 			if finishReason, ok := choice["finish_reason"].(string); ok {
-				responsesResp["status"] = mapFinishReason(finishReason)
+				responsesResp["status"] = mapFinishReason(finishReason, h.toolCallStatus())
 			}
 		}
 	}
@@ -649,33 +1421,284 @@ func (h *ProxyHandler) transformResponse(resp map[string]interface{}) map[string
 		}
 	}
 
-	// Copy model (reverse map to original model name)
-	if model, ok := resp["model"].(string); ok {
-		responsesResp["model"] = h.reverseMapModel(model)
-	}
+	// Echo the requested model, keeping the backend's under x_backend_model
+	backendModel, _ := resp["model"].(string)
+	h.setResponseModel(responsesResp, clientModel, backendModel)
 
 	return responsesResp
 }
 
-func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher http.Flusher) {
+// stringifyArguments returns tool call arguments as the JSON string the
+// Responses API expects. Some backends send an object instead of a string;
+// missing arguments become an empty object.
+func stringifyArguments(args interface{}) string {
+	switch v := args.(type) {
+	case nil:
+		return "{}"
+	case string:
+		if v == "" {
+			return "{}"
+		}
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "{}"
+		}
+		return string(data)
+	}
+}
+
+// transformStream relays a Chat Completions stream as Responses API events. It
+// returns the response ID and, if the stream completed, the assembled
+// assistant message. Every event carries createdAt, the request's start time.
+// Reasoning summaries are relayed, and the detailed reasoning too when
+// reasoningContent is set.
+func (h *ProxyHandler) transformStream(body io.ReadCloser, w http.ResponseWriter, flusher http.Flusher, target *backend, clientModel string, createdAt int64, reasoningContent bool) (string, map[string]interface{}) {
 	reader := bufio.NewReader(body)
-	responseID := fmt.Sprintf("resp_%d", time.Now().UnixNano())
-	itemID := fmt.Sprintf("msg_%d", time.Now().UnixNano())
+	responseID := h.newID("resp_")
+	itemID := h.newID("msg_")
 	sentCreated := false
 	sentOutputItemAdded := false
 	sentContentPartAdded := false
-	sequenceNumber := 0
+	events := newEventStream(w, flusher, h.cfg.Codex.SSEEventLines)
+	reasoning := h.newReasoningStream(events, reasoningContent)
+	nextOutputIndex := 0 // output items are indexed in the order they are added
+	messageIndex := 0
 	fullText := ""
+	backendModel := ""
+	var usage map[string]interface{}
 
 	// Tool call tracking
-	toolCalls := make(map[int]map[string]interface{}) // index -> tool call info
+	toolCalls := make(map[int]map[string]interface{}) // index -> tool call info, including its output_index
 	toolCallItems := make(map[int]string)             // index -> item_id
+	completed := false
+
+	// Whether the backend resends tool call fragments whole
+	cumulative := target != nil && target.config.CumulativeToolCallDeltas
+
+	// A backend that sends a finish_reason but closes the stream without
+	// [DONE] has still finished the response
+	finishReason := ""
+
+	// Output limit: text and tool-call arguments past it are dropped and the
+	// response ends as incomplete
+	limit := outputLimit{max: h.cfg.Server.MaxStreamOutputBytes}
+	truncated := false
+
+	// Progress heartbeats while the backend is silent, once the response is
+	// created (server.stream_progress_interval)
+	stopHeartbeat := func() {}
+	defer func() { stopHeartbeat() }()
+
+	// finish closes the open output items and ends the stream with
+	// response.completed, or response.incomplete once truncated. Only the
+	// first call counts.
+	finish := func() {
+		if completed {
+			return
+		}
+		stopHeartbeat()
+
+		itemStatus := "completed"
+		if truncated {
+			itemStatus = "incomplete"
+		}
+
+		// Close the reasoning item if no answer followed it
+		reasoning.close(itemStatus)
+
+		// Send output_text.done first if we have content
+		if sentContentPartAdded && fullText != "" {
+			outputTextDone := map[string]interface{}{
+				"type":          "response.output_text.done",
+				"item_id":       itemID,
+				"output_index":  messageIndex,
+				"content_index": 0,
+				"text":          fullText,
+			}
+			events.emit(outputTextDone)
+		}
+
+		// Send content_part.done if we added content
+		if sentContentPartAdded {
+			contentPartDone := map[string]interface{}{
+				"type":          "response.content_part.done",
+				"item_id":       itemID,
+				"output_index":  messageIndex,
+				"content_index": 0,
+				"part": map[string]interface{}{
+					"type":        "output_text",
+					"text":        fullText,
+					"annotations": []interface{}{},
+				},
+			}
+			events.emit(contentPartDone)
+		}
+
+		// Finished output items by output_index, repeated in response.completed
+		outputItems := make(map[int]map[string]interface{})
+		if reasoning.item != nil {
+			outputItems[reasoning.outputIndex] = reasoning.item
+		}
+
+		// Send output_item.done for message
+		if sentOutputItemAdded {
+			messageItem := map[string]interface{}{
+				"id":     itemID,
+				"type":   "message",
+				"role":   "assistant",
+				"status": itemStatus,
+				"content": []interface{}{
+					map[string]interface{}{
+						"type":        "output_text",
+						"text":        fullText,
+						"annotations": []interface{}{},
+					},
+				},
+			}
+			outputItems[messageIndex] = messageItem
+			outputItemDone := map[string]interface{}{
+				"type":         "response.output_item.done",
+				"output_index": messageIndex,
+				"item":         messageItem,
+			}
+			events.emit(outputItemDone)
+		}
+
+		// Finalize tool calls
+		for _, idx := range sortedKeys(toolCalls) {
+			tcInfo := toolCalls[idx]
+			toolCallItemID := toolCallItems[idx]
+			outputIdx, _ := tcInfo["output_index"].(int)
+
+			// The deltas were raw fragments; only the whole must parse. A
+			// call with no arguments gets an empty object.
+			if args, _ := tcInfo["arguments"].(string); args == "" {
+				tcInfo["arguments"] = "{}"
+			} else if !truncated && !json.Valid([]byte(args)) {
+				h.logger.Warn("streamed tool call arguments are not valid JSON", "call_id", tcInfo["id"], "name", tcInfo["name"])
+			}
+
+			// Send function_call_arguments.done
+			argsDoneEvent := map[string]interface{}{
+				"type":         "response.function_call_arguments.done",
+				"item_id":      toolCallItemID,
+				"output_index": outputIdx,
+				"name":         tcInfo["name"],
+				"arguments":    tcInfo["arguments"],
+			}
+			events.emit(argsDoneEvent)
+
+			// Send output_item.done for function_call
+			toolItem := map[string]interface{}{
+				"id":        toolCallItemID,
+				"type":      "function_call",
+				"status":    itemStatus,
+				"call_id":   tcInfo["id"],
+				"name":      tcInfo["name"],
+				"arguments": tcInfo["arguments"],
+			}
+			outputItems[outputIdx] = toolItem
+			toolItemDone := map[string]interface{}{
+				"type":         "response.output_item.done",
+				"output_index": outputIdx,
+				"item":         toolItem,
+			}
+			events.emit(toolItemDone)
+		}
+
+		output := make([]interface{}, 0, len(outputItems))
+		for _, idx := range sortedKeys(outputItems) {
+			output = append(output, outputItems[idx])
+		}
+
+		// Send response.completed
+		completedResponse := map[string]interface{}{
+			"id":         responseID,
+			"object":     "response",
+			"created_at": createdAt,
+			"status":     itemStatus,
+			"output":     output,
+		}
+		if usage != nil {
+			completedResponse["usage"] = map[string]interface{}{
+				"input_tokens":  usage["prompt_tokens"],
+				"output_tokens": usage["completion_tokens"],
+				"total_tokens":  usage["total_tokens"],
+			}
+		}
+		if cost, ok := h.recordCost(h.providerName(target), clientModel, backendModel, usage); ok && h.cfg.Codex.ExposeCost {
+			completedResponse["x_cost"] = cost
+		}
+		terminalType := "response.completed"
+		if truncated {
+			completedResponse["incomplete_details"] = map[string]interface{}{"reason": "max_output_tokens"}
+			terminalType = "response.incomplete"
+		}
+		completedEvent := map[string]interface{}{
+			"type":     terminalType,
+			"response": completedResponse,
+		}
+		events.emit(completedEvent)
+
+		// Older clients expect a closing response.done event
+		if h.schema().doneEvent {
+			doneEvent := map[string]interface{}{
+				"type": "response.done",
+			}
+			events.emit(doneEvent)
+		}
+		completed = true
+	}
 
 	for {
-		line, err := reader.ReadString('\n')
+		line, err := limit.readLine(reader)
+		if errors.Is(err, errLineTooLong) {
+			if sentCreated {
+				h.logger.Warn("stream event exceeds the output limit, ending response as incomplete", "limit", limit.max)
+				truncated = true
+				finish()
+				break
+			}
+
+			// Nothing of the response was relayed: fail it with a 502, or
+			// as a failed response once the stream has begun
+			h.logger.Warn("first stream event exceeds the output limit, failing response", "limit", limit.max)
+			if !responseStarted(w) {
+				h.writeError(w, http.StatusBadGateway, "api_error", "backend_response_too_large", eventTooLargeMessage)
+				break
+			}
+			for _, status := range []string{"in_progress", "failed"} {
+				eventResponse := map[string]interface{}{
+					"id":         responseID,
+					"object":     "response",
+					"created_at": createdAt,
+					"status":     status,
+					"output":     []interface{}{},
+				}
+				h.setResponseModel(eventResponse, clientModel, "")
+				eventType := "response.created"
+				if status == "failed" {
+					eventType = "response.failed"
+					eventResponse["error"] = map[string]interface{}{
+						"code":    "backend_response_too_large",
+						"message": eventTooLargeMessage,
+					}
+				}
+				events.emit(map[string]interface{}{
+					"type":     eventType,
+					"response": eventResponse,
+				})
+			}
+			break
+		}
 		if err != nil {
 			if err != io.EOF {
 				h.logger.Error("error reading stream", "error", err)
+			} else if sentCreated && finishReason != "" {
+				h.logger.Debug("stream ended without [DONE] after finish_reason, finalizing", "finish_reason", finishReason)
+				finish()
 			}
 			break
 		}
@@ -690,223 +1713,91 @@ func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher
 			data = strings.TrimSpace(data)
 
 			if data == "[DONE]" {
-				// Send output_text.done first if we have content
-				if sentContentPartAdded && fullText != "" {
-					outputTextDone := map[string]interface{}{
-						"type":            "response.output_text.done",
-						"item_id":         itemID,
-						"output_index":    0,
-						"content_index":   0,
-						"sequence_number": sequenceNumber,
-						"text":            fullText,
-					}
-					eventData, _ := json.Marshal(outputTextDone)
-					fmt.Fprintf(w, "event: response.output_text.done\n")
-					fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-					flusher.Flush()
-					sequenceNumber++
-				}
-
-				// Send content_part.done if we added content
-				if sentContentPartAdded {
-					contentPartDone := map[string]interface{}{
-						"type":            "response.content_part.done",
-						"item_id":         itemID,
-						"output_index":    0,
-						"content_index":   0,
-						"sequence_number": sequenceNumber,
-						"part": map[string]interface{}{
-							"type":        "output_text",
-							"text":        fullText,
-							"annotations": []interface{}{},
-						},
-					}
-					eventData, _ := json.Marshal(contentPartDone)
-					fmt.Fprintf(w, "event: response.content_part.done\n")
-					fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-					flusher.Flush()
-					sequenceNumber++
-				}
-
-				// Send output_item.done for message
-				if sentOutputItemAdded {
-					outputItemDone := map[string]interface{}{
-						"type":            "response.output_item.done",
-						"output_index":    0,
-						"sequence_number": sequenceNumber,
-						"item": map[string]interface{}{
-							"id":     itemID,
-							"type":   "message",
-							"role":   "assistant",
-							"status": "completed",
-							"content": []interface{}{
-								map[string]interface{}{
-									"type":        "output_text",
-									"text":        fullText,
-									"annotations": []interface{}{},
-								},
-							},
-						},
-					}
-					eventData, _ := json.Marshal(outputItemDone)
-					fmt.Fprintf(w, "event: response.output_item.done\n")
-					fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-					flusher.Flush()
-					sequenceNumber++
-				}
-
-				// Finalize tool calls
-				for idx, tcInfo := range toolCalls {
-					toolCallItemID := toolCallItems[idx]
-					outputIdx := idx
-					if sentOutputItemAdded {
-						outputIdx = 1 + idx
-					}
-
-					// Send function_call_arguments.done
-					argsDoneEvent := map[string]interface{}{
-						"type":            "response.function_call_arguments.done",
-						"item_id":         toolCallItemID,
-						"output_index":    outputIdx,
-						"sequence_number": sequenceNumber,
-						"name":            tcInfo["name"],
-						"arguments":       tcInfo["arguments"],
-					}
-					eventData, _ := json.Marshal(argsDoneEvent)
-					fmt.Fprintf(w, "event: response.function_call_arguments.done\n")
-					fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-					flusher.Flush()
-					sequenceNumber++
-
-					// Send output_item.done for function_call
-					toolItemDone := map[string]interface{}{
-						"type":            "response.output_item.done",
-						"output_index":    outputIdx,
-						"sequence_number": sequenceNumber,
-						"item": map[string]interface{}{
-							"id":        toolCallItemID,
-							"type":      "function_call",
-							"status":    "completed",
-							"call_id":   tcInfo["id"],
-							"name":      tcInfo["name"],
-							"arguments": tcInfo["arguments"],
-						},
-					}
-					eventData, _ = json.Marshal(toolItemDone)
-					fmt.Fprintf(w, "event: response.output_item.done\n")
-					fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-					flusher.Flush()
-					sequenceNumber++
-				}
-
-				// Send response.completed
-				completedEvent := map[string]interface{}{
-					"type":            "response.completed",
-					"sequence_number": sequenceNumber,
-					"response": map[string]interface{}{
-						"id":     responseID,
-						"object": "response",
-						"status": "completed",
-						"output": []map[string]interface{}{
-							{
-								"id":      itemID,
-								"type":    "message",
-								"role":    "assistant",
-								"status":  "completed",
-								"content": []interface{}{},
-							},
-						},
-					},
-				}
-				eventData, _ := json.Marshal(completedEvent)
-				fmt.Fprintf(w, "event: response.completed\n")
-				fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-				flusher.Flush()
-				sequenceNumber++
-
-				// Send response.done event
-				doneEvent := map[string]interface{}{
-					"type":            "response.done",
-					"sequence_number": sequenceNumber,
-				}
-				eventData, _ = json.Marshal(doneEvent)
-				fmt.Fprintf(w, "event: response.done\n")
-				fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-				flusher.Flush()
+				finish()
+				drainStream(body)
 				break
 			}
 
 			// Parse the Chat Completions chunk
 			var chunk map[string]interface{}
 			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				transformErrorsStream.Add(1)
 				h.logger.Debug("failed to parse chunk", "error", err)
 				continue
 			}
+			h.remapResponse(chunk, target)
+
+			// Usage arrives on the final chunk when include_usage is requested
+			if u, ok := chunk["usage"].(map[string]interface{}); ok {
+				usage = u
+			}
 
 			// Send response.created event first
 			if !sentCreated {
-				created := int64(0)
-				if c, ok := chunk["created"].(float64); ok {
-					created = int64(c)
-				}
-				backendModel, _ := chunk["model"].(string)
-				model := h.reverseMapModel(backendModel)
+				backendModel, _ = chunk["model"].(string)
 
 				// Send response.created
+				createdResponse := map[string]interface{}{
+					"id":         responseID,
+					"object":     "response",
+					"created_at": createdAt,
+					"status":     "in_progress",
+					"output":     []interface{}{},
+				}
+				h.setResponseModel(createdResponse, clientModel, backendModel)
 				createdEvent := map[string]interface{}{
-					"type":            "response.created",
-					"sequence_number": sequenceNumber,
-					"response": map[string]interface{}{
-						"id":         responseID,
-						"object":     "response",
-						"created_at": created,
-						"model":      model,
-						"status":     "in_progress",
-						"output":     []interface{}{},
-					},
+					"type":     "response.created",
+					"response": createdResponse,
 				}
-				eventData, _ := json.Marshal(createdEvent)
-				fmt.Fprintf(w, "event: response.created\n")
-				fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-				flusher.Flush()
-				sequenceNumber++
+				events.emit(createdEvent)
 
 				// Send response.in_progress
 				inProgressEvent := map[string]interface{}{
-					"type":            "response.in_progress",
-					"sequence_number": sequenceNumber,
-					"response": map[string]interface{}{
-						"id":         responseID,
-						"object":     "response",
-						"created_at": created,
-						"model":      model,
-						"status":     "in_progress",
-						"output":     []interface{}{},
-					},
+					"type":     "response.in_progress",
+					"response": createdResponse,
 				}
-				eventData, _ = json.Marshal(inProgressEvent)
-				fmt.Fprintf(w, "event: response.in_progress\n")
-				fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-				flusher.Flush()
+				events.emit(inProgressEvent)
 				sentCreated = true
-				sequenceNumber++
+
+				stopHeartbeat = events.heartbeat(h.cfg.Server.StreamProgressInterval, func() map[string]interface{} {
+					return map[string]interface{}{
+						"type":     "response.in_progress",
+						"response": createdResponse,
+					}
+				})
 			}
 
 			// Transform choices to output_text deltas
 			if choices, ok := chunk["choices"].([]interface{}); ok {
 				for _, choice := range choices {
 					if choiceMap, ok := choice.(map[string]interface{}); ok {
-						if delta, ok := choiceMap["delta"].(map[string]interface{}); ok {
-							// Handle content - only use "content", skip "reasoning_content" (internal thinking)
-							// z.ai sends reasoning_content first, then content for the actual response
+						if reason, _ := choiceMap["finish_reason"].(string); reason != "" {
+							finishReason = reason
+						}
+						if delta, ok := chunkDelta(choiceMap, fullText, len(toolCalls) > 0); ok {
+							// z.ai sends reasoning_content first, then content for
+							// the actual response; the reasoning is relayed as its
+							// own output item
+							summary, _ := delta["reasoning_summary"].(string)
+							detail, _ := delta["reasoning_content"].(string)
+							reasoning.write(summary, detail, &nextOutputIndex)
+
 							content, hasContent := delta["content"].(string)
+							if hasContent && content != "" {
+								var fits bool
+								if content, fits = limit.fit(content); !fits {
+									truncated = true
+								}
+							}
 							if hasContent && content != "" {
 								// Send output_item.added first if not sent
 								if !sentOutputItemAdded {
+									reasoning.close("completed")
+									messageIndex = nextOutputIndex
+									nextOutputIndex++
 									outputItemAdded := map[string]interface{}{
-										"type":            "response.output_item.added",
-										"output_index":    0,
-										"sequence_number": sequenceNumber,
+										"type":         "response.output_item.added",
+										"output_index": messageIndex,
 										"item": map[string]interface{}{
 											"id":      itemID,
 											"type":    "message",
@@ -915,34 +1806,25 @@ func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher
 											"content": []interface{}{},
 										},
 									}
-									eventData, _ := json.Marshal(outputItemAdded)
-									fmt.Fprintf(w, "event: response.output_item.added\n")
-									fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-									flusher.Flush()
+									events.emit(outputItemAdded)
 									sentOutputItemAdded = true
-									sequenceNumber++
 								}
 
 								// Send content_part.added if not sent
 								if !sentContentPartAdded {
 									contentPartAdded := map[string]interface{}{
-										"type":            "response.content_part.added",
-										"item_id":         itemID,
-										"output_index":    0,
-										"content_index":   0,
-										"sequence_number": sequenceNumber,
+										"type":          "response.content_part.added",
+										"item_id":       itemID,
+										"output_index":  messageIndex,
+										"content_index": 0,
 										"part": map[string]interface{}{
 											"type":        "output_text",
 											"text":        "",
 											"annotations": []interface{}{},
 										},
 									}
-									eventData, _ := json.Marshal(contentPartAdded)
-									fmt.Fprintf(w, "event: response.content_part.added\n")
-									fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-									flusher.Flush()
+									events.emit(contentPartAdded)
 									sentContentPartAdded = true
-									sequenceNumber++
 								}
 
 								// Append to full text
@@ -950,18 +1832,13 @@ func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher
 
 								// Send delta event with correct format
 								deltaEvent := map[string]interface{}{
-									"type":            "response.output_text.delta",
-									"item_id":         itemID,
-									"output_index":    0,
-									"content_index":   0,
-									"sequence_number": sequenceNumber,
-									"delta":           content,
+									"type":          "response.output_text.delta",
+									"item_id":       itemID,
+									"output_index":  messageIndex,
+									"content_index": 0,
+									"delta":         content,
 								}
-								eventData, _ := json.Marshal(deltaEvent)
-								fmt.Fprintf(w, "event: response.output_text.delta\n")
-								fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-								flusher.Flush()
-								sequenceNumber++
+								events.emit(deltaEvent)
 							}
 
 							// Handle tool_calls in delta
@@ -975,8 +1852,9 @@ func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher
 
 										// Initialize tool call tracking if new
 										if _, exists := toolCalls[index]; !exists {
-											toolCallID := fmt.Sprintf("tc_%d_%d", time.Now().UnixNano(), index)
-											toolCallItemID := fmt.Sprintf("fc_%d_%d", time.Now().UnixNano(), index)
+											reasoning.close("completed")
+											toolCallID := h.newID("tc_")
+											toolCallItemID := h.newID("fc_")
 											toolCalls[index] = map[string]interface{}{
 												"id":        toolCallID,
 												"item_id":   toolCallItemID,
@@ -985,18 +1863,15 @@ func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher
 											}
 											toolCallItems[index] = toolCallItemID
 
-											// Calculate output_index for this tool call
-											// If we have a message item, tools start at index 1
-											// Otherwise, tools start at index 0
-											outputIdx := index
-											if sentOutputItemAdded {
-												outputIdx = 1 + index
-											}
+											// Take the next output slot, after the message
+											// item if it was added first
+											outputIdx := nextOutputIndex
+											nextOutputIndex++
+											toolCalls[index]["output_index"] = outputIdx
 
 											toolItemAdded := map[string]interface{}{
-												"type":            "response.output_item.added",
-												"output_index":    outputIdx,
-												"sequence_number": sequenceNumber,
+												"type":         "response.output_item.added",
+												"output_index": outputIdx,
 												"item": map[string]interface{}{
 													"id":        toolCallItemID,
 													"type":      "function_call",
@@ -1006,48 +1881,50 @@ func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher
 													"arguments": "",
 												},
 											}
-											eventData, _ := json.Marshal(toolItemAdded)
-											fmt.Fprintf(w, "event: response.output_item.added\n")
-											fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-											flusher.Flush()
-											sequenceNumber++
+											events.emit(toolItemAdded)
 										}
 
 										tcInfo := toolCalls[index]
 										toolCallItemID := toolCallItems[index]
 
-										// Calculate output_index consistently
-										outputIdx := index
-										if sentOutputItemAdded {
-											outputIdx = 1 + index
-										}
+										outputIdx := tcInfo["output_index"]
 
-										// Handle tool call id
+										// Handle tool call id, which replaces the generated
+										// one once the backend sends any of it
 										if id, ok := tcMap["id"].(string); ok && id != "" {
-											tcInfo["id"] = id
+											backendID, _ := tcInfo["backend_id"].(string)
+											backendID = providers.MergeToolCallFragment(backendID, id, cumulative)
+											tcInfo["backend_id"] = backendID
+											tcInfo["id"] = backendID
 										}
 
-										// Handle function name and arguments
+										// Handle function name and arguments; both may be
+										// split across chunks
 										if fn, ok := tcMap["function"].(map[string]interface{}); ok {
 											if name, ok := fn["name"].(string); ok && name != "" {
-												tcInfo["name"] = name
+												tcInfo["name"] = providers.MergeToolCallFragment(tcInfo["name"].(string), name, cumulative)
 											}
-											if args, ok := fn["arguments"].(string); ok {
+											if args, ok := fn["arguments"].(string); ok && args != "" {
+												// Forward only the part not yet received when
+												// the backend resends the arguments whole
+												current := tcInfo["arguments"].(string)
+												args = providers.MergeToolCallFragment(current, args, cumulative)[len(current):]
+												var fits bool
+												if args, fits = limit.fit(args); !fits {
+													truncated = true
+												}
 												tcInfo["arguments"] = tcInfo["arguments"].(string) + args
 
 												// Send function_call_arguments.delta
-												argsDeltaEvent := map[string]interface{}{
-													"type":            "response.function_call_arguments.delta",
-													"item_id":         toolCallItemID,
-													"output_index":    outputIdx,
-													"sequence_number": sequenceNumber,
-													"delta":           args,
+												if args != "" {
+													argsDeltaEvent := map[string]interface{}{
+														"type":         "response.function_call_arguments.delta",
+														"item_id":      toolCallItemID,
+														"output_index": outputIdx,
+														"delta":        args,
+													}
+													events.emit(argsDeltaEvent)
 												}
-												eventData, _ := json.Marshal(argsDeltaEvent)
-												fmt.Fprintf(w, "event: response.function_call_arguments.delta\n")
-												fmt.Fprintf(w, "data: %s\n\n", string(eventData))
-												flusher.Flush()
-												sequenceNumber++
 											}
 										}
 									}
@@ -1058,22 +1935,231 @@ func (h *ProxyHandler) transformStream(body io.ReadCloser, w io.Writer, flusher
 				}
 			}
 		}
+
+		if truncated {
+			h.logger.Warn("stream output exceeds the output limit, ending response as incomplete", "limit", limit.max)
+			finish()
+			break
+		}
+	}
+
+	if !completed {
+		return responseID, nil
+	}
+
+	assistant := map[string]interface{}{
+		"role":    "assistant",
+		"content": fullText,
+	}
+	if len(toolCalls) > 0 {
+		calls := make([]interface{}, 0, len(toolCalls))
+		for idx := 0; idx < len(toolCalls); idx++ {
+			tcInfo, ok := toolCalls[idx]
+			if !ok {
+				continue
+			}
+			calls = append(calls, map[string]interface{}{
+				"id":   tcInfo["id"],
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      tcInfo["name"],
+					"arguments": tcInfo["arguments"],
+				},
+			})
+		}
+		assistant["tool_calls"] = calls
+	}
+
+	return responseID, assistant
+}
+
+// chunkDelta returns the delta of a stream chunk's choice. Some backends send
+// the whole message in the final chunk instead of deltas; it is converted to
+// a delta carrying only the text not already streamed (sent), so the emitted
+// deltas still add up to the full text. Its tool calls are kept only if none
+// were streamed.
+func chunkDelta(choice map[string]interface{}, sent string, streamedToolCalls bool) (map[string]interface{}, bool) {
+	if delta, ok := choice["delta"].(map[string]interface{}); ok {
+		return delta, true
+	}
+	message, ok := choice["message"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	delta := make(map[string]interface{})
+	if content, ok := message["content"].(string); ok {
+		// Text that diverges from what was streamed can't be reconciled
+		// without repeating it, so only a continuation is kept
+		if strings.HasPrefix(content, sent) {
+			delta["content"] = content[len(sent):]
+		}
+	}
+	if toolCalls, ok := message["tool_calls"].([]interface{}); ok && !streamedToolCalls {
+		indexed := make([]interface{}, 0, len(toolCalls))
+		for i, tc := range toolCalls {
+			tcMap, ok := tc.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := tcMap["index"]; !ok {
+				tcMap["index"] = float64(i)
+			}
+			indexed = append(indexed, tcMap)
+		}
+		delta["tool_calls"] = indexed
 	}
+	return delta, true
 }
 
-func mapFinishReason(reason string) string {
+// toolCallStatus returns the status reported for responses that end in tool calls
+func (h *ProxyHandler) toolCallStatus() string {
+	if h.cfg.Codex.ToolCallStatus == "" {
+		return config.ToolCallStatusRequiresAction
+	}
+	return h.cfg.Codex.ToolCallStatus
+}
+
+// mapFinishReason maps a Chat Completions finish_reason to a response status.
+// Tool calls map to toolCallStatus since the response awaits tool outputs.
+func mapFinishReason(reason, toolCallStatus string) string {
 	switch reason {
 	case "stop":
 		return "completed"
 	case "length":
 		return "incomplete"
 	case "tool_calls":
-		return "completed"
+		return toolCallStatus
 	default:
 		return "failed"
 	}
 }
 
-func generateID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+// stitchConversation prepends the stored history for previous_response_id to
+// the Chat Completions messages, after any system message. It returns the
+// conversation so far (excluding system messages) for saving once the
+// response completes.
+func (h *ProxyHandler) stitchConversation(req, chatReq map[string]interface{}) ([]map[string]interface{}, error) {
+	messages, _ := chatReq["messages"].([]map[string]interface{})
+
+	var system, turn []map[string]interface{}
+	for _, msg := range messages {
+		if role, _ := msg["role"].(string); role == "system" {
+			system = append(system, msg)
+		} else {
+			turn = append(turn, msg)
+		}
+	}
+
+	previousID, _ := req["previous_response_id"].(string)
+	if previousID == "" || h.store == nil {
+		return turn, nil
+	}
+
+	conv, ok := h.store.Get(previousID)
+	if !ok {
+		return nil, fmt.Errorf("previous response with id '%s' not found", previousID)
+	}
+
+	history := make([]map[string]interface{}, 0, len(conv.Messages)+len(turn))
+	history = append(history, conv.Messages...)
+	history = append(history, turn...)
+
+	chatReq["messages"] = append(system, history...)
+	h.logger.Debug("continuing conversation", "previous_response_id", previousID, "history_messages", len(conv.Messages))
+
+	return history, nil
+}
+
+// saveConversation stores the conversation that produced a response so it can
+// be continued with previous_response_id
+func (h *ProxyHandler) saveConversation(responseID string, history []map[string]interface{}, assistant map[string]interface{}) {
+	if h.store == nil || responseID == "" || assistant == nil {
+		return
+	}
+
+	messages := make([]map[string]interface{}, 0, len(history)+1)
+	messages = append(messages, history...)
+	messages = append(messages, assistant)
+
+	if err := h.store.Save(&session.Conversation{ID: responseID, Messages: messages}); err != nil {
+		h.logger.Error("failed to save conversation", "response_id", responseID, "error", err)
+	}
+}
+
+// assistantMessage extracts the assistant message from a Chat Completions response
+func assistantMessage(resp map[string]interface{}) map[string]interface{} {
+	choices, ok := resp["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return nil
+	}
+	choice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	message, _ := choice["message"].(map[string]interface{})
+	return message
+}
+
+// applyTruncation enforces providers.context_budget on the Chat Completions
+// messages. With truncation "auto" the oldest non-system messages are dropped
+// until the request fits; otherwise ("disabled", the default) an oversized
+// request is an error.
+func (h *ProxyHandler) applyTruncation(req, chatReq map[string]interface{}) error {
+	budget := h.cfg.Providers.ContextBudget
+	if budget <= 0 {
+		return nil
+	}
+
+	messages, _ := chatReq["messages"].([]map[string]interface{})
+	if estimateTokens(messages) <= budget {
+		return nil
+	}
+
+	truncation, _ := req["truncation"].(string)
+	if truncation != "auto" {
+		return fmt.Errorf("input of ~%d tokens exceeds the context budget of %d tokens; set truncation to 'auto' to drop older turns", estimateTokens(messages), budget)
+	}
+
+	var system, rest []map[string]interface{}
+	for _, msg := range messages {
+		if role, _ := msg["role"].(string); role == "system" {
+			system = append(system, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+
+	// Always keep the latest message; a tool result is never left without the
+	// assistant tool call it answers
+	dropped := 0
+	for len(rest) > 1 && estimateTokens(system)+estimateTokens(rest) > budget {
+		rest = rest[1:]
+		dropped++
+		for len(rest) > 1 {
+			if role, _ := rest[0]["role"].(string); role != "tool" {
+				break
+			}
+			rest = rest[1:]
+			dropped++
+		}
+	}
+
+	if estimateTokens(system)+estimateTokens(rest) > budget {
+		return fmt.Errorf("latest input exceeds the context budget of %d tokens even after truncation", budget)
+	}
+
+	h.logger.Debug("truncated conversation", "dropped_messages", dropped, "budget", budget)
+	chatReq["messages"] = append(system, rest...)
+	return nil
+}
+
+// estimateTokens roughly estimates the token count of messages at four bytes
+// per token of their JSON encoding
+func estimateTokens(messages []map[string]interface{}) int {
+	if len(messages) == 0 {
+		return 0
+	}
+	data, _ := json.Marshal(messages)
+	return (len(data) + 3) / 4
 }
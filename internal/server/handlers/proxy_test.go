@@ -0,0 +1,2114 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// chatCompletion is a minimal non-streaming Chat Completions response
+const chatCompletion = `{"id":"chatcmpl-1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+
+// discardLogger returns a logger that drops everything
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// backendCall is a request a test backend received
+type backendCall struct {
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+// testBackend is an httptest server that records the requests it receives
+// and answers them with reply, which can read the request body again
+type testBackend struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls []backendCall
+}
+
+func newTestBackend(t *testing.T, reply http.HandlerFunc) *testBackend {
+	t.Helper()
+	b := &testBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		var body map[string]interface{}
+		json.Unmarshal(raw, &body)
+		b.mu.Lock()
+		b.calls = append(b.calls, backendCall{path: r.URL.Path, header: r.Header.Clone(), body: body})
+		b.mu.Unlock()
+		reply(w, r)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// received returns the requests the backend has received so far
+func (b *testBackend) received() []backendCall {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]backendCall(nil), b.calls...)
+}
+
+// replyJSON answers every request with status and body
+func replyJSON(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+// testConfig returns the default config sending z.ai requests to backend
+func testConfig(backend *testBackend) *config.Config {
+	cfg := config.Default()
+	cfg.Zai.APIKey = "zai-key"
+	cfg.Providers.Zai.BaseURL = backend.URL
+	return cfg
+}
+
+// boolPtr returns a pointer to b, for settings where unset differs from false
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// postResponses sends a Responses API request to h
+func postResponses(h http.Handler, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// replyStream answers every request with an SSE stream of the given Chat
+// Completions chunks followed by [DONE]
+func replyStream(chunks ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			io.WriteString(w, "data: "+chunk+"\n\n")
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}
+}
+
+// replyChat answers streaming requests with chunks and others with
+// chatCompletion
+func replyChat(chunks ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Stream {
+			replyStream(chunks...)(w, r)
+			return
+		}
+		replyJSON(http.StatusOK, chatCompletion)(w, r)
+	}
+}
+
+// streamEvents parses the data lines of an SSE response
+func streamEvents(t *testing.T, body io.Reader) []map[string]interface{} {
+	t.Helper()
+	var events []map[string]interface{}
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// eventOfType returns the last event of the given type, or nil
+func eventOfType(events []map[string]interface{}, eventType string) map[string]interface{} {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i]["type"] == eventType {
+			return events[i]
+		}
+	}
+	return nil
+}
+
+// decodeError returns the error object of a JSON error response
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body struct {
+		Error map[string]interface{} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q: %v", rec.Body, err)
+	}
+	return body.Error
+}
+
+func TestTransformRequestMaxTokensAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		req  map[string]interface{}
+		want interface{}
+	}{
+		{"alias only", map[string]interface{}{"max_tokens": 100.0}, 100.0},
+		{"canonical only", map[string]interface{}{"max_output_tokens": 200.0}, 200.0},
+		{"canonical wins over alias", map[string]interface{}{"max_tokens": 100.0, "max_output_tokens": 200.0}, 200.0},
+		{"neither", map[string]interface{}{}, nil},
+	}
+
+	h := NewProxyHandler(config.Default(), discardLogger())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req["model"] = "glm-5"
+			tt.req["input"] = "hi"
+			chatReq := h.transformRequest(tt.req, h.backends["zai"])
+			if got := chatReq["max_tokens"]; got != tt.want {
+				t.Errorf("max_tokens = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilityRejection(t *testing.T) {
+	const image = `[{"role":"user","content":[{"type":"input_image","image_url":"https://example.com/a.png"}]}]`
+
+	tests := []struct {
+		name   string
+		caps   config.CapabilitiesConfig
+		body   string
+		reject bool
+	}{
+		{"streaming unsupported", config.CapabilitiesConfig{SupportsStreaming: boolPtr(false)}, `{"model":"glm-5","input":"hi","stream":true}`, true},
+		{"tools unsupported", config.CapabilitiesConfig{SupportsTools: boolPtr(false)}, `{"model":"glm-5","input":"hi","tools":[{"type":"function","name":"f"}]}`, true},
+		{"reasoning unsupported", config.CapabilitiesConfig{SupportsReasoning: boolPtr(false)}, `{"model":"glm-5","input":"hi","reasoning":{"effort":"high"}}`, true},
+		{"image input unsupported", config.CapabilitiesConfig{SupportsVision: boolPtr(false)}, `{"model":"glm-5","input":` + image + `}`, true},
+		{"image input supported by default", config.CapabilitiesConfig{}, `{"model":"glm-5","input":` + image + `}`, false},
+		{"unset settings take the defaults", config.CapabilitiesConfig{SupportsVision: boolPtr(false)}, `{"model":"glm-5","input":"hi","tools":[{"type":"function","name":"f"}]}`, false},
+		{"plain request", config.CapabilitiesConfig{SupportsStreaming: boolPtr(false)}, `{"model":"glm-5","input":"hi"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			cfg := testConfig(backend)
+			cfg.Providers.Zai.Capabilities = tt.caps
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, tt.body, nil)
+			if !tt.reject {
+				if rec.Code != http.StatusOK || len(backend.received()) != 1 {
+					t.Fatalf("status = %d with %d backend requests, want 200 with 1; body %s", rec.Code, len(backend.received()), rec.Body)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400; body %s", rec.Code, rec.Body)
+			}
+			if code := decodeError(t, rec)["code"]; code != "unsupported_capability" {
+				t.Errorf("error code = %v, want unsupported_capability", code)
+			}
+			if n := len(backend.received()); n != 0 {
+				t.Errorf("backend received %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestStreamOptions(t *testing.T) {
+	const usageChunk = `{"id":"c1","model":"glm-5","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}`
+
+	tests := []struct {
+		name        string
+		body        string
+		wantOptions map[string]interface{} // nil expects none forwarded
+		wantUsage   bool
+	}{
+		{
+			name:        "client requests usage",
+			body:        `{"model":"glm-5","input":"hi","stream":true,"stream_options":{"include_usage":true}}`,
+			wantOptions: map[string]interface{}{"include_usage": true},
+			wantUsage:   true,
+		},
+		{
+			name:        "client options layered over defaults",
+			body:        `{"model":"glm-5","input":"hi","stream":true,"stream_options":{"include_obfuscation":false}}`,
+			wantOptions: map[string]interface{}{"include_usage": true, "include_obfuscation": false},
+			wantUsage:   true,
+		},
+		{
+			name: "not forwarded without streaming",
+			body: `{"model":"glm-5","input":"hi","stream_options":{"include_usage":true}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyChat(
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","content":"hi"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+				usageChunk,
+			))
+			h := NewProxyHandler(testConfig(backend), discardLogger())
+
+			rec := postResponses(h, tt.body, nil)
+			calls := backend.received()
+			if rec.Code != http.StatusOK || len(calls) != 1 {
+				t.Fatalf("status = %d with %d backend requests; body %s", rec.Code, len(calls), rec.Body)
+			}
+
+			options, _ := calls[0].body["stream_options"].(map[string]interface{})
+			if tt.wantOptions == nil {
+				if options != nil {
+					t.Errorf("stream_options = %v, want none", options)
+				}
+				return
+			}
+			if len(options) != len(tt.wantOptions) {
+				t.Errorf("stream_options = %v, want %v", options, tt.wantOptions)
+			}
+			for k, v := range tt.wantOptions {
+				if options[k] != v {
+					t.Errorf("stream_options[%s] = %v, want %v", k, options[k], v)
+				}
+			}
+
+			completed := eventOfType(streamEvents(t, rec.Body), "response.completed")
+			if completed == nil {
+				t.Fatal("no response.completed event")
+			}
+			usage, _ := completed["response"].(map[string]interface{})["usage"].(map[string]interface{})
+			if tt.wantUsage && (usage == nil || usage["input_tokens"] != 3.0 || usage["output_tokens"] != 5.0 || usage["total_tokens"] != 8.0) {
+				t.Errorf("completed usage = %v, want 3 in, 5 out, 8 total", usage)
+			}
+		})
+	}
+}
+
+func TestToolCallStatus(t *testing.T) {
+	const toolCallOnly = `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`
+
+	tests := []struct {
+		name       string
+		configured string
+		reply      string
+		want       string
+	}{
+		{"tool calls default to requires_action", "", toolCallOnly, "requires_action"},
+		{"configured requires_action", config.ToolCallStatusRequiresAction, toolCallOnly, "requires_action"},
+		{"configured completed", config.ToolCallStatusCompleted, toolCallOnly, "completed"},
+		{"text unaffected", config.ToolCallStatusRequiresAction, chatCompletion, "completed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(newTestBackend(t, replyJSON(http.StatusOK, tt.reply)))
+			cfg.Codex.ToolCallStatus = tt.configured
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+			var resp map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("status %d, body %s: %v", rec.Code, rec.Body, err)
+			}
+			if resp["status"] != tt.want {
+				t.Errorf("status = %v, want %s", resp["status"], tt.want)
+			}
+		})
+	}
+}
+
+func TestBackendErrorBody(t *testing.T) {
+	const html = "<html><body><h1>502 Bad Gateway</h1></body></html>"
+
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		stream      bool
+		wantMessage string
+	}{
+		{"HTML 502", http.StatusBadGateway, "text/html", html, false, "Backend returned HTTP 502 Bad Gateway"},
+		{"HTML 502 while streaming", http.StatusBadGateway, "text/html", html, true, "Backend returned HTTP 502 Bad Gateway"},
+		{"plain text 503", http.StatusServiceUnavailable, "text/plain", "upstream connect error", false, "Backend returned HTTP 503 Service Unavailable"},
+		{"JSON error passes through", http.StatusBadRequest, "application/json", `{"error":{"type":"invalid_request_error","message":"bad model"}}`, false, "bad model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+			cfg := testConfig(backend)
+			cfg.Providers.Zai.MaxRetries = 0
+			h := NewProxyHandler(cfg, discardLogger())
+
+			body := `{"model":"glm-5","input":"hi"}`
+			if tt.stream {
+				body = `{"model":"glm-5","input":"hi","stream":true}`
+			}
+			rec := postResponses(h, body, nil)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			if msg := decodeError(t, rec)["message"]; msg != tt.wantMessage {
+				t.Errorf("error message = %v, want %q", msg, tt.wantMessage)
+			}
+		})
+	}
+}
+
+// transformErrors reads codex_router_transform_errors_total for direction
+// from the metrics endpoint
+func transformErrors(t *testing.T, direction string) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler(discardLogger())(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	prefix := `codex_router_transform_errors_total{direction="` + direction + `"} `
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, prefix); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				t.Fatalf("metric %q: %v", line, err)
+			}
+			return n
+		}
+	}
+	t.Fatalf("metric for direction %q not found", direction)
+	return 0
+}
+
+func TestTransformErrorsMetric(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     http.HandlerFunc
+		body      string
+		direction string
+	}{
+		{"malformed request", replyChat(), `{"model":`, "request"},
+		{"malformed response", replyJSON(http.StatusOK, `{"choices":[`), `{"model":"glm-5","input":"hi"}`, "response"},
+		{"malformed chunk", replyStream(`{"choices":[{"delta":`, `{"choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`), `{"model":"glm-5","input":"hi","stream":true}`, "stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProxyHandler(testConfig(newTestBackend(t, tt.reply)), discardLogger())
+			before := transformErrors(t, tt.direction)
+			postResponses(h, tt.body, nil)
+			if got := transformErrors(t, tt.direction) - before; got != 1 {
+				t.Errorf("%s transform errors increased by %d, want 1", tt.direction, got)
+			}
+		})
+	}
+}
+
+func TestResponseIDFromPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		wantID string
+		wantOK bool
+	}{
+		{"/v1/responses/resp_123", "resp_123", true},
+		{"/responses/resp_123", "resp_123", true},
+		{"/v1/responses/resp_123/", "resp_123", true},
+		{"/v1/responses/resp%2Fwith%2Fslashes", "resp/with/slashes", true},
+		{"/responses/resp%20space", "resp space", true},
+		{"/v1/responses/", "", false},
+		{"/responses/", "", false},
+		{"/v1/responses/a/b", "", false},
+		{"/v1/other/resp_123", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			u, err := url.Parse(tt.path)
+			if err != nil {
+				t.Fatalf("parse %q: %v", tt.path, err)
+			}
+			id, ok := responseIDFromPath(u)
+			if id != tt.wantID || ok != tt.wantOK {
+				t.Errorf("responseIDFromPath(%q) = %q, %v, want %q, %v", tt.path, id, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGetResponseRoutes(t *testing.T) {
+	h := NewProxyHandler(config.Default(), discardLogger())
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/v1/responses/resp_123", http.StatusNotFound},
+		{http.MethodGet, "/responses/resp_123", http.StatusNotFound},
+		{http.MethodDelete, "/responses/resp%2F123", http.StatusNotImplemented},
+		{http.MethodGet, "/responses/", http.StatusBadRequest},
+		{http.MethodDelete, "/v1/responses/", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousResponseID(t *testing.T) {
+	backend := newTestBackend(t, replyChat())
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	first := postResponses(h, `{"model":"glm-5","instructions":"be brief","input":"hello"}`, nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first status = %d, body %s", first.Code, first.Body)
+	}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(first.Body.Bytes(), &resp)
+
+	second := postResponses(h, `{"model":"glm-5","instructions":"be brief","input":"again","previous_response_id":"`+resp.ID+`"}`, nil)
+	if second.Code != http.StatusOK {
+		t.Fatalf("second status = %d, body %s", second.Code, second.Body)
+	}
+
+	calls := backend.received()
+	messages, _ := calls[1].body["messages"].([]interface{})
+	var got []string
+	for _, m := range messages {
+		msg := m.(map[string]interface{})
+		got = append(got, msg["role"].(string)+":"+fmt.Sprint(msg["content"]))
+	}
+	want := []string{"system:be brief", "user:hello", "assistant:hi", "user:again"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("stitched messages = %v, want %v", got, want)
+	}
+
+	rec := postResponses(h, `{"model":"glm-5","input":"x","previous_response_id":"resp_missing"}`, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown previous_response_id status = %d, want 404", rec.Code)
+	}
+}
+
+func TestStreamErrorEvents(t *testing.T) {
+	const backendError = `{"error":{"type":"invalid_request_error","message":"model not found"}}`
+	sse := map[string]string{"Accept": "text/event-stream"}
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		header     map[string]string
+		wantStatus int
+		wantEvent  bool
+	}{
+		{"event stream client", true, sse, http.StatusOK, true},
+		{"JSON client", true, nil, http.StatusBadRequest, false},
+		{"disabled", false, sse, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusBadRequest, backendError))
+			cfg := testConfig(backend)
+			cfg.Server.StreamErrorEvents = tt.enabled
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, tt.header)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !tt.wantEvent {
+				if msg := decodeError(t, rec)["message"]; msg != "model not found" {
+					t.Errorf("error message = %v, want backend message", msg)
+				}
+				return
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type = %q, want text/event-stream", ct)
+			}
+			failed := eventOfType(streamEvents(t, rec.Body), "response.failed")
+			if failed == nil {
+				t.Fatal("no response.failed event")
+			}
+			resp := failed["response"].(map[string]interface{})
+			errObj := resp["error"].(map[string]interface{})
+			if errObj["code"] != "http_400" || errObj["message"] != "model not found" {
+				t.Errorf("error = %v, want code http_400 and the backend message", errObj)
+			}
+		})
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestSamplingDefaults(t *testing.T) {
+	cfg := config.Default()
+	cfg.Providers.DefaultTemperature = floatPtr(0.2)
+	cfg.Providers.DefaultTopP = floatPtr(0.9)
+	cfg.Providers.ModelDefaults = map[string]config.SamplingDefaults{
+		"glm-4.6": {Temperature: floatPtr(0.7)},
+	}
+	h := NewProxyHandler(cfg, discardLogger())
+
+	tests := []struct {
+		name     string
+		req      map[string]interface{}
+		wantTemp interface{}
+		wantTopP interface{}
+	}{
+		{"global defaults", map[string]interface{}{"model": "glm-5"}, 0.2, 0.9},
+		{"per-model override", map[string]interface{}{"model": "glm-4.6"}, 0.7, 0.9},
+		{"client values win", map[string]interface{}{"model": "glm-5", "temperature": 1.0, "top_p": 0.5}, 1.0, 0.5},
+		{"client zero is kept", map[string]interface{}{"model": "glm-4.6", "temperature": 0.0}, 0.0, 0.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req["input"] = "hi"
+			chatReq := h.transformRequest(tt.req, h.backends["zai"])
+			if got := chatReq["temperature"]; got != tt.wantTemp {
+				t.Errorf("temperature = %v, want %v", got, tt.wantTemp)
+			}
+			if got := chatReq["top_p"]; got != tt.wantTopP {
+				t.Errorf("top_p = %v, want %v", got, tt.wantTopP)
+			}
+		})
+	}
+
+	t.Run("unset defaults inject nothing", func(t *testing.T) {
+		h := NewProxyHandler(config.Default(), discardLogger())
+		chatReq := h.transformRequest(map[string]interface{}{"model": "glm-5", "input": "hi"}, h.backends["zai"])
+		for _, key := range []string{"temperature", "top_p"} {
+			if v, ok := chatReq[key]; ok {
+				t.Errorf("%s = %v, want unset", key, v)
+			}
+		}
+	})
+}
+
+func TestReasoningInputItems(t *testing.T) {
+	input := func(reasoning map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"model": "glm-5",
+			"input": []interface{}{
+				map[string]interface{}{"type": "message", "role": "user", "content": "solve it"},
+				reasoning,
+				map[string]interface{}{"type": "message", "role": "assistant", "content": "42"},
+				map[string]interface{}{"type": "message", "role": "user", "content": "why?"},
+			},
+		}
+	}
+	encrypted := map[string]interface{}{"type": "reasoning", "id": "rs_1", "encrypted_content": "opaque-blob"}
+	summarized := map[string]interface{}{
+		"type":    "reasoning",
+		"id":      "rs_2",
+		"summary": []interface{}{map[string]interface{}{"type": "summary_text", "text": "thought hard"}},
+	}
+
+	tests := []struct {
+		name      string
+		reasoning bool
+		item      map[string]interface{}
+		want      interface{}
+	}{
+		{"encrypted content forwarded", true, encrypted, "opaque-blob"},
+		{"summary forwarded", true, summarized, "thought hard"},
+		{"dropped without reasoning support", false, encrypted, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Providers.Zai.Capabilities = config.CapabilitiesConfig{SupportsReasoning: boolPtr(tt.reasoning)}
+			h := NewProxyHandler(cfg, discardLogger())
+
+			messages := h.transformRequest(input(tt.item), h.backends["zai"])["messages"].([]map[string]interface{})
+			if len(messages) != 3 {
+				t.Fatalf("got %d messages, want 3: %v", len(messages), messages)
+			}
+			assistant := messages[1]
+			if assistant["role"] != "assistant" {
+				t.Fatalf("messages[1] role = %v, want assistant", assistant["role"])
+			}
+			if got := assistant["reasoning_content"]; got != tt.want {
+				t.Errorf("reasoning_content = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTruncation(t *testing.T) {
+	conversation := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": "first question"},
+			{"role": "assistant", "content": "first answer"},
+			{"role": "user", "content": "second question"},
+		}
+	}
+	full := estimateTokens(conversation())
+
+	tests := []struct {
+		name       string
+		budget     int
+		truncation string
+		wantErr    bool
+		wantRoles  string
+	}{
+		{"exactly at budget", full, "disabled", false, "system,user,assistant,user"},
+		{"disabled over budget", full - 1, "disabled", true, ""},
+		{"unset defaults to disabled", full - 1, "", true, ""},
+		{"auto drops the oldest turn", full - 1, "auto", false, "system,assistant,user"},
+		{"auto keeps the latest message", estimateTokens(conversation()[:1]) + estimateTokens(conversation()[3:]), "auto", false, "system,user"},
+		{"auto cannot fit the latest message", 1, "auto", true, ""},
+		{"no budget", 0, "disabled", false, "system,user,assistant,user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Providers.ContextBudget = tt.budget
+			h := NewProxyHandler(cfg, discardLogger())
+
+			chatReq := map[string]interface{}{"messages": conversation()}
+			err := h.applyTruncation(map[string]interface{}{"truncation": tt.truncation}, chatReq)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyTruncation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var roles []string
+			for _, msg := range chatReq["messages"].([]map[string]interface{}) {
+				roles = append(roles, msg["role"].(string))
+			}
+			if got := strings.Join(roles, ","); got != tt.wantRoles {
+				t.Errorf("roles = %s, want %s", got, tt.wantRoles)
+			}
+		})
+	}
+
+	t.Run("oversized request is rejected with 400", func(t *testing.T) {
+		backend := newTestBackend(t, replyChat())
+		cfg := testConfig(backend)
+		cfg.Providers.ContextBudget = 5
+		h := NewProxyHandler(cfg, discardLogger())
+
+		rec := postResponses(h, `{"model":"glm-5","input":"a question much longer than five tokens"}`, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400", rec.Code)
+		}
+		if code := decodeError(t, rec)["code"]; code != "context_length_exceeded" {
+			t.Errorf("error code = %v, want context_length_exceeded", code)
+		}
+		if len(backend.received()) != 0 {
+			t.Error("oversized request reached the backend")
+		}
+	})
+}
+
+func TestResponseEchoesRequestedModel(t *testing.T) {
+	chunks := []string{
+		`{"id":"c1","model":"glm-5","created":1,"choices":[{"index":0,"delta":{"role":"assistant","content":"hi"}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	}
+
+	tests := []struct {
+		name        string
+		stream      bool
+		expose      bool
+		wantBackend interface{}
+	}{
+		{"non-streaming", false, false, nil},
+		{"non-streaming with backend model", false, true, "glm-5"},
+		{"streaming", true, false, nil},
+		{"streaming with backend model", true, true, "glm-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyChat(chunks...))
+			cfg := testConfig(backend)
+			cfg.Codex.ExposeBackendModel = tt.expose
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, fmt.Sprintf(`{"model":"gpt-5.2-codex","input":"hi","stream":%t}`, tt.stream), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			if got := backend.received()[0].body["model"]; got != "glm-5" {
+				t.Errorf("backend model = %v, want glm-5", got)
+			}
+
+			var resp map[string]interface{}
+			if tt.stream {
+				resp, _ = eventOfType(streamEvents(t, rec.Body), "response.created")["response"].(map[string]interface{})
+			} else {
+				json.Unmarshal(rec.Body.Bytes(), &resp)
+			}
+			if resp["model"] != "gpt-5.2-codex" {
+				t.Errorf("model = %v, want gpt-5.2-codex", resp["model"])
+			}
+			if resp["x_backend_model"] != tt.wantBackend {
+				t.Errorf("x_backend_model = %v, want %v", resp["x_backend_model"], tt.wantBackend)
+			}
+		})
+	}
+}
+
+func TestClientCancelAbortsBackendRequest(t *testing.T) {
+	backendDone := make(chan struct{})
+	backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		defer close(backendDone)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","content":"partial"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		// Stall until the router gives up on the request
+		<-r.Context().Done()
+	})
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(`{"model":"glm-5","input":"hi","stream":true}`)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after the client cancelled")
+	}
+	select {
+	case <-backendDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("backend request was not aborted")
+	}
+}
+
+func TestStringBooleanFields(t *testing.T) {
+	tests := []struct {
+		name          string
+		stream        string
+		wantStreaming bool
+	}{
+		{"string true", `"true"`, true},
+		{"string TRUE with spaces", `" TRUE "`, true},
+		{"string false", `"false"`, false},
+		{"boolean true", `true`, true},
+		{"unrecognized string", `"yes please"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyChat(`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`))
+			h := NewProxyHandler(testConfig(backend), discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":`+tt.stream+`}`, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			streaming := rec.Header().Get("Content-Type") == "text/event-stream"
+			if streaming != tt.wantStreaming {
+				t.Errorf("streamed = %v, want %v", streaming, tt.wantStreaming)
+			}
+			if got := backend.received()[0].body["stream"] == true; got != tt.wantStreaming {
+				t.Errorf("backend stream = %v, want %v", backend.received()[0].body["stream"], tt.wantStreaming)
+			}
+		})
+	}
+}
+
+func TestNormalizeBoolFields(t *testing.T) {
+	req := map[string]interface{}{
+		"stream":              "true",
+		"background":          "0",
+		"store":               false,
+		"parallel_tool_calls": "False",
+		"model":               "true",
+	}
+	normalizeBoolFields(req)
+
+	want := map[string]interface{}{
+		"stream":              true,
+		"background":          false,
+		"store":               false,
+		"parallel_tool_calls": false,
+		"model":               "true",
+	}
+	for key, value := range want {
+		if req[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, req[key], value)
+		}
+	}
+}
+
+func TestRecordCost(t *testing.T) {
+	cfg := config.Default()
+	cfg.Providers.Pricing = map[string]config.ModelPricing{
+		"glm-5":         {Input: 1.0, Output: 4.0},
+		"gpt-5.2-codex": {Input: 2.0, Output: 8.0},
+	}
+	h := NewProxyHandler(cfg, discardLogger())
+
+	usage := map[string]interface{}{"prompt_tokens": 1000.0, "completion_tokens": 500.0}
+
+	tests := []struct {
+		name         string
+		clientModel  string
+		backendModel string
+		usage        map[string]interface{}
+		want         float64
+		wantOK       bool
+	}{
+		{"backend model price", "codex", "glm-5", usage, 0.003, true},
+		{"client model price wins", "gpt-5.2-codex", "glm-5", usage, 0.006, true},
+		{"unpriced model", "other", "other", usage, 0, false},
+		{"no usage", "glm-5", "glm-5", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := h.recordCost("zai", tt.clientModel, tt.backendModel, tt.usage)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("recordCost() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCostExposedInResponse(t *testing.T) {
+	backend := newTestBackend(t, replyChat())
+	cfg := testConfig(backend)
+	cfg.Codex.ExposeCost = true
+	cfg.Providers.Pricing = map[string]config.ModelPricing{"glm-5": {Input: 1e6, Output: 2e6}}
+	h := NewProxyHandler(cfg, discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+	var resp map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	// chatCompletion uses 1 prompt and 1 completion token
+	if resp["x_cost"] != 3.0 {
+		t.Errorf("x_cost = %v, want 3", resp["x_cost"])
+	}
+
+	metrics := httptest.NewRecorder()
+	MetricsHandler(discardLogger())(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(metrics.Body.String(), `codex_router_cost_usd_total{model="glm-5",provider="zai"}`) {
+		t.Errorf("cost metric missing:\n%s", metrics.Body)
+	}
+}
+
+func TestCodexHeadersPropagated(t *testing.T) {
+	backend := newTestBackend(t, replyChat())
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	postResponses(h, `{"model":"glm-5","input":"hi"}`, map[string]string{
+		"Session_id":         "sess-1",
+		"Conversation_id":    "conv-1",
+		"Chatgpt-Account-Id": "acct-1",
+	})
+
+	header := backend.received()[0].header
+	if header.Get("Session_id") != "sess-1" || header.Get("Conversation_id") != "conv-1" {
+		t.Errorf("backend session headers = %q, %q", header.Get("Session_id"), header.Get("Conversation_id"))
+	}
+	if header.Get("Chatgpt-Account-Id") != "" {
+		t.Error("account ID forwarded to the backend")
+	}
+}
+
+func TestTransformToolsMissingType(t *testing.T) {
+	h := NewProxyHandler(config.Default(), discardLogger())
+
+	tools := h.transformTools([]interface{}{
+		map[string]interface{}{"name": "lookup", "parameters": map[string]interface{}{"type": "object"}},
+		map[string]interface{}{"function": map[string]interface{}{"name": "search"}},
+	})
+
+	if len(tools) != 2 {
+		t.Fatalf("got %d tools, want 2: %v", len(tools), tools)
+	}
+	for i, name := range []string{"lookup", "search"} {
+		if tools[i]["type"] != "function" {
+			t.Errorf("tool %d type = %v, want function", i, tools[i]["type"])
+		}
+		if fn := tools[i]["function"].(map[string]interface{}); fn["name"] != name {
+			t.Errorf("tool %d name = %v, want %s", i, fn["name"], name)
+		}
+	}
+}
+
+func TestStreamedToolCallFragments(t *testing.T) {
+	backend := newTestBackend(t, replyStream(
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_","type":"function","function":{"name":"get_","arguments":""}}]}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"1","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	))
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"weather?","stream":true}`, nil)
+	events := streamEvents(t, rec.Body)
+
+	var item map[string]interface{}
+	for _, event := range events {
+		if event["type"] != "response.output_item.done" {
+			continue
+		}
+		if it, _ := event["item"].(map[string]interface{}); it["type"] == "function_call" {
+			item = it
+		}
+	}
+	if item == nil {
+		t.Fatalf("no function_call item in %v", events)
+	}
+	if item["name"] != "get_weather" {
+		t.Errorf("name = %v, want get_weather", item["name"])
+	}
+	if item["call_id"] != "call_1" {
+		t.Errorf("call_id = %v, want call_1", item["call_id"])
+	}
+	if item["arguments"] != `{"city":"Paris"}` {
+		t.Errorf("arguments = %v", item["arguments"])
+	}
+}
+
+func TestStreamedToolCallArgumentsMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		cumulative bool
+		want       string
+	}{
+		{"appended by default", false, "aab"},
+		{"replaced when cumulative", true, "ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyStream(
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"f","arguments":"a"}}]}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ab"}}]}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			))
+			cfg := testConfig(backend)
+			cfg.Providers.Zai.CumulativeToolCallDeltas = tt.cumulative
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+
+			deltas := ""
+			var item map[string]interface{}
+			for _, event := range streamEvents(t, rec.Body) {
+				switch event["type"] {
+				case "response.function_call_arguments.delta":
+					deltas += event["delta"].(string)
+				case "response.output_item.done":
+					if it, _ := event["item"].(map[string]interface{}); it["type"] == "function_call" {
+						item = it
+					}
+				}
+			}
+			if item == nil {
+				t.Fatal("no function_call item")
+			}
+			if item["arguments"] != tt.want {
+				t.Errorf("arguments = %v, want %s", item["arguments"], tt.want)
+			}
+			if deltas != tt.want {
+				t.Errorf("argument deltas = %q, want %q", deltas, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentTypeCheck(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantStatus  int
+	}{
+		{"application/json", http.StatusOK},
+		{"application/json; charset=utf-8", http.StatusOK},
+		{"Application/JSON", http.StatusOK},
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			h := NewProxyHandler(testConfig(backend), discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, map[string]string{"Content-Type": tt.contentType})
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				if code := decodeError(t, rec)["code"]; code != "unsupported_media_type" {
+					t.Errorf("code = %v, want unsupported_media_type", code)
+				}
+				if len(backend.received()) != 0 {
+					t.Error("rejected request reached the backend")
+				}
+			}
+		})
+	}
+}
+
+func TestLogitBias(t *testing.T) {
+	tests := []struct {
+		name        string
+		supported   bool
+		bias        string
+		wantStatus  int
+		wantForward bool
+	}{
+		{"forwarded when supported", true, `{"50256":-100}`, http.StatusOK, true},
+		{"dropped when unsupported", false, `{"50256":-100}`, http.StatusOK, false},
+		{"bias out of range", true, `{"50256":-101}`, http.StatusBadRequest, false},
+		{"key not a token ID", true, `{"hello":1}`, http.StatusBadRequest, false},
+		{"not an object", true, `[1]`, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			cfg := testConfig(backend)
+			cfg.Providers.Zai.Capabilities.SupportsLogitBias = boolPtr(tt.supported)
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","logit_bias":`+tt.bias+`}`, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(backend.received()) != 0 {
+					t.Error("invalid logit_bias reached the backend")
+				}
+				return
+			}
+
+			_, forwarded := backend.received()[0].body["logit_bias"]
+			if forwarded != tt.wantForward {
+				t.Errorf("logit_bias forwarded = %v, want %v", forwarded, tt.wantForward)
+			}
+		})
+	}
+}
+
+func TestStreamCreatedAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		created string
+	}{
+		{"backend timestamp ignored", `"created":1700000000,`},
+		{"missing", ``},
+		{"zero", `"created":0,`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyStream(
+				`{"id":"c1",`+tt.created+`"model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+				`{"id":"c1",`+tt.created+`"model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			))
+			h := NewProxyHandler(testConfig(backend), discardLogger())
+
+			start := time.Now().Unix()
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+			end := time.Now().Unix()
+
+			// Every event carrying the response has the request's start time
+			var stamped int
+			for _, event := range streamEvents(t, rec.Body) {
+				resp, ok := event["response"].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				stamped++
+				ts, _ := resp["created_at"].(float64)
+				if int64(ts) < start || int64(ts) > end {
+					t.Errorf("%s created_at = %d, want the request start in [%d, %d]", event["type"], int64(ts), start, end)
+				}
+			}
+			if stamped < 2 {
+				t.Errorf("%d events carry the response, want response.created and response.completed", stamped)
+			}
+		})
+	}
+}
+
+func TestCreatedAtIsRequestStart(t *testing.T) {
+	backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1100 * time.Millisecond) // Cross a second boundary before answering
+		replyJSON(http.StatusOK, chatCompletion)(w, r)
+	})
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	start := time.Now().Unix()
+	rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+	end := time.Now().Unix()
+	var resp struct {
+		CreatedAt int64 `json:"created_at"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v; body = %s", err, rec.Body)
+	}
+	// The backend answered over a second after the request started
+	if resp.CreatedAt < start || resp.CreatedAt >= end {
+		t.Errorf("created_at = %d, want the request start in [%d, %d)", resp.CreatedAt, start, end)
+	}
+}
+
+func TestStreamCompletedOutput(t *testing.T) {
+	backend := newTestBackend(t, replyStream(
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","content":"Checking "}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"the weather"}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	))
+	h := NewProxyHandler(testConfig(backend), discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"weather?","stream":true}`, nil)
+	events := streamEvents(t, rec.Body)
+
+	completed := eventOfType(events, "response.completed")
+	if completed == nil {
+		t.Fatalf("no response.completed event in %v", events)
+	}
+	output, _ := completed["response"].(map[string]interface{})["output"].([]interface{})
+
+	// Each completed output item matches its output_item.done event
+	var done []interface{}
+	for _, event := range events {
+		if event["type"] == "response.output_item.done" {
+			done = append(done, event["item"])
+		}
+	}
+	if len(output) != 2 || !reflect.DeepEqual(output, done) {
+		t.Fatalf("completed output = %v, want the output_item.done items %v", output, done)
+	}
+
+	message := output[0].(map[string]interface{})
+	content := message["content"].([]interface{})
+	if text := content[0].(map[string]interface{})["text"]; text != "Checking the weather" {
+		t.Errorf("message text = %v", text)
+	}
+	if call := output[1].(map[string]interface{}); call["name"] != "get_weather" || call["call_id"] != "call_1" {
+		t.Errorf("tool call item = %v", call)
+	}
+}
+
+func TestInstructionsTemplate(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+
+	tests := []struct {
+		name     string
+		global   string
+		provider string
+		body     string
+		want     string // system message content, empty for none
+	}{
+		{"passthrough", "", "", `{"model":"glm-5","input":"hi","instructions":"Be brief."}`, "Be brief."},
+		{"templated", "Date: {{.Date}}\n{{.Instructions}}", "", `{"model":"glm-5","input":"hi","instructions":"Be brief."}`, "Date: " + today + "\nBe brief."},
+		{"model available", "You serve {{.Model}}.", "", `{"model":"glm-5","input":"hi"}`, "You serve glm-5."},
+		{"provider override", "global {{.Instructions}}", "provider {{.Instructions}}", `{"model":"glm-5","input":"hi","instructions":"x"}`, "provider x"},
+		{"empty render", "{{.Instructions}}", "", `{"model":"glm-5","input":"hi"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			cfg := testConfig(backend)
+			cfg.Codex.InstructionsTemplate = tt.global
+			cfg.Providers.Zai.InstructionsTemplate = tt.provider
+			h := NewProxyHandler(cfg, discardLogger())
+
+			if rec := postResponses(h, tt.body, nil); rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+
+			messages, _ := backend.received()[0].body["messages"].([]interface{})
+			var system string
+			for _, m := range messages {
+				if msg := m.(map[string]interface{}); msg["role"] == "system" {
+					system, _ = msg["content"].(string)
+				}
+			}
+			if system != tt.want {
+				t.Errorf("system message = %q, want %q", system, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	withLimits := func(reply http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Ratelimit-Remaining-Requests", "41")
+			w.Header().Set("X-Ratelimit-Reset-Tokens", "6m0s")
+			w.Header().Set("X-Backend-Internal", "hidden")
+			reply(w, r)
+		}
+	}
+	streamChunk := `{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":"stop"}]}`
+
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			backend := newTestBackend(t, withLimits(replyChat(streamChunk)))
+			cfg := testConfig(backend)
+			cfg.Server.MaxConcurrentRequests = 4
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, fmt.Sprintf(`{"model":"glm-5","input":"hi","stream":%v}`, stream), nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+
+			want := map[string]string{
+				"X-Ratelimit-Remaining-Requests": "41",
+				"X-Ratelimit-Reset-Tokens":       "6m0s",
+				"X-Router-Concurrency-Limit":     "4",
+				"X-Router-Concurrency-Remaining": "3",
+				"X-Backend-Internal":             "",
+			}
+			for name, value := range want {
+				if got := rec.Header().Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+
+	t.Run("no router headers without a limit", func(t *testing.T) {
+		backend := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+		h := NewProxyHandler(testConfig(backend), discardLogger())
+
+		rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+		if got := rec.Header().Get("X-Router-Concurrency-Limit"); got != "" {
+			t.Errorf("X-Router-Concurrency-Limit = %q, want none", got)
+		}
+	})
+}
+
+func TestStreamDeltasAddUpToFullText(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{
+			name: "deltas",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"Hello, "}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"world"},"finish_reason":"stop"}]}`,
+			},
+			want: "Hello, world",
+		},
+		{
+			name: "content only in the last chunk",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"Hello, world"},"finish_reason":"stop"}]}`,
+			},
+			want: "Hello, world",
+		},
+		{
+			name: "last chunk repeats streamed text",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"Hello, "}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"Hello, world"},"finish_reason":"stop"}]}`,
+			},
+			want: "Hello, world",
+		},
+		{
+			name: "diverging last chunk is dropped",
+			chunks: []string{
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"Hello"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"Goodbye"},"finish_reason":"stop"}]}`,
+			},
+			want: "Hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProxyHandler(testConfig(newTestBackend(t, replyStream(tt.chunks...))), discardLogger())
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+			events := streamEvents(t, rec.Body)
+
+			var deltas strings.Builder
+			for _, event := range events {
+				if event["type"] == "response.output_text.delta" {
+					deltas.WriteString(event["delta"].(string))
+				}
+			}
+			done := eventOfType(events, "response.output_text.done")
+			if done == nil {
+				t.Fatalf("no response.output_text.done in %v", events)
+			}
+
+			if done["text"] != tt.want {
+				t.Errorf("full text = %q, want %q", done["text"], tt.want)
+			}
+			if deltas.String() != done["text"] {
+				t.Errorf("deltas add up to %q, full text is %q", deltas.String(), done["text"])
+			}
+		})
+	}
+}
+
+func TestOversizedBackendResponse(t *testing.T) {
+	long := `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"` + strings.Repeat("x", 4096) + `"},"finish_reason":"stop"}]}`
+	cfg := testConfig(newTestBackend(t, replyJSON(http.StatusOK, long)))
+	cfg.Providers.Zai.MaxResponseBytes = 1024
+	h := NewProxyHandler(cfg, discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502: %s", rec.Code, rec.Body)
+	}
+	if code := decodeError(t, rec)["code"]; code != "backend_response_too_large" {
+		t.Errorf("error code = %v, want backend_response_too_large", code)
+	}
+
+	t.Run("names the routed provider's limit", func(t *testing.T) {
+		cfg := testConfig(newTestBackend(t, replyJSON(http.StatusOK, chatCompletion)))
+		cfg.Providers.OpenAI.Enabled = true
+		cfg.Providers.OpenAI.APIKey = "openai-key"
+		cfg.Providers.OpenAI.BaseURL = newTestBackend(t, replyJSON(http.StatusOK, long)).URL
+		cfg.Providers.OpenAI.MaxResponseBytes = 1024
+		h := NewProxyHandler(cfg, discardLogger())
+
+		rec := postResponses(h, `{"model":"gpt-4","input":"hi"}`, nil)
+		if rec.Code != http.StatusBadGateway {
+			t.Fatalf("status = %d, want 502: %s", rec.Code, rec.Body)
+		}
+		if msg, _ := decodeError(t, rec)["message"].(string); !strings.Contains(msg, "providers.openai.max_response_bytes") {
+			t.Errorf("error message = %q, want it to name providers.openai.max_response_bytes", msg)
+		}
+	})
+}
+
+// sequenceIDs numbers the IDs it generates
+type sequenceIDs struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (g *sequenceIDs) NewID(prefix string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("%s%d", prefix, g.n)
+}
+
+func TestDeterministicIDs(t *testing.T) {
+	t.Run("response", func(t *testing.T) {
+		backend := newTestBackend(t, replyJSON(http.StatusOK,
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+		h := NewProxyHandler(testConfig(backend), discardLogger())
+		h.SetIDGenerator(&sequenceIDs{})
+
+		rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+
+		// req_1 is taken by the request's lifecycle
+		if resp["id"] != "resp_2" {
+			t.Errorf("id = %v, want resp_2", resp["id"])
+		}
+		item := resp["output"].([]interface{})[0].(map[string]interface{})
+		if item["id"] != "msg_3" {
+			t.Errorf("output item id = %v, want msg_3", item["id"])
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		backend := newTestBackend(t, replyStream(
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		))
+		h := NewProxyHandler(testConfig(backend), discardLogger())
+		h.SetIDGenerator(&sequenceIDs{})
+
+		rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil)
+		events := streamEvents(t, rec.Body)
+
+		for _, eventType := range []string{"response.created", "response.completed"} {
+			event := eventOfType(events, eventType)
+			if event == nil {
+				t.Fatalf("no %s event", eventType)
+			}
+			if id := event["response"].(map[string]interface{})["id"]; id != "resp_2" {
+				t.Errorf("%s id = %v, want resp_2", eventType, id)
+			}
+		}
+	})
+}
+
+func TestResponsesAPIVersion(t *testing.T) {
+	const toolCallCompletion = `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`
+
+	tests := []struct {
+		version   string
+		itemTypes []string // Non-streaming output item types
+		toolCalls bool     // Tool calls nested under the message item
+		doneEvent bool
+	}{
+		{config.ResponsesAPIV1, []string{"message"}, true, true},
+		{config.ResponsesAPIV2, []string{"function_call"}, false, false},
+		{"", []string{"message"}, true, true}, // Unset is v1
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusOK, toolCallCompletion))
+			cfg := testConfig(backend)
+			cfg.Server.ResponsesAPIVersion = tt.version
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"weather?"}`, nil)
+			var resp map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v; body = %s", err, rec.Body)
+			}
+			var itemTypes []string
+			toolCalls := false
+			for _, item := range resp["output"].([]interface{}) {
+				item := item.(map[string]interface{})
+				itemTypes = append(itemTypes, item["type"].(string))
+				if _, ok := item["tool_calls"]; ok {
+					toolCalls = true
+				}
+			}
+			if !reflect.DeepEqual(itemTypes, tt.itemTypes) {
+				t.Errorf("output item types = %v, want %v", itemTypes, tt.itemTypes)
+			}
+			if toolCalls != tt.toolCalls {
+				t.Errorf("message carries tool_calls = %v, want %v", toolCalls, tt.toolCalls)
+			}
+
+			streamBackend := newTestBackend(t, replyStream(
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+				`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			))
+			cfg = testConfig(streamBackend)
+			cfg.Server.ResponsesAPIVersion = tt.version
+			h = NewProxyHandler(cfg, discardLogger())
+
+			events := streamEvents(t, postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil).Body)
+			if got := eventOfType(events, "response.done") != nil; got != tt.doneEvent {
+				t.Errorf("response.done sent = %v, want %v", got, tt.doneEvent)
+			}
+			if eventOfType(events, "response.completed") == nil {
+				t.Error("no response.completed event")
+			}
+		})
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty string", `{"model":"glm-5","input":""}`},
+		{"empty array", `{"model":"glm-5","input":[]}`},
+		{"missing", `{"model":"glm-5"}`},
+		{"instructions only", `{"model":"glm-5","instructions":"be brief","input":""}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyChat())
+			h := NewProxyHandler(testConfig(backend), discardLogger())
+
+			rec := postResponses(h, tt.body, nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", rec.Code)
+			}
+			errBody := decodeError(t, rec)
+			if errBody["type"] != "invalid_request_error" || errBody["message"] != "input is required" {
+				t.Errorf("error = %v", errBody)
+			}
+			if calls := backend.received(); len(calls) != 0 {
+				t.Errorf("backend called %d times", len(calls))
+			}
+		})
+	}
+
+	t.Run("previous_response_id supplies context", func(t *testing.T) {
+		backend := newTestBackend(t, replyChat())
+		h := NewProxyHandler(testConfig(backend), discardLogger())
+
+		first := postResponses(h, `{"model":"glm-5","input":"hello"}`, nil)
+		var resp struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(first.Body.Bytes(), &resp)
+
+		rec := postResponses(h, `{"model":"glm-5","input":"","previous_response_id":"`+resp.ID+`"}`, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		messages, _ := backend.received()[1].body["messages"].([]interface{})
+		if len(messages) != 2 {
+			t.Errorf("messages = %v, want the stored turn only", messages)
+		}
+	})
+}
+
+func TestTextAndToolCallsInOneChoice(t *testing.T) {
+	const completion = `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"Let me check both cities.","tool_calls":[` +
+		`{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},` +
+		`{"id":"call_2","function":{"name":"get_weather","arguments":"{\"city\":\"Oslo\"}"}}]},"finish_reason":"tool_calls"}]}`
+
+	decode := func(t *testing.T, version string) []interface{} {
+		t.Helper()
+		backend := newTestBackend(t, replyJSON(http.StatusOK, completion))
+		cfg := testConfig(backend)
+		cfg.Server.ResponsesAPIVersion = version
+		rec := postResponses(NewProxyHandler(cfg, discardLogger()), `{"model":"glm-5","input":"weather?"}`, nil)
+		var resp map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding response: %v; body = %s", err, rec.Body)
+		}
+		output, _ := resp["output"].([]interface{})
+		return output
+	}
+
+	t.Run(config.ResponsesAPIV2, func(t *testing.T) {
+		var got []string
+		for _, item := range decode(t, config.ResponsesAPIV2) {
+			item := item.(map[string]interface{})
+			switch item["type"] {
+			case "message":
+				content := item["content"].([]interface{})[0].(map[string]interface{})
+				got = append(got, "message:"+content["text"].(string))
+			case "function_call":
+				got = append(got, "function_call:"+item["call_id"].(string)+":"+item["arguments"].(string))
+			default:
+				got = append(got, fmt.Sprint(item["type"]))
+			}
+		}
+		want := []string{
+			"message:Let me check both cities.",
+			`function_call:call_1:{"city":"Paris"}`,
+			`function_call:call_2:{"city":"Oslo"}`,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("output = %q, want %q", got, want)
+		}
+	})
+
+	t.Run(config.ResponsesAPIV1, func(t *testing.T) {
+		output := decode(t, config.ResponsesAPIV1)
+		if len(output) != 1 {
+			t.Fatalf("output = %v, want one message item", output)
+		}
+		msg := output[0].(map[string]interface{})
+		content := msg["content"].([]interface{})[0].(map[string]interface{})
+		if content["text"] != "Let me check both cities." {
+			t.Errorf("text = %v", content["text"])
+		}
+		calls := msg["tool_calls"].([]interface{})
+		if len(calls) != 2 {
+			t.Fatalf("tool_calls = %v, want 2", calls)
+		}
+		for i, id := range []string{"call_1", "call_2"} {
+			call := calls[i].(map[string]interface{})
+			if call["id"] != id || call["type"] != "function" {
+				t.Errorf("tool_calls[%d] = %v, want function call %s", i, call, id)
+			}
+		}
+	})
+}
+
+func TestPassthroughAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		passthrough bool
+		auth        string // Client Authorization header
+		wantStatus  int
+		wantBackend string // Authorization the backend receives
+	}{
+		{"configured key", false, "Bearer client-key", http.StatusOK, "Bearer zai-key"},
+		{"client key forwarded", true, "Bearer client-key", http.StatusOK, "Bearer client-key"},
+		{"missing client key", true, "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyChat())
+			cfg := testConfig(backend)
+			cfg.Providers.PassthroughAuth = tt.passthrough
+			h := NewProxyHandler(cfg, discardLogger())
+
+			header := map[string]string{}
+			if tt.auth != "" {
+				header["Authorization"] = tt.auth
+			}
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, header)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body = %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			calls := backend.received()
+			if tt.wantBackend == "" {
+				if len(calls) != 0 {
+					t.Errorf("backend called %d times", len(calls))
+				}
+				return
+			}
+			if got := calls[0].header.Get("Authorization"); got != tt.wantBackend {
+				t.Errorf("backend Authorization = %q, want %q", got, tt.wantBackend)
+			}
+		})
+	}
+}
+
+func TestStreamedToolCallArguments(t *testing.T) {
+	toolCallDelta := func(args string) string {
+		return `{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":` + strconv.Quote(args) + `}}]}}]}`
+	}
+
+	tests := []struct {
+		name      string
+		fragments []string
+		wantArgs  string
+		wantWarn  bool
+	}{
+		{"fragmented JSON", []string{`{"ci`, `ty":"Pa`, `ris"}`}, `{"city":"Paris"}`, false},
+		{"no arguments", nil, `{}`, false},
+		{"invalid JSON", []string{`{"city":`, `"Paris"`}, `{"city":"Paris"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := []string{`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`}
+			for _, fragment := range tt.fragments {
+				chunks = append(chunks, toolCallDelta(fragment))
+			}
+			chunks = append(chunks, `{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`)
+			backend := newTestBackend(t, replyStream(chunks...))
+
+			var logs bytes.Buffer
+			h := NewProxyHandler(testConfig(backend), slog.New(slog.NewTextHandler(&logs, nil)))
+			events := streamEvents(t, postResponses(h, `{"model":"glm-5","input":"weather?","stream":true}`, nil).Body)
+
+			// Deltas relay the raw fragments and precede the done event
+			var deltas []string
+			doneAt := -1
+			for i, event := range events {
+				switch event["type"] {
+				case "response.function_call_arguments.delta":
+					if doneAt >= 0 {
+						t.Errorf("delta after function_call_arguments.done")
+					}
+					deltas = append(deltas, event["delta"].(string))
+				case "response.function_call_arguments.done":
+					doneAt = i
+					if event["arguments"] != tt.wantArgs {
+						t.Errorf("done arguments = %v, want %s", event["arguments"], tt.wantArgs)
+					}
+				}
+			}
+			if doneAt < 0 {
+				t.Fatal("no function_call_arguments.done event")
+			}
+			if !reflect.DeepEqual(deltas, tt.fragments) {
+				t.Errorf("deltas = %q, want %q", deltas, tt.fragments)
+			}
+
+			warned := strings.Contains(logs.String(), "not valid JSON")
+			if warned != tt.wantWarn {
+				t.Errorf("invalid JSON warning logged = %v, want %v", warned, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestStrictRequestFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		strict bool
+		body   string
+		want   int
+	}{
+		{"unknown field rejected", true, `{"model":"glm-5","input":"hi","max_token":100}`, http.StatusBadRequest},
+		{"known fields accepted", true, `{"model":"glm-5","input":"hi","max_output_tokens":100}`, http.StatusOK},
+		{"lenient by default", false, `{"model":"glm-5","input":"hi","max_token":100}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyChat())
+			cfg := testConfig(backend)
+			cfg.Server.StrictRequestFields = tt.strict
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, tt.body, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d; body = %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusBadRequest {
+				errBody := decodeError(t, rec)
+				if errBody["code"] != "unknown_parameter" || !strings.Contains(errBody["message"].(string), "max_token") {
+					t.Errorf("error = %v, want unknown_parameter naming max_token", errBody)
+				}
+			}
+		})
+	}
+}
+
+func TestReasoningSummaryStream(t *testing.T) {
+	stream := replyStream(
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"reasoning_summary":"Plan","reasoning_content":"step 1"}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"reasoning_summary":" it","reasoning_content":", step 2"}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"done"}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	)
+
+	tests := []struct {
+		name       string
+		forward    bool
+		include    string
+		wantDetail bool
+	}{
+		{"summary only by default", false, `["reasoning.content"]`, false},
+		{"detail not included", true, `[]`, false},
+		{"detail forwarded and included", true, `["reasoning.content"]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, stream)
+			cfg := testConfig(backend)
+			cfg.Codex.ForwardReasoningContent = tt.forward
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true,"include":`+tt.include+`}`, nil)
+			events := streamEvents(t, rec.Body)
+
+			var summary, detail string
+			for _, event := range events {
+				switch event["type"] {
+				case "response.reasoning_summary_text.delta":
+					summary += event["delta"].(string)
+				case "response.reasoning_text.delta":
+					detail += event["delta"].(string)
+				}
+			}
+			if summary != "Plan it" {
+				t.Errorf("summary deltas = %q, want %q", summary, "Plan it")
+			}
+			wantDetail := ""
+			if tt.wantDetail {
+				wantDetail = "step 1, step 2"
+			}
+			if detail != wantDetail {
+				t.Errorf("reasoning deltas = %q, want %q", detail, wantDetail)
+			}
+
+			// The reasoning item precedes the message in the completed output
+			completed := eventOfType(events, "response.completed")
+			if completed == nil {
+				t.Fatal("no response.completed")
+			}
+			output, _ := completed["response"].(map[string]interface{})["output"].([]interface{})
+			if len(output) != 2 {
+				t.Fatalf("output = %v, want reasoning then message", output)
+			}
+			if typ := output[0].(map[string]interface{})["type"]; typ != "reasoning" {
+				t.Errorf("output[0].type = %v, want reasoning", typ)
+			}
+			if typ := output[1].(map[string]interface{})["type"]; typ != "message" {
+				t.Errorf("output[1].type = %v, want message", typ)
+			}
+		})
+	}
+}
+
+func TestRequestIdentifiers(t *testing.T) {
+	tests := []struct {
+		name        string
+		supported   bool
+		wantForward bool
+	}{
+		{"forwarded when supported", true, true},
+		{"dropped when unsupported", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			cfg := testConfig(backend)
+			cfg.Providers.Zai.Capabilities.SupportsPromptCacheKey = boolPtr(tt.supported)
+			cfg.Providers.Zai.Capabilities.SupportsSafetyIdentifier = boolPtr(tt.supported)
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi","prompt_cache_key":"repo-123","safety_identifier":"user-hash"}`, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+
+			body := backend.received()[0].body
+			for field, want := range map[string]string{"prompt_cache_key": "repo-123", "safety_identifier": "user-hash"} {
+				got, forwarded := body[field]
+				if forwarded != tt.wantForward {
+					t.Errorf("%s forwarded = %v, want %v", field, forwarded, tt.wantForward)
+				}
+				if forwarded && got != want {
+					t.Errorf("%s = %v, want %q", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestToolCallArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string // JSON of the backend's function.arguments, "" to omit it
+		want      string
+	}{
+		{"string", `"{\"path\":\"a.go\"}"`, `{"path":"a.go"}`},
+		{"object", `{"path":"a.go"}`, `{"path":"a.go"}`},
+		{"empty string", `""`, `{}`},
+		{"missing", ``, `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			function := `{"name":"read_file"}`
+			if tt.arguments != "" {
+				function = `{"name":"read_file","arguments":` + tt.arguments + `}`
+			}
+			reply := `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":` + function + `}]},"finish_reason":"tool_calls"}]}`
+			cfg := testConfig(newTestBackend(t, replyJSON(http.StatusOK, reply)))
+			cfg.Server.ResponsesAPIVersion = config.ResponsesAPIV2
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+			var resp map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("status %d, body %s: %v", rec.Code, rec.Body, err)
+			}
+			output, _ := resp["output"].([]interface{})
+			if len(output) != 1 {
+				t.Fatalf("output = %v, want one function_call", output)
+			}
+			item := output[0].(map[string]interface{})
+			if item["type"] != "function_call" || item["call_id"] != "call_1" || item["name"] != "read_file" {
+				t.Errorf("item = %v, want function_call call_1 read_file", item)
+			}
+			if item["arguments"] != tt.want {
+				t.Errorf("arguments = %#v, want %q", item["arguments"], tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamEndsWithoutDone(t *testing.T) {
+	tests := []struct {
+		name          string
+		chunks        []string
+		wantCompleted bool
+	}{
+		{"tool call with finish_reason", []string{
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":"}}]}}]}`,
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.go\"}"}}]}}]}`,
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		}, true},
+		{"cut off before finish_reason", []string{
+			`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":"}}]}}]}`,
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, chunk := range tt.chunks {
+					io.WriteString(w, "data: "+chunk+"\n\n")
+				}
+			})
+			h := NewProxyHandler(testConfig(backend), discardLogger())
+
+			events := streamEvents(t, postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, nil).Body)
+			if got := eventOfType(events, "response.completed") != nil; got != tt.wantCompleted {
+				t.Fatalf("response.completed sent = %v, want %v", got, tt.wantCompleted)
+			}
+			if !tt.wantCompleted {
+				return
+			}
+			done := eventOfType(events, "response.function_call_arguments.done")
+			if done == nil {
+				t.Fatal("no response.function_call_arguments.done")
+			}
+			if done["arguments"] != `{"path":"a.go"}` {
+				t.Errorf("arguments = %v, want the assembled fragments", done["arguments"])
+			}
+		})
+	}
+}
+
+func TestGetStoredResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		wait time.Duration
+		want int
+	}{
+		{"stored", time.Hour, 0, http.StatusOK},
+		{"expired", 20 * time.Millisecond, 50 * time.Millisecond, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(newTestBackend(t, replyChat()))
+			cfg.Session.TTL = tt.ttl
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+			var created map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+				t.Fatalf("status %d, body %s: %v", rec.Code, rec.Body, err)
+			}
+			id, _ := created["id"].(string)
+			time.Sleep(tt.wait)
+
+			get := httptest.NewRecorder()
+			h.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/v1/responses/"+id, nil))
+			if get.Code != tt.want {
+				t.Fatalf("GET %s status = %d, want %d", id, get.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var stored map[string]interface{}
+			json.Unmarshal(get.Body.Bytes(), &stored)
+			if !reflect.DeepEqual(stored["output"], created["output"]) || stored["id"] != id {
+				t.Errorf("stored response = %v, want the created one %v", stored, created)
+			}
+		})
+	}
+}
+
+func TestErrorInOKResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantType    string
+		wantMessage string
+	}{
+		{"no status", `{"error":{"message":"model overloaded"}}`, http.StatusBadGateway, "api_error", "model overloaded"},
+		{"numeric code", `{"error":{"message":"slow down","type":"rate_limit_error","code":429}}`, http.StatusTooManyRequests, "rate_limit_error", "slow down"},
+		{"string status", `{"error":{"message":"bad model","status":"400","code":"model_not_found"}}`, http.StatusBadRequest, "api_error", "bad model"},
+		{"code not a status", `{"error":{"message":"oops","code":"1234"}}`, http.StatusBadGateway, "api_error", "oops"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProxyHandler(testConfig(newTestBackend(t, replyJSON(http.StatusOK, tt.body))), discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			errObj := decodeError(t, rec)
+			if errObj["type"] != tt.wantType || errObj["message"] != tt.wantMessage {
+				t.Errorf("error = %v, want %s %q", errObj, tt.wantType, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestModelMapping(t *testing.T) {
+	tests := []struct {
+		name        string
+		model       string
+		wantBackend string
+	}{
+		{"mapped", "claude-sonnet-4", "glm-5"},
+		{"unmapped", "glm-4.7", "glm-4.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			cfg := testConfig(backend)
+			cfg.Providers.ModelMapping = map[string]string{"claude-sonnet-4": "glm-5"}
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"`+tt.model+`","input":"hi"}`, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			if got := backend.received()[0].body["model"]; got != tt.wantBackend {
+				t.Errorf("backend model = %v, want %s", got, tt.wantBackend)
+			}
+		})
+	}
+}
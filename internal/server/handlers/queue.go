@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	errQueueFull    = errors.New("request queue is full")
+	errQueueTimeout = errors.New("timed out waiting in request queue")
+)
+
+// dispatchQueue limits the number of requests dispatched to the backend at
+// once. Requests over the limit wait in FIFO order, up to maxDepth waiters and
+// for at most wait each.
+type dispatchQueue struct {
+	mu       sync.Mutex
+	limit    int
+	maxDepth int
+	wait     time.Duration
+	inflight int
+	waiters  []chan struct{}
+}
+
+// newDispatchQueue returns nil when limit is zero, disabling the limit. All
+// dispatchQueue methods are no-ops on a nil receiver.
+func newDispatchQueue(limit, maxDepth int, wait time.Duration) *dispatchQueue {
+	if limit <= 0 {
+		return nil
+	}
+	return &dispatchQueue{limit: limit, maxDepth: maxDepth, wait: wait}
+}
+
+// acquire blocks until a dispatch slot is free. Every successful acquire must
+// be paired with a release.
+func (q *dispatchQueue) acquire(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	if q.inflight < q.limit && len(q.waiters) == 0 {
+		q.inflight++
+		q.mu.Unlock()
+		return nil
+	}
+	if len(q.waiters) >= q.maxDepth {
+		q.mu.Unlock()
+		queueRejectedFull.Add(1)
+		return errQueueFull
+	}
+
+	ready := make(chan struct{})
+	q.waiters = append(q.waiters, ready)
+	queueDepth.Add(1)
+	q.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		queuedRequests.Add(1)
+		queueWaitMs.Add(time.Since(start).Milliseconds())
+	}()
+
+	var timeout <-chan time.Time
+	if q.wait > 0 {
+		timer := time.NewTimer(q.wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-timeout:
+		if q.leave(ready) {
+			queueRejectedTimeout.Add(1)
+			return errQueueTimeout
+		}
+		return nil
+	case <-ctx.Done():
+		if q.leave(ready) {
+			return ctx.Err()
+		}
+		// The slot was handed over as we gave up; pass it on
+		q.release()
+		return ctx.Err()
+	}
+}
+
+// leave removes a waiter from the queue. It reports false when the waiter
+// was already granted a slot.
+func (q *dispatchQueue) leave(ready chan struct{}) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, w := range q.waiters {
+		if w == ready {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			queueDepth.Add(-1)
+			return true
+		}
+	}
+	return false
+}
+
+// release frees a dispatch slot, handing it to the longest waiting request
+func (q *dispatchQueue) release() {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiters) > 0 {
+		next := q.waiters[0]
+		q.waiters = q.waiters[1:]
+		queueDepth.Add(-1)
+		close(next)
+		return
+	}
+	q.inflight--
+}
+
+// slot returns a release for a slot just acquired that frees it at most once,
+// so a request can give up its slot before it finishes
+func (q *dispatchQueue) slot() func() {
+	var once sync.Once
+	return func() { once.Do(q.release) }
+}
+
+type slotKey struct{}
+
+// withSlot records the release of the request's dispatch slot
+func withSlot(ctx context.Context, release func()) context.Context {
+	return context.WithValue(ctx, slotKey{}, release)
+}
+
+// releaseSlot frees the dispatch slot of the request with ctx early, if it
+// holds one
+func releaseSlot(ctx context.Context) {
+	if release, ok := ctx.Value(slotKey{}).(func()); ok {
+		release()
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until q has n queued requests
+func waitForWaiters(t *testing.T, q *dispatchQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		waiting := len(q.waiters)
+		q.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("queue never reached %d waiters", n)
+}
+
+func TestDispatchQueue(t *testing.T) {
+	t.Run("queued requests are served in FIFO order", func(t *testing.T) {
+		q := newDispatchQueue(1, 10, time.Second)
+		if err := q.acquire(context.Background()); err != nil {
+			t.Fatalf("acquire() error = %v", err)
+		}
+
+		var mu sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := q.acquire(context.Background()); err != nil {
+					t.Errorf("waiter %d: acquire() error = %v", i, err)
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				q.release()
+			}(i)
+			waitForWaiters(t, q, i+1)
+		}
+
+		q.release()
+		wg.Wait()
+		if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+			t.Errorf("served order = %v, want [0 1 2]", order)
+		}
+	})
+
+	t.Run("times out in the queue", func(t *testing.T) {
+		q := newDispatchQueue(1, 10, 20*time.Millisecond)
+		q.acquire(context.Background())
+
+		start := time.Now()
+		err := q.acquire(context.Background())
+		if !errors.Is(err, errQueueTimeout) {
+			t.Fatalf("acquire() error = %v, want errQueueTimeout", err)
+		}
+		if waited := time.Since(start); waited < 20*time.Millisecond {
+			t.Errorf("gave up after %v, want at least 20ms", waited)
+		}
+		waitForWaiters(t, q, 0)
+	})
+
+	t.Run("rejects when the queue is full", func(t *testing.T) {
+		q := newDispatchQueue(1, 1, time.Second)
+		q.acquire(context.Background())
+		go q.acquire(context.Background())
+		waitForWaiters(t, q, 1)
+
+		if err := q.acquire(context.Background()); !errors.Is(err, errQueueFull) {
+			t.Errorf("acquire() error = %v, want errQueueFull", err)
+		}
+	})
+
+	t.Run("cancelled waiter leaves the queue", func(t *testing.T) {
+		q := newDispatchQueue(1, 10, time.Second)
+		q.acquire(context.Background())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- q.acquire(ctx) }()
+		waitForWaiters(t, q, 1)
+		cancel()
+
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("acquire() error = %v, want context.Canceled", err)
+		}
+		waitForWaiters(t, q, 0)
+	})
+
+	t.Run("nil queue is unlimited", func(t *testing.T) {
+		q := newDispatchQueue(0, 0, 0)
+		for i := 0; i < 100; i++ {
+			if err := q.acquire(context.Background()); err != nil {
+				t.Fatalf("acquire() error = %v", err)
+			}
+		}
+		q.release()
+	})
+}
+
+func TestQueueFullReturns429(t *testing.T) {
+	release := make(chan struct{})
+	backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		replyJSON(http.StatusOK, chatCompletion)(w, r)
+	})
+	cfg := testConfig(backend)
+	cfg.Server.MaxConcurrentRequests = 1
+	cfg.Server.MaxQueueDepth = 1
+	h := NewProxyHandler(cfg, discardLogger())
+
+	// One request holds the only slot and one waits in the queue
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+		}()
+	}
+	waitForWaiters(t, h.queue, 1)
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+	close(release)
+	wg.Wait()
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header missing")
+	}
+	if code := decodeError(t, rec)["code"]; code != "queue_unavailable" {
+		t.Errorf("error code = %v, want queue_unavailable", code)
+	}
+	if n := len(backend.received()); n != 2 {
+		t.Errorf("backend received %d requests, want 2", n)
+	}
+}
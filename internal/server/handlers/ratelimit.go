@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// backendRateLimitPrefix marks the backend rate-limit headers relayed to
+// clients, such as x-ratelimit-remaining-requests (in canonical form)
+const backendRateLimitPrefix = "X-Ratelimit-"
+
+// Headers reporting the router's dispatch limit when max_concurrent_requests is set
+const (
+	headerConcurrencyLimit     = "X-Router-Concurrency-Limit"
+	headerConcurrencyRemaining = "X-Router-Concurrency-Remaining"
+)
+
+// copyRateLimitHeaders relays the backend's rate-limit headers, and its
+// Retry-After, so clients can throttle themselves
+func copyRateLimitHeaders(dst, src http.Header) {
+	for name, values := range src {
+		if strings.HasPrefix(name, backendRateLimitPrefix) || name == "Retry-After" {
+			dst[name] = append([]string(nil), values...)
+		}
+	}
+}
+
+// setHeaders reports the dispatch limit and the slots still free. It does
+// nothing when the limit is disabled.
+func (q *dispatchQueue) setHeaders(h http.Header) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	remaining := q.limit - q.inflight
+	q.mu.Unlock()
+
+	h.Set(headerConcurrencyLimit, strconv.Itoa(q.limit))
+	h.Set(headerConcurrencyRemaining, strconv.Itoa(max(remaining, 0)))
+}
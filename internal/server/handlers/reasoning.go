@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+)
+
+// reasoningContentInclude is the include value asking streams for the
+// backend's detailed reasoning, as well as its summary
+const reasoningContentInclude = "reasoning.content"
+
+type reasoningContentKey struct{}
+
+// withReasoningContent marks the request context when its detailed
+// reasoning is to be streamed: codex.forward_reasoning_content allows it and
+// the request's include asks for it
+func (h *ProxyHandler) withReasoningContent(ctx context.Context, req map[string]interface{}) context.Context {
+	if !h.cfg.Codex.ForwardReasoningContent {
+		return ctx
+	}
+	include, _ := req["include"].([]interface{})
+	if !slices.Contains(include, interface{}(reasoningContentInclude)) {
+		return ctx
+	}
+	return context.WithValue(ctx, reasoningContentKey{}, true)
+}
+
+// reasoningContentRequested reports whether withReasoningContent marked ctx
+func reasoningContentRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(reasoningContentKey{}).(bool)
+	return requested
+}
+
+// reasoningStream relays a backend's reasoning as one Responses API
+// reasoning item: reasoning_summary deltas as response.reasoning_summary_text
+// events and, when content is set, reasoning_content deltas as
+// response.reasoning_text events. The item precedes the answer, so it is
+// closed once the message or a tool call starts and later reasoning is
+// dropped.
+type reasoningStream struct {
+	events  *eventStream
+	itemID  string
+	content bool // Relay the detailed reasoning
+
+	opened      bool
+	closed      bool
+	outputIndex int
+	summary     string
+	detail      string
+	item        map[string]interface{} // The finished item, once closed
+}
+
+// newReasoningStream creates the reasoning relay for one stream
+func (h *ProxyHandler) newReasoningStream(events *eventStream, content bool) *reasoningStream {
+	return &reasoningStream{events: events, itemID: h.newID("rs_"), content: content}
+}
+
+// write relays the reasoning of one delta, opening the item at the next
+// output index if this is the first
+func (s *reasoningStream) write(summary, detail string, nextOutputIndex *int) {
+	if !s.content {
+		detail = ""
+	}
+	if s.closed || (summary == "" && detail == "") {
+		return
+	}
+
+	if !s.opened {
+		s.outputIndex = *nextOutputIndex
+		*nextOutputIndex++
+		s.events.emit(map[string]interface{}{
+			"type":         "response.output_item.added",
+			"output_index": s.outputIndex,
+			"item": map[string]interface{}{
+				"id":      s.itemID,
+				"type":    "reasoning",
+				"status":  "in_progress",
+				"summary": []interface{}{},
+			},
+		})
+		s.opened = true
+	}
+
+	if summary != "" {
+		if s.summary == "" {
+			s.events.emit(map[string]interface{}{
+				"type":          "response.reasoning_summary_part.added",
+				"item_id":       s.itemID,
+				"output_index":  s.outputIndex,
+				"summary_index": 0,
+				"part":          map[string]interface{}{"type": "summary_text", "text": ""},
+			})
+		}
+		s.summary += summary
+		s.events.emit(map[string]interface{}{
+			"type":          "response.reasoning_summary_text.delta",
+			"item_id":       s.itemID,
+			"output_index":  s.outputIndex,
+			"summary_index": 0,
+			"delta":         summary,
+		})
+	}
+
+	if detail != "" {
+		s.detail += detail
+		s.events.emit(map[string]interface{}{
+			"type":          "response.reasoning_text.delta",
+			"item_id":       s.itemID,
+			"output_index":  s.outputIndex,
+			"content_index": 0,
+			"delta":         detail,
+		})
+	}
+}
+
+// close emits the done events of an open item. Only the first call counts.
+func (s *reasoningStream) close(status string) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if !s.opened {
+		return
+	}
+
+	summaryParts := []interface{}{}
+	if s.summary != "" {
+		part := map[string]interface{}{"type": "summary_text", "text": s.summary}
+		summaryParts = append(summaryParts, part)
+		s.events.emit(map[string]interface{}{
+			"type":          "response.reasoning_summary_text.done",
+			"item_id":       s.itemID,
+			"output_index":  s.outputIndex,
+			"summary_index": 0,
+			"text":          s.summary,
+		})
+		s.events.emit(map[string]interface{}{
+			"type":          "response.reasoning_summary_part.done",
+			"item_id":       s.itemID,
+			"output_index":  s.outputIndex,
+			"summary_index": 0,
+			"part":          part,
+		})
+	}
+
+	s.item = map[string]interface{}{
+		"id":      s.itemID,
+		"type":    "reasoning",
+		"status":  status,
+		"summary": summaryParts,
+	}
+	if s.detail != "" {
+		s.events.emit(map[string]interface{}{
+			"type":          "response.reasoning_text.done",
+			"item_id":       s.itemID,
+			"output_index":  s.outputIndex,
+			"content_index": 0,
+			"text":          s.detail,
+		})
+		s.item["content"] = []interface{}{
+			map[string]interface{}{"type": "reasoning_text", "text": s.detail},
+		}
+	}
+
+	s.events.emit(map[string]interface{}{
+		"type":         "response.output_item.done",
+		"output_index": s.outputIndex,
+		"item":         s.item,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/plasmadev/codex-api-router/internal/providers"
+)
+
+// doBackend sends a backend request to the provider it was routed to,
+// retrying connection failures and retryable statuses per the provider's
+// max_retries, retry_delay and retry_max_elapsed. Each attempt starts at the
+// provider endpoint that last answered and fails over to its base_urls on a
+// connection failure. Each attempt sends a fresh copy of the request body.
+//
+// When the retries are exhausted and providers.fallback is enabled, the
+// request is reshaped for the provider SelectFallback picks and sent there
+// with that provider's retry settings, and the request's route is updated
+// to it.
+func (h *ProxyHandler) doBackend(backendReq *http.Request) (*http.Response, error) {
+	ctx := backendReq.Context()
+	target := h.routedBackend(ctx)
+	resp, err := h.sendWithRetries(target, backendReq)
+
+	rt := routeFromContext(ctx)
+	if rt == nil || rt.req == nil || target.provider == nil || !h.cfg.Providers.Fallback.Enabled || ctx.Err() != nil {
+		return resp, err
+	}
+	if err == nil && !retryableStatus(resp.StatusCode) {
+		return resp, nil
+	}
+
+	provider, ferr := h.providers.SelectFallback(ctx, target.provider, rt.model)
+	if ferr != nil {
+		h.logger.Debug("no fallback provider", "provider", target.name, "error", ferr)
+		return resp, err
+	}
+	fallback := h.backendFor(provider)
+	if fallback == nil {
+		return resp, err
+	}
+	fallbackReq, ferr := h.fallbackRequest(backendReq, rt.req, fallback)
+	if ferr != nil {
+		h.logger.Warn("fallback provider can't serve the request", "provider", target.name, "fallback", fallback.name, "error", ferr)
+		return resp, err
+	}
+
+	h.logger.Warn("backend failed, falling back to another provider", "provider", target.name, "fallback", fallback.name)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	rt.backend = fallback
+	return h.sendWithRetries(fallback, fallbackReq)
+}
+
+// fallbackRequest returns a copy of backendReq carrying req, the Responses
+// API request behind it, shaped for the fallback backend as it was for the
+// first: checked against its capabilities, transformed with its settings and
+// renamed to its field names
+func (h *ProxyHandler) fallbackRequest(backendReq *http.Request, req map[string]interface{}, fallback *backend) (*http.Request, error) {
+	if err := h.validateCapabilities(req, fallback); err != nil {
+		return nil, err
+	}
+	chatReq := h.transformRequest(req, fallback)
+	if _, err := h.stitchConversation(req, chatReq); err != nil {
+		return nil, err
+	}
+	if err := h.applyTruncation(req, chatReq); err != nil {
+		return nil, err
+	}
+	h.remapRequest(chatReq, fallback)
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackReq := backendReq.Clone(backendReq.Context())
+	fallbackReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	fallbackReq.Body, _ = fallbackReq.GetBody()
+	fallbackReq.ContentLength = int64(len(body))
+	return fallbackReq, nil
+}
+
+// sendWithRetries sends a backend request to b per b's retry policy
+func (h *ProxyHandler) sendWithRetries(b *backend, backendReq *http.Request) (*http.Response, error) {
+	policy := providers.RetryPolicy{
+		MaxRetries: b.config.MaxRetries,
+		Delay:      b.config.RetryDelay,
+		MaxElapsed: b.config.RetryMaxElapsed,
+	}
+	if backendReq.GetBody == nil {
+		policy.MaxRetries = 0
+	}
+
+	attempt := 0
+	return policy.Do(backendReq.Context(), func() (*http.Response, error) {
+		if attempt > 0 {
+			h.logger.Warn("retrying backend request", "provider", b.name, "attempt", attempt+1)
+		}
+		attempt++
+		return h.send(b, backendReq)
+	})
+}
+
+// send makes one attempt at a backend request on b, failing over between
+// its endpoints
+func (h *ProxyHandler) send(b *backend, backendReq *http.Request) (*http.Response, error) {
+	var model string
+	if rt := routeFromContext(backendReq.Context()); rt != nil {
+		model = rt.model
+	}
+	return b.endpoints.Do(backendReq.Context(), b.client, func(baseURL string) (*http.Request, error) {
+		return b.request(backendReq, baseURL, model, h.cfg.Providers.PassthroughAuth)
+	})
+}
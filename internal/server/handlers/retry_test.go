@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestDoBackendRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		maxElapsed time.Duration
+		statuses   []int // Backend status per attempt; the last repeats
+		wantCalls  int
+		wantStatus int
+	}{
+		{"success", 3, 0, []int{200}, 1, 200},
+		{"client error not retried", 3, 0, []int{400}, 1, 400},
+		{"retried until success", 3, 0, []int{503, 502, 200}, 3, 200},
+		{"retries exhausted", 2, 0, []int{500}, 3, 500},
+		{"no retries", 0, 0, []int{503}, 1, 503},
+		{"elapsed budget stops retries", 5, 50 * time.Millisecond, []int{503}, 1, 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			backend := newTestBackend(t, func(w http.ResponseWriter, r *http.Request) {
+				n := int(calls.Add(1)) - 1
+				status := tt.statuses[min(n, len(tt.statuses)-1)]
+				body := chatCompletion
+				if status != http.StatusOK {
+					body = `{"error":{"message":"backend error"}}`
+				}
+				replyJSON(status, body)(w, r)
+			})
+
+			cfg := testConfig(backend)
+			cfg.Providers.Zai.MaxRetries = tt.maxRetries
+			cfg.Providers.Zai.RetryDelay = 100 * time.Millisecond
+			cfg.Providers.Zai.RetryMaxElapsed = tt.maxElapsed
+			if tt.maxElapsed == 0 {
+				cfg.Providers.Zai.RetryDelay = time.Millisecond
+			}
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+			if got := int(calls.Load()); got != tt.wantCalls {
+				t.Errorf("backend attempts = %d, want %d", got, tt.wantCalls)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			// Every attempt carries the full request
+			for i, call := range backend.received() {
+				if call.body["model"] != "glm-5" {
+					t.Errorf("attempt %d body = %v", i+1, call.body)
+				}
+			}
+		})
+	}
+}
+
+func TestDoBackendFallback(t *testing.T) {
+	const body = `{"model":"glm-5","input":"hi","instructions":"x","max_output_tokens":100,"prompt_cache_key":"k"}`
+
+	// chatCalls returns the chat requests b received, leaving out health probes
+	chatCalls := func(b *testBackend) []backendCall {
+		var calls []backendCall
+		for _, call := range b.received() {
+			if strings.HasSuffix(call.path, "/chat/completions") {
+				calls = append(calls, call)
+			}
+		}
+		return calls
+	}
+
+	tests := []struct {
+		name     string
+		fallback func(cfg *config.Config, url string)
+		wantPath string
+		wantAuth [2]string // Header name and value authenticating the fallback request
+	}{
+		{
+			name: "openai",
+			fallback: func(cfg *config.Config, url string) {
+				cfg.Providers.OpenAI.Enabled = true
+				cfg.Providers.OpenAI.APIKey = "openai-key"
+				cfg.Providers.OpenAI.BaseURL = url
+				cfg.Providers.OpenAI.Models = []string{"glm-5"}
+				cfg.Providers.OpenAI.MaxRetries = 0
+				cfg.Providers.OpenAI.InstructionsTemplate = "fallback {{.Instructions}}"
+				cfg.Providers.OpenAI.FieldMapping.Request = map[string]string{"max_tokens": "max_completion_tokens"}
+			},
+			wantPath: "/chat/completions",
+			wantAuth: [2]string{"Authorization", "Bearer openai-key"},
+		},
+		{
+			name: "azure",
+			fallback: func(cfg *config.Config, url string) {
+				cfg.Providers.Azure = config.ProviderConfig{
+					Enabled:              true,
+					Type:                 "azure",
+					Priority:             2,
+					APIKey:               "azure-key",
+					BaseURL:              url,
+					Models:               []string{"glm-5"},
+					InstructionsTemplate: "fallback {{.Instructions}}",
+					FieldMapping:         config.FieldMapping{Request: map[string]string{"max_tokens": "max_completion_tokens"}},
+					Azure:                config.AzureConfig{Resource: "res", Deployments: map[string]string{"glm-5": "glm-prod"}},
+				}
+			},
+			wantPath: "/openai/deployments/glm-prod/chat/completions",
+			wantAuth: [2]string{"api-key", "azure-key"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zai := newTestBackend(t, replyJSON(http.StatusServiceUnavailable, `{"error":{"message":"overloaded"}}`))
+			fallback := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+			cfg := testConfig(zai)
+			cfg.Providers.Zai.MaxRetries = 0
+			cfg.Providers.Zai.InstructionsTemplate = "zai {{.Instructions}}"
+			tt.fallback(cfg, fallback.URL)
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, body, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 from the fallback; body %s", rec.Code, rec.Body)
+			}
+			if n := len(chatCalls(zai)); n != 1 {
+				t.Errorf("z.ai received %d requests, want 1", n)
+			}
+			calls := chatCalls(fallback)
+			if len(calls) != 1 {
+				t.Fatalf("fallback received %d requests, want 1", len(calls))
+			}
+			call := calls[0]
+
+			if call.path != tt.wantPath {
+				t.Errorf("path = %s, want %s", call.path, tt.wantPath)
+			}
+			if got := call.header.Get(tt.wantAuth[0]); got != tt.wantAuth[1] {
+				t.Errorf("%s = %q, want %q", tt.wantAuth[0], got, tt.wantAuth[1])
+			}
+			if tt.wantAuth[0] != "Authorization" && call.header.Get("Authorization") != "" {
+				t.Errorf("Authorization = %q sent to azure", call.header.Get("Authorization"))
+			}
+
+			// Shaped with the fallback's settings, not z.ai's
+			if call.body["max_completion_tokens"] != float64(100) {
+				t.Errorf("max_completion_tokens = %v, want the fallback's field mapping applied", call.body["max_completion_tokens"])
+			}
+			if call.body["prompt_cache_key"] != "k" {
+				t.Errorf("prompt_cache_key = %v, want it forwarded to a provider supporting it", call.body["prompt_cache_key"])
+			}
+			messages, _ := call.body["messages"].([]interface{})
+			if len(messages) == 0 || messages[0].(map[string]interface{})["content"] != "fallback x" {
+				t.Errorf("messages = %v, want the fallback's instructions template", messages)
+			}
+		})
+	}
+}
+
+func TestDoBackendFallbackCapabilities(t *testing.T) {
+	zai := newTestBackend(t, replyJSON(http.StatusServiceUnavailable, `{"error":{"message":"overloaded"}}`))
+	openai := newTestBackend(t, replyJSON(http.StatusOK, chatCompletion))
+	cfg := testConfig(zai)
+	cfg.Providers.Zai.MaxRetries = 0
+	cfg.Providers.OpenAI.Enabled = true
+	cfg.Providers.OpenAI.APIKey = "openai-key"
+	cfg.Providers.OpenAI.BaseURL = openai.URL
+	cfg.Providers.OpenAI.Models = []string{"glm-5"}
+	cfg.Providers.OpenAI.Capabilities.SupportsTools = boolPtr(false)
+	h := NewProxyHandler(cfg, discardLogger())
+
+	rec := postResponses(h, `{"model":"glm-5","input":"hi","tools":[{"type":"function","name":"f"}]}`, nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want z.ai's 503; body %s", rec.Code, rec.Body)
+	}
+	for _, call := range openai.received() {
+		if strings.HasSuffix(call.path, "/chat/completions") {
+			t.Fatal("request with tools sent to a fallback provider without tool support")
+		}
+	}
+}
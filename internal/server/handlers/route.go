@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/providers"
+)
+
+// RouteDecision explains how the server would route a request for a model
+type RouteDecision struct {
+	Model        string `json:"model" yaml:"model"`                         // Model named by the client
+	BackendModel string `json:"backend_model" yaml:"backend_model"`         // Model sent to the backend
+	Mapped       bool   `json:"mapped" yaml:"mapped"`                       // providers.model_mapping applied
+	Provider     string `json:"provider" yaml:"provider"`                   // Provider serving the request
+	Pattern      string `json:"pattern,omitempty" yaml:"pattern,omitempty"` // Entry of the provider's models list that matched
+	Fallback     bool   `json:"fallback" yaml:"fallback"`                   // No models entry matched; the provider serves it as the default backend
+	Reason       string `json:"reason" yaml:"reason"`
+}
+
+// RouteModel applies the server's model mapping and provider selection to a
+// model without sending a request
+func RouteModel(cfg *config.Config, model string) RouteDecision {
+	h := &ProxyHandler{cfg: cfg}
+	if !cfg.Providers.Mock.Enabled {
+		h.providers = providers.NewFactory()
+		h.providers.SetDefaultForUnmatched(cfg.Providers.DefaultForUnmatchedModels)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		// Providers that fail to initialize are left out, as when serving
+		_ = registerProviders(h.providers, cfg)
+		h.backends = newBackends(cfg, h.providers, nil, logger)
+		defer h.providers.ShutdownAll(context.Background())
+	}
+
+	decision := RouteDecision{
+		Model:        model,
+		BackendModel: h.mapModel(model),
+	}
+	decision.Mapped = decision.BackendModel != model
+
+	if cfg.Providers.Mock.Enabled {
+		decision.Provider = "mock"
+		decision.Reason = "providers.mock.enabled: every request is answered by the mock provider"
+		return decision
+	}
+
+	decision.Provider = h.resolveBackend(context.Background(), decision.BackendModel, nil).name
+	models := providers.DefaultZaiModels
+	if provider, err := h.providers.GetProvider(decision.Provider); err == nil {
+		models = provider.GetModels()
+	}
+	for _, pattern := range models {
+		if providers.MatchModelPattern(pattern, decision.BackendModel) {
+			decision.Pattern = pattern
+			decision.Reason = fmt.Sprintf("%s matches providers.%s.models entry %q", decision.BackendModel, decision.Provider, pattern)
+			return decision
+		}
+	}
+
+	decision.Fallback = true
+	decision.Reason = fmt.Sprintf("no provider's models entry matches %s; it is sent to %s as the default backend", decision.BackendModel, decision.Provider)
+	return decision
+}
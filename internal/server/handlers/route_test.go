@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestRouteModel(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config.Config)
+		model  string
+		want   RouteDecision
+	}{
+		{
+			name:  "mapped",
+			model: "gpt-5.2-codex",
+			want:  RouteDecision{Model: "gpt-5.2-codex", BackendModel: "glm-5", Mapped: true, Provider: "zai", Pattern: "glm-5"},
+		},
+		{
+			name:   "default models",
+			modify: func(c *config.Config) { c.Providers.Zai.Models = nil },
+			model:  "glm-4.6",
+			want:   RouteDecision{Model: "glm-4.6", BackendModel: "glm-4.6", Provider: "zai", Pattern: "glm-*"},
+		},
+		{
+			name:   "configured models",
+			modify: func(c *config.Config) { c.Providers.Zai.Models = []string{"qwen-*"} },
+			model:  "qwen-max",
+			want:   RouteDecision{Model: "qwen-max", BackendModel: "qwen-max", Provider: "zai", Pattern: "qwen-*"},
+		},
+		{
+			name:  "unmatched",
+			model: "llama-3",
+			want:  RouteDecision{Model: "llama-3", BackendModel: "llama-3", Provider: "zai", Fallback: true},
+		},
+		{
+			name:   "mock",
+			modify: func(c *config.Config) { c.Providers.Mock.Enabled = true },
+			model:  "glm-5",
+			want:   RouteDecision{Model: "glm-5", BackendModel: "glm-5", Provider: "mock"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			if tt.modify != nil {
+				tt.modify(cfg)
+			}
+
+			got := RouteModel(cfg, tt.model)
+			if got.Reason == "" {
+				t.Error("decision has no reason")
+			}
+			got.Reason = ""
+			if got != tt.want {
+				t.Errorf("RouteModel() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
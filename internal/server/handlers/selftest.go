@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// SelfTestResult is the outcome of one self-test fixture
+type SelfTestResult struct {
+	Name  string `json:"name" yaml:"name"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"` // Empty when the fixture passed
+}
+
+// selfTestFixture is a canned request run through the transform pipeline
+// against the mock provider
+type selfTestFixture struct {
+	name  string
+	body  string
+	mock  config.MockConfig
+	check func(body []byte) error
+}
+
+var selfTestFixtures = []selfTestFixture{
+	{
+		name:  "string input",
+		body:  `{"model":"gpt-5.2-codex","input":"Say hello"}`,
+		mock:  config.MockConfig{Text: "Hello from the self-test"},
+		check: checkResponseShape(hasMessage),
+	},
+	{
+		name: "tool call",
+		body: `{"model":"gpt-5.2-codex","input":"What's the weather?","tools":[{"type":"function","name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}]}`,
+		mock: config.MockConfig{
+			ToolCalls: []config.MockToolCall{{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		},
+		check: checkResponseShape(hasToolCall),
+	},
+	{
+		name:  "image input",
+		body:  `{"model":"gpt-5.2-codex","input":[{"type":"message","role":"user","content":[{"type":"input_text","text":"Describe this"},{"type":"input_image","image_url":"data:image/png;base64,iVBORw0KGgo="}]}]}`,
+		mock:  config.MockConfig{Text: "A tiny image"},
+		check: checkResponseShape(hasMessage),
+	},
+	{
+		name:  "streaming",
+		body:  `{"model":"gpt-5.2-codex","input":"Stream a reply","stream":true}`,
+		mock:  config.MockConfig{Text: "Streaming from the self-test"},
+		check: checkStreamShape,
+	},
+}
+
+// SelfTest runs canned requests through the request and response transforms
+// using the mock provider and checks the output is valid Responses API. The
+// configuration's model mapping and transform options are used; the backend,
+// sessions and limits are not.
+func SelfTest(cfg *config.Config, logger *slog.Logger) []SelfTestResult {
+	results := make([]SelfTestResult, 0, len(selfTestFixtures))
+
+	for _, fixture := range selfTestFixtures {
+		testCfg := *cfg
+		testCfg.Providers.Mock = fixture.mock
+		testCfg.Providers.Mock.Enabled = true
+		testCfg.Providers.Zai.Capabilities = config.CapabilitiesConfig{}
+		testCfg.Providers.ContextBudget = 0
+		testCfg.Session.Enabled = false
+		testCfg.Server.MaxConcurrentRequests = 0
+
+		handler := NewProxyHandler(&testCfg, logger)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(fixture.body))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rec, req)
+		handler.Shutdown(context.Background())
+
+		result := SelfTestResult{Name: fixture.name}
+		if rec.Code != http.StatusOK {
+			result.Error = fmt.Sprintf("status %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+		} else if err := fixture.check(rec.Body.Bytes()); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// checkResponseShape returns a check that a body is a Responses API object
+// whose output includes an item matching want
+func checkResponseShape(want func(item map[string]interface{}) bool) func([]byte) error {
+	return func(body []byte) error {
+		var resp struct {
+			ID     string                   `json:"id"`
+			Object string                   `json:"object"`
+			Status string                   `json:"status"`
+			Model  string                   `json:"model"`
+			Output []map[string]interface{} `json:"output"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("response is not JSON: %w", err)
+		}
+		if resp.Object != "response" || !strings.HasPrefix(resp.ID, "resp_") {
+			return fmt.Errorf("unexpected object %q with id %q", resp.Object, resp.ID)
+		}
+		if resp.Status == "" || resp.Model == "" {
+			return fmt.Errorf("response is missing status or model")
+		}
+
+		for _, item := range resp.Output {
+			if want(item) {
+				return nil
+			}
+		}
+		return fmt.Errorf("output has no matching item")
+	}
+}
+
+// hasMessage reports whether an output item is an assistant message with text
+func hasMessage(item map[string]interface{}) bool {
+	if item["type"] != "message" {
+		return false
+	}
+	content, _ := item["content"].([]interface{})
+	for _, part := range content {
+		if p, ok := part.(map[string]interface{}); ok && p["type"] == "output_text" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasToolCall reports whether an output item is or carries a named function
+// call, in either response schema
+func hasToolCall(item map[string]interface{}) bool {
+	if item["type"] == "function_call" {
+		name, _ := item["name"].(string)
+		return name != "" && item["call_id"] != nil
+	}
+	toolCalls, _ := item["tool_calls"].([]interface{})
+	for _, tc := range toolCalls {
+		call, _ := tc.(map[string]interface{})
+		fn, _ := call["function"].(map[string]interface{})
+		if name, _ := fn["name"].(string); name != "" && call["id"] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStreamShape checks a Responses API event stream: every event's data
+// matches its event name, if it has one, sequence numbers are contiguous
+// from zero, and the stream runs from response.created to response.completed
+func checkStreamShape(body []byte) error {
+	var types []string
+	eventName := ""
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			eventName = name
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+
+		var event struct {
+			Type           string `json:"type"`
+			SequenceNumber *int   `json:"sequence_number"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("event data is not JSON: %w", err)
+		}
+		if eventName != "" && event.Type != eventName {
+			return fmt.Errorf("event %q carries data of type %q", eventName, event.Type)
+		}
+		eventName = ""
+		if event.SequenceNumber == nil || *event.SequenceNumber != len(types) {
+			return fmt.Errorf("event %q is out of sequence", event.Type)
+		}
+		types = append(types, event.Type)
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return err
+	}
+
+	if len(types) == 0 || types[0] != "response.created" {
+		return fmt.Errorf("stream does not start with response.created")
+	}
+	for _, t := range types {
+		if t == "response.completed" {
+			return nil
+		}
+	}
+	return fmt.Errorf("stream has no response.completed event")
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestSelfTest(t *testing.T) {
+	results := SelfTest(config.Default(), discardLogger())
+	if len(results) != len(selfTestFixtures) {
+		t.Fatalf("got %d results, want %d", len(results), len(selfTestFixtures))
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			t.Errorf("fixture %q failed: %s", result.Name, result.Error)
+		}
+	}
+}
+
+func TestSelfTestChecks(t *testing.T) {
+	message := checkResponseShape(hasMessage)
+	toolCall := checkResponseShape(hasToolCall)
+
+	tests := []struct {
+		name    string
+		check   func([]byte) error
+		body    string
+		wantErr bool
+	}{
+		{"message", message, `{"id":"resp_1","object":"response","status":"completed","model":"m","output":[{"type":"message","content":[{"type":"output_text","text":"hi"}]}]}`, false},
+		{"not JSON", message, `not json`, true},
+		{"wrong object", message, `{"id":"resp_1","object":"chat.completion","status":"completed","model":"m"}`, true},
+		{"missing status", message, `{"id":"resp_1","object":"response","model":"m","output":[]}`, true},
+		{"no message", message, `{"id":"resp_1","object":"response","status":"completed","model":"m","output":[{"type":"reasoning"}]}`, true},
+		{"tool call", toolCall, `{"id":"resp_1","object":"response","status":"completed","model":"m","output":[{"type":"message","tool_calls":[{"id":"call_1","function":{"name":"f"}}]}]}`, false},
+		{"unnamed tool call", toolCall, `{"id":"resp_1","object":"response","status":"completed","model":"m","output":[{"type":"message","tool_calls":[{"id":"call_1","function":{}}]}]}`, true},
+		{"stream", checkStreamShape, "event: response.created\ndata: {\"type\":\"response.created\",\"sequence_number\":0}\n\nevent: response.completed\ndata: {\"type\":\"response.completed\",\"sequence_number\":1}\n\n", false},
+		{"stream gap", checkStreamShape, "event: response.created\ndata: {\"type\":\"response.created\",\"sequence_number\":0}\n\nevent: response.completed\ndata: {\"type\":\"response.completed\",\"sequence_number\":2}\n\n", true},
+		{"stream mismatched event", checkStreamShape, "event: response.created\ndata: {\"type\":\"response.completed\",\"sequence_number\":0}\n\n", true},
+		{"stream without event lines", checkStreamShape, "data: {\"type\":\"response.created\",\"sequence_number\":0}\n\ndata: {\"type\":\"response.completed\",\"sequence_number\":1}\n\n", false},
+		{"stream not completed", checkStreamShape, "event: response.created\ndata: {\"type\":\"response.created\",\"sequence_number\":0}\n\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Request headers honored when debug.synthetic_latency is enabled
+const (
+	syntheticDelayHeader      = "X-Codex-Delay"       // Wait before dispatching to the backend
+	syntheticTokenDelayHeader = "X-Codex-Token-Delay" // Wait before each streamed chunk after the first
+)
+
+// syntheticLatency is the delay a request asked for with the debug headers
+type syntheticLatency struct {
+	dispatch time.Duration
+	token    time.Duration
+}
+
+// parseSyntheticLatency reads the debug latency headers as Go durations
+// (e.g. 1500ms), capped at debug.max_synthetic_latency. The headers are
+// ignored unless debug.synthetic_latency is enabled.
+func (h *ProxyHandler) parseSyntheticLatency(r *http.Request) (syntheticLatency, error) {
+	var latency syntheticLatency
+	if !h.cfg.Debug.SyntheticLatency {
+		return latency, nil
+	}
+
+	for _, field := range []struct {
+		header string
+		delay  *time.Duration
+	}{
+		{syntheticDelayHeader, &latency.dispatch},
+		{syntheticTokenDelayHeader, &latency.token},
+	} {
+		header := field.header
+		value := r.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return latency, fmt.Errorf("%s must be a non-negative duration such as 500ms, got %q", header, value)
+		}
+		*field.delay = min(d, h.cfg.Debug.MaxSyntheticLatency)
+	}
+	return latency, nil
+}
+
+type syntheticLatencyKey struct{}
+
+// syntheticLatencyFromContext returns the latency stored on a request's
+// context once its headers are parsed, or none
+func syntheticLatencyFromContext(ctx context.Context) syntheticLatency {
+	latency, _ := ctx.Value(syntheticLatencyKey{}).(syntheticLatency)
+	return latency
+}
+
+// sleepContext waits for d, returning early with the context's error if it
+// is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delayedStream holds back each SSE data line after the first of a backend
+// stream by delay, so clients see tokens arrive slowly
+type delayedStream struct {
+	io.ReadCloser
+	ctx     context.Context
+	reader  *bufio.Reader
+	delay   time.Duration
+	pending []byte
+	seen    bool // A data line has been relayed
+}
+
+func newDelayedStream(ctx context.Context, body io.ReadCloser, delay time.Duration) io.ReadCloser {
+	if delay <= 0 {
+		return body
+	}
+	return &delayedStream{ReadCloser: body, ctx: ctx, reader: bufio.NewReader(body), delay: delay}
+}
+
+// Read returns the stream a line at a time, waiting before data lines
+func (s *delayedStream) Read(p []byte) (int, error) {
+	if len(s.pending) == 0 {
+		line, err := s.reader.ReadBytes('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+		if bytes.HasPrefix(line, []byte("data:")) {
+			if s.seen {
+				if err := sleepContext(s.ctx, s.delay); err != nil {
+					return 0, err
+				}
+			}
+			s.seen = true
+		}
+		s.pending = line
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
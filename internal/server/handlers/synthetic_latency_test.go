@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSyntheticLatency(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		header     map[string]string
+		wantStatus int
+		wantDelay  bool
+	}{
+		{"delay applied when enabled", true, map[string]string{"X-Codex-Delay": "200ms"}, http.StatusOK, true},
+		{"header ignored when disabled", false, map[string]string{"X-Codex-Delay": "200ms"}, http.StatusOK, false},
+		{"capped at max_synthetic_latency", true, map[string]string{"X-Codex-Delay": "1h"}, http.StatusOK, true},
+		{"invalid delay", true, map[string]string{"X-Codex-Delay": "soon"}, http.StatusBadRequest, false},
+		{"negative delay", true, map[string]string{"X-Codex-Delay": "-1s"}, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(newTestBackend(t, replyJSON(http.StatusOK, chatCompletion)))
+			cfg.Debug.SyntheticLatency = tt.enabled
+			cfg.Debug.MaxSyntheticLatency = delay
+			h := NewProxyHandler(cfg, discardLogger())
+
+			start := time.Now()
+			rec := postResponses(h, `{"model":"glm-5","input":"hi"}`, tt.header)
+			elapsed := time.Since(start)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if delayed := elapsed >= delay; delayed != tt.wantDelay {
+				t.Errorf("request took %v, want delayed by %v: %v", elapsed, delay, tt.wantDelay)
+			}
+			if elapsed > 5*delay {
+				t.Errorf("request took %v, past max_synthetic_latency", elapsed)
+			}
+		})
+	}
+}
+
+func TestSyntheticTokenDelay(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	backend := newTestBackend(t, replyStream(
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"a"}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{"content":"b"}}]}`,
+		`{"id":"c1","model":"glm-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	))
+
+	for _, enabled := range []bool{true, false} {
+		cfg := testConfig(backend)
+		cfg.Debug.SyntheticLatency = enabled
+		h := NewProxyHandler(cfg, discardLogger())
+
+		start := time.Now()
+		rec := postResponses(h, `{"model":"glm-5","input":"hi","stream":true}`, map[string]string{"X-Codex-Token-Delay": "100ms"})
+		elapsed := time.Since(start)
+
+		if eventOfType(streamEvents(t, rec.Body), "response.completed") == nil {
+			t.Fatalf("enabled %v: no response.completed", enabled)
+		}
+		// Three data lines after the first: two chunks and [DONE]
+		if delayed := elapsed >= 3*delay; delayed != enabled {
+			t.Errorf("enabled %v: stream took %v, want delayed %v", enabled, elapsed, enabled)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/server/middleware"
+)
+
+// tenantLabeler attributes requests to tenants for metrics.tenants, keeping
+// the number of distinct labels bounded. A nil tenantLabeler labels nothing.
+type tenantLabeler struct {
+	header    string
+	keyHeader string            // Header carrying the client API key
+	keys      map[string]string // Client API key -> tenant
+	max       int               // 0 disables the cap
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// newTenantLabeler returns the labeler configured by metrics.tenants, or nil
+// when tenant labels are off
+func newTenantLabeler(cfg *config.Config) *tenantLabeler {
+	tenants := cfg.Metrics.Tenants
+	if tenants.Header == "" && len(tenants.APIKeys) == 0 {
+		return nil
+	}
+	return &tenantLabeler{
+		header:    tenants.Header,
+		keyHeader: cfg.Codex.APIKeyHeader,
+		keys:      tenants.APIKeys,
+		max:       tenants.MaxTenants,
+		seen:      make(map[string]struct{}),
+	}
+}
+
+// label returns the tenant label of a request: the tenant header, else the
+// tenant of its API key, else unknown. Once max distinct tenants have been
+// seen, new ones are labeled unknown too.
+func (l *tenantLabeler) label(r *http.Request) string {
+	if l == nil {
+		return ""
+	}
+
+	tenant := ""
+	if l.header != "" {
+		tenant = strings.TrimSpace(r.Header.Get(l.header))
+	}
+	if tenant == "" && len(l.keys) > 0 {
+		tenant = l.keys[middleware.ClientCredential(r, l.keyHeader)]
+	}
+	if tenant == "" {
+		return config.TenantUnknown
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.seen[tenant]; !ok {
+		if l.max > 0 && len(l.seen) >= l.max {
+			return config.TenantUnknown
+		}
+		l.seen[tenant] = struct{}{}
+	}
+	return tenant
+}
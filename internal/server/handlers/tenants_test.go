@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestTenantLabel(t *testing.T) {
+	cfg := config.Default()
+	cfg.Metrics.Tenants = config.MetricsTenantsConfig{
+		Header:     "X-Tenant-Id",
+		APIKeys:    map[string]string{"key-a": "acme"},
+		MaxTenants: 2,
+	}
+	labeler := newTenantLabeler(cfg)
+
+	// Run in order: the cap counts the distinct tenants seen so far
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   string
+	}{
+		{"tenant header", map[string]string{"X-Tenant-Id": "globex"}, "globex"},
+		{"api key mapping", map[string]string{"Authorization": "Bearer key-a"}, "acme"},
+		{"header wins over api key", map[string]string{"X-Tenant-Id": "globex", "Authorization": "Bearer key-a"}, "globex"},
+		{"unmapped api key", map[string]string{"Authorization": "Bearer key-b"}, config.TenantUnknown},
+		{"no tenant", nil, config.TenantUnknown},
+		{"over max_tenants", map[string]string{"X-Tenant-Id": "initech"}, config.TenantUnknown},
+		{"seen tenant after the cap", map[string]string{"X-Tenant-Id": "globex"}, "globex"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		if got := labeler.label(req); got != tt.want {
+			t.Errorf("%s: label() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := newTenantLabeler(config.Default()).label(httptest.NewRequest(http.MethodPost, "/", nil)); got != "" {
+		t.Errorf("label() with metrics.tenants unset = %q, want no label", got)
+	}
+}
+
+// tenantRequestCount reads codex_router_requests_total for tenant from the
+// metrics endpoint, 0 until the tenant has a series
+func tenantRequestCount(t *testing.T, tenant string) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler(discardLogger())(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	prefix := `codex_router_requests_total{tenant="` + tenant + `"} `
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, prefix); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				t.Fatalf("metric %q: %v", line, err)
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func TestTenantMetricsLabel(t *testing.T) {
+	cfg := testConfig(newTestBackend(t, replyChat()))
+	cfg.Metrics.Tenants.Header = "X-Tenant-Id"
+	h := NewProxyHandler(cfg, discardLogger())
+	h.Events().Subscribe(MetricsCollector)
+
+	tenantBefore := tenantRequestCount(t, "tenant-metrics-test")
+	unknownBefore := tenantRequestCount(t, config.TenantUnknown)
+
+	postResponses(h, `{"model":"glm-5","input":"hi"}`, map[string]string{"X-Tenant-Id": "tenant-metrics-test"})
+	postResponses(h, `{"model":"glm-5","input":"hi"}`, map[string]string{"X-Tenant-Id": "tenant-metrics-test"})
+	postResponses(h, `{"model":"glm-5","input":"hi"}`, nil)
+
+	if got := tenantRequestCount(t, "tenant-metrics-test") - tenantBefore; got != 2 {
+		t.Errorf("tenant requests increased by %d, want 2", got)
+	}
+	if got := tenantRequestCount(t, config.TenantUnknown) - unknownBefore; got != 1 {
+		t.Errorf("unknown tenant requests increased by %d, want 1", got)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/providers"
+)
+
+// requiredToolInstruction is appended to the conversation when retrying a
+// request whose tool_choice "required" the backend ignored
+const requiredToolInstruction = "You must respond by calling one of the provided tools. Do not answer with text."
+
+// errToolChoiceIgnored means the backend answered with text although
+// tool_choice was "required"
+var errToolChoiceIgnored = errors.New("backend did not honor tool_choice \"required\": the response has no tool call")
+
+// requiresToolCall reports whether a Chat Completions request body sets
+// tool_choice "required"
+func requiresToolCall(chatReq map[string]interface{}) bool {
+	tc, _ := chatReq["tool_choice"].(string)
+	return tc == "required"
+}
+
+// hasToolCalls reports whether a Chat Completions response calls a tool
+func hasToolCalls(chatResp map[string]interface{}) bool {
+	choices, _ := chatResp["choices"].([]interface{})
+	for _, c := range choices {
+		choice, _ := c.(map[string]interface{})
+		message, _ := choice["message"].(map[string]interface{})
+		if calls, _ := message["tool_calls"].([]interface{}); len(calls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceToolChoice applies providers.enforce_tool_choice to a backend
+// response. It returns chatResp unchanged unless the request required a tool
+// call and got none; then, per the mode, it returns the response of one
+// retry with a stronger instruction, or errToolChoiceIgnored.
+func (h *ProxyHandler) enforceToolChoice(backendReq *http.Request, chatResp map[string]interface{}) (map[string]interface{}, error) {
+	mode := h.cfg.Providers.EnforceToolChoice
+	if mode == "" || mode == config.EnforceToolChoiceOff || hasToolCalls(chatResp) {
+		return chatResp, nil
+	}
+
+	chatReq, err := readChatRequest(backendReq)
+	if err != nil || !requiresToolCall(chatReq) {
+		return chatResp, nil
+	}
+
+	if mode == config.EnforceToolChoiceError {
+		return nil, errToolChoiceIgnored
+	}
+
+	h.logger.Warn("backend ignored tool_choice required, retrying with a stronger instruction")
+	messages, _ := chatReq["messages"].([]interface{})
+	chatReq["messages"] = append(messages, map[string]interface{}{
+		"role":    "system",
+		"content": requiredToolInstruction,
+	})
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	retryReq := backendReq.Clone(backendReq.Context())
+	retryReq.Body = io.NopCloser(bytes.NewReader(body))
+	retryReq.ContentLength = int64(len(body))
+	retryReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	target := h.routedBackend(backendReq.Context())
+	resp, err := h.send(target, retryReq)
+	if err != nil {
+		return nil, fmt.Errorf("tool_choice retry failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := providers.ReadResponse(resp.Body, target.config.MaxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("tool_choice retry failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tool_choice retry failed: backend returned status %d", resp.StatusCode)
+	}
+
+	var retried map[string]interface{}
+	if err := json.Unmarshal(respBody, &retried); err != nil {
+		transformErrorsResponse.Add(1)
+		return nil, fmt.Errorf("tool_choice retry failed: %w", err)
+	}
+	h.remapResponse(retried, target)
+
+	if !hasToolCalls(retried) {
+		return nil, errToolChoiceIgnored
+	}
+	return retried, nil
+}
+
+// readChatRequest decodes the body of a backend request without consuming it
+func readChatRequest(backendReq *http.Request) (map[string]interface{}, error) {
+	if backendReq.GetBody == nil {
+		return nil, errors.New("request body cannot be re-read")
+	}
+	body, err := backendReq.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var chatReq map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&chatReq); err != nil {
+		return nil, err
+	}
+	return chatReq, nil
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+const (
+	textCompletion     = `{"id":"c1","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"It is sunny."},"finish_reason":"stop"}]}`
+	toolCallCompletion = `{"id":"c2","model":"glm-5","choices":[{"index":0,"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`
+)
+
+// replySequence answers with each body in turn, repeating the last
+func replySequence(bodies ...string) http.HandlerFunc {
+	var n atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		i := min(int(n.Add(1))-1, len(bodies)-1)
+		replyJSON(http.StatusOK, bodies[i])(w, r)
+	}
+}
+
+func TestEnforceToolChoice(t *testing.T) {
+	const requiredTool = `{"model":"glm-5","input":"weather?","tools":[{"type":"function","name":"get_weather","parameters":{"type":"object"}}],"tool_choice":"required"}`
+
+	tests := []struct {
+		name    string
+		mode    string
+		replies []string
+		body    string
+		status  int
+		calls   int
+	}{
+		{"off returns the text", config.EnforceToolChoiceOff, []string{textCompletion}, requiredTool, http.StatusOK, 1},
+		{"error fails the request", config.EnforceToolChoiceError, []string{textCompletion}, requiredTool, http.StatusBadGateway, 1},
+		{"retry gets a tool call", config.EnforceToolChoiceRetry, []string{textCompletion, toolCallCompletion}, requiredTool, http.StatusOK, 2},
+		{"retry ignored again", config.EnforceToolChoiceRetry, []string{textCompletion}, requiredTool, http.StatusBadGateway, 2},
+		{"tool call needs no retry", config.EnforceToolChoiceRetry, []string{toolCallCompletion}, requiredTool, http.StatusOK, 1},
+		{"tool_choice auto is not enforced", config.EnforceToolChoiceError, []string{textCompletion}, `{"model":"glm-5","input":"weather?","tool_choice":"auto"}`, http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestBackend(t, replySequence(tt.replies...))
+			cfg := testConfig(backend)
+			cfg.Providers.EnforceToolChoice = tt.mode
+			h := NewProxyHandler(cfg, discardLogger())
+
+			rec := postResponses(h, tt.body, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body = %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusBadGateway {
+				if code := decodeError(t, rec)["code"]; code != "tool_choice_not_honored" {
+					t.Errorf("error code = %v, want tool_choice_not_honored", code)
+				}
+			}
+
+			calls := backend.received()
+			if len(calls) != tt.calls {
+				t.Fatalf("backend called %d times, want %d", len(calls), tt.calls)
+			}
+			if tt.calls == 2 {
+				messages, _ := calls[1].body["messages"].([]interface{})
+				last, _ := messages[len(messages)-1].(map[string]interface{})
+				if last["content"] != requiredToolInstruction {
+					t.Errorf("retry ends with %v, want the required tool instruction", last)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestListenKeepAlive(t *testing.T) {
+	tests := []struct {
+		name      string
+		keepAlive time.Duration
+		wantOn    bool
+		wantIdle  int // TCP_KEEPIDLE in seconds; 0 skips the check
+	}{
+		{"configured period", 45 * time.Second, true, 45},
+		{"OS default", 0, true, 0},
+		{"disabled", -1, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Server.TCPKeepAlive = tt.keepAlive
+			s := &Server{cfg: cfg}
+
+			ln, err := s.listen("127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen() error = %v", err)
+			}
+			defer ln.Close()
+
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer client.Close()
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Fatalf("Accept() error = %v", err)
+			}
+			defer conn.Close()
+
+			on, idle := keepAliveOptions(t, conn.(*net.TCPConn))
+			if on != tt.wantOn {
+				t.Errorf("SO_KEEPALIVE = %v, want %v", on, tt.wantOn)
+			}
+			if tt.wantIdle != 0 && idle != tt.wantIdle {
+				t.Errorf("TCP_KEEPIDLE = %ds, want %ds", idle, tt.wantIdle)
+			}
+		})
+	}
+}
+
+// keepAliveOptions reads whether keep-alive is on for conn and its idle time
+func keepAliveOptions(t *testing.T, conn *net.TCPConn) (on bool, idle int) {
+	t.Helper()
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatalf("SyscallConn() error = %v", err)
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		var keepAlive int
+		keepAlive, sockErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+		if sockErr != nil {
+			return
+		}
+		on = keepAlive != 0
+		idle, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+	})
+	if err != nil || sockErr != nil {
+		t.Fatalf("getsockopt: %v %v", err, sockErr)
+	}
+	return on, idle
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// ClientCredential returns the credential a client sent in header
+// (codex.api_key_header), without any auth scheme such as "Bearer". An empty
+// header name means Authorization.
+func ClientCredential(r *http.Request, header string) string {
+	if header == "" {
+		header = "Authorization"
+	}
+
+	value := strings.TrimSpace(r.Header.Get(header))
+	if scheme, credential, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(credential)
+	}
+	return value
+}
+
+// ClientAuth rejects requests whose credential in header does not match one
+// of keys. With no keys configured it is disabled and returns next unchanged.
+func ClientAuth(next http.Handler, logger *slog.Logger, header string, keys []string, errorFormat string) http.Handler {
+	if len(keys) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential := ClientCredential(r, header)
+		for _, key := range keys {
+			if credential != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(key)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		logger.Warn("unauthorized request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		WriteError(w, errorFormat, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Invalid or missing API key")
+	})
+}
+
+// ScrapeAuth rejects requests that carry neither the bearer token nor the
+// basic auth username and password. With no credentials configured it is
+// disabled and returns next unchanged.
+func ScrapeAuth(next http.Handler, logger *slog.Logger, token, username, password string) http.Handler {
+	if token == "" && username == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			if credential := ClientCredential(r, ""); credential != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if username != "" {
+			user, pass, ok := r.BasicAuth()
+			userOK := subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
+			if ok && userOK && passOK {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+		}
+
+		logger.Warn("unauthorized metrics request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestClientAuth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		header  string // codex.api_key_header
+		keys    []string
+		request map[string]string
+		want    int
+	}{
+		{"custom header", "X-Codex-Key", []string{"k1"}, map[string]string{"X-Codex-Key": "k1"}, http.StatusOK},
+		{"custom header with bearer scheme", "X-Codex-Key", []string{"k1"}, map[string]string{"X-Codex-Key": "Bearer k1"}, http.StatusOK},
+		{"key in Authorization is ignored for a custom header", "X-Codex-Key", []string{"k1"}, map[string]string{"Authorization": "Bearer k1"}, http.StatusUnauthorized},
+		{"wrong key", "X-Codex-Key", []string{"k1"}, map[string]string{"X-Codex-Key": "k2"}, http.StatusUnauthorized},
+		{"default header is Authorization", "", []string{"k1", "k2"}, map[string]string{"Authorization": "Bearer k2"}, http.StatusOK},
+		{"missing credential", "", []string{"k1"}, nil, http.StatusUnauthorized},
+		{"no keys disables auth", "X-Codex-Key", nil, nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ClientAuth(ok, logger, tt.header, tt.keys, config.ErrorFormatOpenAI)
+			req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+			for k, v := range tt.request {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeAuth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name     string
+		token    string
+		username string
+		password string
+		request  func(*http.Request)
+		want     int
+	}{
+		{"no credentials configured", "", "", "", func(*http.Request) {}, http.StatusOK},
+		{"missing credentials", "t1", "", "", func(*http.Request) {}, http.StatusUnauthorized},
+		{"bearer token", "t1", "", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t1") }, http.StatusOK},
+		{"wrong bearer token", "t1", "", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t2") }, http.StatusUnauthorized},
+		{"basic auth", "", "prom", "secret", func(r *http.Request) { r.SetBasicAuth("prom", "secret") }, http.StatusOK},
+		{"wrong password", "", "prom", "secret", func(r *http.Request) { r.SetBasicAuth("prom", "guess") }, http.StatusUnauthorized},
+		{"basic auth when both are configured", "t1", "prom", "secret", func(r *http.Request) { r.SetBasicAuth("prom", "secret") }, http.StatusOK},
+		{"bearer token when both are configured", "t1", "prom", "secret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t1") }, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ScrapeAuth(ok, logger, tt.token, tt.username, tt.password)
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			tt.request(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && tt.username != "" && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("no WWW-Authenticate challenge for basic auth")
+			}
+		})
+	}
+}
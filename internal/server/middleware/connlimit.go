@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Headers reporting a client's per-IP connection limit and the connections it has left
+const (
+	headerConnectionsLimit     = "X-Router-Connections-Limit"
+	headerConnectionsRemaining = "X-Router-Connections-Remaining"
+)
+
+// ConnectionLimit caps the requests in flight from each client IP at max,
+// answering any more with a 429. A streaming response counts until it ends.
+// With max 0 it is disabled and returns next unchanged.
+func ConnectionLimit(next http.Handler, logger *slog.Logger, max int, errorFormat string) http.Handler {
+	if max <= 0 {
+		return next
+	}
+
+	var mu sync.Mutex
+	active := make(map[string]int)
+
+	release := func(ip string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if active[ip]--; active[ip] <= 0 {
+			delete(active, ip)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := remoteIP(r)
+		w.Header().Set(headerConnectionsLimit, strconv.Itoa(max))
+
+		mu.Lock()
+		if active[ip] >= max {
+			mu.Unlock()
+			w.Header().Set(headerConnectionsRemaining, "0")
+			w.Header().Set("Retry-After", "1")
+			logger.Warn("too many connections from client", "remote_addr", r.RemoteAddr, "limit", max)
+			WriteError(w, errorFormat, http.StatusTooManyRequests, "rate_limit_error", "too_many_connections", "Too many concurrent connections from this client")
+			return
+		}
+		active[ip]++
+		remaining := max - active[ip]
+		mu.Unlock()
+
+		w.Header().Set(headerConnectionsRemaining, strconv.Itoa(remaining))
+
+		defer release(ip)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// remoteIP returns the IP address of the client connection
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConnectionLimit(t *testing.T) {
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hold" {
+			started.Done()
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := ConnectionLimit(next, slog.New(slog.NewTextHandler(io.Discard, nil)), 2, "openai")
+
+	serve := func(path, remoteAddr string) int {
+		code, _ := serveWithHeader(handler, path, remoteAddr)
+		return code
+	}
+
+	// Hold two requests open from one client
+	var held sync.WaitGroup
+	started.Add(2)
+	for i := 0; i < 2; i++ {
+		held.Add(1)
+		go func() {
+			defer held.Done()
+			serve("/hold", "10.0.0.1:4000")
+		}()
+	}
+	started.Wait()
+
+	if code := serve("/", "10.0.0.1:4001"); code != http.StatusTooManyRequests {
+		t.Errorf("third request from the client: status %d, want 429", code)
+	}
+	if code := serve("/", "10.0.0.2:4000"); code != http.StatusOK {
+		t.Errorf("request from another client: status %d, want 200", code)
+	}
+
+	// Closing the held requests frees the client's slots
+	close(release)
+	held.Wait()
+	if code := serve("/", "10.0.0.1:4002"); code != http.StatusOK {
+		t.Errorf("request after the others closed: status %d, want 200", code)
+	}
+}
+
+// serveWithHeader serves a GET of path from remoteAddr through handler
+func serveWithHeader(handler http.Handler, path, remoteAddr string) (int, http.Header) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, rec.Header()
+}
+
+func TestConnectionLimitHeaders(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hold" {
+			close(started)
+			<-release
+		}
+	})
+	handler := ConnectionLimit(next, slog.New(slog.NewTextHandler(io.Discard, nil)), 2, "openai")
+
+	_, header := serveWithHeader(handler, "/", "10.0.0.1:4000")
+	if header.Get("X-Router-Connections-Limit") != "2" || header.Get("X-Router-Connections-Remaining") != "1" {
+		t.Errorf("headers = %v, want limit 2 with 1 remaining", header)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveWithHeader(handler, "/hold", "10.0.0.1:4001")
+	}()
+	<-started
+
+	_, header = serveWithHeader(handler, "/", "10.0.0.1:4003")
+	if remaining := header.Get("X-Router-Connections-Remaining"); remaining != "0" {
+		t.Errorf("remaining with one held = %q, want 0", remaining)
+	}
+	close(release)
+	<-done
+}
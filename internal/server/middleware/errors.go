@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// WriteError writes a JSON error response in the envelope expected by
+// clients of the given format (server.error_format):
+//
+//	openai:    {"error": {"type": ..., "code": ..., "message": ...}}
+//	anthropic: {"type": "error", "error": {"type": ..., "message": ...}}
+func WriteError(w http.ResponseWriter, format string, status int, errType, code, message string) {
+	var body map[string]interface{}
+
+	switch format {
+	case config.ErrorFormatAnthropic:
+		body = map[string]interface{}{
+			"type": "error",
+			"error": map[string]interface{}{
+				"type":    AnthropicErrorType(status, errType),
+				"message": message,
+			},
+		}
+	default:
+		errBody := map[string]interface{}{
+			"type":    errType,
+			"message": message,
+		}
+		if code != "" {
+			errBody["code"] = code
+		}
+		body = map[string]interface{}{"error": errBody}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// AnthropicErrorType maps an error to the closest Anthropic error type, which
+// is keyed more on status than the OpenAI types are
+func AnthropicErrorType(status int, errType string) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	}
+
+	if errType == "invalid_request_error" {
+		return errType
+	}
+	return "api_error"
+}
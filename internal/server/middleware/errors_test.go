@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		status  int
+		errType string
+		code    string
+		want    string
+	}{
+		{
+			"openai", config.ErrorFormatOpenAI, http.StatusBadRequest, "invalid_request_error", "bad_field",
+			`{"error":{"code":"bad_field","message":"boom","type":"invalid_request_error"}}`,
+		},
+		{
+			"openai without a code", config.ErrorFormatOpenAI, http.StatusBadGateway, "api_error", "",
+			`{"error":{"message":"boom","type":"api_error"}}`,
+		},
+		{
+			"anthropic", config.ErrorFormatAnthropic, http.StatusBadRequest, "invalid_request_error", "bad_field",
+			`{"error":{"message":"boom","type":"invalid_request_error"},"type":"error"}`,
+		},
+		{
+			"anthropic type from status", config.ErrorFormatAnthropic, http.StatusTooManyRequests, "rate_limit_error", "",
+			`{"error":{"message":"boom","type":"rate_limit_error"},"type":"error"}`,
+		},
+		{
+			"anthropic server error", config.ErrorFormatAnthropic, http.StatusBadGateway, "upstream_error", "",
+			`{"error":{"message":"boom","type":"api_error"},"type":"error"}`,
+		},
+		{
+			"unset format is openai", "", http.StatusNotFound, "invalid_request_error", "",
+			`{"error":{"message":"boom","type":"invalid_request_error"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(rec, tt.format, tt.status, tt.errType, tt.code, "boom")
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			// Re-encode so key order doesn't matter
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q: %v", rec.Body, err)
+			}
+			got, _ := json.Marshal(body)
+			if string(got) != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,33 +1,176 @@
 package middleware
 
 import (
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
-// Recovery recovers from panics
-func Recovery(next http.Handler, logger *slog.Logger) http.Handler {
+// AnthropicStreamPrefix is the route prefix under which streaming responses
+// are emitted as Anthropic Messages events instead of Responses API events
+const AnthropicStreamPrefix = "/anthropic/"
+
+// Recovery recovers from panics, responding with an error in the given
+// envelope format. It should wrap every other middleware. A panic after an
+// event stream has started ends the stream with a terminal failure event, so
+// clients don't wait on a stream that will never finish; any other response
+// already under way can't be repaired, and its connection is aborted.
+func Recovery(next http.Handler, logger *slog.Logger, errorFormat string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryWriter{ResponseWriter: w}
 		defer func() {
-			if err := recover(); err != nil {
-				logger.Error("panic recovered",
-					"error", err,
-					"path", r.URL.Path,
-					"method", r.Method,
-				)
-				http.Error(w, `{"error":{"type":"internal_error","message":"Internal server error"}}`, http.StatusInternalServerError)
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			logger.Error("panic recovered",
+				"error", err,
+				"path", r.URL.Path,
+				"method", r.Method,
+				"headers_written", rw.wroteHeader,
+			)
+
+			switch {
+			case !rw.wroteHeader:
+				WriteError(w, errorFormat, http.StatusInternalServerError, "internal_error", "", "Internal server error")
+			case strings.HasPrefix(rw.Header().Get("Content-Type"), "text/event-stream"):
+				writeStreamFailure(rw, r, "Internal server error")
+			default:
+				panic(http.ErrAbortHandler)
 			}
 		}()
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(rw, r)
 	})
 }
 
-// RequestLogging logs each request
-func RequestLogging(next http.Handler, logger *slog.Logger) http.Handler {
+// recoveryWriter records whether the response has started, so Recovery
+// knows whether it can still send an error status, and how far a Responses
+// API event stream has got, so it can end one in the same form
+type recoveryWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+
+	// Set by TrackEvent
+	tracked    bool
+	nextSeq    int
+	eventLines bool
+}
+
+func (w *recoveryWriter) WriteHeader(status int) {
+	// Informational responses don't start the real one
+	if status >= http.StatusOK {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recoveryWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher for streaming support
+func (w *recoveryWriter) Flush() {
+	w.wroteHeader = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *recoveryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// TrackEvent implements StreamTracker
+func (w *recoveryWriter) TrackEvent(sequenceNumber int, eventLines bool) {
+	w.tracked = true
+	w.nextSeq = sequenceNumber + 1
+	w.eventLines = eventLines
+}
+
+// StreamTracker is implemented by the writer Recovery passes down. A handler
+// writing Responses API events reports each one, so a panic mid-stream ends
+// the stream with a response.failed event that continues its sequence
+// numbers and has an event: line only if the stream's events did.
+type StreamTracker interface {
+	TrackEvent(sequenceNumber int, eventLines bool)
+}
+
+// FindStreamTracker returns the StreamTracker among w and the writers it
+// wraps, or nil outside Recovery
+func FindStreamTracker(w http.ResponseWriter) StreamTracker {
+	for {
+		if tracker, ok := w.(StreamTracker); ok {
+			return tracker
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// writeStreamFailure ends a started event stream with a terminal failure
+// event: an Anthropic error event under AnthropicStreamPrefix, otherwise a
+// Responses API response.failed event numbered and named as the stream's
+// tracked events were
+func writeStreamFailure(w *recoveryWriter, r *http.Request, message string) {
+	eventType := "response.failed"
+	eventLines := !w.tracked || w.eventLines
+	event := map[string]interface{}{
+		"type":            eventType,
+		"sequence_number": w.nextSeq,
+		"response": map[string]interface{}{
+			"object": "response",
+			"status": "failed",
+			"output": []interface{}{},
+			"error": map[string]interface{}{
+				"code":    "internal_error",
+				"message": message,
+			},
+		},
+	}
+	if strings.HasPrefix(r.URL.Path, AnthropicStreamPrefix) {
+		eventType = "error"
+		eventLines = true
+		event = map[string]interface{}{
+			"type": eventType,
+			"error": map[string]interface{}{
+				"type":    "api_error",
+				"message": message,
+			},
+		}
+	}
+
+	eventData, _ := json.Marshal(event)
+	if eventLines {
+		fmt.Fprintf(w, "event: %s\n", eventType)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", eventData)
+	w.Flush()
+}
+
+// RequestLogging logs each request. Request headers are logged at debug level
+// with credentials and sensitiveHeaders redacted.
+func RequestLogging(next http.Handler, logger *slog.Logger, sensitiveHeaders []string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		if logger.Enabled(r.Context(), slog.LevelDebug) {
+			logger.Debug("request headers",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"headers", RedactHeaders(r.Header, sensitiveHeaders),
+			)
+		}
+
 		// Wrap response writer to capture status code
 		wrapped := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 
@@ -35,6 +178,7 @@ func RequestLogging(next http.Handler, logger *slog.Logger) http.Handler {
 
 		duration := time.Since(start)
 		logger.Info("request completed",
+			"request_id", RequestIDFromContext(r.Context()),
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", wrapped.status,
@@ -77,3 +221,7 @@ func (w *responseWriter) Flush() {
 		flusher.Flush()
 	}
 }
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestRecoveryMidStream(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: response.created\ndata: {}\n\n")
+		w.(http.Flusher).Flush()
+		panic("boom")
+	})
+	srv := httptest.NewServer(Recovery(CORS(panicking), logger, config.ErrorFormatOpenAI))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		wantEvent string
+	}{
+		{"responses stream", "/v1/responses", "event: response.failed\n"},
+		{"anthropic stream", AnthropicStreamPrefix + "v1/responses", "event: error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Timeout: 5 * time.Second}
+			resp, err := client.Post(srv.URL+tt.path, "application/json", strings.NewReader("{}"))
+			if err != nil {
+				t.Fatalf("POST: %v", err)
+			}
+			defer resp.Body.Close()
+
+			// The stream ends rather than hanging until the client times out
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			if !strings.HasPrefix(string(body), "event: response.created\n") {
+				t.Errorf("stream = %q, want the events sent before the panic", body)
+			}
+			if !strings.Contains(string(body), tt.wantEvent) {
+				t.Errorf("stream = %q, want a terminal %q", body, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestRecoveryMidStreamContinuesTrackedStream(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		tracker := FindStreamTracker(w)
+		if tracker == nil {
+			t.Error("no StreamTracker under Recovery")
+			return
+		}
+		for seq := range 2 {
+			fmt.Fprintf(w, "data: {\"type\":\"response.in_progress\",\"sequence_number\":%d}\n\n", seq)
+			tracker.TrackEvent(seq, false)
+		}
+		panic("boom")
+	})
+	handler := Recovery(RequestLogging(panicking, logger, nil), logger, config.ErrorFormatOpenAI)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/responses", nil))
+
+	body := rec.Body.String()
+	if strings.Contains(body, "event:") {
+		t.Errorf("stream = %q, want no event: lines as the stream had none", body)
+	}
+	chunks := strings.Split(strings.TrimSpace(body), "\n\n")
+	var last map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(chunks[len(chunks)-1], "data: ")), &last); err != nil {
+		t.Fatalf("last event %q: %v", chunks[len(chunks)-1], err)
+	}
+	if last["type"] != "response.failed" || last["sequence_number"] != float64(2) {
+		t.Errorf("last event = %v, want response.failed with sequence_number 2", last)
+	}
+}
+
+func TestRecoveryBeforeResponse(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), logger, config.ErrorFormatOpenAI)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/responses", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if !strings.Contains(rec.Body.String(), "internal_error") {
+		t.Errorf("body = %q, want an internal_error", rec.Body)
+	}
+}
+
+func TestRecoveryAbortsStartedResponse(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"partial":`)
+		panic("boom")
+	}), logger, config.ErrorFormatOpenAI)
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/responses", nil))
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultSensitiveHeaders are always redacted from logs
+var defaultSensitiveHeaders = []string{"Authorization", "X-Api-Key", "Proxy-Authorization", "Cookie"}
+
+// RedactHeaders returns a copy of headers suitable for logging, with the
+// default sensitive headers and any extra ones masked. Credentials that carry
+// an auth scheme keep it, e.g. "Bearer ***".
+func RedactHeaders(headers http.Header, extra []string) map[string]string {
+	sensitive := make(map[string]bool, len(defaultSensitiveHeaders)+len(extra))
+	for _, name := range defaultSensitiveHeaders {
+		sensitive[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range extra {
+		sensitive[http.CanonicalHeaderKey(name)] = true
+	}
+
+	redacted := make(map[string]string, len(headers))
+	for name, values := range headers {
+		value := strings.Join(values, ", ")
+		if sensitive[http.CanonicalHeaderKey(name)] {
+			value = maskCredential(value)
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// maskCredential hides a credential while keeping its auth scheme
+func maskCredential(value string) string {
+	if scheme, _, ok := strings.Cut(value, " "); ok && scheme != "" {
+		return scheme + " ***"
+	}
+	return "***"
+}
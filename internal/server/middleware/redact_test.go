@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer sk-secret-token")
+	headers.Set("X-Api-Key", "sk-other-secret")
+	headers.Set("X-Tenant-Token", "tenant-secret")
+	headers.Set("Content-Type", "application/json")
+
+	got := RedactHeaders(headers, []string{"x-tenant-token"})
+
+	want := map[string]string{
+		"Authorization":  "Bearer ***",
+		"X-Api-Key":      "***",
+		"X-Tenant-Token": "***",
+		"Content-Type":   "application/json",
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+	if headers.Get("Authorization") != "Bearer sk-secret-token" {
+		t.Error("RedactHeaders modified the request headers")
+	}
+}
+
+func TestRequestLoggingRedactsCredentials(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	handler := RequestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), logger, nil)
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	req.Header.Set("Authorization", "Bearer sk-secret-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(logs.String(), "sk-secret-token") {
+		t.Errorf("bearer token logged: %s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "Bearer ***") {
+		t.Errorf("masked Authorization header not logged: %s", logs.String())
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/plasmadev/codex-api-router/internal/ids"
+)
+
+// RequestIDHeader carries a request's ID. Clients may send their own to
+// correlate logs; every response carries the ID the request was handled under.
+const RequestIDHeader = "X-Request-Id"
+
+// validRequestID bounds the client IDs accepted, so they are safe to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request's ID, or "" outside RequestID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID assigns each request an ID: the client's X-Request-Id when it is
+// up to 128 letters, digits and ._:- characters, otherwise a req_ ID from gen,
+// or from ids.Default when gen is nil. The ID is stored in the request
+// context and echoed in the response.
+func RequestID(next http.Handler, logger *slog.Logger, gen ids.Generator) http.Handler {
+	if gen == nil {
+		gen = ids.Default
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id != "" && !validRequestID.MatchString(id) {
+			logger.Debug("ignoring malformed client request ID", "length", len(id))
+			id = ""
+		}
+		if id == "" {
+			id = gen.NewID("req_")
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fixedID generates the same ID every time
+type fixedID string
+
+func (id fixedID) NewID(prefix string) string {
+	return prefix + string(id)
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"generated without a client ID", "", "req_gen"},
+		{"client ID kept", "client-123", "client-123"},
+		{"client ID with ._: kept", "trace.abc_1:2", "trace.abc_1:2"},
+		{"longest client ID kept", strings.Repeat("a", 128), strings.Repeat("a", 128)},
+		{"too long replaced", strings.Repeat("a", 129), "req_gen"},
+		{"spaces replaced", "two words", "req_gen"},
+		{"log injection replaced", "id\nlevel=ERROR", "req_gen"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = RequestIDFromContext(r.Context())
+			})
+			handler := RequestID(next, slog.New(slog.NewTextHandler(io.Discard, nil)), fixedID("gen"))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if fromContext != tt.want {
+				t.Errorf("RequestIDFromContext() = %q, want %q", fromContext, tt.want)
+			}
+			if got := rec.Header().Get(RequestIDHeader); got != tt.want {
+				t.Errorf("response %s = %q, want %q", RequestIDHeader, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestIDDefaultGenerator(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	seen := make(map[string]bool)
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		id := rec.Header().Get(RequestIDHeader)
+		if !strings.HasPrefix(id, "req_") || seen[id] {
+			t.Errorf("%s = %q, want a new req_ ID", RequestIDHeader, id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRequestIDFromContextOutsideMiddleware(t *testing.T) {
+	if id := RequestIDFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); id != "" {
+		t.Errorf("RequestIDFromContext() = %q, want \"\"", id)
+	}
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler = RequestLogging(handler, logger, nil)
+	handler = RequestID(handler, logger, fixedID("gen"))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	req.Header.Set(RequestIDHeader, "client-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(logs.String(), `"request_id":"client-123"`) {
+		t.Errorf("client request ID not logged: %s", logs.String())
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type timingKey struct{}
+
+// Timing accumulates where a request spent its time. Handlers record into it
+// via TimingFromContext; it is safe for concurrent use so batch items sharing
+// a request context can report into the same breakdown.
+type Timing struct {
+	start     time.Time
+	queue     atomic.Int64
+	backend   atomic.Int64
+	transform atomic.Int64
+}
+
+// TimingFromContext returns the request's Timing, or nil when slow-request
+// logging is disabled. All Timing methods are no-ops on a nil receiver.
+func TimingFromContext(ctx context.Context) *Timing {
+	t, _ := ctx.Value(timingKey{}).(*Timing)
+	return t
+}
+
+// MarkDispatch records the time spent in the router before the first backend
+// call was made
+func (t *Timing) MarkDispatch() {
+	if t == nil {
+		return
+	}
+	t.queue.CompareAndSwap(0, int64(time.Since(t.start)))
+}
+
+// AddBackend adds time spent waiting on the backend
+func (t *Timing) AddBackend(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.backend.Add(int64(d))
+}
+
+// AddTransform adds time spent translating requests and responses
+func (t *Timing) AddTransform(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.transform.Add(int64(d))
+}
+
+// SlowRequestLogging logs requests whose total duration exceeds threshold at
+// warn level, with a breakdown of queue, backend and transform time. A
+// threshold of zero or less disables it.
+func SlowRequestLogging(next http.Handler, logger *slog.Logger, threshold time.Duration) http.Handler {
+	if threshold <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timing := &Timing{start: time.Now()}
+		r = r.WithContext(context.WithValue(r.Context(), timingKey{}, timing))
+
+		next.ServeHTTP(w, r)
+
+		duration := time.Since(timing.start)
+		if duration <= threshold {
+			return
+		}
+
+		logger.Warn("slow request",
+			"request_id", RequestIDFromContext(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"duration_ms", duration.Milliseconds(),
+			"threshold_ms", threshold.Milliseconds(),
+			"queue_ms", time.Duration(timing.queue.Load()).Milliseconds(),
+			"backend_ms", time.Duration(timing.backend.Load()).Milliseconds(),
+			"transform_ms", time.Duration(timing.transform.Load()).Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
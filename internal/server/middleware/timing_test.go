@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowRequestLogging(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		backend   time.Duration // Time the simulated backend takes
+		wantWarn  bool
+	}{
+		{"slow backend", 10 * time.Millisecond, 30 * time.Millisecond, true},
+		{"fast backend", time.Second, 0, false},
+		{"disabled", 0, 30 * time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				timing := TimingFromContext(r.Context())
+				timing.MarkDispatch()
+				start := time.Now()
+				time.Sleep(tt.backend)
+				timing.AddBackend(time.Since(start))
+				timing.AddTransform(time.Millisecond)
+			})
+			handler := SlowRequestLogging(next, logger, tt.threshold)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/responses", nil))
+
+			if !tt.wantWarn {
+				if logs.Len() != 0 {
+					t.Errorf("logged %s, want nothing", logs.String())
+				}
+				return
+			}
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("log entry %q: %v", logs.String(), err)
+			}
+			if entry["level"] != "WARN" || entry["msg"] != "slow request" {
+				t.Errorf("logged %s %q, want a slow request warning", entry["level"], entry["msg"])
+			}
+			if backend, _ := entry["backend_ms"].(float64); backend < float64(tt.backend.Milliseconds()) {
+				t.Errorf("backend_ms = %v, want at least %d", entry["backend_ms"], tt.backend.Milliseconds())
+			}
+			if entry["transform_ms"] != 1.0 || entry["path"] != "/v1/responses" {
+				t.Errorf("transform_ms = %v, path = %v; want 1 and /v1/responses", entry["transform_ms"], entry["path"])
+			}
+		})
+	}
+}
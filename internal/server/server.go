@@ -4,51 +4,88 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/ids"
 	"github.com/plasmadev/codex-api-router/internal/server/handlers"
 	"github.com/plasmadev/codex-api-router/internal/server/middleware"
 )
 
+// Version is reported in startup logs and by GET /v1/capabilities
+var Version = "0.1.0"
+
 // Server represents the HTTP server
 type Server struct {
 	cfg        *config.Config
 	httpServer *http.Server
 	listener   net.Listener
 	logger     *slog.Logger
+	logBuffer  *handlers.LogBuffer // nil unless admin is enabled
+	proxy      *handlers.ProxyHandler
+	cancel     context.CancelFunc // Cancels background work such as health checks
 	shutdown   atomic.Bool
 	wg         sync.WaitGroup
+
+	// Listeners served alongside the API, keyed by address: server.admin_addr
+	// and metrics.listen_addr
+	auxMuxes   map[string]*http.ServeMux
+	auxServers []*http.Server
 }
 
 // New creates a new server instance
 func New(cfg *config.Config) *Server {
-	logger := newLogger(cfg.Logging)
+	var logBuffer *handlers.LogBuffer
+	if cfg.Admin.Enabled {
+		logBuffer = handlers.NewLogBuffer(cfg.Admin.LogBufferSize)
+	}
+
+	logger := newLogger(cfg.Logging, logBuffer)
 	return &Server{
-		cfg:    cfg,
-		logger: logger,
+		cfg:       cfg,
+		logger:    logger,
+		logBuffer: logBuffer,
 	}
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	s.logger.Info("starting codex-api-router",
-		"version", "0.1.0",
+		"version", Version,
 		"host", s.cfg.Server.Host,
 		"port", s.cfg.Server.Port,
 		"backend", s.cfg.Zai.BaseURL,
 		"translator_mode", s.cfg.Translator.Mode,
 	)
 
-	handler := s.createHandler()
+	if s.cfg.Debug.SyntheticLatency {
+		s.logger.Warn("debug.synthetic_latency is enabled: clients can delay their requests with X-Codex-Delay; do not use in production",
+			"max_synthetic_latency", s.cfg.Debug.MaxSyntheticLatency)
+	}
+
+	if err := s.selfTest(); err != nil {
+		return err
+	}
+
+	handler, err := s.createHandler()
+	if err != nil {
+		return err
+	}
+
+	// Background work runs until Shutdown cancels it
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.proxy.StartHealthChecks(ctx)
 
 	s.httpServer = &http.Server{
 		Addr:              fmt.Sprintf("%s:%d", s.cfg.Server.Host, s.cfg.Server.Port),
@@ -65,13 +102,22 @@ func (s *Server) Start() error {
 		}
 	}
 
-	var err error
-	s.listener, err = net.Listen("tcp", s.httpServer.Addr)
+	if err := s.startAuxServers(); err != nil {
+		return err
+	}
+
+	s.listener, err = s.listen(s.httpServer.Addr)
 	if err != nil {
+		for _, srv := range s.auxServers {
+			srv.Close()
+		}
 		return fmt.Errorf("failed to create listener: %w", err)
 	}
 
-	s.logger.Info("server listening", "addr", s.listener.Addr().String())
+	s.logger.Info("server listening",
+		"addr", s.listener.Addr().String(),
+		"tcp_keepalive", s.cfg.Server.TCPKeepAlive,
+	)
 
 	s.wg.Add(1)
 	go func() {
@@ -90,11 +136,102 @@ func (s *Server) Start() error {
 	return s.waitForShutdown()
 }
 
+// listen opens the server's TCP listener on addr. Keep-alive probes stop NAT
+// devices from dropping idle SSE connections.
+func (s *Server) listen(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{
+		KeepAlive: s.cfg.Server.TCPKeepAlive,
+	}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// auxMux returns the mux for the auxiliary listener on addr, creating it on
+// first use
+func (s *Server) auxMux(addr string) *http.ServeMux {
+	if s.auxMuxes == nil {
+		s.auxMuxes = make(map[string]*http.ServeMux)
+	}
+	mux, ok := s.auxMuxes[addr]
+	if !ok {
+		mux = http.NewServeMux()
+		s.auxMuxes[addr] = mux
+	}
+	return mux
+}
+
+// startAuxServers serves the auxiliary listeners registered by createHandler.
+// If one fails to listen, those already started are closed.
+func (s *Server) startAuxServers() error {
+	addrs := make([]string, 0, len(s.auxMuxes))
+	for addr := range s.auxMuxes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           middleware.Recovery(s.auxMuxes[addr], s.logger, s.cfg.Server.ErrorFormat),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		listener, err := s.listen(addr)
+		if err != nil {
+			for _, started := range s.auxServers {
+				started.Close()
+			}
+			return fmt.Errorf("failed to create listener on %s: %w", addr, err)
+		}
+		s.logger.Info("admin listener started", "addr", listener.Addr().String())
+		s.auxServers = append(s.auxServers, srv)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := srv.Serve(listener); err != nil && !s.shutdown.Load() {
+				s.logger.Error("admin listener error", "addr", addr, "error", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// selfTest runs the transform pipeline self-test per server.self_test. Only
+// strict mode turns a failing fixture into a startup error.
+func (s *Server) selfTest() error {
+	mode := s.cfg.Server.SelfTest
+	if mode == "" || mode == config.SelfTestOff {
+		return nil
+	}
+
+	failed := 0
+	for _, result := range handlers.SelfTest(s.cfg, s.logger) {
+		if result.Error != "" {
+			failed++
+			s.logger.Error("self-test failed", "fixture", result.Name, "error", result.Error)
+		}
+	}
+	if failed == 0 {
+		s.logger.Info("self-test passed")
+		return nil
+	}
+
+	if mode == config.SelfTestStrict {
+		return fmt.Errorf("self-test failed: %d fixture(s) failed", failed)
+	}
+	return nil
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("shutting down server")
 	s.shutdown.Store(true)
 
+	// Abort health checks in flight rather than wait on a dead backend
+	if s.cancel != nil {
+		s.cancel()
+	}
+
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		s.logger.Error("failed to shutdown http server", "error", err)
 		return err
@@ -104,6 +241,19 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		s.listener.Close()
 	}
 
+	for _, srv := range s.auxServers {
+		if err := srv.Shutdown(ctx); err != nil {
+			s.logger.Error("failed to shutdown admin listener", "addr", srv.Addr, "error", err)
+		}
+	}
+
+	// Providers are force-closed if they outlast the shutdown deadline
+	if s.proxy != nil {
+		if err := s.proxy.Shutdown(ctx); err != nil {
+			s.logger.Error("failed to shutdown providers", "error", err)
+		}
+	}
+
 	done := make(chan struct{})
 	go func() {
 		s.wg.Wait()
@@ -120,32 +270,76 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 }
 
-func (s *Server) createHandler() http.Handler {
+func (s *Server) createHandler() (http.Handler, error) {
 	mux := http.NewServeMux()
 
 	proxyHandler := handlers.NewProxyHandler(s.cfg, s.logger)
+	s.proxy = proxyHandler
+	if err := proxyHandler.InitError(); err != nil {
+		proxyHandler.Shutdown(context.Background())
+		return nil, err
+	}
+	proxyHandler.Events().Subscribe(handlers.MetricsCollector)
+
+	// API routes require a client key when codex.api_keys is set
+	auth := func(h http.Handler) http.Handler {
+		return middleware.ClientAuth(h, s.logger, s.cfg.Codex.APIKeyHeader, s.cfg.Codex.APIKeys, s.cfg.Server.ErrorFormat)
+	}
+	api := auth(proxyHandler)
 
-	mux.HandleFunc("/v1/responses", proxyHandler.ServeHTTP)
-	mux.HandleFunc("/v1/responses/", proxyHandler.ServeHTTP)
-	mux.HandleFunc("/responses", proxyHandler.ServeHTTP)
-	mux.HandleFunc("/responses/", proxyHandler.ServeHTTP)
+	mux.Handle("/v1/responses", api)
+	mux.Handle("/v1/responses/", api)
+	mux.Handle("/responses", api)
+	mux.Handle("/responses/", api)
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// Same API, but streams are emitted as Anthropic Messages events
+	mux.Handle("/anthropic/v1/responses", api)
+
+	if s.cfg.Batch.Enabled {
+		batchHandler := handlers.NewBatchHandler(s.cfg, s.logger, proxyHandler)
+		mux.Handle("/v1/responses/batch", auth(batchHandler))
+	}
+
+	mux.HandleFunc("/v1/capabilities", handlers.CapabilitiesHandler(s.cfg, Version))
+
+	// Operational endpoints move to server.admin_addr when set, and metrics
+	// to metrics.listen_addr, so they can be firewalled off
+	adminMux := mux
+	if s.cfg.Server.AdminAddr != "" {
+		adminMux = s.auxMux(s.cfg.Server.AdminAddr)
+	}
+	metricsMux := adminMux
+	if s.cfg.Metrics.ListenAddr != "" {
+		metricsMux = s.auxMux(s.cfg.Metrics.ListenAddr)
+	}
+
+	adminMux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 
 	if s.cfg.Metrics.Enabled {
-		mux.HandleFunc("/metrics", handlers.MetricsHandler(s.logger))
+		auth := s.cfg.Metrics.Auth
+		metrics := middleware.ScrapeAuth(handlers.MetricsHandler(s.logger), s.logger, auth.BearerToken, auth.Username, auth.Password)
+		metricsMux.Handle("/metrics", metrics)
 	}
 
+	if s.cfg.Admin.Enabled {
+		adminMux.HandleFunc("/admin/logs", handlers.AdminLogsHandler(s.logBuffer, s.cfg.Admin.Token, s.logger))
+		adminMux.HandleFunc("/admin/store/flush", handlers.AdminStoreFlushHandler(proxyHandler, s.cfg.Admin.Token, s.logger))
+	}
+
+	// Recovery goes outermost so a panic in any middleware is caught too
 	var handler http.Handler = mux
-	handler = middleware.Recovery(handler, s.logger)
-	handler = middleware.RequestLogging(handler, s.logger)
+	handler = middleware.ConnectionLimit(handler, s.logger, s.cfg.Server.MaxConnectionsPerIP, s.cfg.Server.ErrorFormat)
+	handler = middleware.SlowRequestLogging(handler, s.logger, s.cfg.Logging.SlowRequestThreshold)
+	handler = middleware.RequestLogging(handler, s.logger, append([]string{s.cfg.Codex.APIKeyHeader}, s.cfg.Logging.RedactHeaders...))
+	handler = middleware.RequestID(handler, s.logger, ids.Default)
 	handler = middleware.CORS(handler)
+	handler = middleware.Recovery(handler, s.logger, s.cfg.Server.ErrorFormat)
 
-	return handler
+	return handler, nil
 }
 
 func (s *Server) waitForShutdown() error {
@@ -161,28 +355,30 @@ func (s *Server) waitForShutdown() error {
 	return s.Shutdown(ctx)
 }
 
-func newLogger(cfg config.LoggingConfig) *slog.Logger {
+// newLogger creates the server logger. When buf is non-nil, log lines are
+// also kept in it for the admin log stream.
+func newLogger(cfg config.LoggingConfig, buf *handlers.LogBuffer) *slog.Logger {
 	opts := &slog.HandlerOptions{
 		Level: parseLogLevel(cfg.Level),
 	}
 
-	var handler slog.Handler
-	if cfg.Format == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
-	}
-
+	var out io.Writer = os.Stdout
 	if cfg.File != "" {
 		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err == nil {
-			if cfg.Format == "json" {
-				handler = slog.NewJSONHandler(file, opts)
-			} else {
-				handler = slog.NewTextHandler(file, opts)
-			}
+			out = file
 		}
 	}
+	if buf != nil {
+		out = io.MultiWriter(out, buf)
+	}
+
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
 
 	return slog.New(handler)
 }
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// testServer returns a server for cfg with its handlers created
+func testServer(t *testing.T, modify func(*config.Config)) (*Server, http.Handler) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Zai.APIKey = "test-key"
+	modify(cfg)
+	s := New(cfg)
+	handler, err := s.createHandler()
+	if err != nil {
+		t.Fatalf("createHandler() error = %v", err)
+	}
+	t.Cleanup(func() { s.proxy.Shutdown(context.Background()) })
+	return s, handler
+}
+
+// get returns the status of a GET for path on handler
+func get(handler http.Handler, path, authorization string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		auth  string
+		want  int
+	}{
+		{"open", "", "", http.StatusOK},
+		{"auth without credentials", "t1", "", http.StatusUnauthorized},
+		{"auth with the token", "t1", "Bearer t1", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := testServer(t, func(cfg *config.Config) { cfg.Metrics.Auth.BearerToken = tt.token })
+			if got := get(handler, "/metrics", tt.auth); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminListeners(t *testing.T) {
+	tests := []struct {
+		name        string
+		adminAddr   string
+		metricsAddr string
+		health      string // Listener serving /health; "" is the main one
+		metrics     string // Listener serving /metrics
+	}{
+		{"main listener only", "", "", "", ""},
+		{"admin listener", "127.0.0.1:9091", "", "127.0.0.1:9091", "127.0.0.1:9091"},
+		{"metrics listener", "", "127.0.0.1:9092", "", "127.0.0.1:9092"},
+		{"admin and metrics listeners", "127.0.0.1:9091", "127.0.0.1:9092", "127.0.0.1:9091", "127.0.0.1:9092"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, main := testServer(t, func(cfg *config.Config) {
+				cfg.Server.AdminAddr = tt.adminAddr
+				cfg.Metrics.ListenAddr = tt.metricsAddr
+			})
+
+			listeners := map[string]http.Handler{"": main}
+			for addr, mux := range s.auxMuxes {
+				listeners[addr] = mux
+			}
+
+			for path, servedOn := range map[string]string{"/health": tt.health, "/metrics": tt.metrics} {
+				for addr, handler := range listeners {
+					want := http.StatusNotFound
+					if addr == servedOn {
+						want = http.StatusOK
+					}
+					if got := get(handler, path, ""); got != want {
+						t.Errorf("%s on listener %q: status = %d, want %d", path, addr, got, want)
+					}
+				}
+			}
+
+			if got := get(main, "/v1/capabilities", ""); got != http.StatusOK {
+				t.Errorf("/v1/capabilities on the main listener: status = %d", got)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/plasmadev/codex-api-router/internal/config"
+	"github.com/plasmadev/codex-api-router/internal/ids"
 	"github.com/plasmadev/codex-api-router/internal/server/handlers"
 	"github.com/plasmadev/codex-api-router/internal/server/middleware"
 )
@@ -62,9 +63,10 @@ func Start(cfg *Config) error {
 
 	// Apply middleware
 	var h http.Handler = mux
-	h = middleware.Recovery(h, logger)
-	h = middleware.RequestLogging(h, logger)
+	h = middleware.RequestLogging(h, logger, nil)
+	h = middleware.RequestID(h, logger, ids.Default)
 	h = middleware.CORS(h)
+	h = middleware.Recovery(h, logger, config.ErrorFormatOpenAI)
 
 	// Create server
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
@@ -0,0 +1,185 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// persistDelay is how long the file store waits after a change before
+// writing the file, so a burst of requests costs one write
+const persistDelay = time.Second
+
+// FileStore is a ConversationStore that keeps conversations in memory and
+// writes them to a JSON file shortly after they change, so they survive
+// restarts. Writes happen in the background rather than on the request path;
+// Close writes any still pending. Expired conversations are dropped when the
+// file is loaded.
+type FileStore struct {
+	*MemoryStore
+	path  string
+	delay time.Duration
+
+	dirty   atomic.Bool   // Changes not yet written
+	wake    chan struct{} // Signals the writer that the store changed
+	done    chan struct{} // Closed by Close to stop the writer
+	stopped chan struct{} // Closed when the writer has stopped
+	close   sync.Once
+
+	writeMu sync.Mutex // Serializes writes of the file
+	errMu   sync.Mutex
+	err     error // Result of the last write
+}
+
+// NewFileStore opens the store at path, loading any conversations saved there
+func NewFileStore(path string, ttl time.Duration, max int) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("session store path is required for the file store")
+	}
+
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(ttl, max),
+		path:        path,
+		delay:       persistDelay,
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read session store: %w", err)
+	}
+	if err == nil {
+		var conversations []*Conversation
+		if err := json.Unmarshal(data, &conversations); err != nil {
+			return nil, fmt.Errorf("failed to parse session store: %w", err)
+		}
+		for _, conv := range conversations {
+			s.conversations[conv.ID] = conv
+		}
+		s.prune(time.Now())
+	}
+
+	go s.writeLoop()
+	return s, nil
+}
+
+// Save stores a conversation and schedules a write of the store. The error
+// is that of the last write, so a store that can't be written is reported.
+func (s *FileStore) Save(conv *Conversation) error {
+	s.MemoryStore.Save(conv)
+	s.changed()
+	return s.writeErr()
+}
+
+// Delete removes a conversation and schedules a write of the store
+func (s *FileStore) Delete(id string) error {
+	s.MemoryStore.Delete(id)
+	s.changed()
+	return s.writeErr()
+}
+
+// Clear removes every conversation and writes the empty store right away
+func (s *FileStore) Clear() (int, error) {
+	n, _ := s.MemoryStore.Clear()
+	s.dirty.Store(true)
+	return n, s.Flush()
+}
+
+// Flush writes pending changes to the file now
+func (s *FileStore) Flush() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if !s.dirty.Swap(false) {
+		return s.writeErr()
+	}
+
+	err := s.persist()
+	if err != nil {
+		s.dirty.Store(true) // Retried on the next change or flush
+	}
+	s.errMu.Lock()
+	s.err = err
+	s.errMu.Unlock()
+	return err
+}
+
+// Close stops the background writer and writes any pending changes
+func (s *FileStore) Close() error {
+	s.close.Do(func() { close(s.done) })
+	<-s.stopped
+	return s.Flush()
+}
+
+// changed marks the store dirty and wakes the writer
+func (s *FileStore) changed() {
+	s.dirty.Store(true)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// writeErr returns the result of the last write
+func (s *FileStore) writeErr() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.err
+}
+
+// writeLoop writes the store delay after it changes, until Close
+func (s *FileStore) writeLoop() {
+	defer close(s.stopped)
+
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		timer := time.NewTimer(s.delay)
+		select {
+		case <-timer.C:
+		case <-s.done:
+			timer.Stop()
+			return
+		}
+		s.Flush()
+	}
+}
+
+// persist writes all conversations to a temporary file and renames it over
+// the store so a crash never leaves a partial file. Callers must hold writeMu.
+func (s *FileStore) persist() error {
+	s.mu.RLock()
+	conversations := make([]*Conversation, 0, len(s.conversations))
+	for _, conv := range s.conversations {
+		conversations = append(conversations, conv)
+	}
+	data, err := json.Marshal(conversations)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal session store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create session store directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace session store: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process ConversationStore. Conversations are lost on restart.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	conversations map[string]*Conversation
+	ttl           time.Duration // 0 disables expiry
+	max           int           // 0 disables the limit
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore(ttl time.Duration, max int) *MemoryStore {
+	return &MemoryStore{
+		conversations: make(map[string]*Conversation),
+		ttl:           ttl,
+		max:           max,
+	}
+}
+
+// Get returns the conversation for a response ID, if present and unexpired
+func (s *MemoryStore) Get(id string) (*Conversation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conv, ok := s.conversations[id]
+	if !ok || s.expired(conv, time.Now()) {
+		return nil, false
+	}
+	return conv, true
+}
+
+// Save stores a conversation, evicting expired and then the oldest conversations when full
+func (s *MemoryStore) Save(conv *Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(conv)
+	return nil
+}
+
+// Delete removes a conversation
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conversations, id)
+	return nil
+}
+
+// Clear removes every conversation
+func (s *MemoryStore) Clear() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.conversations)
+	s.conversations = make(map[string]*Conversation)
+	return n, nil
+}
+
+// Len returns the number of stored conversations, including any not yet pruned
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.conversations)
+}
+
+// Close does nothing; the memory store has nothing to persist
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// put stores a conversation and enforces limits. Callers must hold the write lock.
+func (s *MemoryStore) put(conv *Conversation) {
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = time.Now()
+	}
+	s.conversations[conv.ID] = conv
+	s.prune(time.Now())
+}
+
+// prune drops expired conversations, then the oldest ones beyond the limit.
+// Callers must hold the write lock.
+func (s *MemoryStore) prune(now time.Time) {
+	for id, conv := range s.conversations {
+		if s.expired(conv, now) {
+			delete(s.conversations, id)
+		}
+	}
+
+	if s.max <= 0 || len(s.conversations) <= s.max {
+		return
+	}
+
+	ordered := make([]*Conversation, 0, len(s.conversations))
+	for _, conv := range s.conversations {
+		ordered = append(ordered, conv)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+	})
+	for _, conv := range ordered[:len(ordered)-s.max] {
+		delete(s.conversations, conv.ID)
+	}
+}
+
+func (s *MemoryStore) expired(conv *Conversation, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(conv.CreatedAt) > s.ttl
+}
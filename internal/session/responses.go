@@ -0,0 +1,256 @@
+package session
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Handling of responses over the size limit, for session.oversized_responses
+const (
+	OversizedSkip     = "skip"     // Not stored; GET reports it was too large
+	OversizedTruncate = "truncate" // Output text is cut until the response fits
+)
+
+// StoredResponse is a Responses API object kept for GET /v1/responses/{id}
+type StoredResponse struct {
+	ID          string
+	RequestID   string // X-Request-Id of the request that produced it
+	RequestHash string // Hash of the request body, when saved with SaveForRequest
+	Body        map[string]interface{}
+	CreatedAt   time.Time
+	TooLarge    bool // Body was dropped for exceeding the size limit
+}
+
+// ResponseStore keeps Responses API objects in memory by response ID, with
+// the same TTL and size limits as conversations. It is safe for concurrent use.
+type ResponseStore struct {
+	mu        sync.RWMutex
+	responses map[string]*StoredResponse
+	ttl       time.Duration // 0 disables expiry
+	max       int           // 0 disables the limit
+	maxBytes  int           // 0 disables the size limit
+	truncate  bool          // Truncate oversized responses rather than skip them
+}
+
+// NewResponseStore creates an empty response store
+func NewResponseStore(ttl time.Duration, max int) *ResponseStore {
+	return &ResponseStore{
+		responses: make(map[string]*StoredResponse),
+		ttl:       ttl,
+		max:       max,
+	}
+}
+
+// LimitSize caps the JSON size of stored responses at maxBytes. Larger ones
+// are truncated (OversizedTruncate) or not stored (OversizedSkip). A maxBytes
+// of 0 disables the limit.
+func (s *ResponseStore) LimitSize(maxBytes int, oversized string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxBytes = maxBytes
+	s.truncate = oversized == OversizedTruncate
+}
+
+// Get returns the stored response, if present and unexpired
+func (s *ResponseStore) Get(id string) (StoredResponse, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.responses[id]
+	if !ok || stored.TooLarge || s.expired(stored, time.Now()) {
+		return StoredResponse{}, false
+	}
+	return *stored, true
+}
+
+// TooLarge reports whether a response was not stored for exceeding the size limit
+func (s *ResponseStore) TooLarge(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.responses[id]
+	return ok && stored.TooLarge && !s.expired(stored, time.Now())
+}
+
+// Save stores or replaces a response produced by the request with requestID.
+// A replaced response keeps its original creation time so status updates
+// don't extend its TTL. A response over the size limit is truncated or
+// recorded as too large, per LimitSize.
+func (s *ResponseStore) Save(id, requestID string, body map[string]interface{}) {
+	s.SaveForRequest(id, requestID, "", body)
+}
+
+// SaveForRequest is Save, also recording a hash of the request body so a
+// later request stored under the same id can be told apart from a retry
+func (s *ResponseStore) SaveForRequest(id, requestID, requestHash string, body map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	createdAt := now
+	if existing, ok := s.responses[id]; ok {
+		createdAt = existing.CreatedAt
+	}
+
+	stored := &StoredResponse{ID: id, RequestID: requestID, RequestHash: requestHash, Body: body, CreatedAt: createdAt}
+	if s.maxBytes > 0 {
+		if fitted, ok := fitResponse(body, s.maxBytes, s.truncate); ok {
+			stored.Body = fitted
+		} else {
+			stored.Body, stored.TooLarge = nil, true
+		}
+	}
+	s.responses[id] = stored
+	s.prune(now)
+}
+
+// Delete removes a response, reporting whether it was stored
+func (s *ResponseStore) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.responses[id]
+	delete(s.responses, id)
+	return ok
+}
+
+// Clear removes every response, returning how many were stored
+func (s *ResponseStore) Clear() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.responses)
+	s.responses = make(map[string]*StoredResponse)
+	return n
+}
+
+// prune drops expired responses, then the oldest ones beyond the limit.
+// Callers must hold the write lock.
+func (s *ResponseStore) prune(now time.Time) {
+	for id, stored := range s.responses {
+		if s.expired(stored, now) {
+			delete(s.responses, id)
+		}
+	}
+
+	if s.max <= 0 || len(s.responses) <= s.max {
+		return
+	}
+
+	ordered := make([]*StoredResponse, 0, len(s.responses))
+	for _, stored := range s.responses {
+		ordered = append(ordered, stored)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].CreatedAt.Before(ordered[j].CreatedAt)
+	})
+	for _, stored := range ordered[:len(ordered)-s.max] {
+		delete(s.responses, stored.ID)
+	}
+}
+
+func (s *ResponseStore) expired(stored *StoredResponse, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(stored.CreatedAt) > s.ttl
+}
+
+// fitResponse returns body if its JSON encoding is within maxBytes. Otherwise,
+// when truncate is set, it returns a copy with output text and tool-call
+// arguments cut from the end until it fits, marked x_storage_truncated. It
+// reports false when the response cannot be made to fit.
+func fitResponse(body map[string]interface{}, maxBytes int, truncate bool) (map[string]interface{}, bool) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, false
+	}
+	if len(data) <= maxBytes {
+		return body, true
+	}
+	if !truncate {
+		return nil, false
+	}
+
+	// Work on a copy so the caller's response is left intact
+	var fitted map[string]interface{}
+	if err := json.Unmarshal(data, &fitted); err != nil {
+		return nil, false
+	}
+	fitted["x_storage_truncated"] = true
+
+	size := func() int {
+		data, _ := json.Marshal(fitted)
+		return len(data)
+	}
+
+	// Working back from the last text, keep the longest prefix of each that
+	// lets the response fit, or drop it entirely and move on
+	texts := outputTexts(fitted)
+	for i := len(texts) - 1; i >= 0 && size() > maxBytes; i-- {
+		text := texts[i].get()
+		lo, hi := 0, len(text)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			texts[i].set(runePrefix(text, mid))
+			if size() <= maxBytes {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		texts[i].set(runePrefix(text, lo))
+	}
+
+	return fitted, size() <= maxBytes
+}
+
+// textField is a string field of a decoded response that can be truncated
+type textField struct {
+	get func() string
+	set func(string)
+}
+
+// outputTexts returns the output text parts and tool-call arguments of a
+// decoded response, in output order
+func outputTexts(body map[string]interface{}) []textField {
+	field := func(m map[string]interface{}, key string) textField {
+		return textField{
+			get: func() string { s, _ := m[key].(string); return s },
+			set: func(s string) { m[key] = s },
+		}
+	}
+
+	var texts []textField
+	output, _ := body["output"].([]interface{})
+	for _, item := range output {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := itemMap["arguments"].(string); ok {
+			texts = append(texts, field(itemMap, "arguments"))
+		}
+		content, _ := itemMap["content"].([]interface{})
+		for _, part := range content {
+			if partMap, ok := part.(map[string]interface{}); ok {
+				if _, ok := partMap["text"].(string); ok {
+					texts = append(texts, field(partMap, "text"))
+				}
+			}
+		}
+	}
+	return texts
+}
+
+// runePrefix returns the longest prefix of s of at most n bytes that ends at a rune boundary
+func runePrefix(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
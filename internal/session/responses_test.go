@@ -0,0 +1,139 @@
+package session
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// textResponse is a stored response whose single message carries text
+func textResponse(id, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"object": "response",
+		"status": "completed",
+		"output": []interface{}{
+			map[string]interface{}{
+				"type": "message",
+				"content": []interface{}{
+					map[string]interface{}{"type": "output_text", "text": text},
+				},
+			},
+		},
+	}
+}
+
+// responseText returns the text of a textResponse
+func responseText(resp map[string]interface{}) string {
+	message := resp["output"].([]interface{})[0].(map[string]interface{})
+	text, _ := message["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	return text
+}
+
+func TestResponseStoreSizeLimit(t *testing.T) {
+	small := textResponse("resp_small", "hi")
+	large := textResponse("resp_large", strings.Repeat("x", 2000))
+
+	t.Run("skip", func(t *testing.T) {
+		s := NewResponseStore(time.Hour, 10)
+		s.LimitSize(500, OversizedSkip)
+		s.Save("resp_small", "", small)
+		s.Save("resp_large", "", large)
+
+		if _, ok := s.Get("resp_small"); !ok || s.TooLarge("resp_small") {
+			t.Error("response within the limit was not stored")
+		}
+		if _, ok := s.Get("resp_large"); ok {
+			t.Error("oversized response was stored")
+		}
+		if !s.TooLarge("resp_large") {
+			t.Error("oversized response not reported as too large")
+		}
+		if s.TooLarge("resp_unknown") {
+			t.Error("unknown response reported as too large")
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		s := NewResponseStore(time.Hour, 10)
+		s.LimitSize(500, OversizedTruncate)
+		s.Save("resp_large", "", large)
+
+		got, ok := s.Get("resp_large")
+		if !ok {
+			t.Fatal("truncated response was not stored")
+		}
+		stored := got.Body
+		data, _ := json.Marshal(stored)
+		if len(data) > 500 {
+			t.Errorf("stored response is %d bytes, want at most 500", len(data))
+		}
+		if stored["x_storage_truncated"] != true {
+			t.Error("truncated response not marked x_storage_truncated")
+		}
+		text := responseText(stored)
+		if text == "" || !strings.HasPrefix(responseText(large), text) {
+			t.Errorf("stored text %q is not a prefix of the original", text)
+		}
+		if _, marked := large["x_storage_truncated"]; marked {
+			t.Error("caller's response was modified")
+		}
+	})
+
+	t.Run("truncate cannot fit", func(t *testing.T) {
+		s := NewResponseStore(time.Hour, 10)
+		s.LimitSize(20, OversizedTruncate)
+		s.Save("resp_large", "", large)
+		if !s.TooLarge("resp_large") {
+			t.Error("response that cannot fit not reported as too large")
+		}
+	})
+}
+
+func TestRunePrefix(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"hello", 3, "hel"},
+		{"hello", 10, "hello"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+	}
+	for _, tt := range tests {
+		if got := runePrefix(tt.s, tt.n); got != tt.want {
+			t.Errorf("runePrefix(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestResponseStoreClear(t *testing.T) {
+	s := NewResponseStore(time.Hour, 10)
+	s.Save("resp_1", "", textResponse("resp_1", "hi"))
+	s.Save("resp_2", "", textResponse("resp_2", "hello"))
+
+	if n := s.Clear(); n != 2 {
+		t.Errorf("Clear() = %d, want 2", n)
+	}
+	if _, ok := s.Get("resp_1"); ok {
+		t.Error("response survived Clear()")
+	}
+	if n := s.Clear(); n != 0 {
+		t.Errorf("Clear() of an empty store = %d, want 0", n)
+	}
+}
+
+func TestResponseStoreKeepsRequestID(t *testing.T) {
+	s := NewResponseStore(time.Hour, 10)
+	s.Save("resp_1", "client-123", textResponse("resp_1", "hi"))
+
+	stored, ok := s.Get("resp_1")
+	if !ok {
+		t.Fatal("response was not stored")
+	}
+	if stored.RequestID != "client-123" {
+		t.Errorf("RequestID = %q, want client-123", stored.RequestID)
+	}
+}
@@ -0,0 +1,59 @@
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+// Store backends for session.store
+const (
+	StoreMemory = "memory"
+	StoreFile   = "file"
+)
+
+// Conversation is the Chat Completions message history that produced a
+// response. It is keyed by the response ID so a follow-up request can resume
+// it via previous_response_id.
+type Conversation struct {
+	ID        string                   `json:"id"`
+	Messages  []map[string]interface{} `json:"messages"`
+	CreatedAt time.Time                `json:"created_at"`
+}
+
+// ConversationStore stores conversations for previous_response_id stitching.
+// Implementations are safe for concurrent use and enforce the configured TTL
+// and maximum number of conversations.
+type ConversationStore interface {
+	// Get returns the conversation for a response ID, if present and unexpired
+	Get(id string) (*Conversation, bool)
+
+	// Save stores a conversation, evicting the oldest ones when full
+	Save(conv *Conversation) error
+
+	// Delete removes a conversation
+	Delete(id string) error
+
+	// Clear removes every conversation, returning how many were stored
+	Clear() (int, error)
+
+	// Len returns the number of stored conversations
+	Len() int
+
+	// Close writes any changes not yet persisted. The store must not be
+	// used afterwards.
+	Close() error
+}
+
+// NewStore creates the conversation store selected by the session config
+func NewStore(cfg config.SessionConfig) (ConversationStore, error) {
+	switch cfg.Store {
+	case "", StoreMemory:
+		return NewMemoryStore(cfg.TTL, cfg.MaxConversations), nil
+	case StoreFile:
+		return NewFileStore(cfg.Path, cfg.TTL, cfg.MaxConversations)
+	default:
+		return nil, fmt.Errorf("invalid session store: %s (must be '%s' or '%s')", cfg.Store, StoreMemory, StoreFile)
+	}
+}
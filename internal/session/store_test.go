@@ -0,0 +1,233 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/plasmadev/codex-api-router/internal/config"
+)
+
+func TestMemoryStoreLimits(t *testing.T) {
+	t.Run("max conversations evicts the oldest", func(t *testing.T) {
+		s := NewMemoryStore(0, 2)
+		now := time.Now()
+		for i, id := range []string{"a", "b", "c"} {
+			s.Save(&Conversation{ID: id, CreatedAt: now.Add(time.Duration(i) * time.Second)})
+		}
+
+		if s.Len() != 2 {
+			t.Errorf("Len() = %d, want 2", s.Len())
+		}
+		if _, ok := s.Get("a"); ok {
+			t.Error("oldest conversation was not evicted")
+		}
+		for _, id := range []string{"b", "c"} {
+			if _, ok := s.Get(id); !ok {
+				t.Errorf("conversation %q missing", id)
+			}
+		}
+	})
+
+	t.Run("expired conversations are not returned", func(t *testing.T) {
+		s := NewMemoryStore(time.Minute, 0)
+		s.Save(&Conversation{ID: "old", CreatedAt: time.Now().Add(-2 * time.Minute)})
+		s.Save(&Conversation{ID: "new"})
+
+		if _, ok := s.Get("old"); ok {
+			t.Error("expired conversation returned")
+		}
+		if _, ok := s.Get("new"); !ok {
+			t.Error("fresh conversation missing")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := NewMemoryStore(0, 0)
+		s.Save(&Conversation{ID: "a"})
+		s.Delete("a")
+		if _, ok := s.Get("a"); ok {
+			t.Error("deleted conversation returned")
+		}
+	})
+}
+
+func TestFileStoreRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "store.json")
+
+	s, err := NewFileStore(path, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	messages := []map[string]interface{}{
+		{"role": "user", "content": "hi"},
+		{"role": "assistant", "content": "hello"},
+	}
+	if err := s.Save(&Conversation{ID: "resp_1", Messages: messages}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := s.Save(&Conversation{ID: "resp_expired", CreatedAt: time.Now().Add(-2 * time.Hour)}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := s.Save(&Conversation{ID: "resp_deleted"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := s.Delete("resp_deleted"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// Reopen the store as a restarted process would after shutting down
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	reopened, err := NewFileStore(path, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+
+	conv, ok := reopened.Get("resp_1")
+	if !ok {
+		t.Fatal("conversation lost across restart")
+	}
+	if len(conv.Messages) != 2 || conv.Messages[1]["content"] != "hello" {
+		t.Errorf("messages = %v, want %v", conv.Messages, messages)
+	}
+	for _, id := range []string{"resp_expired", "resp_deleted"} {
+		if _, ok := reopened.Get(id); ok {
+			t.Errorf("conversation %q survived restart", id)
+		}
+	}
+	if reopened.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", reopened.Len())
+	}
+}
+
+func TestFileStoreWritesInBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	t.Run("not on the request path", func(t *testing.T) {
+		s, err := NewFileStore(path, time.Hour, 10)
+		if err != nil {
+			t.Fatalf("NewFileStore() error = %v", err)
+		}
+		s.delay = time.Hour
+		for _, id := range []string{"resp_1", "resp_2", "resp_3"} {
+			if err := s.Save(&Conversation{ID: id}); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("store written by Save(), want the write deferred (stat error %v)", err)
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		reopened, err := NewFileStore(path, time.Hour, 10)
+		if err != nil {
+			t.Fatalf("reopen error = %v", err)
+		}
+		if reopened.Len() != 3 {
+			t.Errorf("Len() after Close() and reopen = %d, want 3", reopened.Len())
+		}
+		reopened.Close()
+	})
+
+	t.Run("after the delay", func(t *testing.T) {
+		os.Remove(path)
+		s, err := NewFileStore(path, time.Hour, 10)
+		if err != nil {
+			t.Fatalf("NewFileStore() error = %v", err)
+		}
+		defer s.Close()
+		s.delay = 10 * time.Millisecond
+		if err := s.Save(&Conversation{ID: "resp_1"}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("store not written after the delay")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
+
+func TestNewStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.SessionConfig
+		want    interface{}
+		wantErr bool
+	}{
+		{"default", config.SessionConfig{}, &MemoryStore{}, false},
+		{"memory", config.SessionConfig{Store: StoreMemory}, &MemoryStore{}, false},
+		{"file", config.SessionConfig{Store: StoreFile, Path: filepath.Join(t.TempDir(), "store.json")}, &FileStore{}, false},
+		{"file without path", config.SessionConfig{Store: StoreFile}, nil, true},
+		{"unknown", config.SessionConfig{Store: "redis"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			switch tt.want.(type) {
+			case *MemoryStore:
+				if _, ok := store.(*MemoryStore); !ok {
+					t.Errorf("NewStore() = %T, want *MemoryStore", store)
+				}
+			case *FileStore:
+				if _, ok := store.(*FileStore); !ok {
+					t.Errorf("NewStore() = %T, want *FileStore", store)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreClear(t *testing.T) {
+	fileStore, err := NewFileStore(filepath.Join(t.TempDir(), "store.json"), time.Hour, 10)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	for name, s := range map[string]ConversationStore{"memory": NewMemoryStore(0, 0), "file": fileStore} {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []string{"resp_1", "resp_2"} {
+				if err := s.Save(&Conversation{ID: id}); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			}
+
+			n, err := s.Clear()
+			if err != nil || n != 2 {
+				t.Errorf("Clear() = %d, %v; want 2, nil", n, err)
+			}
+			for _, id := range []string{"resp_1", "resp_2"} {
+				if _, ok := s.Get(id); ok {
+					t.Errorf("conversation %q survived Clear()", id)
+				}
+			}
+		})
+	}
+
+	// The cleared file store stays empty after a restart
+	reopened, err := NewFileStore(fileStore.path, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	if _, ok := reopened.Get("resp_1"); ok {
+		t.Error("cleared conversation restored from disk")
+	}
+}
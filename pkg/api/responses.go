@@ -19,6 +19,9 @@ type ResponseRequest struct {
 	Conversation      *Conversation   `json:"conversation,omitempty"`
 	Include           []string        `json:"include,omitempty"`
 	Metadata          map[string]any  `json:"metadata,omitempty"`
+	Truncation        string          `json:"truncation,omitempty"` // auto | disabled
+	Background        bool            `json:"background,omitempty"` // Return queued immediately and poll via GET
+	LogitBias         map[string]float64 `json:"logit_bias,omitempty"` // Token ID -> bias in [-100, 100]
 }
 
 // InputItem represents an item in the input array